@@ -0,0 +1,53 @@
+// Command mockoidc runs the test OpenID Connect provider for local development. Every sign-in is approved at
+// once for the user given on the command line.
+//
+//	go run ./cmd/mockoidc -email a@example.com -groups qp-admins
+//	export OIDC_ISSUER=http://127.0.0.1:9000 OIDC_CLIENT_ID=qp1 OIDC_ROLE_MAPPING=qp-admins=admin
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"qp1/utils/oidctest"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:9000", "address to listen on")
+	clientID := flag.String("client-id", "qp1", "client ID the backend uses")
+	sub := flag.String("sub", "", "subject claim, defaults to the email")
+	email := flag.String("email", "dev@example.com", "email claim")
+	name := flag.String("name", "Dev User", "name claim")
+	verified := flag.Bool("email-verified", true, "email_verified claim")
+	groups := flag.String("groups", "", "comma-separated groups claim")
+	mfa := flag.Bool("mfa", false, "report mfa in the amr claim")
+	flag.Parse()
+
+	provider, err := oidctest.Listen(*clientID, *addr)
+	if err != nil {
+		fmt.Println("Failed to start mock provider:", err)
+		os.Exit(1)
+	}
+	defer provider.Close()
+
+	claims := jwt.MapClaims{"sub": *sub, "email": *email, "email_verified": *verified, "name": *name}
+	if *sub == "" {
+		claims["sub"] = *email
+	}
+	if *groups != "" {
+		claims["groups"] = strings.Split(*groups, ",")
+	}
+	if *mfa {
+		claims["amr"] = []string{"pwd", "mfa"}
+	}
+	provider.SetClaims(claims)
+
+	fmt.Printf("Mock OIDC provider for client %q running at %s, signing in as %s\n", *clientID, provider.Issuer(), *email)
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt)
+	<-stop
+}
@@ -5,26 +5,34 @@ import (
 	"qp1/models"
 
 	"github.com/gofiber/fiber/v2"
-	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
-// AdminUserList 仅管理员可访问，返回所有用户信息
+var userListSpec = listSpec{
+	Fields: map[string]listField{
+		"id":           {Column: "id", Kind: fieldNumber, Sort: true, Filter: true},
+		"name":         {Column: "name", Kind: fieldString, Sort: true, Filter: true},
+		"email":        {Column: "email", Kind: fieldString, Sort: true, Filter: true},
+		"role":         {Column: "role", Kind: fieldString, Sort: true, Filter: true},
+		"status":       {Column: "status", Kind: fieldString, Sort: true, Filter: true},
+		"totp_enabled": {Column: "totp_enabled", Kind: fieldBool, Filter: true},
+	},
+	DefaultSort: "id",
+}
+
+// AdminUserList 仅管理员可访问，返回用户列表（支持排序、过滤、分页）
 func AdminUserList(c *fiber.Ctx) error {
-	var users []models.User
-	if err := database.DB.Find(&users).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to get user list"})
+	lq, err := parseListQuery(c, userListSpec)
+	if err != nil {
+		return respondListError(c, err)
 	}
-	// 不返回密码字段
-	var result []map[string]interface{}
-	for _, u := range users {
-		result = append(result, map[string]interface{}{
-			"id":    u.ID,
-			"name":  u.Name,
-			"email": u.Email,
-			"role":  u.Role,
-		})
+	// 密码等敏感字段不会序列化
+	users := []models.User{}
+	p, err := lq.find(database.DB.Model(&models.User{}), &users)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to get user list"})
 	}
-	return c.JSON(result)
+	return respondList(c, users, p)
 }
 
 // AdminResetPassword 管理员通过email重置任意用户密码
@@ -42,13 +50,21 @@ func AdminResetPassword(c *fiber.Ctx) error {
 	if err := database.DB.Where("email = ?", email).First(&user).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
 	}
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to encrypt password"})
+	if !canManageUser(c, user) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Only admins can manage admin accounts"})
 	}
-	user.Password = hashedPassword
-	if err := database.DB.Save(&user).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to reset password"})
+	// Sign the user out everywhere and revoke their API tokens, whoever knew the old password
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := setUserPassword(tx, &user, newPassword); err != nil {
+			return err
+		}
+		if err := revokeUserSessions(tx, user.ID, 0); err != nil {
+			return err
+		}
+		return revokeUserAPITokens(tx, user.ID)
+	})
+	if err != nil {
+		return respondPasswordError(c, err)
 	}
 	return c.JSON(fiber.Map{"message": "Password reset successfully"})
 }
@@ -63,9 +79,16 @@ func AdminDeleteUser(c *fiber.Ctx) error {
 	if !ok {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "User ID is required and must be a number"})
 	}
-	id := uint(idFloat)
-	if err := database.DB.Delete(&models.User{}, id).Error; err != nil {
+	var user models.User
+	if err := database.DB.First(&user, uint(idFloat)).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	}
+	if !canManageUser(c, user) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Only admins can manage admin accounts"})
+	}
+	if err := database.DB.Delete(&user).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete user"})
 	}
+	revokeUserSessions(database.DB, user.ID, 0)
 	return c.JSON(fiber.Map{"message": "User deleted successfully"})
 }
@@ -1,11 +1,13 @@
 package controllers
 
 import (
+	"errors"
 	"qp1/database"
 	"qp1/models"
-	"strconv"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
 // CreateMaterial 新增物料
@@ -20,50 +22,61 @@ func CreateMaterial(c *fiber.Ctx) error {
 	if data.UnitCost < 0 || data.StockQty < 0 {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Unit cost and stock quantity must be non-negative"})
 	}
+	data.SKU = normalizeSKU(data.SKU)
+	if data.PricingPolicy == "" {
+		data.PricingPolicy = models.PricingPolicyPreferred
+	}
+	if msg := validateMaterialPurchasing(&data); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": msg})
+	}
+	units, err := loadUnits(database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create material"})
+	}
+	if err := units.checkMaterialUnit(data.Unit); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Unit: " + err.Error()})
+	}
 	if err := database.DB.Create(&data).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create material"})
 	}
 	return c.JSON(data)
 }
 
-// ListMaterials 查询所有物料
-func ListMaterials(c *fiber.Ctx) error {
-	var materials []models.Material
-	if err := database.DB.Find(&materials).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch materials"})
-	}
-	return c.JSON(materials)
+var materialListSpec = listSpec{
+	Fields: map[string]listField{
+		"id":             {Column: "id", Kind: fieldNumber, Sort: true, Filter: true},
+		"name":           {Column: "name", Kind: fieldString, Sort: true, Filter: true},
+		"unit":           {Column: "unit", Kind: fieldString, Sort: true, Filter: true},
+		"unit_cost":      {Column: "unit_cost", Kind: fieldNumber, Sort: true, Filter: true},
+		"stock_qty":      {Column: "stock_qty", Kind: fieldNumber, Sort: true, Filter: true},
+		"classification": {Column: "classification", Kind: fieldString, Sort: true, Filter: true},
+		"supplier_id":    {Column: "supplier_id", Kind: fieldNumber, Filter: true},
+		"lead_time_days": {Column: "lead_time_days", Kind: fieldNumber, Sort: true, Filter: true},
+		"pricing_policy": {Column: "pricing_policy", Kind: fieldString, Filter: true},
+		"waste_percent":  {Column: "waste_percent", Kind: fieldNumber, Sort: true, Filter: true},
+		"created_at":     {Column: "created_at", Kind: fieldTime, Sort: true, Filter: true},
+		"updated_at":     {Column: "updated_at", Kind: fieldTime, Sort: true, Filter: true},
+	},
+	DefaultSort: "name",
 }
 
-// ListAllMaterials 查询所有物料（带分页）
-func ListAllMaterials(c *fiber.Ctx) error {
-	page, _ := strconv.Atoi(c.Query("page", "1"))
-	pageSize, _ := strconv.Atoi(c.Query("pageSize", "10"))
-	if page < 1 {
-		page = 1
-	}
-	if pageSize < 1 {
-		pageSize = 10
+// ListMaterials 查询物料（支持排序、过滤、分页）
+func ListMaterials(c *fiber.Ctx) error {
+	lq, err := parseListQuery(c, materialListSpec)
+	if err != nil {
+		return respondListError(c, err)
 	}
-
-	var total int64
-	var materials []models.Material
-
-	database.DB.Model(&models.Material{}).Count(&total)
-	if err := database.DB.
-		Limit(pageSize).
-		Offset((page - 1) * pageSize).
-		Find(&materials).Error; err != nil {
+	materials := []models.Material{}
+	p, err := lq.find(database.DB.Model(&models.Material{}), &materials)
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch materials"})
 	}
+	return respondList(c, materials, p)
+}
 
-	return c.JSON(fiber.Map{
-		"data":       materials,
-		"total":      total,
-		"page":       page,
-		"pageSize":   pageSize,
-		"totalPages": (total + int64(pageSize) - 1) / int64(pageSize),
-	})
+// ListAllMaterials 查询所有物料（带分页），与 ListMaterials 相同
+func ListAllMaterials(c *fiber.Ctx) error {
+	return ListMaterials(c)
 }
 
 // UpdateMaterial 修改物料
@@ -77,6 +90,17 @@ func UpdateMaterial(c *fiber.Ctx) error {
 	if err := c.BodyParser(&data); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Failed to parse request body"})
 	}
+	// Waste and purchase increment can be set back to 0, so they are only changed when present
+	var quantities struct {
+		WastePercent      *float64 `json:"waste_percent"`
+		PurchaseIncrement *float64 `json:"purchase_increment"`
+	}
+	if err := c.BodyParser(&quantities); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Failed to parse request body"})
+	}
+	renamed := data.Name != "" && data.Name != material.Name
+	// Units registered before the registry existed are kept until the unit is changed
+	unitChanged := data.Unit != "" && !sameUnit(data.Unit, material.Unit)
 	if data.Name != "" {
 		material.Name = data.Name
 	}
@@ -92,7 +116,68 @@ func UpdateMaterial(c *fiber.Ctx) error {
 	if data.StockQty >= 0 {
 		material.StockQty = data.StockQty
 	}
-	if err := database.DB.Save(&material).Error; err != nil {
+	if data.SKU != nil {
+		// An empty SKU clears it
+		material.SKU = normalizeSKU(data.SKU)
+	}
+	if data.SupplierID != nil {
+		if *data.SupplierID == 0 {
+			material.SupplierID = nil
+		} else {
+			material.SupplierID = data.SupplierID
+		}
+	}
+	if data.SupplierPartNo != "" {
+		material.SupplierPartNo = data.SupplierPartNo
+	}
+	if data.LeadTimeDays > 0 {
+		material.LeadTimeDays = data.LeadTimeDays
+	}
+	if data.MinOrderQty > 0 {
+		material.MinOrderQty = data.MinOrderQty
+	}
+	if data.PricingPolicy != "" {
+		material.PricingPolicy = data.PricingPolicy
+	}
+	if quantities.WastePercent != nil {
+		material.WastePercent = *quantities.WastePercent
+	}
+	if quantities.PurchaseIncrement != nil {
+		material.PurchaseIncrement = *quantities.PurchaseIncrement
+	}
+	if msg := validateMaterialPurchasing(&material); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": msg})
+	}
+	if unitChanged {
+		units, err := loadUnits(database.DB)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update material"})
+		}
+		if err := units.checkMaterialUnit(material.Unit); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Unit: " + err.Error()})
+		}
+	}
+	// Component lines in their own unit must still convert to a changed material unit
+	if err := checkMaterialUnitChange(database.DB, material); err != nil {
+		if errors.Is(err, errUnknownUnit) || errors.Is(err, errIncompatibleUnits) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Unit: " + err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update material"})
+	}
+	// Saving and re-costing the components that use this material happen together
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&material).Error; err != nil {
+			return err
+		}
+		// Quotations are also searched by the current names of their materials
+		if renamed {
+			if err := database.RefreshQuotationSearchTextForMaterial(tx, material.ID); err != nil {
+				return err
+			}
+		}
+		return recalculateComponentsUsingMaterial(tx, material.ID)
+	})
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update material"})
 	}
 	return c.JSON(material)
@@ -111,16 +196,18 @@ func DeleteMaterial(c *fiber.Ctx) error {
 func GetMaterialById(c *fiber.Ctx) error {
 	id := c.Params("id")
 	var material models.Material
-	if err := database.DB.First(&material, id).Error; err != nil {
+	if err := database.DB.Preload("Supplier").Preload("SupplierPrices.Supplier").First(&material, id).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Material not found"})
 	}
 	return c.JSON(material)
 }
 
-// SearchMaterials 按名称或类别搜索物料
+// SearchMaterials 按名称、类别、SKU或供应商搜索物料
 func SearchMaterials(c *fiber.Ctx) error {
 	name := c.Query("name")
 	category := c.Query("category")
+	sku := c.Query("sku")
+	supplierID := c.Query("supplier_id")
 	var materials []models.Material
 	query := database.DB
 	if name != "" {
@@ -129,8 +216,58 @@ func SearchMaterials(c *fiber.Ctx) error {
 	if category != "" {
 		query = query.Where("category LIKE ?", "%"+category+"%")
 	}
+	if sku != "" {
+		query = query.Where("sku LIKE ?", sku+"%")
+	}
+	if supplierID != "" {
+		query = query.Where("supplier_id = ?", supplierID)
+	}
 	if err := query.Find(&materials).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to search materials"})
 	}
 	return c.JSON(materials)
 }
+
+// normalizeSKU trims a SKU and treats an empty one as unset, so materials without a SKU don't collide on the unique index
+func normalizeSKU(sku *string) *string {
+	if sku == nil {
+		return nil
+	}
+	trimmed := strings.TrimSpace(*sku)
+	if trimmed == "" {
+		return nil
+	}
+	return &trimmed
+}
+
+// validateMaterialPurchasing checks the SKU and supplier fields of a material and returns an error message, or "" if valid
+func validateMaterialPurchasing(material *models.Material) string {
+	if material.LeadTimeDays < 0 || material.MinOrderQty < 0 {
+		return "Lead time and minimum order quantity must be non-negative"
+	}
+	if material.WastePercent < 0 || material.WastePercent > 100 {
+		return "Waste percent must be between 0 and 100"
+	}
+	if material.PurchaseIncrement < 0 {
+		return "Purchase increment must be non-negative"
+	}
+	switch material.PricingPolicy {
+	case models.PricingPolicyPreferred, models.PricingPolicyCheapest, models.PricingPolicyUnitCost:
+	default:
+		return "Pricing policy must be one of preferred, cheapest or unit_cost"
+	}
+	if material.SKU != nil {
+		var count int64
+		database.DB.Unscoped().Model(&models.Material{}).Where("sku = ? AND id <> ?", *material.SKU, material.ID).Count(&count)
+		if count > 0 {
+			return "SKU already exists"
+		}
+	}
+	if material.SupplierID != nil {
+		var supplier models.Supplier
+		if err := database.DB.First(&supplier, *material.SupplierID).Error; err != nil {
+			return "Supplier not found"
+		}
+	}
+	return ""
+}
@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"qp1/models"
+	"strconv"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestMaterialSKUsAndSuppliers(t *testing.T) {
+	db := setupTest(t)
+	app := fiber.New()
+	app.Post("/materials", CreateMaterial)
+	app.Put("/materials/:id", UpdateMaterial)
+	app.Delete("/materials/:id", DeleteMaterial)
+	app.Delete("/suppliers/:id", DeleteSupplier)
+	supplier := models.Supplier{Name: "Timber Co"}
+	db.Create(&supplier)
+	supplierID := strconv.FormatUint(uint64(supplier.ID), 10)
+
+	create := func(body string) (int, models.Material) {
+		t.Helper()
+		status, resp := sendJSON(t, app, http.MethodPost, "/materials", body)
+		var material models.Material
+		json.Unmarshal([]byte(resp), &material)
+		return status, material
+	}
+	status, oak := create(`{"name": "Oak board", "unit": "m", "sku": " OAK-1 ", "supplier_id": ` + supplierID + `}`)
+	if status != fiber.StatusOK || oak.SKU == nil || *oak.SKU != "OAK-1" || oak.PricingPolicy != models.PricingPolicyPreferred {
+		t.Fatalf("creating a material returned %d: %+v", status, oak)
+	}
+	oakPath := "/materials/" + strconv.FormatUint(uint64(oak.ID), 10)
+
+	// Materials without a SKU don't collide on the unique index
+	for _, name := range []string{"Glue", "Nails"} {
+		if status, material := create(`{"name": "` + name + `", "unit": "pcs", "sku": "  "}`); status != fiber.StatusOK || material.SKU != nil {
+			t.Errorf("creating %s without a SKU returned %d: %+v", name, status, material)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"duplicate SKU", http.MethodPost, "/materials", `{"name": "Pine board", "unit": "m", "sku": "OAK-1"}`, fiber.StatusBadRequest},
+		{"unknown supplier", http.MethodPost, "/materials", `{"name": "Pine board", "unit": "m", "supplier_id": 999}`, fiber.StatusBadRequest},
+		{"unknown pricing policy", http.MethodPost, "/materials", `{"name": "Pine board", "unit": "m", "pricing_policy": "random"}`, fiber.StatusBadRequest},
+		{"negative lead time", http.MethodPost, "/materials", `{"name": "Pine board", "unit": "m", "lead_time_days": -1}`, fiber.StatusBadRequest},
+		{"supplier in use", http.MethodDelete, "/suppliers/" + supplierID, "", fiber.StatusBadRequest},
+		{"keeping its own SKU", http.MethodPut, oakPath, `{"sku": "OAK-1"}`, fiber.StatusOK},
+		{"unassigning the supplier", http.MethodPut, oakPath, `{"supplier_id": 0}`, fiber.StatusOK},
+		{"unused supplier", http.MethodDelete, "/suppliers/" + supplierID, "", fiber.StatusOK},
+		{"deleted material", http.MethodDelete, oakPath, "", fiber.StatusOK},
+		// The unique index still covers deleted materials
+		{"SKU of a deleted material", http.MethodPost, "/materials", `{"name": "Pine board", "unit": "m", "sku": "OAK-1"}`, fiber.StatusBadRequest},
+		{"new SKU", http.MethodPost, "/materials", `{"name": "Pine board", "unit": "m", "sku": "PINE-1"}`, fiber.StatusOK},
+	}
+	for _, tt := range tests {
+		if status, body := sendJSON(t, app, tt.method, tt.path, tt.body); status != tt.status {
+			t.Errorf("%s: %s %s returned %d, want %d: %s", tt.name, tt.method, tt.path, status, tt.status, body)
+		}
+	}
+}
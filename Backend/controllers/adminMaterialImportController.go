@@ -0,0 +1,356 @@
+package controllers
+
+import (
+	"fmt"
+	"qp1/database"
+	"qp1/models"
+	"qp1/utils"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// MaterialImportRow is the validation result and planned action for one spreadsheet row
+type MaterialImportRow struct {
+	Row    int      `json:"row"`
+	Name   string   `json:"name"`
+	Action string   `json:"action"` // create, update or skip
+	Errors []string `json:"errors,omitempty"`
+}
+
+// materialImportColumns are the recognised header names; name and unit are required
+var materialImportColumns = []string{
+	"name", "description", "unit", "unit_cost", "stock_qty", "classification",
+	"sku", "supplier", "supplier_part_no", "lead_time_days", "min_order_qty", "waste_percent", "purchase_increment",
+}
+
+// materialImportNumberColumns are left unchanged on update when their cell is empty, rather than set to zero
+var materialImportNumberColumns = map[string]bool{
+	"unit_cost": true, "stock_qty": true, "lead_time_days": true, "min_order_qty": true, "waste_percent": true, "purchase_increment": true,
+}
+
+type parsedMaterialRow struct {
+	result       MaterialImportRow
+	material     models.Material
+	supplierName string
+	present      map[string]bool
+}
+
+// ImportMaterials 批量导入物料 (CSV/XLSX). With ?dry_run=true only the per-row preview is returned.
+func ImportMaterials(c *fiber.Ctx) error {
+	dryRun := c.QueryBool("dry_run", false)
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "A CSV or XLSX file is required in the 'file' field"})
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Failed to read uploaded file"})
+	}
+	defer file.Close()
+
+	rows, err := utils.ReadTable(file, fileHeader.Filename)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Failed to parse file: " + err.Error()})
+	}
+	if len(rows) < 2 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "File has no data rows"})
+	}
+	index := utils.HeaderIndex(rows[0])
+	if _, ok := index["name"]; !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Missing required column: name"})
+	}
+	if _, ok := index["unit"]; !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Missing required column: unit"})
+	}
+
+	var suppliers []models.Supplier
+	if err := database.DB.Find(&suppliers).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to load suppliers"})
+	}
+	units, err := loadUnits(database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to load units"})
+	}
+	supplierIDs := make(map[string]uint, len(suppliers))
+	for _, supplier := range suppliers {
+		supplierIDs[strings.ToLower(supplier.Name)] = supplier.ID
+	}
+
+	// Validate every row first; spreadsheet row numbers start at 2 because row 1 is the header
+	var parsed []*parsedMaterialRow
+	seen := make(map[string]int)
+	seenSKU := make(map[string]int)
+	var names []string
+	var skus []string
+	for i, row := range rows[1:] {
+		if isBlankRow(row) {
+			continue
+		}
+		p := parseMaterialRow(row, index)
+		p.result.Row = i + 2
+		if p.material.Name != "" {
+			key := strings.ToLower(p.material.Name)
+			if first, dup := seen[key]; dup {
+				p.result.Errors = append(p.result.Errors, fmt.Sprintf("Duplicate name, already used on row %d", first))
+			} else {
+				seen[key] = p.result.Row
+				names = append(names, p.material.Name)
+			}
+		}
+		if p.material.SKU != nil {
+			key := strings.ToLower(*p.material.SKU)
+			if first, dup := seenSKU[key]; dup {
+				p.result.Errors = append(p.result.Errors, fmt.Sprintf("Duplicate SKU, already used on row %d", first))
+			} else {
+				seenSKU[key] = p.result.Row
+				skus = append(skus, *p.material.SKU)
+			}
+		}
+		if p.supplierName != "" {
+			if id, ok := supplierIDs[strings.ToLower(p.supplierName)]; ok {
+				p.material.SupplierID = &id
+			} else {
+				p.result.Errors = append(p.result.Errors, fmt.Sprintf("Supplier %q not found", p.supplierName))
+			}
+		}
+		parsed = append(parsed, p)
+	}
+
+	// Look up existing materials by name, including soft-deleted ones since the unique index still covers them
+	existing := make(map[string]*models.Material)
+	for start := 0; start < len(names); start += 500 {
+		end := start + 500
+		if end > len(names) {
+			end = len(names)
+		}
+		var found []models.Material
+		if err := database.DB.Unscoped().Where("name IN ?", names[start:end]).Find(&found).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to look up existing materials"})
+		}
+		for i := range found {
+			existing[strings.ToLower(found[i].Name)] = &found[i]
+		}
+	}
+
+	// A SKU may only move with its own material, never onto a different name
+	skuOwners := make(map[string]string)
+	for start := 0; start < len(skus); start += 500 {
+		end := start + 500
+		if end > len(skus) {
+			end = len(skus)
+		}
+		var found []models.Material
+		if err := database.DB.Unscoped().Where("sku IN ?", skus[start:end]).Find(&found).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to look up existing materials"})
+		}
+		for _, m := range found {
+			skuOwners[strings.ToLower(*m.SKU)] = strings.ToLower(m.Name)
+		}
+	}
+	for _, p := range parsed {
+		if p.material.SKU == nil {
+			continue
+		}
+		if owner, ok := skuOwners[strings.ToLower(*p.material.SKU)]; ok && owner != strings.ToLower(p.material.Name) {
+			p.result.Errors = append(p.result.Errors, "SKU already belongs to another material")
+		}
+	}
+
+	created, updated, skipped := 0, 0, 0
+	for _, p := range parsed {
+		current := existing[strings.ToLower(p.material.Name)]
+		// New and changed units must be registered; existing materials keep an unregistered unit they already have
+		if p.material.Unit != "" && (current == nil || !sameUnit(current.Unit, p.material.Unit)) {
+			if err := units.checkMaterialUnit(p.material.Unit); err != nil {
+				p.result.Errors = append(p.result.Errors, "Unit: "+err.Error())
+			}
+		}
+		switch {
+		case len(p.result.Errors) > 0:
+			p.result.Action = "skip"
+			skipped++
+		case current == nil:
+			p.result.Action = "create"
+			created++
+		case applyMaterialImport(current, p):
+			// Component lines in their own unit must still convert to a changed material unit
+			if err := checkMaterialUnitChange(database.DB, *current); err != nil {
+				p.result.Errors = append(p.result.Errors, "Unit: "+err.Error())
+				p.result.Action = "skip"
+				skipped++
+				break
+			}
+			p.result.Action = "update"
+			updated++
+		default:
+			p.result.Action = "skip"
+			skipped++
+		}
+	}
+
+	results := make([]MaterialImportRow, len(parsed))
+	for i, p := range parsed {
+		results[i] = p.result
+	}
+	summary := fiber.Map{
+		"dry_run": dryRun,
+		"created": created,
+		"updated": updated,
+		"skipped": skipped,
+		"rows":    results,
+	}
+	if dryRun {
+		return c.JSON(summary)
+	}
+
+	// Apply all creates and updates atomically
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		for _, p := range parsed {
+			switch p.result.Action {
+			case "create":
+				material := p.material
+				if err := tx.Create(&material).Error; err != nil {
+					return fmt.Errorf("row %d: %v", p.result.Row, err)
+				}
+			case "update":
+				material := existing[strings.ToLower(p.material.Name)]
+				if err := tx.Unscoped().Save(material).Error; err != nil {
+					return fmt.Errorf("row %d: %v", p.result.Row, err)
+				}
+				if err := recalculateComponentsUsingMaterial(tx, material.ID); err != nil {
+					return fmt.Errorf("row %d: %v", p.result.Row, err)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Import failed, no changes were saved: " + err.Error()})
+	}
+	return c.JSON(summary)
+}
+
+func parseMaterialRow(row []string, index map[string]int) *parsedMaterialRow {
+	p := &parsedMaterialRow{present: make(map[string]bool)}
+	values := make(map[string]string)
+	for _, column := range materialImportColumns {
+		if value, ok := utils.CellValue(row, index, column); ok {
+			values[column] = value
+			p.present[column] = value != "" || !materialImportNumberColumns[column]
+		}
+	}
+
+	p.material.Name = values["name"]
+	p.material.Description = values["description"]
+	p.material.Unit = values["unit"]
+	p.material.Classification = values["classification"]
+	p.material.SKU = normalizeSKU(stringPtr(values["sku"]))
+	p.material.SupplierPartNo = values["supplier_part_no"]
+	p.supplierName = values["supplier"]
+	p.result.Name = p.material.Name
+
+	if p.material.Name == "" {
+		p.result.Errors = append(p.result.Errors, "Name is required")
+	}
+	if p.material.Unit == "" {
+		p.result.Errors = append(p.result.Errors, "Unit is required")
+	}
+	p.material.UnitCost = parseImportNumber(values["unit_cost"], "Unit cost", &p.result.Errors)
+	p.material.StockQty = parseImportNumber(values["stock_qty"], "Stock quantity", &p.result.Errors)
+	p.material.MinOrderQty = parseImportNumber(values["min_order_qty"], "Minimum order quantity", &p.result.Errors)
+	p.material.LeadTimeDays = int(parseImportNumber(values["lead_time_days"], "Lead time", &p.result.Errors))
+	p.material.WastePercent = parseImportNumber(values["waste_percent"], "Waste percent", &p.result.Errors)
+	if p.material.WastePercent > 100 {
+		p.result.Errors = append(p.result.Errors, "Waste percent must be at most 100")
+	}
+	p.material.PurchaseIncrement = parseImportNumber(values["purchase_increment"], "Purchase increment", &p.result.Errors)
+	return p
+}
+
+// parseImportNumber parses a non-negative number. An empty cell is zero for new materials; updates skip it.
+func parseImportNumber(value, label string, errs *[]string) float64 {
+	if value == "" {
+		return 0
+	}
+	n, err := strconv.ParseFloat(strings.ReplaceAll(value, ",", ""), 64)
+	if err != nil {
+		*errs = append(*errs, fmt.Sprintf("%s must be a number", label))
+		return 0
+	}
+	if n < 0 {
+		*errs = append(*errs, fmt.Sprintf("%s must be non-negative", label))
+		return 0
+	}
+	return n
+}
+
+// applyMaterialImport copies the columns present in the file onto an existing material and reports whether anything changed
+func applyMaterialImport(current *models.Material, p *parsedMaterialRow) bool {
+	changed := current.DeletedAt.Valid
+	current.DeletedAt = gorm.DeletedAt{}
+	setString := func(column string, dst *string, value string) {
+		if p.present[column] && *dst != value {
+			*dst = value
+			changed = true
+		}
+	}
+	setNumber := func(column string, dst *float64, value float64) {
+		if p.present[column] && *dst != value {
+			*dst = value
+			changed = true
+		}
+	}
+	setString("description", &current.Description, p.material.Description)
+	setString("unit", &current.Unit, p.material.Unit)
+	setString("classification", &current.Classification, p.material.Classification)
+	setNumber("unit_cost", &current.UnitCost, p.material.UnitCost)
+	setNumber("stock_qty", &current.StockQty, p.material.StockQty)
+	setString("supplier_part_no", &current.SupplierPartNo, p.material.SupplierPartNo)
+	setNumber("min_order_qty", &current.MinOrderQty, p.material.MinOrderQty)
+	setNumber("waste_percent", &current.WastePercent, p.material.WastePercent)
+	setNumber("purchase_increment", &current.PurchaseIncrement, p.material.PurchaseIncrement)
+	if p.present["lead_time_days"] && current.LeadTimeDays != p.material.LeadTimeDays {
+		current.LeadTimeDays = p.material.LeadTimeDays
+		changed = true
+	}
+	if p.present["sku"] && stringValue(current.SKU) != stringValue(p.material.SKU) {
+		current.SKU = p.material.SKU
+		changed = true
+	}
+	if p.present["supplier"] && uintValue(current.SupplierID) != uintValue(p.material.SupplierID) {
+		current.SupplierID = p.material.SupplierID
+		changed = true
+	}
+	return changed
+}
+
+func isBlankRow(row []string) bool {
+	for _, cell := range row {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func uintValue(u *uint) uint {
+	if u == nil {
+		return 0
+	}
+	return *u
+}
@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"qp1/models"
+	"reflect"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// uploadFile posts content as the multipart field "file" and returns the status and body
+func uploadFile(t *testing.T, app *fiber.App, path string, filename string, content string) (int, string) {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write([]byte(content))
+	form.Close()
+	req := httptest.NewRequest(http.MethodPost, path, &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(data)
+}
+
+type importSummary struct {
+	DryRun  bool                `json:"dry_run"`
+	Created int                 `json:"created"`
+	Updated int                 `json:"updated"`
+	Skipped int                 `json:"skipped"`
+	Rows    []MaterialImportRow `json:"rows"`
+}
+
+func TestImportMaterialsDryRunAndCommit(t *testing.T) {
+	db := setupTest(t)
+	db.Create(&models.Material{Name: "Oak board", Unit: "m", UnitCost: 10, StockQty: 7})
+	app := fiber.New()
+	app.Post("/import", ImportMaterials)
+
+	file := "name,unit,unit_cost,stock_qty\n" +
+		"Oak board,m,12,\n" + // update; the empty stock cell keeps the stock
+		"Pine board,m,5,3\n" + // create
+		",m,1,1\n" + // no name
+		"Pine board,m,6,1\n" + // duplicate of row 3
+		",,,\n" + // blank rows are left out
+		"Glue,furlong,1,1\n" // unknown unit
+	wantRows := []MaterialImportRow{
+		{Row: 2, Name: "Oak board", Action: "update"},
+		{Row: 3, Name: "Pine board", Action: "create"},
+		{Row: 4, Action: "skip", Errors: []string{"Name is required"}},
+		{Row: 5, Name: "Pine board", Action: "skip", Errors: []string{"Duplicate name, already used on row 3"}},
+		{Row: 7, Name: "Glue", Action: "skip", Errors: []string{`Unit: unknown unit "furlong"`}},
+	}
+
+	for _, dryRun := range []bool{true, false} {
+		path := "/import"
+		if dryRun {
+			path += "?dry_run=true"
+		}
+		status, body := uploadFile(t, app, path, "materials.csv", file)
+		if status != fiber.StatusOK {
+			t.Fatalf("dry run %v: import returned %d: %s", dryRun, status, body)
+		}
+		var summary importSummary
+		if err := json.Unmarshal([]byte(body), &summary); err != nil {
+			t.Fatal(err)
+		}
+		if summary.DryRun != dryRun || summary.Created != 1 || summary.Updated != 1 || summary.Skipped != 3 {
+			t.Errorf("dry run %v: summary %+v", dryRun, summary)
+		}
+		if !reflect.DeepEqual(summary.Rows, wantRows) {
+			t.Errorf("dry run %v: rows\n%+v\nwant\n%+v", dryRun, summary.Rows, wantRows)
+		}
+
+		var oak models.Material
+		db.Where("name = ?", "Oak board").First(&oak)
+		var count int64
+		db.Model(&models.Material{}).Count(&count)
+		if dryRun {
+			if oak.UnitCost != 10 || count != 1 {
+				t.Errorf("the dry run saved changes: oak costs %v, %d materials", oak.UnitCost, count)
+			}
+			continue
+		}
+		if oak.UnitCost != 12 || oak.StockQty != 7 || count != 2 {
+			t.Errorf("after the import oak costs %v with stock %v, %d materials", oak.UnitCost, oak.StockQty, count)
+		}
+	}
+
+	// Importing the same file again changes nothing
+	_, body := uploadFile(t, app, "/import", "materials.csv", file)
+	var summary importSummary
+	json.Unmarshal([]byte(body), &summary)
+	if summary.Created != 0 || summary.Updated != 0 || summary.Skipped != 5 {
+		t.Errorf("second import summary %+v", summary)
+	}
+}
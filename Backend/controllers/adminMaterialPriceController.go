@@ -0,0 +1,154 @@
+package controllers
+
+import (
+	"qp1/database"
+	"qp1/models"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// MaterialPriceRequest is the body for adding or updating a supplier price of a material
+type MaterialPriceRequest struct {
+	SupplierID   uint     `json:"supplier_id"`
+	Price        *float64 `json:"price"`
+	Currency     string   `json:"currency"`
+	LeadTimeDays *int     `json:"lead_time_days"`
+	Preferred    *bool    `json:"preferred"`
+}
+
+// ListMaterialPrices 查询物料的所有供应商报价
+func ListMaterialPrices(c *fiber.Ctx) error {
+	id := c.Params("id")
+	var material models.Material
+	if err := database.DB.First(&material, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Material not found"})
+	}
+	var prices []models.MaterialSupplierPrice
+	if err := database.DB.Preload("Supplier").Where("material_id = ?", material.ID).Order("price").Find(&prices).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch supplier prices"})
+	}
+	return c.JSON(fiber.Map{
+		"material_id":    material.ID,
+		"pricing_policy": material.PricingPolicy,
+		"prices":         prices,
+	})
+}
+
+// CreateMaterialPrice 新增物料的供应商报价
+func CreateMaterialPrice(c *fiber.Ctx) error {
+	id := c.Params("id")
+	var material models.Material
+	if err := database.DB.First(&material, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Material not found"})
+	}
+	var req MaterialPriceRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Failed to parse request body"})
+	}
+	if req.SupplierID == 0 || req.Price == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Supplier and price are required"})
+	}
+	var supplier models.Supplier
+	if err := database.DB.First(&supplier, req.SupplierID).Error; err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Supplier not found"})
+	}
+	var count int64
+	database.DB.Model(&models.MaterialSupplierPrice{}).Where("material_id = ? AND supplier_id = ?", material.ID, req.SupplierID).Count(&count)
+	if count > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "This supplier already has a price for the material"})
+	}
+
+	price := models.MaterialSupplierPrice{
+		MaterialID: material.ID,
+		SupplierID: req.SupplierID,
+		Currency:   req.Currency,
+	}
+	if msg := applyMaterialPriceRequest(&price, req); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": msg})
+	}
+	if err := saveMaterialPrice(&price); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create supplier price"})
+	}
+	price.Supplier = supplier
+	return c.JSON(price)
+}
+
+// UpdateMaterialPrice 修改物料的供应商报价
+func UpdateMaterialPrice(c *fiber.Ctx) error {
+	var price models.MaterialSupplierPrice
+	if err := database.DB.Where("id = ? AND material_id = ?", c.Params("priceId"), c.Params("id")).First(&price).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Supplier price not found"})
+	}
+	var req MaterialPriceRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Failed to parse request body"})
+	}
+	if req.Currency != "" {
+		price.Currency = req.Currency
+	}
+	if msg := applyMaterialPriceRequest(&price, req); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": msg})
+	}
+	if err := saveMaterialPrice(&price); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update supplier price"})
+	}
+	database.DB.Preload("Supplier").First(&price, price.ID)
+	return c.JSON(price)
+}
+
+// DeleteMaterialPrice 删除物料的供应商报价
+func DeleteMaterialPrice(c *fiber.Ctx) error {
+	var price models.MaterialSupplierPrice
+	if err := database.DB.Where("id = ? AND material_id = ?", c.Params("priceId"), c.Params("id")).First(&price).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Supplier price not found"})
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&price).Error; err != nil {
+			return err
+		}
+		return recalculateComponentsUsingMaterial(tx, price.MaterialID)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete supplier price"})
+	}
+	return c.JSON(fiber.Map{"message": "Supplier price deleted successfully"})
+}
+
+// applyMaterialPriceRequest copies the provided fields onto price and returns an error message, or "" if valid
+func applyMaterialPriceRequest(price *models.MaterialSupplierPrice, req MaterialPriceRequest) string {
+	if req.Price != nil {
+		if *req.Price < 0 {
+			return "Price must be non-negative"
+		}
+		price.Price = *req.Price
+	}
+	if req.LeadTimeDays != nil {
+		if *req.LeadTimeDays < 0 {
+			return "Lead time must be non-negative"
+		}
+		price.LeadTimeDays = *req.LeadTimeDays
+	}
+	if req.Preferred != nil {
+		price.Preferred = *req.Preferred
+	}
+	return ""
+}
+
+// saveMaterialPrice stores a supplier price, keeps a single preferred price per material,
+// and re-costs the components that use the material
+func saveMaterialPrice(price *models.MaterialSupplierPrice) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(price).Error; err != nil {
+			return err
+		}
+		if price.Preferred {
+			if err := tx.Model(&models.MaterialSupplierPrice{}).
+				Where("material_id = ? AND id <> ?", price.MaterialID, price.ID).
+				Update("preferred", false).Error; err != nil {
+				return err
+			}
+		}
+		return recalculateComponentsUsingMaterial(tx, price.MaterialID)
+	})
+}
@@ -0,0 +1,172 @@
+package controllers
+
+import (
+	"fmt"
+	"qp1/database"
+	"qp1/models"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// RoleRequest is the body for creating or updating a role
+type RoleRequest struct {
+	Name        string    `json:"name"`
+	Description *string   `json:"description"`
+	Permissions *[]string `json:"permissions"` // permission names; replaces the role's permissions when given
+}
+
+// ListPermissions 查询所有权限
+func ListPermissions(c *fiber.Ctx) error {
+	var permissions []models.Permission
+	if err := database.DB.Order("name").Find(&permissions).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch permissions"})
+	}
+	return c.JSON(permissions)
+}
+
+// ListRoles 查询所有角色及其权限
+func ListRoles(c *fiber.Ctx) error {
+	var roles []models.Role
+	if err := database.DB.Preload("Permissions").Order("name").Find(&roles).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch roles"})
+	}
+	return c.JSON(roles)
+}
+
+// GetRoleById 查询单个角色
+func GetRoleById(c *fiber.Ctx) error {
+	var role models.Role
+	if err := database.DB.Preload("Permissions").First(&role, c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Role not found"})
+	}
+	return c.JSON(role)
+}
+
+// CreateRole 新增角色
+func CreateRole(c *fiber.Ctx) error {
+	var req RoleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Failed to parse request body"})
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Role name is required"})
+	}
+	var count int64
+	database.DB.Model(&models.Role{}).Where("name = ?", name).Count(&count)
+	if count > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Role already exists"})
+	}
+
+	role := models.Role{Name: name}
+	if req.Description != nil {
+		role.Description = *req.Description
+	}
+	if req.Permissions != nil {
+		permissions, err := findPermissions(*req.Permissions)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		role.Permissions = permissions
+	}
+	if err := database.DB.Create(&role).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create role"})
+	}
+	return c.JSON(role)
+}
+
+// UpdateRole 修改角色描述和权限（角色名不可修改，用户通过角色名关联）
+func UpdateRole(c *fiber.Ctx) error {
+	var role models.Role
+	if err := database.DB.First(&role, c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Role not found"})
+	}
+	var req RoleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Failed to parse request body"})
+	}
+	if req.Name != "" && req.Name != role.Name {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Role name can't be changed"})
+	}
+	if role.Name == models.RoleAdmin && req.Permissions != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "The admin role always has every permission"})
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if req.Description != nil {
+			role.Description = *req.Description
+			if err := tx.Save(&role).Error; err != nil {
+				return err
+			}
+		}
+		if req.Permissions != nil {
+			permissions, err := findPermissions(*req.Permissions)
+			if err != nil {
+				return err
+			}
+			return tx.Model(&role).Association("Permissions").Replace(permissions)
+		}
+		return nil
+	})
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Failed to update role: " + err.Error()})
+	}
+	database.DB.Preload("Permissions").First(&role, role.ID)
+	return c.JSON(role)
+}
+
+// DeleteRole 删除角色，内置角色和仍有用户使用的角色不可删除
+func DeleteRole(c *fiber.Ctx) error {
+	var role models.Role
+	if err := database.DB.First(&role, c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Role not found"})
+	}
+	if role.System {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Built-in roles can't be deleted"})
+	}
+	var users int64
+	database.DB.Model(&models.User{}).Where("role = ?", role.Name).Count(&users)
+	if users > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Role is assigned to %d users", users)})
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&role).Association("Permissions").Clear(); err != nil {
+			return err
+		}
+		return tx.Delete(&role).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete role"})
+	}
+	return c.JSON(fiber.Map{"message": "Role deleted successfully"})
+}
+
+// findPermissions resolves permission names, failing on unknown names
+func findPermissions(names []string) ([]models.Permission, error) {
+	permissions := []models.Permission{}
+	if len(names) == 0 {
+		return permissions, nil
+	}
+	if err := database.DB.Where("name IN ?", names).Find(&permissions).Error; err != nil {
+		return nil, err
+	}
+	found := make(map[string]bool, len(permissions))
+	for _, p := range permissions {
+		found[p.Name] = true
+	}
+	for _, name := range names {
+		if !found[name] {
+			return nil, fmt.Errorf("Unknown permission %q", name)
+		}
+	}
+	return permissions, nil
+}
+
+// roleExists reports whether a role with this name has been defined
+func roleExists(name string) bool {
+	var count int64
+	database.DB.Model(&models.Role{}).Where("name = ?", name).Count(&count)
+	return count > 0
+}
@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"qp1/database"
+	"qp1/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// CreateSupplier 新增供应商
+func CreateSupplier(c *fiber.Ctx) error {
+	var data models.Supplier
+	if err := c.BodyParser(&data); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Failed to parse request body"})
+	}
+	if data.Name == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Name is required"})
+	}
+	var existing models.Supplier
+	if err := database.DB.Where("name = ?", data.Name).First(&existing).Error; err == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Supplier already exists"})
+	}
+	data.ID = 0
+	if err := database.DB.Create(&data).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create supplier"})
+	}
+	return c.JSON(data)
+}
+
+var supplierListSpec = listSpec{
+	Fields: map[string]listField{
+		"id":           {Column: "id", Kind: fieldNumber, Sort: true, Filter: true},
+		"name":         {Column: "name", Kind: fieldString, Sort: true},
+		"contact_name": {Column: "contact_name", Kind: fieldString, Sort: true, Filter: true},
+		"email":        {Column: "email", Kind: fieldString, Sort: true, Filter: true},
+		"created_at":   {Column: "created_at", Kind: fieldTime, Sort: true, Filter: true},
+		"updated_at":   {Column: "updated_at", Kind: fieldTime, Sort: true, Filter: true},
+	},
+	DefaultSort: "name",
+}
+
+// ListSuppliers 查询供应商，name 为模糊匹配（支持排序、过滤、分页）
+func ListSuppliers(c *fiber.Ctx) error {
+	lq, err := parseListQuery(c, supplierListSpec)
+	if err != nil {
+		return respondListError(c, err)
+	}
+	query := database.DB.Model(&models.Supplier{})
+	if name := c.Query("name"); name != "" {
+		query = query.Where("name LIKE ?", "%"+name+"%")
+	}
+	suppliers := []models.Supplier{}
+	p, err := lq.find(query, &suppliers)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch suppliers"})
+	}
+	return respondList(c, suppliers, p)
+}
+
+// GetSupplierById 获取单个供应商信息
+func GetSupplierById(c *fiber.Ctx) error {
+	id := c.Params("id")
+	var supplier models.Supplier
+	if err := database.DB.First(&supplier, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Supplier not found"})
+	}
+	return c.JSON(supplier)
+}
+
+// UpdateSupplier 修改供应商
+func UpdateSupplier(c *fiber.Ctx) error {
+	id := c.Params("id")
+	var supplier models.Supplier
+	if err := database.DB.First(&supplier, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Supplier not found"})
+	}
+	var data models.Supplier
+	if err := c.BodyParser(&data); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Failed to parse request body"})
+	}
+	if data.Name != "" && data.Name != supplier.Name {
+		var existing models.Supplier
+		if err := database.DB.Where("name = ?", data.Name).First(&existing).Error; err == nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Supplier already exists"})
+		}
+		supplier.Name = data.Name
+	}
+	if data.ContactName != "" {
+		supplier.ContactName = data.ContactName
+	}
+	if data.Email != "" {
+		supplier.Email = data.Email
+	}
+	if data.Phone != "" {
+		supplier.Phone = data.Phone
+	}
+	if data.Address != "" {
+		supplier.Address = data.Address
+	}
+	if data.Notes != "" {
+		supplier.Notes = data.Notes
+	}
+	if err := database.DB.Save(&supplier).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update supplier"})
+	}
+	return c.JSON(supplier)
+}
+
+// DeleteSupplier 删除供应商（仍被物料引用或仍有物料报价时不允许删除）
+func DeleteSupplier(c *fiber.Ctx) error {
+	id := c.Params("id")
+	var count int64
+	database.DB.Model(&models.Material{}).Where("supplier_id = ?", id).Count(&count)
+	if count > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Supplier is still assigned to materials"})
+	}
+	// Its prices would otherwise keep costing materials
+	database.DB.Model(&models.MaterialSupplierPrice{}).Where("supplier_id = ?", id).Count(&count)
+	if count > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Supplier still has material prices"})
+	}
+	if err := database.DB.Delete(&models.Supplier{}, id).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete supplier"})
+	}
+	return c.JSON(fiber.Map{"message": "Supplier deleted successfully"})
+}
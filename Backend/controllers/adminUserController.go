@@ -5,7 +5,6 @@ import (
 	"qp1/models"
 
 	"github.com/gofiber/fiber/v2"
-	"golang.org/x/crypto/bcrypt"
 )
 
 // Admin adds a new user
@@ -19,19 +18,50 @@ func AddUser(c *fiber.Ctx) error {
 	if err := c.BodyParser(&data); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
 	}
+	if data.Role == "" {
+		data.Role = "user"
+	}
+	if !roleExists(data.Role) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Unknown role"})
+	}
+	if data.Role == models.RoleAdmin && !isAdmin(c) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Only admins can grant the admin role"})
+	}
 	var existing models.User
 	if err := database.DB.Where("email = ?", data.Email).First(&existing).Error; err == nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Email already exists"})
 	}
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(data.Password), bcrypt.DefaultCost)
+
+	// Without a password the user is invited to choose their own
+	if data.Password == "" {
+		if data.Email == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Email is required"})
+		}
+		user := models.User{
+			Name:   data.Name,
+			Email:  data.Email,
+			Role:   data.Role,
+			Status: models.UserStatusInvited,
+		}
+		if err := database.DB.Create(&user).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create user"})
+		}
+		if err := inviteUser(c, user); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create invitation"})
+		}
+		return c.JSON(fiber.Map{"message": "Invitation sent", "id": user.ID})
+	}
+
+	hashedPassword, err := hashNewPassword(database.DB, nil, data.Password)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to hash password"})
+		return respondPasswordError(c, err)
 	}
 	user := models.User{
 		Name:     data.Name,
 		Email:    data.Email,
 		Password: hashedPassword,
 		Role:     data.Role,
+		Status:   models.UserStatusActive,
 	}
 	if err := database.DB.Create(&user).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create user"})
@@ -46,6 +76,9 @@ func UpdateUser(c *fiber.Ctx) error {
 	if err := database.DB.First(&user, id).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
 	}
+	if !canManageUser(c, user) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Only admins can manage admin accounts"})
+	}
 	var data struct {
 		Name  string `json:"name"`
 		Email string `json:"email"`
@@ -64,9 +97,17 @@ func UpdateUser(c *fiber.Ctx) error {
 // Admin deletes user
 func DeleteUser(c *fiber.Ctx) error {
 	id := c.Params("id")
-	if err := database.DB.Delete(&models.User{}, id).Error; err != nil {
+	var user models.User
+	if err := database.DB.First(&user, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	}
+	if !canManageUser(c, user) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Only admins can manage admin accounts"})
+	}
+	if err := database.DB.Delete(&user).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete user"})
 	}
+	revokeUserSessions(database.DB, user.ID, 0)
 	return c.JSON(fiber.Map{"message": "User deleted successfully"})
 }
 
@@ -89,7 +130,7 @@ func ListUsers(c *fiber.Ctx) error {
 	return c.JSON(users)
 }
 
-// Change user role (Admin/User)
+// Change user role; the role must exist in the roles table
 func UpdateUserRole(c *fiber.Ctx) error {
 	id := c.Params("id")
 	var data struct {
@@ -98,10 +139,19 @@ func UpdateUserRole(c *fiber.Ctx) error {
 	if err := c.BodyParser(&data); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
 	}
+	if !roleExists(data.Role) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Unknown role"})
+	}
+	if data.Role == models.RoleAdmin && !isAdmin(c) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Only admins can grant the admin role"})
+	}
 	var user models.User
 	if err := database.DB.First(&user, id).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
 	}
+	if user.Role == models.RoleAdmin && !isAdmin(c) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Only admins can change the role of an admin"})
+	}
 	user.Role = data.Role
 	if err := database.DB.Save(&user).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update role"})
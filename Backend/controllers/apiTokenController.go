@@ -0,0 +1,184 @@
+package controllers
+
+import (
+	"fmt"
+	"qp1/database"
+	"qp1/models"
+	"qp1/utils"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const (
+	apiTokenPrefix         = "qp1_"
+	apiTokenDefaultDays    = 90
+	apiTokenMaxDays        = 365
+	apiTokenDisplayedChars = 12
+)
+
+// APITokenRequest is the body for creating an API token
+type APITokenRequest struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays int      `json:"expires_in_days"`
+}
+
+// APITokenResponse describes an API token; Token is only set right after creation
+type APITokenResponse struct {
+	models.APIToken
+	Scopes []string `json:"scopes"`
+	Token  string   `json:"token,omitempty"`
+}
+
+func apiTokenScopes(token models.APIToken) []string {
+	if token.Scopes == "" {
+		return []string{}
+	}
+	return strings.Split(token.Scopes, ",")
+}
+
+// authenticateAPIToken 校验 Authorization: Bearer 中的API token，写入上下文 user_id、api_token
+func authenticateAPIToken(c *fiber.Ctx, raw string) (models.User, error) {
+	var user models.User
+	if !strings.HasPrefix(raw, apiTokenPrefix) {
+		return user, &authError{fiber.StatusUnauthorized, "无效token"}
+	}
+	var token models.APIToken
+	if err := database.DB.Where("token_hash = ?", utils.HashToken(raw)).First(&token).Error; err != nil {
+		return user, &authError{fiber.StatusUnauthorized, "无效token"}
+	}
+	if token.RevokedAt != nil || time.Now().After(token.ExpiresAt) {
+		return user, &authError{fiber.StatusUnauthorized, "token已失效"}
+	}
+	if err := database.DB.First(&user, token.UserID).Error; err != nil {
+		return user, &authError{fiber.StatusUnauthorized, "用户不存在"}
+	}
+	if token.LastUsedAt == nil || time.Since(*token.LastUsedAt) > time.Minute {
+		database.DB.Model(&token).UpdateColumn("last_used_at", time.Now())
+	}
+	c.Locals("user_id", user.ID)
+	c.Locals("api_token", token)
+	c.Locals("two_factor_verified", token.TwoFactorVerified)
+	return user, nil
+}
+
+// tokenAllows reports whether the request may use scope; requests authenticated with a session cookie are not limited by scopes
+func tokenAllows(c *fiber.Ctx, scope string) bool {
+	token, ok := c.Locals("api_token").(models.APIToken)
+	if !ok {
+		return true
+	}
+	for _, s := range apiTokenScopes(token) {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// respondMissingScope rejects an API token request outside the token's scopes
+func respondMissingScope(c *fiber.Ctx, scope string) error {
+	return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+		"error":         "API token scope does not allow this request",
+		"missing_scope": scope,
+	})
+}
+
+// ListAPITokens 查询当前用户的API token
+func ListAPITokens(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+	var tokens []models.APIToken
+	if err := database.DB.Where("user_id = ?", user.ID).Order("id DESC").Find(&tokens).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch API tokens"})
+	}
+	result := make([]APITokenResponse, 0, len(tokens))
+	for _, token := range tokens {
+		result = append(result, APITokenResponse{APIToken: token, Scopes: apiTokenScopes(token)})
+	}
+	return c.JSON(result)
+}
+
+// CreateAPIToken 创建API token，token明文只在此返回一次
+func CreateAPIToken(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+	var req APITokenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Failed to parse request body"})
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 100 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Name is required and must be at most 100 characters"})
+	}
+	if len(req.Scopes) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "At least one scope is required"})
+	}
+	if req.ExpiresInDays == 0 {
+		req.ExpiresInDays = apiTokenDefaultDays
+	}
+	if req.ExpiresInDays < 1 || req.ExpiresInDays > apiTokenMaxDays {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Expiry must be between 1 and %d days", apiTokenMaxDays)})
+	}
+
+	// A token can only carry permissions the user has
+	granted, err := rolePermissions(user.Role)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to check permissions"})
+	}
+	seen := make(map[string]bool)
+	var scopes []string
+	for _, scope := range req.Scopes {
+		scope = strings.TrimSpace(scope)
+		if seen[scope] {
+			continue
+		}
+		seen[scope] = true
+		allowed := scope == models.ScopeUser || granted[scope] || (scope == models.ScopeAdmin && user.Role == models.RoleAdmin)
+		if !allowed {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Scope %q is unknown or not granted to your role", scope)})
+		}
+		scopes = append(scopes, scope)
+	}
+
+	secret, err := utils.GenerateToken(32)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to generate token"})
+	}
+	raw := apiTokenPrefix + secret
+	verified, _ := c.Locals("two_factor_verified").(bool)
+	token := models.APIToken{
+		UserID:            user.ID,
+		Name:              req.Name,
+		TokenPrefix:       raw[:apiTokenDisplayedChars],
+		TokenHash:         utils.HashToken(raw),
+		Scopes:            strings.Join(scopes, ","),
+		TwoFactorVerified: verified,
+		ExpiresAt:         time.Now().AddDate(0, 0, req.ExpiresInDays),
+	}
+	if err := database.DB.Create(&token).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create API token"})
+	}
+	return c.JSON(APITokenResponse{APIToken: token, Scopes: scopes, Token: raw})
+}
+
+// revokeUserAPITokens revokes all active API tokens of a user, e.g. together with their sessions when the password is reset
+func revokeUserAPITokens(tx *gorm.DB, userID uint) error {
+	return tx.Model(&models.APIToken{}).Where("user_id = ? AND revoked_at IS NULL", userID).Update("revoked_at", time.Now()).Error
+}
+
+// RevokeAPIToken 吊销当前用户的API token
+func RevokeAPIToken(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+	result := database.DB.Model(&models.APIToken{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", c.Params("id"), user.ID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to revoke API token"})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "API token not found"})
+	}
+	return c.JSON(fiber.Map{"message": "API token revoked"})
+}
@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"qp1/models"
+	"qp1/utils"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestTokenAllows(t *testing.T) {
+	tests := []struct {
+		name   string
+		token  *models.APIToken
+		scope  string
+		allows bool
+	}{
+		{"session without a token", nil, models.ScopeAdmin, true},
+		{"scope granted", &models.APIToken{Scopes: "user,materials:read"}, models.PermMaterialsRead, true},
+		{"scope not granted", &models.APIToken{Scopes: "user,materials:read"}, models.PermMaterialsWrite, false},
+		{"no scopes", &models.APIToken{}, models.ScopeUser, false},
+		{"scope must match exactly", &models.APIToken{Scopes: "materials:read"}, "materials", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Get("/", func(c *fiber.Ctx) error {
+				if tt.token != nil {
+					c.Locals("api_token", *tt.token)
+				}
+				if tokenAllows(c, tt.scope) {
+					return c.SendStatus(fiber.StatusOK)
+				}
+				return c.SendStatus(fiber.StatusForbidden)
+			})
+			resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if allows := resp.StatusCode == fiber.StatusOK; allows != tt.allows {
+				t.Errorf("tokenAllows(%q) = %v, want %v", tt.scope, allows, tt.allows)
+			}
+		})
+	}
+}
+
+func TestAPITokenScopesAndRevocation(t *testing.T) {
+	db := setupTest(t)
+	user := models.User{Name: "Sam", Email: "sam@example.com", Role: "purchasing_manager", Status: models.UserStatusActive}
+	db.Create(&user)
+	newToken := func(name string, scopes string, expiresAt time.Time, revoked bool) string {
+		raw := apiTokenPrefix + name
+		token := models.APIToken{UserID: user.ID, Name: name, TokenHash: utils.HashToken(raw), Scopes: scopes, ExpiresAt: expiresAt}
+		if revoked {
+			now := time.Now()
+			token.RevokedAt = &now
+		}
+		db.Create(&token)
+		return raw
+	}
+	future := time.Now().Add(time.Hour)
+	scoped := newToken("scoped", "user,"+models.PermMaterialsRead, future, false)
+	noUser := newToken("nouser", models.PermMaterialsRead, future, false)
+	expired := newToken("expired", "user,"+models.PermMaterialsRead, time.Now().Add(-time.Hour), false)
+	revoked := newToken("revoked", "user,"+models.PermMaterialsRead, future, true)
+
+	ok := func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) }
+	app := fiber.New()
+	app.Get("/me", RequireUser, ok)
+	app.Get("/materials", RequirePermission(models.PermMaterialsRead), ok)
+	app.Post("/materials", RequirePermission(models.PermMaterialsWrite), ok)
+	app.Put("/password", RequireUser, RequireInteractiveSession, ok)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		header string
+		want   int
+	}{
+		{"user scope", http.MethodGet, "/me", "Bearer " + scoped, fiber.StatusOK},
+		{"permission scope", http.MethodGet, "/materials", "Bearer " + scoped, fiber.StatusOK},
+		{"permission the role has but the token doesn't", http.MethodPost, "/materials", "Bearer " + scoped, fiber.StatusForbidden},
+		{"token without the user scope", http.MethodGet, "/me", "Bearer " + noUser, fiber.StatusForbidden},
+		{"account management", http.MethodPut, "/password", "Bearer " + scoped, fiber.StatusForbidden},
+		{"expired token", http.MethodGet, "/me", "Bearer " + expired, fiber.StatusUnauthorized},
+		{"revoked token", http.MethodGet, "/me", "Bearer " + revoked, fiber.StatusUnauthorized},
+		{"unknown token", http.MethodGet, "/me", "Bearer " + apiTokenPrefix + "unknown", fiber.StatusUnauthorized},
+		{"not a bearer header", http.MethodGet, "/me", "Basic " + scoped, fiber.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set(fiber.HeaderAuthorization, tt.header)
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.want {
+				t.Errorf("%s %s returned %d, want %d", tt.method, tt.path, resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
@@ -4,16 +4,15 @@ import (
 	"fmt"
 	"qp1/database"
 	"qp1/models"
+	"qp1/utils"
 	"strconv"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
-const secretKey = "secret"
-
 // Hello returns a simple "Hello world!!" message
 func Hello(c *fiber.Ctx) error {
 	return c.SendString("Hello world!!")
@@ -31,6 +30,27 @@ func Register(c *fiber.Ctx) error {
 
 	fmt.Println("User name: ", data["name"], "email", data["email"])
 
+	settings, err := getSettings(database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load settings",
+		})
+	}
+	if !settings.SelfRegistrationEnabled {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Registration is disabled, ask an administrator for an invitation",
+		})
+	}
+	// A pending account could never be activated without the verification email
+	if settings.RequireEmailVerification {
+		if _, err := utils.EmailConfigFromEnv(); err != nil {
+			fmt.Println("Registration refused:", err)
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+				"error": "Registration is unavailable, ask an administrator for an invitation",
+			})
+		}
+	}
+
 	// Check if the email already exists
 	var existingUser models.User
 	if err := database.DB.Where("email = ?", data["email"]).First(&existingUser).Error; err == nil {
@@ -39,11 +59,9 @@ func Register(c *fiber.Ctx) error {
 		})
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(data["password"]), bcrypt.DefaultCost)
+	hashedPassword, err := hashNewPassword(database.DB, nil, data["password"])
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to hash password",
-		})
+		return respondPasswordError(c, err)
 	}
 
 	fmt.Println("Creating User...")
@@ -52,13 +70,38 @@ func Register(c *fiber.Ctx) error {
 		Email:    data["email"],
 		Password: hashedPassword,
 		Role:     "user", // 默认注册为普通用户
+		Status:   models.UserStatusActive,
+	}
+	// With verification on, the account can't sign in until the emailed link is opened
+	if settings.RequireEmailVerification {
+		user.Status = models.UserStatusPending
 	}
-	if err := database.DB.Create(&user).Error; err != nil {
+	var token string
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		if user.Status != models.UserStatusPending {
+			return nil
+		}
+		token, err = issueUserToken(tx, user.ID, models.TokenPurposeEmailVerification, emailVerificationTTL, c.IP())
+		return err
+	})
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to create user",
 		})
 	}
 
+	if user.Status == models.UserStatusPending {
+		go sendVerificationEmail(*user, token)
+		fmt.Println("User registered, waiting for email verification")
+		return c.JSON(fiber.Map{
+			"message":                     "User registered, check your email to verify your address",
+			"email_verification_required": true,
+		})
+	}
+
 	fmt.Println("User registered successfully")
 	return c.JSON(fiber.Map{
 		"message": "User registered successfully",
@@ -76,54 +119,80 @@ func Login(c *fiber.Ctx) error {
 		})
 	}
 
-	fmt.Println("User email", data)
+	email := data["email"]
+	fmt.Println("User email", email)
+
+	// Refuse attempts while the account or the client IP is locked out or still has to wait
+	wait, err := loginRetryAfter(email, c.IP())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to check login attempts",
+		})
+	}
+	if wait > 0 {
+		return respondLoginThrottled(c, wait)
+	}
 
 	// Check if user exists
 	var user models.User
-	database.DB.Where("email = ?", data["email"]).First(&user)
+	database.DB.Where("email = ?", email).First(&user)
 	if user.ID == 0 {
 		fmt.Println("User not found")
+		if err := recordLoginFailure(email, c.IP(), nil); err != nil {
+			fmt.Println("Failed to record login failure:", err)
+		}
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"message": "Invalid credentials",
 		})
 	}
 
 	// Compare passwords
-	err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(data["password"]))
+	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(data["password"]))
 	if err != nil {
 		fmt.Println("Invalid Password:", err)
+		if err := recordLoginFailure(email, c.IP(), &user); err != nil {
+			fmt.Println("Failed to record login failure:", err)
+		}
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"message": "Invalid credentials",
 		})
 	}
 
-	fmt.Println("Generating JWT token")
-	// Generate JWT token
-	claims := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"sub":  strconv.Itoa(int(user.ID)),
-		"role": user.Role,                             // 登录时写入数据库中的role
-		"exp":  time.Now().Add(time.Hour * 24).Unix(), // Expires in 24 hours
-	})
-	token, err := claims.SignedString([]byte(secretKey))
-	if err != nil {
-		fmt.Println("Error generating token:", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to generate token",
+	// Pending accounts have to verify their email first
+	if user.Status == models.UserStatusPending {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error":                       "Please verify your email address before logging in",
+			"email_verification_required": true,
 		})
 	}
 
-	fmt.Println("Setting cookie")
+	// Rehash with the configured cost if the stored hash is weaker
+	upgradePasswordHash(&user, data["password"])
+
+	// With two-factor authentication the session is only started by LoginTwoFactor
+	if user.TOTPEnabled {
+		challenge, err := signTwoFactorChallenge(user)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to generate token",
+			})
+		}
+		return c.JSON(fiber.Map{
+			"message":             "Two-factor authentication required",
+			"two_factor_required": true,
+			"challenge":           challenge,
+		})
+	}
+	resetLoginFailures(email)
 
-	// Set JWT token in cookie
-	// In the Login function, around line 120-130
-	cookie := fiber.Cookie{
-		Name:     "jwt",
-		Value:    token,
-		Expires:  time.Now().Add(time.Hour * 24),
-		HTTPOnly: true,
-		Secure:   false, // Change this from true to false for local development
+	fmt.Println("Starting session")
+	// Issue a short-lived access token and a refresh token bound to a new server-side session
+	if err := startSession(c, user, false); err != nil {
+		fmt.Println("Error starting session:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to generate token",
+		})
 	}
-	c.Cookie(&cookie)
 
 	fmt.Println("Authentication successful, returning")
 	// Authentication successful, return success response
@@ -135,13 +204,8 @@ func Login(c *fiber.Ctx) error {
 func User(c *fiber.Ctx) error {
 	fmt.Println("Request to get user...")
 
-	// Retrieve JWT token from cookie
-	cookie := c.Cookies("jwt")
-
-	// Parse JWT token with claims
-	token, err := jwt.ParseWithClaims(cookie, &jwt.MapClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(secretKey), nil
-	})
+	// Verify the JWT cookie and its session
+	claims, err := authenticate(c)
 
 	// Handle token parsing errors
 	if err != nil {
@@ -151,17 +215,9 @@ func User(c *fiber.Ctx) error {
 		})
 	}
 
-	// Extract claims from token
-	claims, ok := token.Claims.(*jwt.MapClaims)
-	if !ok {
-		fmt.Println("Failed to parse claims")
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to parse claims",
-		})
-	}
-
 	// Extract user ID from claims
-	id, _ := strconv.Atoi((*claims)["sub"].(string))
+	sub, _ := claims["sub"].(string)
+	id, _ := strconv.Atoi(sub)
 	user := models.User{ID: uint(id)}
 
 	// Query user from database using ID
@@ -187,15 +243,17 @@ func User(c *fiber.Ctx) error {
 func Logout(c *fiber.Ctx) error {
 	fmt.Println("Received a logout request")
 
-	// Clear JWT token by setting an empty value and expired time in the cookie
-	cookie := fiber.Cookie{
-		Name:     "jwt",
-		Value:    "",
-		Expires:  time.Now().Add(-time.Hour), // Expired 1 hour ago
-		HTTPOnly: true,
-		Secure:   true,
+	// Revoke the server-side session so the refresh token can no longer be used
+	if claims, err := utils.ParseJWT(c.Cookies("jwt")); err == nil {
+		if sid, ok := claims["sid"].(string); ok {
+			database.DB.Model(&models.Session{}).Where("id = ? AND revoked_at IS NULL", sid).Update("revoked_at", time.Now())
+		}
+	} else if refreshToken := c.Cookies(refreshCookieName); refreshToken != "" {
+		database.DB.Model(&models.Session{}).Where("refresh_token_hash = ? AND revoked_at IS NULL", utils.HashToken(refreshToken)).Update("revoked_at", time.Now())
 	}
-	c.Cookie(&cookie)
+
+	// Clear the access and refresh token cookies
+	clearSessionCookies(c)
 
 	// Return success response indicating logout was successful
 	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
@@ -203,32 +261,6 @@ func Logout(c *fiber.Ctx) error {
 	})
 }
 
-// ResetPassword 用户通过email重置密码
-func ResetPassword(c *fiber.Ctx) error {
-	var data map[string]string
-	if err := c.BodyParser(&data); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "请求体解析失败"})
-	}
-	email := data["email"]
-	newPassword := data["new_password"]
-	if email == "" || newPassword == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "邮箱和新密码不能为空"})
-	}
-	var user models.User
-	if err := database.DB.Where("email = ?", email).First(&user).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "用户不存在"})
-	}
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "密码加密失败"})
-	}
-	user.Password = hashedPassword
-	if err := database.DB.Save(&user).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "密码重置失败"})
-	}
-	return c.JSON(fiber.Map{"message": "密码重置成功"})
-}
-
 // ChangePassword allows a logged-in user to change their password
 func ChangePassword(c *fiber.Ctx) error {
 	type Request struct {
@@ -240,9 +272,9 @@ func ChangePassword(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
 	}
 
-	// Get user from context/session (adjust as per your implementation)
-	user, ok := c.Locals("user").(*models.User)
-	if !ok || user == nil {
+	// Get user from context/session
+	user, ok := c.Locals("user").(models.User)
+	if !ok {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
 	}
 
@@ -251,16 +283,16 @@ func ChangePassword(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Old password is incorrect"})
 	}
 
-	// Hash new password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(body.NewPassword), bcrypt.DefaultCost)
+	// Update password in DB and sign out the user's other sessions
+	sessionID, _ := c.Locals("session_id").(uint)
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := setUserPassword(tx, &user, body.NewPassword); err != nil {
+			return err
+		}
+		return revokeUserSessions(tx, user.ID, sessionID)
+	})
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to hash password"})
-	}
-
-	// Update password in DB
-	user.Password = hashedPassword
-	if err := database.DB.Save(user).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update password"})
+		return respondPasswordError(c, err)
 	}
 
 	return c.JSON(fiber.Map{"message": "Password changed successfully"})
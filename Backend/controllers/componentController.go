@@ -1,24 +1,360 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
 	"qp1/database"
 	"qp1/models"
-	"strconv"
+	"qp1/utils"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
 // CreateComponentRequest represents the request structure for creating a component
 type CreateComponentRequest struct {
-	Name        string                   `json:"name"`
-	Description string                   `json:"description"`
-	Materials   []ComponentMaterialInput `json:"materials"`
+	Name            string                   `json:"name"`
+	Description     string                   `json:"description"`
+	Materials       []ComponentMaterialInput `json:"materials"`
+	Labor           []ComponentLaborInput    `json:"labor"`
+	OverheadRateIDs []uint                   `json:"overhead_rate_ids"`
 }
 
 // ComponentMaterialInput represents material input for component creation
 type ComponentMaterialInput struct {
-	MaterialID uint    `json:"material_id"`
-	Quantity   float64 `json:"quantity"`
+	MaterialID   uint     `json:"material_id"`
+	Quantity     float64  `json:"quantity"`
+	Unit         string   `json:"unit"`          // unit of the quantity when it isn't the material's unit
+	WastePercent *float64 `json:"waste_percent"` // overrides the material's waste when set
+}
+
+// ComponentLaborInput is a labor line of a component: quantity units (usually hours) of a labor rate
+type ComponentLaborInput struct {
+	LaborRateID uint    `json:"labor_rate_id"`
+	Quantity    float64 `json:"quantity"`
+}
+
+// errMaterialNotFound is returned when a component references a material that does not exist
+var errMaterialNotFound = errors.New("material not found")
+
+// errLaborRateNotFound and errOverheadRateNotFound are returned when a component references a rate that does not exist
+var (
+	errLaborRateNotFound    = errors.New("labor rate not found")
+	errOverheadRateNotFound = errors.New("overhead rate not found")
+)
+
+// errInvalidLaborQuantity is returned for a labor line with a negative quantity
+var errInvalidLaborQuantity = errors.New("labor quantity must not be negative")
+
+// errInvalidWastePercent is returned for a material line with a waste outside 0 to 100 percent
+var errInvalidWastePercent = errors.New("waste percent must be between 0 and 100")
+
+// componentLineError maps the errors of replacing component lines to a response
+func componentLineError(c *fiber.Ctx, err error, fallback string) error {
+	switch {
+	case errors.Is(err, errMaterialNotFound):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Material not found"})
+	case errors.Is(err, errLaborRateNotFound):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Labor rate not found"})
+	case errors.Is(err, errOverheadRateNotFound):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Overhead rate not found"})
+	case errors.Is(err, errInvalidLaborQuantity):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Labor quantity must not be negative"})
+	case errors.Is(err, errUnknownUnit), errors.Is(err, errIncompatibleUnits):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Material unit: " + err.Error()})
+	case errors.Is(err, errInvalidWastePercent):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Waste percent must be between 0 and 100"})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fallback})
+}
+
+// preloadComponentLines loads the material, labor and overhead lines of components
+func preloadComponentLines(db *gorm.DB) *gorm.DB {
+	return db.Preload("Materials.Material").Preload("Labor.LaborRate").Preload("Overheads.OverheadRate")
+}
+
+// replaceComponentMaterials replaces the bill of materials of a component and recalculates its total cost.
+// The component itself is not saved.
+func replaceComponentMaterials(tx *gorm.DB, component *models.Component, inputs []ComponentMaterialInput) error {
+	// Delete existing component-material relationships
+	if err := tx.Where("component_id = ?", component.ID).Delete(&models.ComponentMaterial{}).Error; err != nil {
+		return err
+	}
+
+	units, err := loadUnits(tx)
+	if err != nil {
+		return err
+	}
+	for _, materialInput := range inputs {
+		if w := materialInput.WastePercent; w != nil && (*w < 0 || *w > 100) {
+			return errInvalidWastePercent
+		}
+
+		// Verify material exists
+		var material models.Material
+		if err := tx.First(&material, materialInput.MaterialID).Error; err != nil {
+			return fmt.Errorf("%w: id %d", errMaterialNotFound, materialInput.MaterialID)
+		}
+		unit := strings.TrimSpace(materialInput.Unit)
+		if sameUnit(unit, material.Unit) {
+			unit = ""
+		}
+		if err := units.checkLineUnit(unit, material); err != nil {
+			return fmt.Errorf("%s: %w", material.Name, err)
+		}
+
+		// Create component-material relationship
+		componentMaterial := models.ComponentMaterial{
+			ComponentID:  component.ID,
+			MaterialID:   materialInput.MaterialID,
+			Quantity:     materialInput.Quantity,
+			Unit:         unit,
+			WastePercent: materialInput.WastePercent,
+		}
+		if err := tx.Create(&componentMaterial).Error; err != nil {
+			return err
+		}
+	}
+
+	return rollUpComponentCost(tx, component)
+}
+
+// replaceComponentLabor replaces the labor lines of a component and recalculates its total cost.
+// The component itself is not saved.
+func replaceComponentLabor(tx *gorm.DB, component *models.Component, inputs []ComponentLaborInput) error {
+	if err := tx.Where("component_id = ?", component.ID).Delete(&models.ComponentLabor{}).Error; err != nil {
+		return err
+	}
+	for _, input := range inputs {
+		if input.Quantity < 0 {
+			return errInvalidLaborQuantity
+		}
+		var rate models.LaborRate
+		if err := tx.First(&rate, input.LaborRateID).Error; err != nil {
+			return fmt.Errorf("%w: id %d", errLaborRateNotFound, input.LaborRateID)
+		}
+		line := models.ComponentLabor{ComponentID: component.ID, LaborRateID: input.LaborRateID, Quantity: input.Quantity}
+		if err := tx.Create(&line).Error; err != nil {
+			return err
+		}
+	}
+	return rollUpComponentCost(tx, component)
+}
+
+// replaceComponentOverheads replaces the overhead rates applied to a component and recalculates its total cost.
+// The component itself is not saved.
+func replaceComponentOverheads(tx *gorm.DB, component *models.Component, rateIDs []uint) error {
+	if err := tx.Where("component_id = ?", component.ID).Delete(&models.ComponentOverhead{}).Error; err != nil {
+		return err
+	}
+	seen := make(map[uint]bool)
+	for _, id := range rateIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		var rate models.OverheadRate
+		if err := tx.First(&rate, id).Error; err != nil {
+			return fmt.Errorf("%w: id %d", errOverheadRateNotFound, id)
+		}
+		if err := tx.Create(&models.ComponentOverhead{ComponentID: component.ID, OverheadRateID: id}).Error; err != nil {
+			return err
+		}
+	}
+	return rollUpComponentCost(tx, component)
+}
+
+// rollUpComponentCost sets the material, labor, overhead and total cost of a component from its lines
+func rollUpComponentCost(tx *gorm.DB, component *models.Component) error {
+	bom, err := componentBOM(tx, component.ID)
+	if err != nil {
+		return err
+	}
+	component.MaterialCost = bom.MaterialCost
+	component.LaborCost = bom.LaborCost
+	component.OverheadCost = bom.OverheadCost
+	component.TotalCost = bom.TotalCost
+	return nil
+}
+
+// ComponentBOM is the cost breakdown of a component with its material, labor and overhead lines
+type ComponentBOM struct {
+	ComponentID  uint              `json:"component_id"`
+	Materials    []BOMMaterialLine `json:"materials"`
+	Labor        []BOMLaborLine    `json:"labor"`
+	Overheads    []BOMOverheadLine `json:"overheads"`
+	MaterialCost float64           `json:"material_cost"`
+	LaborCost    float64           `json:"labor_cost"`
+	OverheadCost float64           `json:"overhead_cost"`
+	TotalCost    float64           `json:"total_cost"`
+}
+
+// BOMMaterialLine is a material line of a component priced by the material's pricing policy. Its cost is for
+// the gross quantity: the quantity used converted to the material's unit, plus cutting waste.
+type BOMMaterialLine struct {
+	MaterialID       uint    `json:"material_id"`
+	Name             string  `json:"name"`
+	Quantity         float64 `json:"quantity"`
+	Unit             string  `json:"unit"` // unit of Quantity
+	MaterialUnit     string  `json:"material_unit"`
+	MaterialQuantity float64 `json:"material_quantity"` // Quantity in MaterialUnit
+	WastePercent     float64 `json:"waste_percent"`
+	GrossQuantity    float64 `json:"gross_quantity"` // in MaterialUnit
+	UnitCost         float64 `json:"unit_cost"`      // per MaterialUnit
+	SupplierID       *uint   `json:"supplier_id"`    // supplier whose price was used, nil for Material.UnitCost
+	TotalCost        float64 `json:"total_cost"`
+}
+
+// BOMLaborLine is a labor line of a component
+type BOMLaborLine struct {
+	LaborRateID uint    `json:"labor_rate_id"`
+	Name        string  `json:"name"`
+	Unit        string  `json:"unit"`
+	Quantity    float64 `json:"quantity"`
+	Rate        float64 `json:"rate"`
+	TotalCost   float64 `json:"total_cost"`
+}
+
+// BOMOverheadLine is an overhead of a component, charged in percent of its material and labor cost
+type BOMOverheadLine struct {
+	OverheadRateID uint    `json:"overhead_rate_id"`
+	Name           string  `json:"name"`
+	Percent        float64 `json:"percent"`
+	TotalCost      float64 `json:"total_cost"`
+}
+
+// wastePercent returns the cutting waste of a material line: its own, or else its material's.
+// Material must be loaded.
+func wastePercent(line models.ComponentMaterial) float64 {
+	if line.WastePercent != nil {
+		return *line.WastePercent
+	}
+	return line.Material.WastePercent
+}
+
+// grossQuantity returns the quantity of a material line in the material's unit, including cutting waste
+func grossQuantity(line models.ComponentMaterial, units unitRegistry) float64 {
+	return utils.ApplyWaste(units.materialQuantity(line), wastePercent(line))
+}
+
+// componentBOM prices the lines of a component: materials including waste by their pricing policy, labor by the
+// current rates, and overheads in percent of the material and labor cost
+func componentBOM(tx *gorm.DB, componentID uint) (ComponentBOM, error) {
+	bom := ComponentBOM{
+		ComponentID: componentID,
+		Materials:   []BOMMaterialLine{},
+		Labor:       []BOMLaborLine{},
+		Overheads:   []BOMOverheadLine{},
+	}
+
+	units, err := loadUnits(tx)
+	if err != nil {
+		return bom, err
+	}
+	settings, err := getSettings(tx)
+	if err != nil {
+		return bom, err
+	}
+	var materials []models.ComponentMaterial
+	if err := tx.Preload("Material.SupplierPrices").Where("component_id = ?", componentID).Find(&materials).Error; err != nil {
+		return bom, err
+	}
+	for _, line := range materials {
+		unitCost, supplierPrice := utils.ResolveMaterialPrice(line.Material, settings.Currency)
+		gross := grossQuantity(line, units)
+		bomLine := BOMMaterialLine{
+			MaterialID:       line.MaterialID,
+			Name:             line.Material.Name,
+			Quantity:         line.Quantity,
+			Unit:             line.Unit,
+			MaterialUnit:     line.Material.Unit,
+			MaterialQuantity: units.materialQuantity(line),
+			WastePercent:     wastePercent(line),
+			GrossQuantity:    gross,
+			UnitCost:         unitCost,
+			TotalCost:        unitCost * gross,
+		}
+		if bomLine.Unit == "" {
+			bomLine.Unit = line.Material.Unit
+		}
+		if supplierPrice != nil {
+			bomLine.SupplierID = &supplierPrice.SupplierID
+		}
+		bom.Materials = append(bom.Materials, bomLine)
+		bom.MaterialCost += bomLine.TotalCost
+	}
+
+	var labor []models.ComponentLabor
+	if err := tx.Preload("LaborRate").Where("component_id = ?", componentID).Find(&labor).Error; err != nil {
+		return bom, err
+	}
+	for _, line := range labor {
+		bomLine := BOMLaborLine{
+			LaborRateID: line.LaborRateID,
+			Name:        line.LaborRate.Name,
+			Unit:        line.LaborRate.Unit,
+			Quantity:    line.Quantity,
+			Rate:        line.LaborRate.Rate,
+			TotalCost:   line.LaborRate.Rate * line.Quantity,
+		}
+		bom.Labor = append(bom.Labor, bomLine)
+		bom.LaborCost += bomLine.TotalCost
+	}
+
+	var overheads []models.ComponentOverhead
+	if err := tx.Preload("OverheadRate").Where("component_id = ?", componentID).Find(&overheads).Error; err != nil {
+		return bom, err
+	}
+	base := bom.MaterialCost + bom.LaborCost
+	for _, line := range overheads {
+		bomLine := BOMOverheadLine{
+			OverheadRateID: line.OverheadRateID,
+			Name:           line.OverheadRate.Name,
+			Percent:        line.OverheadRate.Percent,
+			TotalCost:      base * line.OverheadRate.Percent / 100,
+		}
+		bom.Overheads = append(bom.Overheads, bomLine)
+		bom.OverheadCost += bomLine.TotalCost
+	}
+
+	bom.TotalCost = base + bom.OverheadCost
+	return bom, nil
+}
+
+// recalculateComponents refreshes the stored costs of components
+func recalculateComponents(tx *gorm.DB, componentIDs []uint) error {
+	for _, componentID := range componentIDs {
+		bom, err := componentBOM(tx, componentID)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Component{}).Where("id = ?", componentID).Updates(map[string]interface{}{
+			"material_cost": bom.MaterialCost,
+			"labor_cost":    bom.LaborCost,
+			"overhead_cost": bom.OverheadCost,
+			"total_cost":    bom.TotalCost,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recalculateComponentsUsing refreshes the stored costs of every component with a line in the table of model
+// whose column equals id
+func recalculateComponentsUsing(tx *gorm.DB, model interface{}, column string, id uint) error {
+	var componentIDs []uint
+	if err := tx.Model(model).Where(column+" = ?", id).Distinct().Pluck("component_id", &componentIDs).Error; err != nil {
+		return err
+	}
+	return recalculateComponents(tx, componentIDs)
+}
+
+// recalculateComponentsUsingMaterial refreshes the stored total cost of every component that uses a material,
+// so quotations keep pricing from current material and supplier prices
+func recalculateComponentsUsingMaterial(tx *gorm.DB, materialID uint) error {
+	return recalculateComponentsUsing(tx, &models.ComponentMaterial{}, "material_id", materialID)
 }
 
 // CreateComponent creates a new component with its materials
@@ -51,34 +387,21 @@ func CreateComponent(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create component"})
 	}
 
-	// Add materials to the component and calculate total cost
-	totalCost := 0.0
-	for _, materialInput := range req.Materials {
-		// Verify material exists
-		var material models.Material
-		if err := tx.First(&material, materialInput.MaterialID).Error; err != nil {
-			tx.Rollback()
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Material not found"})
-		}
-
-		// Create component-material relationship
-		componentMaterial := models.ComponentMaterial{
-			ComponentID: component.ID,
-			MaterialID:  materialInput.MaterialID,
-			Quantity:    materialInput.Quantity,
-		}
-
-		if err := tx.Create(&componentMaterial).Error; err != nil {
-			tx.Rollback()
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to add material to component"})
-		}
-
-		// Calculate total cost
-		totalCost += material.UnitCost * materialInput.Quantity
+	// Add materials, labor and overheads to the component and calculate total cost
+	if err := replaceComponentMaterials(tx, &component, req.Materials); err != nil {
+		tx.Rollback()
+		return componentLineError(c, err, "Failed to add material to component")
+	}
+	if err := replaceComponentLabor(tx, &component, req.Labor); err != nil {
+		tx.Rollback()
+		return componentLineError(c, err, "Failed to add labor to component")
+	}
+	if err := replaceComponentOverheads(tx, &component, req.OverheadRateIDs); err != nil {
+		tx.Rollback()
+		return componentLineError(c, err, "Failed to add overheads to component")
 	}
 
 	// Update component with total cost
-	component.TotalCost = totalCost
 	if err := tx.Save(&component).Error; err != nil {
 		tx.Rollback()
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update component cost"})
@@ -87,60 +410,70 @@ func CreateComponent(c *fiber.Ctx) error {
 	// Commit transaction
 	tx.Commit()
 
-	// Fetch the complete component with materials
+	// Fetch the complete component with its lines
 	var completeComponent models.Component
-	database.DB.Preload("Materials.Material").First(&completeComponent, component.ID)
+	database.DB.Scopes(preloadComponentLines).First(&completeComponent, component.ID)
 
 	return c.JSON(completeComponent)
 }
 
-// ListComponents retrieves all components with their materials (with pagination)
+var componentListSpec = listSpec{
+	Fields: map[string]listField{
+		"id":         {Column: "id", Kind: fieldNumber, Sort: true, Filter: true},
+		"name":       {Column: "name", Kind: fieldString, Sort: true, Filter: true},
+		"total_cost": {Column: "total_cost", Kind: fieldNumber, Sort: true, Filter: true},
+		"created_at": {Column: "created_at", Kind: fieldTime, Sort: true, Filter: true},
+		"updated_at": {Column: "updated_at", Kind: fieldTime, Sort: true, Filter: true},
+	},
+	DefaultSort: "id",
+}
+
+// ListComponents retrieves components with their materials, with sorting, filtering and pagination
 func ListComponents(c *fiber.Ctx) error {
-	page, _ := strconv.Atoi(c.Query("page", "1"))
-	pageSize, _ := strconv.Atoi(c.Query("pageSize", "10"))
-	if page < 1 {
-		page = 1
-	}
-	if pageSize < 1 {
-		pageSize = 10
+	lq, err := parseListQuery(c, componentListSpec)
+	if err != nil {
+		return respondListError(c, err)
 	}
-
-	var total int64
-	var components []models.Component
-
-	database.DB.Model(&models.Component{}).Count(&total)
-	if err := database.DB.
-		Preload("Materials.Material").
-		Limit(pageSize).
-		Offset((page - 1) * pageSize).
-		Find(&components).Error; err != nil {
+	components := []models.Component{}
+	p, err := lq.find(database.DB.Model(&models.Component{}), &components, preloadComponentLines)
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch components"})
 	}
-
-	return c.JSON(fiber.Map{
-		"data":       components,
-		"total":      total,
-		"page":       page,
-		"pageSize":   pageSize,
-		"totalPages": (total + int64(pageSize) - 1) / int64(pageSize),
-	})
+	return respondList(c, components, p)
 }
 
 // GetComponent retrieves a specific component with its materials
 func GetComponent(c *fiber.Ctx) error {
 	id := c.Params("id")
 	var component models.Component
-	if err := database.DB.Preload("Materials.Material").First(&component, id).Error; err != nil {
+	if err := database.DB.Scopes(preloadComponentLines).First(&component, id).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Component not found"})
 	}
 	return c.JSON(component)
 }
 
+// GetComponentBOM returns the cost breakdown of a component with its material, labor and overhead lines
+// priced at current prices and rates
+func GetComponentBOM(c *fiber.Ctx) error {
+	var component models.Component
+	if err := database.DB.First(&component, c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Component not found"})
+	}
+	bom, err := componentBOM(database.DB, component.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to calculate component cost"})
+	}
+	return c.JSON(fiber.Map{"component": component, "bom": bom})
+}
+
 // UpdateComponentRequest represents the request structure for updating a component
+// Labor and overheads are replaced when given, an empty list removes them.
 type UpdateComponentRequest struct {
-	Name        string                   `json:"name"`
-	Description string                   `json:"description"`
-	Materials   []ComponentMaterialInput `json:"materials"`
+	Name            string                   `json:"name"`
+	Description     string                   `json:"description"`
+	Materials       []ComponentMaterialInput `json:"materials"`
+	Labor           *[]ComponentLaborInput   `json:"labor"`
+	OverheadRateIDs *[]uint                  `json:"overhead_rate_ids"`
 }
 
 // UpdateComponent updates an existing component and its materials
@@ -160,6 +493,7 @@ func UpdateComponent(c *fiber.Ctx) error {
 	tx := database.DB.Begin()
 
 	// Update basic component info
+	renamed := req.Name != "" && req.Name != component.Name
 	if req.Name != "" {
 		component.Name = req.Name
 	}
@@ -169,39 +503,22 @@ func UpdateComponent(c *fiber.Ctx) error {
 
 	// If materials are provided, update them
 	if len(req.Materials) > 0 {
-		// Delete existing component-material relationships
-		if err := tx.Where("component_id = ?", component.ID).Delete(&models.ComponentMaterial{}).Error; err != nil {
+		if err := replaceComponentMaterials(tx, &component, req.Materials); err != nil {
 			tx.Rollback()
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update component materials"})
+			return componentLineError(c, err, "Failed to update component materials")
 		}
-
-		// Add new materials and calculate total cost
-		totalCost := 0.0
-		for _, materialInput := range req.Materials {
-			// Verify material exists
-			var material models.Material
-			if err := tx.First(&material, materialInput.MaterialID).Error; err != nil {
-				tx.Rollback()
-				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Material not found"})
-			}
-
-			// Create new component-material relationship
-			componentMaterial := models.ComponentMaterial{
-				ComponentID: component.ID,
-				MaterialID:  materialInput.MaterialID,
-				Quantity:    materialInput.Quantity,
-			}
-
-			if err := tx.Create(&componentMaterial).Error; err != nil {
-				tx.Rollback()
-				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to add material to component"})
-			}
-
-			// Calculate total cost
-			totalCost += material.UnitCost * materialInput.Quantity
+	}
+	if req.Labor != nil {
+		if err := replaceComponentLabor(tx, &component, *req.Labor); err != nil {
+			tx.Rollback()
+			return componentLineError(c, err, "Failed to update component labor")
+		}
+	}
+	if req.OverheadRateIDs != nil {
+		if err := replaceComponentOverheads(tx, &component, *req.OverheadRateIDs); err != nil {
+			tx.Rollback()
+			return componentLineError(c, err, "Failed to update component overheads")
 		}
-
-		component.TotalCost = totalCost
 	}
 
 	// Save the updated component
@@ -209,13 +526,20 @@ func UpdateComponent(c *fiber.Ctx) error {
 		tx.Rollback()
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update component"})
 	}
+	// Quotations are searched by the names of their components
+	if renamed {
+		if err := database.RefreshQuotationSearchTextForComponent(tx, component.ID); err != nil {
+			tx.Rollback()
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update component"})
+		}
+	}
 
 	// Commit transaction
 	tx.Commit()
 
 	// Fetch the complete updated component
 	var updatedComponent models.Component
-	database.DB.Preload("Materials.Material").First(&updatedComponent, component.ID)
+	database.DB.Scopes(preloadComponentLines).First(&updatedComponent, component.ID)
 
 	return c.JSON(updatedComponent)
 }
@@ -233,6 +557,16 @@ func DeleteComponent(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete component materials"})
 	}
 
+	// Delete labor and overhead lines
+	if err := tx.Where("component_id = ?", id).Delete(&models.ComponentLabor{}).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete component labor"})
+	}
+	if err := tx.Where("component_id = ?", id).Delete(&models.ComponentOverhead{}).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete component overheads"})
+	}
+
 	// Delete the component
 	if err := tx.Delete(&models.Component{}, id).Error; err != nil {
 		tx.Rollback()
@@ -251,7 +585,7 @@ func SearchComponents(c *fiber.Ctx) error {
 	description := c.Query("description")
 
 	var components []models.Component
-	query := database.DB.Preload("Materials.Material")
+	query := database.DB.Scopes(preloadComponentLines)
 
 	if name != "" {
 		query = query.Where("name LIKE ?", "%"+name+"%")
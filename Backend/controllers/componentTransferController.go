@@ -0,0 +1,344 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"qp1/database"
+	"qp1/models"
+	"qp1/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// componentTransferVersion is bumped whenever the portable component format changes incompatibly
+const componentTransferVersion = 1
+
+// ComponentCatalog is the portable JSON document used to move components between environments
+type ComponentCatalog struct {
+	Version    int                 `json:"version"`
+	ExportedAt time.Time           `json:"exported_at"`
+	Components []ComponentTransfer `json:"components"`
+}
+
+// ComponentTransfer describes a component and its bill of materials without database IDs
+type ComponentTransfer struct {
+	Name        string                      `json:"name"`
+	Description string                      `json:"description"`
+	Materials   []ComponentMaterialTransfer `json:"materials"`
+}
+
+// ComponentMaterialTransfer references a material by SKU or name instead of ID; the SKU wins when both are given
+type ComponentMaterialTransfer struct {
+	Material     string   `json:"material"`
+	SKU          string   `json:"sku,omitempty"`
+	Quantity     float64  `json:"quantity"`
+	Unit         string   `json:"unit,omitempty"`          // unit of the quantity, the material's unit when empty
+	WastePercent *float64 `json:"waste_percent,omitempty"` // overrides the material's waste
+}
+
+// ComponentImportResult reports the outcome for one imported component
+type ComponentImportResult struct {
+	Name   string   `json:"name"`
+	Action string   `json:"action"` // create or update
+	Errors []string `json:"errors,omitempty"`
+}
+
+var componentCSVHeader = []string{"component_name", "component_description", "material", "material_sku", "quantity", "unit", "waste_percent"}
+
+// ExportComponents exports all components with their bill of materials as JSON (default) or CSV
+func ExportComponents(c *fiber.Ctx) error {
+	format := c.Query("format", "json")
+	if format != "json" && format != utils.ExportFormatCSV {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Unsupported format, expected json or csv"})
+	}
+
+	if format == utils.ExportFormatCSV {
+		header := componentCSVHeader
+		return streamExport(c, format, "components", header, func(write func(row ...interface{}) error) error {
+			var batch []models.Component
+			return database.DB.Preload("Materials.Material").FindInBatches(&batch, exportBatchSize, func(tx *gorm.DB, _ int) error {
+				for _, component := range batch {
+					for _, line := range component.Materials {
+						var waste interface{} // empty unless the line overrides the material's waste
+						if line.WastePercent != nil {
+							waste = *line.WastePercent
+						}
+						if err := write(component.Name, component.Description, line.Material.Name, stringValue(line.Material.SKU), line.Quantity, line.Unit, waste); err != nil {
+							return err
+						}
+					}
+				}
+				return nil
+			}).Error
+		})
+	}
+
+	var components []models.Component
+	if err := database.DB.Preload("Materials.Material").Order("name").Find(&components).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch components"})
+	}
+	catalog := ComponentCatalog{Version: componentTransferVersion, ExportedAt: time.Now()}
+	for _, component := range components {
+		transfer := ComponentTransfer{Name: component.Name, Description: component.Description}
+		for _, line := range component.Materials {
+			transfer.Materials = append(transfer.Materials, ComponentMaterialTransfer{
+				Material:     line.Material.Name,
+				SKU:          stringValue(line.Material.SKU),
+				Quantity:     line.Quantity,
+				Unit:         line.Unit,
+				WastePercent: line.WastePercent,
+			})
+		}
+		catalog.Components = append(catalog.Components, transfer)
+	}
+	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=components_%s.json", time.Now().Format("20060102_150405")))
+	return c.JSON(catalog)
+}
+
+// ImportComponents creates or replaces components from a JSON catalog (request body or uploaded .json file)
+// or an uploaded CSV file. Components are matched by name and materials are resolved by SKU or name.
+// Nothing is saved unless every component resolves; ?dry_run=true only reports the planned changes.
+func ImportComponents(c *fiber.Ctx) error {
+	dryRun := c.QueryBool("dry_run", false)
+
+	transfers, err := readComponentTransfers(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if len(transfers) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "No components to import"})
+	}
+
+	units, err := loadUnits(database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to load units"})
+	}
+	results := make([]ComponentImportResult, len(transfers))
+	resolved := make([][]ComponentMaterialInput, len(transfers))
+	existing := make([]*models.Component, len(transfers))
+	materialCache := make(map[string]*models.Material)
+	seen := make(map[string]bool)
+	hasErrors := false
+	created, updated := 0, 0
+
+	for i, transfer := range transfers {
+		result := ComponentImportResult{Name: transfer.Name}
+		key := strings.ToLower(transfer.Name)
+		if transfer.Name == "" {
+			result.Errors = append(result.Errors, "Component name is required")
+		} else if seen[key] {
+			result.Errors = append(result.Errors, "Duplicate component name in import")
+		}
+		seen[key] = true
+		if len(transfer.Materials) == 0 {
+			result.Errors = append(result.Errors, "At least one material is required")
+		}
+
+		for _, line := range transfer.Materials {
+			material, err := lookupTransferMaterial(line, materialCache)
+			if err != nil {
+				result.Errors = append(result.Errors, err.Error())
+				continue
+			}
+			if line.Quantity <= 0 {
+				result.Errors = append(result.Errors, fmt.Sprintf("Quantity for material %q must be greater than 0", line.Material))
+				continue
+			}
+			if w := line.WastePercent; w != nil && (*w < 0 || *w > 100) {
+				result.Errors = append(result.Errors, fmt.Sprintf("Waste percent for material %q must be between 0 and 100", line.Material))
+				continue
+			}
+			if err := units.checkLineUnit(strings.TrimSpace(line.Unit), *material); err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("Unit for material %q: %v", line.Material, err))
+				continue
+			}
+			resolved[i] = append(resolved[i], ComponentMaterialInput{MaterialID: material.ID, Quantity: line.Quantity, Unit: line.Unit, WastePercent: line.WastePercent})
+		}
+
+		if transfer.Name != "" {
+			var component models.Component
+			err := database.DB.Unscoped().Where("name = ?", transfer.Name).First(&component).Error
+			if err == nil {
+				existing[i] = &component
+			} else if err != gorm.ErrRecordNotFound {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to look up components"})
+			}
+		}
+		if existing[i] != nil {
+			result.Action = "update"
+			updated++
+		} else {
+			result.Action = "create"
+			created++
+		}
+		if len(result.Errors) > 0 {
+			hasErrors = true
+		}
+		results[i] = result
+	}
+
+	if hasErrors {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":      "Import has errors, no changes were saved",
+			"components": results,
+		})
+	}
+
+	summary := fiber.Map{
+		"dry_run":    dryRun,
+		"created":    created,
+		"updated":    updated,
+		"components": results,
+	}
+	if dryRun {
+		return c.JSON(summary)
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		for i, transfer := range transfers {
+			component := existing[i]
+			if component == nil {
+				component = &models.Component{Name: transfer.Name}
+				if err := tx.Create(component).Error; err != nil {
+					return fmt.Errorf("%s: %v", transfer.Name, err)
+				}
+			}
+			component.Description = transfer.Description
+			component.DeletedAt = gorm.DeletedAt{}
+			if err := replaceComponentMaterials(tx, component, resolved[i]); err != nil {
+				return fmt.Errorf("%s: %v", transfer.Name, err)
+			}
+			if err := tx.Unscoped().Save(component).Error; err != nil {
+				return fmt.Errorf("%s: %v", transfer.Name, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Import failed, no changes were saved: " + err.Error()})
+	}
+	return c.JSON(summary)
+}
+
+// lookupTransferMaterial resolves a material reference by SKU or name, caching lookups across the import
+func lookupTransferMaterial(line ComponentMaterialTransfer, cache map[string]*models.Material) (*models.Material, error) {
+	column, value := "sku", strings.TrimSpace(line.SKU)
+	if value == "" {
+		column, value = "name", strings.TrimSpace(line.Material)
+	}
+	if value == "" {
+		return nil, fmt.Errorf("Material reference is required")
+	}
+	key := column + ":" + strings.ToLower(value)
+	if material, ok := cache[key]; ok {
+		return material, nil
+	}
+	var material models.Material
+	if err := database.DB.Where(column+" = ?", value).First(&material).Error; err != nil {
+		if column == "sku" {
+			return nil, fmt.Errorf("Material with SKU %q not found", value)
+		}
+		return nil, fmt.Errorf("Material %q not found", value)
+	}
+	cache[key] = &material
+	return &material, nil
+}
+
+// readComponentTransfers accepts a JSON body or a multipart upload of a .json or .csv file
+func readComponentTransfers(c *fiber.Ctx) ([]ComponentTransfer, error) {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var catalog ComponentCatalog
+		if err := json.Unmarshal(c.Body(), &catalog); err != nil {
+			return nil, fmt.Errorf("Failed to parse request body")
+		}
+		return catalog.Components, nil
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read uploaded file")
+	}
+	defer file.Close()
+
+	if strings.ToLower(filepath.Ext(fileHeader.Filename)) == ".json" {
+		data, err := io.ReadAll(file)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read uploaded file")
+		}
+		var catalog ComponentCatalog
+		if err := json.Unmarshal(data, &catalog); err != nil {
+			return nil, fmt.Errorf("Failed to parse JSON file: %v", err)
+		}
+		return catalog.Components, nil
+	}
+
+	rows, err := utils.ReadTable(file, fileHeader.Filename)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse file: %v", err)
+	}
+	return componentTransfersFromRows(rows)
+}
+
+// componentTransfersFromRows groups CSV rows (one per bill-of-materials line) by component name
+func componentTransfersFromRows(rows [][]string) ([]ComponentTransfer, error) {
+	if len(rows) < 2 {
+		return nil, fmt.Errorf("File has no data rows")
+	}
+	index := utils.HeaderIndex(rows[0])
+	for _, column := range []string{"component_name", "quantity"} {
+		if _, ok := index[column]; !ok {
+			return nil, fmt.Errorf("Missing required column: %s", column)
+		}
+	}
+	_, hasName := index["material"]
+	_, hasSKU := index["material_sku"]
+	if !hasName && !hasSKU {
+		return nil, fmt.Errorf("Missing required column: material or material_sku")
+	}
+
+	var transfers []ComponentTransfer
+	positions := make(map[string]int)
+	for i, row := range rows[1:] {
+		if isBlankRow(row) {
+			continue
+		}
+		name, _ := utils.CellValue(row, index, "component_name")
+		description, _ := utils.CellValue(row, index, "component_description")
+		material, _ := utils.CellValue(row, index, "material")
+		sku, _ := utils.CellValue(row, index, "material_sku")
+		quantityText, _ := utils.CellValue(row, index, "quantity")
+		quantity, err := strconv.ParseFloat(quantityText, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Row %d: quantity must be a number", i+2)
+		}
+		unit, _ := utils.CellValue(row, index, "unit")
+		var waste *float64
+		if wasteText, _ := utils.CellValue(row, index, "waste_percent"); wasteText != "" {
+			value, err := strconv.ParseFloat(wasteText, 64)
+			if err != nil {
+				return nil, fmt.Errorf("Row %d: waste_percent must be a number", i+2)
+			}
+			waste = &value
+		}
+
+		key := strings.ToLower(name)
+		pos, ok := positions[key]
+		if !ok {
+			pos = len(transfers)
+			positions[key] = pos
+			transfers = append(transfers, ComponentTransfer{Name: name, Description: description})
+		}
+		if transfers[pos].Description == "" {
+			transfers[pos].Description = description
+		}
+		transfers[pos].Materials = append(transfers[pos].Materials, ComponentMaterialTransfer{Material: material, SKU: sku, Quantity: quantity, Unit: unit, WastePercent: waste})
+	}
+	return transfers, nil
+}
@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"qp1/models"
+	"reflect"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func newComponentTransferTestApp() *fiber.App {
+	app := fiber.New()
+	app.Get("/export", ExportComponents)
+	app.Post("/import", ImportComponents)
+	return app
+}
+
+// setupTransferMaterials creates the materials referenced by testCatalog in a fresh database
+func setupTransferMaterials(t *testing.T) {
+	db := setupTest(t)
+	sku := "OAK-1"
+	db.Create(&models.Material{Name: "Oak board", Unit: "m", UnitCost: 10, SKU: &sku})
+	db.Create(&models.Material{Name: "Screw", Unit: "pcs", UnitCost: 0.1})
+}
+
+// exportedComponents exports the components as JSON without the export time
+func exportedComponents(t *testing.T, app *fiber.App) []ComponentTransfer {
+	t.Helper()
+	status, body := sendJSON(t, app, http.MethodGet, "/export", "")
+	if status != fiber.StatusOK {
+		t.Fatalf("export returned %d: %s", status, body)
+	}
+	var catalog ComponentCatalog
+	if err := json.Unmarshal([]byte(body), &catalog); err != nil {
+		t.Fatal(err)
+	}
+	if catalog.Version != componentTransferVersion {
+		t.Errorf("catalog version %d, want %d", catalog.Version, componentTransferVersion)
+	}
+	return catalog.Components
+}
+
+func TestComponentTransferRoundTrip(t *testing.T) {
+	waste := 15.0
+	components := []ComponentTransfer{
+		{Name: "Cabinet", Description: "Base unit", Materials: []ComponentMaterialTransfer{
+			{Material: "Oak board", SKU: "OAK-1", Quantity: 3.5},
+		}},
+		{Name: "Shelf", Description: "-Top shelf, oak", Materials: []ComponentMaterialTransfer{
+			{Material: "Oak board", SKU: "OAK-1", Quantity: 200, Unit: "cm", WastePercent: &waste},
+			{Material: "Screw", Quantity: 8},
+		}},
+	}
+	catalog, err := json.Marshal(ComponentCatalog{Version: componentTransferVersion, Components: components})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setupTransferMaterials(t)
+	app := newComponentTransferTestApp()
+	if status, body := sendJSON(t, app, http.MethodPost, "/import", string(catalog)); status != fiber.StatusOK {
+		t.Fatalf("JSON import returned %d: %s", status, body)
+	}
+	if got := exportedComponents(t, app); !reflect.DeepEqual(got, components) {
+		t.Errorf("JSON export after import\n%+v\nwant\n%+v", got, components)
+	}
+	status, csvExport := sendJSON(t, app, http.MethodGet, "/export?format=csv", "")
+	if status != fiber.StatusOK {
+		t.Fatalf("CSV export returned %d: %s", status, csvExport)
+	}
+
+	// The CSV export imported into another database gives the same components
+	setupTransferMaterials(t)
+	if status, body := uploadFile(t, app, "/import", "components.csv", csvExport); status != fiber.StatusOK {
+		t.Fatalf("CSV import returned %d: %s", status, body)
+	}
+	if got := exportedComponents(t, app); !reflect.DeepEqual(got, components) {
+		t.Errorf("JSON export after CSV import\n%+v\nwant\n%+v", got, components)
+	}
+
+	// Importing again updates the components in place
+	status, body := uploadFile(t, app, "/import?dry_run=true", "components.csv", csvExport)
+	var summary struct {
+		Created int `json:"created"`
+		Updated int `json:"updated"`
+	}
+	if err := json.Unmarshal([]byte(body), &summary); err != nil || status != fiber.StatusOK || summary.Created != 0 || summary.Updated != 2 {
+		t.Errorf("second import returned %d: %s", status, body)
+	}
+}
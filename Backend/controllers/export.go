@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"bufio"
+	"fmt"
+	"qp1/models"
+	"qp1/utils"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// exportBatchSize is the number of rows loaded from the database per batch while streaming an export
+const exportBatchSize = 500
+
+// exportFormat returns the export format requested via ?format=, or "" for a normal JSON response
+func exportFormat(c *fiber.Ctx) (string, error) {
+	format := c.Query("format")
+	if format == "" || format == "json" {
+		return "", nil
+	}
+	if !utils.IsExportFormat(format) {
+		return "", fmt.Errorf("unsupported format %q, expected csv or xlsx", format)
+	}
+	return format, nil
+}
+
+// streamExport sends a CSV/XLSX download. The body is produced after the handler returns,
+// so fill must not touch the fiber context; it only calls write once per data row.
+func streamExport(c *fiber.Ctx, format, name string, header []string, fill func(write func(row ...interface{}) error) error) error {
+	filename := fmt.Sprintf("%s_%s.%s", name, time.Now().Format("20060102_150405"), format)
+	c.Set("Content-Type", utils.ExportContentType(format))
+	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		tw, err := utils.NewTableWriter(format, w)
+		if err != nil {
+			fmt.Println("Export error:", err)
+			return
+		}
+		headerRow := make([]interface{}, len(header))
+		for i, h := range header {
+			headerRow[i] = h
+		}
+		err = tw.WriteRow(headerRow)
+		if err == nil {
+			err = fill(func(row ...interface{}) error {
+				return tw.WriteRow(row)
+			})
+		}
+		if err != nil {
+			fmt.Println("Export error:", err)
+		}
+		if err := tw.Close(); err != nil {
+			fmt.Println("Export error:", err)
+		}
+		w.Flush()
+	})
+	return nil
+}
+
+// quotationExportHeader returns the columns of quotation exports; cost and margin need quotations:view_cost
+func quotationExportHeader(showCost bool) []string {
+	header := []string{"Quotation No", "Title", "Client", "Status", "Created By", "Sell Total", "Created At"}
+	if showCost {
+		header = append(header, "Total Cost", "Gross Margin")
+	}
+	return header
+}
+
+func quotationExportRow(q models.Quotation, showCost bool) []interface{} {
+	row := []interface{}{q.QuotationNo, q.Title, q.ClientName, q.Status, q.CreatedBy, q.SellTotal, q.CreatedAt}
+	if showCost {
+		row = append(row, q.TotalCost, q.SellTotal.Sub(q.TotalCost))
+	}
+	return row
+}
+
+// exportQuotations streams every quotation matched by query, loading them in batches
+func exportQuotations(c *fiber.Ctx, format, name string, query *gorm.DB) error {
+	showCost := canViewCost(c)
+	return streamExport(c, format, name, quotationExportHeader(showCost), func(write func(row ...interface{}) error) error {
+		var batch []models.Quotation
+		return query.FindInBatches(&batch, exportBatchSize, func(tx *gorm.DB, _ int) error {
+			for _, q := range batch {
+				if err := write(quotationExportRow(q, showCost)...); err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+	})
+}
@@ -0,0 +1,179 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"qp1/models"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shopspring/decimal"
+	"github.com/xuri/excelize/v2"
+)
+
+func newReportTestApp(user models.User) *fiber.App {
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("user", user)
+		return c.Next()
+	})
+	app.Get("/api/admin/sales-report", GenerateSalesReport)
+	app.Get("/api/admin/material-usage-report", GenerateMaterialUsageReport)
+	return app
+}
+
+// readExport requests path and returns the rows of the CSV or XLSX file it sends back
+func readExport(t *testing.T, app *fiber.App, path string, format string) [][]string {
+	t.Helper()
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+	resp, err := app.Test(httptest.NewRequest("GET", path+separator+"format="+format, nil), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("%s returned %d: %s", path, resp.StatusCode, data)
+	}
+	if format == "csv" {
+		rows, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rows
+	}
+	f, err := excelize.OpenReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := f.GetRows("Sheet1", excelize.Options{RawCellValue: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rows
+}
+
+func TestSalesReportExportCostColumns(t *testing.T) {
+	db := setupTest(t)
+	db.Create(&models.Quotation{UserID: 1, QuotationNo: "Q-1", Title: "=1+1", ClientName: "Acme", Status: "accepted",
+		CreatedBy: "sam", SellTotal: decimal.NewFromInt(100), TotalCost: decimal.NewFromInt(60)})
+	db.Create(&models.Quotation{UserID: 1, QuotationNo: "Q-2", Title: "Draft", Status: "draft",
+		SellTotal: decimal.NewFromInt(50), TotalCost: decimal.NewFromInt(20)})
+
+	tests := []struct {
+		role       string
+		format     string
+		wantHeader []string
+		wantRow    []string
+		wantTotals []string
+	}{
+		{"sales", "csv",
+			[]string{"Quotation No", "Title", "Client", "Status", "Created By", "Sell Total", "Created At"},
+			[]string{"Q-1", "'=1+1", "Acme", "accepted", "sam", "100.00"},
+			[]string{"TOTAL", "1 quotations", "", "", "", "100.00", ""}},
+		{"estimator", "csv",
+			[]string{"Quotation No", "Title", "Client", "Status", "Created By", "Sell Total", "Created At", "Total Cost", "Gross Margin"},
+			[]string{"Q-1", "'=1+1", "Acme", "accepted", "sam", "100.00"},
+			[]string{"TOTAL", "1 quotations", "", "", "", "100.00", "", "60.00", "40.00"}},
+		{"sales", "xlsx",
+			[]string{"Quotation No", "Title", "Client", "Status", "Created By", "Sell Total", "Created At"},
+			[]string{"Q-1", "=1+1", "Acme", "accepted", "sam", "100"},
+			[]string{"TOTAL", "1 quotations", "", "", "", "100"}},
+		{"estimator", "xlsx",
+			[]string{"Quotation No", "Title", "Client", "Status", "Created By", "Sell Total", "Created At", "Total Cost", "Gross Margin"},
+			[]string{"Q-1", "=1+1", "Acme", "accepted", "sam", "100"},
+			[]string{"TOTAL", "1 quotations", "", "", "", "100", "", "60", "40"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.role+" "+tt.format, func(t *testing.T) {
+			app := newReportTestApp(models.User{ID: 1, Role: tt.role})
+			rows := readExport(t, app, "/api/admin/sales-report", tt.format)
+			if len(rows) != 3 {
+				t.Fatalf("got %d rows, want header, one quotation and totals: %q", len(rows), rows)
+			}
+			if !reflect.DeepEqual(rows[0], tt.wantHeader) {
+				t.Errorf("header %q, want %q", rows[0], tt.wantHeader)
+			}
+			if got := rows[1][:len(tt.wantRow)]; !reflect.DeepEqual(got, tt.wantRow) {
+				t.Errorf("quotation row %q, want %q", got, tt.wantRow)
+			}
+			if len(rows[1]) > len(tt.wantHeader) {
+				t.Errorf("quotation row has %d columns, header %d", len(rows[1]), len(tt.wantHeader))
+			}
+			if !reflect.DeepEqual(rows[2], tt.wantTotals) {
+				t.Errorf("totals %q, want %q", rows[2], tt.wantTotals)
+			}
+		})
+	}
+}
+
+func TestMaterialUsageReportExportStreamsBatches(t *testing.T) {
+	db := setupTest(t)
+	used := []models.Material{}
+	for i := 0; i < exportBatchSize+2; i++ {
+		material := models.Material{Name: fmt.Sprintf("Board %d", i), Unit: "m", StockQty: float64(i)}
+		db.Create(&material)
+		used = append(used, material)
+	}
+	db.Create(&models.Material{Name: "@unused", Unit: "m"})
+	quotation := models.Quotation{UserID: 1, QuotationNo: "Q-1", Title: "Boards"}
+	db.Create(&quotation)
+	for _, material := range used {
+		// Two lines for the same material must still give one report row
+		db.Create(&models.QuotationMaterial{QuotationID: quotation.ID, MaterialID: material.ID, Quantity: 1})
+		db.Create(&models.QuotationMaterial{QuotationID: quotation.ID, MaterialID: material.ID, Quantity: 2})
+	}
+	app := newReportTestApp(models.User{ID: 1, Role: "sales"})
+
+	rows := readExport(t, app, "/api/admin/material-usage-report", "csv")
+	if want := len(used) + 2; len(rows) != want {
+		t.Errorf("all materials: got %d rows, want %d", len(rows), want)
+	}
+	if last := rows[len(rows)-1]; last[1] != "'@unused" {
+		t.Errorf("last row %q, want the escaped unused material", last)
+	}
+
+	rows = readExport(t, app, "/api/admin/material-usage-report?start_date=2000-01-01&end_date=2999-01-01", "csv")
+	if want := len(used) + 1; len(rows) != want {
+		t.Fatalf("materials used by quotations: got %d rows, want %d", len(rows), want)
+	}
+	if !reflect.DeepEqual(rows[0], []string{"Material ID", "Material Name", "Total Used"}) {
+		t.Errorf("header %q", rows[0])
+	}
+}
+
+func TestSalesReportTotals(t *testing.T) {
+	db := setupTest(t)
+	db.Create(&models.Quotation{UserID: 1, QuotationNo: "Q-1", Title: "Kitchen", Status: "accepted",
+		SellTotal: decimal.NewFromInt(100), TotalCost: decimal.NewFromInt(60)})
+	db.Create(&models.Quotation{UserID: 1, QuotationNo: "Q-2", Title: "Bathroom", Status: "accepted",
+		SellTotal: decimal.NewFromFloat(50.5), TotalCost: decimal.NewFromInt(30)})
+	db.Create(&models.Quotation{UserID: 1, QuotationNo: "Q-3", Title: "Draft", Status: "draft",
+		SellTotal: decimal.NewFromInt(1000), TotalCost: decimal.NewFromInt(900)})
+
+	tests := []struct {
+		role string
+		want string
+	}{
+		{"sales", `{"total_quotations":2,"sell_total":150.5}`},
+		{"estimator", `{"total_quotations":2,"sell_total":150.5,"total_cost":90,"gross_margin":60.5}`},
+	}
+	for _, tt := range tests {
+		app := newReportTestApp(models.User{ID: 1, Role: tt.role})
+		status, body := sendJSON(t, app, "GET", "/api/admin/sales-report", "")
+		if status != fiber.StatusOK {
+			t.Fatalf("%s: sales report returned %d: %s", tt.role, status, body)
+		}
+		if !strings.Contains(body, `"data":`+tt.want) {
+			t.Errorf("%s: sales report %s, want data %s", tt.role, body, tt.want)
+		}
+	}
+}
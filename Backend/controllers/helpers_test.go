@@ -0,0 +1,20 @@
+package controllers
+
+import (
+	"qp1/database/dbtest"
+	"qp1/utils"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// setupTest installs a fresh test database and signing keys for a test that goes through the handlers
+func setupTest(t *testing.T) *gorm.DB {
+	t.Helper()
+	t.Setenv("JWT_KEYS", "")
+	t.Setenv("JWT_SECRET", "test-secret-that-is-at-least-32-bytes")
+	if err := utils.LoadJWTKeys(); err != nil {
+		t.Fatalf("loading JWT keys: %v", err)
+	}
+	return dbtest.Open(t)
+}
@@ -0,0 +1,257 @@
+package controllers
+
+import (
+	"fmt"
+	"qp1/database"
+	"qp1/models"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// LaborRateRequest is the body for creating or updating a labor rate
+type LaborRateRequest struct {
+	Name        string   `json:"name"`
+	Unit        string   `json:"unit"`
+	Rate        *float64 `json:"rate"`
+	Description string   `json:"description"`
+}
+
+// OverheadRateRequest is the body for creating or updating an overhead rate
+type OverheadRateRequest struct {
+	Name        string   `json:"name"`
+	Percent     *float64 `json:"percent"`
+	Description string   `json:"description"`
+}
+
+var laborRateListSpec = listSpec{
+	Fields: map[string]listField{
+		"id":   {Column: "id", Kind: fieldNumber, Sort: true, Filter: true},
+		"name": {Column: "name", Kind: fieldString, Sort: true, Filter: true},
+		"unit": {Column: "unit", Kind: fieldString, Sort: true, Filter: true},
+		"rate": {Column: "rate", Kind: fieldNumber, Sort: true, Filter: true},
+	},
+	DefaultSort: "name",
+	MaxPageSize: 500,
+}
+
+var overheadRateListSpec = listSpec{
+	Fields: map[string]listField{
+		"id":      {Column: "id", Kind: fieldNumber, Sort: true, Filter: true},
+		"name":    {Column: "name", Kind: fieldString, Sort: true, Filter: true},
+		"percent": {Column: "percent", Kind: fieldNumber, Sort: true, Filter: true},
+	},
+	DefaultSort: "name",
+	MaxPageSize: 500,
+}
+
+// validateLaborRate checks a labor rate request and normalizes its name and unit
+func validateLaborRate(req *LaborRateRequest) string {
+	req.Name = strings.TrimSpace(req.Name)
+	req.Unit = strings.TrimSpace(req.Unit)
+	if req.Name == "" {
+		return "Name is required"
+	}
+	if req.Unit == "" {
+		req.Unit = "hour"
+	}
+	if req.Rate == nil {
+		return "Rate is required"
+	}
+	if *req.Rate < 0 {
+		return "Rate must not be negative"
+	}
+	return ""
+}
+
+// validateOverheadRate checks an overhead rate request and normalizes its name
+func validateOverheadRate(req *OverheadRateRequest) string {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return "Name is required"
+	}
+	if req.Percent == nil {
+		return "Percent is required"
+	}
+	if *req.Percent < 0 {
+		return "Percent must not be negative"
+	}
+	return ""
+}
+
+// rateNameTaken reports whether another rate of model already has name
+func rateNameTaken(model interface{}, name string, exceptID uint) bool {
+	var count int64
+	database.DB.Model(model).Where("name = ? AND id <> ?", name, exceptID).Count(&count)
+	return count > 0
+}
+
+// rateUsage counts the components with a line in the table of model whose column equals id
+func rateUsage(model interface{}, column string, id uint) (int64, error) {
+	var count int64
+	err := database.DB.Model(model).Where(column+" = ?", id).Distinct("component_id").Count(&count).Error
+	return count, err
+}
+
+// ListLaborRates 查询人工费率（支持排序、过滤、分页）
+func ListLaborRates(c *fiber.Ctx) error {
+	lq, err := parseListQuery(c, laborRateListSpec)
+	if err != nil {
+		return respondListError(c, err)
+	}
+	rates := []models.LaborRate{}
+	p, err := lq.find(database.DB.Model(&models.LaborRate{}), &rates)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch labor rates"})
+	}
+	return respondList(c, rates, p)
+}
+
+// CreateLaborRate 新增人工费率
+func CreateLaborRate(c *fiber.Ctx) error {
+	var req LaborRateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Failed to parse request body"})
+	}
+	if msg := validateLaborRate(&req); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": msg})
+	}
+	if rateNameTaken(&models.LaborRate{}, req.Name, 0) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "A labor rate with this name already exists"})
+	}
+	rate := models.LaborRate{Name: req.Name, Unit: req.Unit, Rate: *req.Rate, Description: req.Description}
+	if err := database.DB.Create(&rate).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create labor rate"})
+	}
+	return c.Status(fiber.StatusCreated).JSON(rate)
+}
+
+// UpdateLaborRate 修改人工费率，并重新计算使用该费率的组件成本
+func UpdateLaborRate(c *fiber.Ctx) error {
+	var rate models.LaborRate
+	if err := database.DB.First(&rate, c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Labor rate not found"})
+	}
+	var req LaborRateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Failed to parse request body"})
+	}
+	if msg := validateLaborRate(&req); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": msg})
+	}
+	if rateNameTaken(&models.LaborRate{}, req.Name, rate.ID) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "A labor rate with this name already exists"})
+	}
+	rate.Name, rate.Unit, rate.Rate, rate.Description = req.Name, req.Unit, *req.Rate, req.Description
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&rate).Error; err != nil {
+			return err
+		}
+		return recalculateComponentsUsing(tx, &models.ComponentLabor{}, "labor_rate_id", rate.ID)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update labor rate"})
+	}
+	return c.JSON(rate)
+}
+
+// DeleteLaborRate 删除人工费率；仍被组件使用的费率不能删除
+func DeleteLaborRate(c *fiber.Ctx) error {
+	var rate models.LaborRate
+	if err := database.DB.First(&rate, c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Labor rate not found"})
+	}
+	used, err := rateUsage(&models.ComponentLabor{}, "labor_rate_id", rate.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete labor rate"})
+	}
+	if used > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Labor rate is used by %d components", used)})
+	}
+	if err := database.DB.Delete(&rate).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete labor rate"})
+	}
+	return c.JSON(fiber.Map{"message": "Labor rate deleted successfully"})
+}
+
+// ListOverheadRates 查询间接费用率（支持排序、过滤、分页）
+func ListOverheadRates(c *fiber.Ctx) error {
+	lq, err := parseListQuery(c, overheadRateListSpec)
+	if err != nil {
+		return respondListError(c, err)
+	}
+	rates := []models.OverheadRate{}
+	p, err := lq.find(database.DB.Model(&models.OverheadRate{}), &rates)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch overhead rates"})
+	}
+	return respondList(c, rates, p)
+}
+
+// CreateOverheadRate 新增间接费用率
+func CreateOverheadRate(c *fiber.Ctx) error {
+	var req OverheadRateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Failed to parse request body"})
+	}
+	if msg := validateOverheadRate(&req); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": msg})
+	}
+	if rateNameTaken(&models.OverheadRate{}, req.Name, 0) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "An overhead rate with this name already exists"})
+	}
+	rate := models.OverheadRate{Name: req.Name, Percent: *req.Percent, Description: req.Description}
+	if err := database.DB.Create(&rate).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create overhead rate"})
+	}
+	return c.Status(fiber.StatusCreated).JSON(rate)
+}
+
+// UpdateOverheadRate 修改间接费用率，并重新计算使用该费率的组件成本
+func UpdateOverheadRate(c *fiber.Ctx) error {
+	var rate models.OverheadRate
+	if err := database.DB.First(&rate, c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Overhead rate not found"})
+	}
+	var req OverheadRateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Failed to parse request body"})
+	}
+	if msg := validateOverheadRate(&req); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": msg})
+	}
+	if rateNameTaken(&models.OverheadRate{}, req.Name, rate.ID) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "An overhead rate with this name already exists"})
+	}
+	rate.Name, rate.Percent, rate.Description = req.Name, *req.Percent, req.Description
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&rate).Error; err != nil {
+			return err
+		}
+		return recalculateComponentsUsing(tx, &models.ComponentOverhead{}, "overhead_rate_id", rate.ID)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update overhead rate"})
+	}
+	return c.JSON(rate)
+}
+
+// DeleteOverheadRate 删除间接费用率；仍被组件使用的费率不能删除
+func DeleteOverheadRate(c *fiber.Ctx) error {
+	var rate models.OverheadRate
+	if err := database.DB.First(&rate, c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Overhead rate not found"})
+	}
+	used, err := rateUsage(&models.ComponentOverhead{}, "overhead_rate_id", rate.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete overhead rate"})
+	}
+	if used > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Overhead rate is used by %d components", used)})
+	}
+	if err := database.DB.Delete(&rate).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete overhead rate"})
+	}
+	return c.JSON(fiber.Map{"message": "Overhead rate deleted successfully"})
+}
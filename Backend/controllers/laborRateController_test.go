@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"net/http"
+	"qp1/models"
+	"strconv"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestComponentCostSplit(t *testing.T) {
+	db := setupTest(t)
+	material := models.Material{Name: "Steel tube", Unit: "m", UnitCost: 5}
+	db.Create(&material)
+	welding := models.LaborRate{Name: "Welding", Unit: "hour", Rate: 30}
+	db.Create(&welding)
+	workshop := models.OverheadRate{Name: "Workshop", Percent: 10}
+	db.Create(&workshop)
+	component := models.Component{Name: "Frame"}
+	db.Create(&component)
+	db.Create(&models.ComponentMaterial{ComponentID: component.ID, MaterialID: material.ID, Quantity: 2})
+	db.Create(&models.ComponentLabor{ComponentID: component.ID, LaborRateID: welding.ID, Quantity: 2})
+	db.Create(&models.ComponentOverhead{ComponentID: component.ID, OverheadRateID: workshop.ID})
+	if err := recalculateComponents(db, []uint{component.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	checkSplit := func(when string, material, labor, overhead float64) {
+		t.Helper()
+		db.First(&component, component.ID)
+		if !closeTo(component.MaterialCost, material) || !closeTo(component.LaborCost, labor) || !closeTo(component.OverheadCost, overhead) ||
+			!closeTo(component.TotalCost, material+labor+overhead) {
+			t.Errorf("%s: material %v, labor %v, overhead %v, total %v, want %v, %v, %v", when,
+				component.MaterialCost, component.LaborCost, component.OverheadCost, component.TotalCost, material, labor, overhead)
+		}
+	}
+	// The overhead is charged on the material and labor cost
+	checkSplit("costed", 10, 60, 7)
+
+	app := fiber.New()
+	app.Put("/labor-rates/:id", UpdateLaborRate)
+	app.Delete("/labor-rates/:id", DeleteLaborRate)
+	path := "/labor-rates/" + strconv.FormatUint(uint64(welding.ID), 10)
+	if status, body := sendJSON(t, app, http.MethodPut, path, `{"name": "Welding", "unit": "hour", "rate": 40}`); status != fiber.StatusOK {
+		t.Fatalf("updating the labor rate returned %d: %s", status, body)
+	}
+	checkSplit("after a rate change", 10, 80, 9)
+	if status, _ := sendJSON(t, app, http.MethodDelete, path, ""); status != fiber.StatusBadRequest {
+		t.Errorf("deleting a labor rate in use returned %d", status)
+	}
+
+	// Quotation items carry the split of their component times their size and quantity
+	deliveryPrice, deliveryCost := 25.0, 20.0
+	quotation := models.Quotation{UserID: 1, Title: "Frames", QuotationNo: "Q-1"}
+	db.Create(&quotation)
+	err := processQuotationItems(db, &quotation, nil, []CreateQuotationItemRequest{
+		{ComponentID: component.ID, Length: 2, Width: 1, Height: 1, Quantity: 3},
+		{Description: "Delivery", Quantity: 1, UnitPrice: &deliveryPrice, UnitCost: &deliveryCost},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var items []models.QuotationItem
+	db.Where("quotation_id = ?", quotation.ID).Order("position").Find(&items)
+	if len(items) != 2 {
+		t.Fatalf("got %d items", len(items))
+	}
+	frame := items[0]
+	if !closeTo(frame.MaterialCost, 60) || !closeTo(frame.LaborCost, 480) || !closeTo(frame.OverheadCost, 54) || !closeTo(frame.TotalCost, 594) {
+		t.Errorf("item material %v, labor %v, overhead %v, total %v, want 60, 480, 54, 594",
+			frame.MaterialCost, frame.LaborCost, frame.OverheadCost, frame.TotalCost)
+	}
+	// Ad-hoc items have no split
+	if delivery := items[1]; delivery.MaterialCost != 0 || delivery.LaborCost != 0 || delivery.OverheadCost != 0 || !closeTo(delivery.TotalCost, 20) {
+		t.Errorf("ad-hoc item %+v", delivery)
+	}
+	if got, _ := quotation.TotalCost.Float64(); !closeTo(got, 614) {
+		t.Errorf("quotation total cost %v, want 614", got)
+	}
+}
@@ -0,0 +1,400 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// Kinds of list fields, which decide how filter values are parsed and which operators are allowed
+const (
+	fieldString = iota
+	fieldNumber
+	fieldTime
+	fieldBool
+)
+
+const (
+	listDefaultPageSize = 10
+	listMaxPageSize     = 100
+)
+
+// listField is a field a list endpoint can be sorted or filtered on
+type listField struct {
+	Column string
+	Kind   int
+	Sort   bool
+	Filter bool
+}
+
+// listSpec declares the sortable and filterable fields of a list endpoint.
+// Fields are referred to by their JSON name in the sort and filter parameters.
+type listSpec struct {
+	Fields          map[string]listField
+	DefaultSort     string // e.g. "-created_at"
+	DefaultPageSize int    // listDefaultPageSize when 0
+	MaxPageSize     int    // listMaxPageSize when 0
+}
+
+// Pagination describes the page returned by a list endpoint. Offset pagination sets page, total and total_pages;
+// cursor pagination sets next_cursor instead.
+type Pagination struct {
+	Page       int    `json:"page,omitempty"`
+	PageSize   int    `json:"page_size"`
+	Total      *int64 `json:"total,omitempty"`
+	TotalPages int    `json:"total_pages,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+}
+
+// ListResponse is the envelope every list endpoint responds with
+type ListResponse struct {
+	Data       interface{} `json:"data"`
+	Pagination Pagination  `json:"pagination"`
+}
+
+type listSort struct {
+	field listField
+	desc  bool
+}
+
+type listFilter struct {
+	field listField
+	op    string
+	value interface{}
+}
+
+// listQuery is the parsed sort, filter and pagination parameters of a list request
+type listQuery struct {
+	sorts    []listSort
+	filters  []listFilter
+	page     int
+	pageSize int
+	cursor   bool
+	after    []interface{} // sort values of the last row of the previous page, in cursor mode
+	sorted   bool          // the request chose the sort order
+}
+
+// listIDField is the tiebreaker appended to every sort so the order, and therefore cursors, are stable
+var listIDField = listField{Column: "id", Kind: fieldNumber, Sort: true}
+
+// parseListQuery reads the list parameters of a request:
+//
+//	sort=name,-created_at            sort fields, "-" for descending
+//	status=draft                     equality filter on a field
+//	total_cost[gte]=100              filter with an operator: eq, ne, gt, gte, lt, lte, in, like
+//	created_at[gte]=2024-01-01       dates are YYYY-MM-DD or RFC 3339; a date-only lte includes the whole day
+//	status[in]=draft,issued          comma separated values
+//	page=2&page_size=20              offset pagination (pageSize and limit are accepted as older names)
+//	cursor=                          cursor pagination: start with an empty cursor, then pass next_cursor
+//
+// Parameters that don't name a field are ignored so endpoints can read their own.
+func parseListQuery(c *fiber.Ctx, spec listSpec) (*listQuery, error) {
+	q := &listQuery{page: 1, pageSize: spec.DefaultPageSize}
+	if q.pageSize == 0 {
+		q.pageSize = listDefaultPageSize
+	}
+	maxPageSize := spec.MaxPageSize
+	if maxPageSize == 0 {
+		maxPageSize = listMaxPageSize
+	}
+	for _, name := range []string{"page_size", "pageSize", "limit"} {
+		if raw := c.Query(name); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("%s must be a positive number", name)
+			}
+			q.pageSize = min(n, maxPageSize)
+			break
+		}
+	}
+	if raw := c.Query("page"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("page must be a positive number")
+		}
+		q.page = n
+	}
+
+	q.sorted = c.Query("sort") != ""
+	sortParam := c.Query("sort", spec.DefaultSort)
+	hasID := false
+	for _, name := range strings.Split(sortParam, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		desc := strings.HasPrefix(name, "-")
+		name = strings.TrimPrefix(name, "-")
+		field, ok := spec.Fields[name]
+		if !ok || !field.Sort {
+			return nil, fmt.Errorf("can't sort by %q", name)
+		}
+		hasID = hasID || field.Column == listIDField.Column
+		q.sorts = append(q.sorts, listSort{field: field, desc: desc})
+	}
+	if !hasID {
+		q.sorts = append(q.sorts, listSort{field: listIDField})
+	}
+
+	for key, raw := range c.Queries() {
+		name, op, explicit := key, "eq", false
+		if i := strings.IndexByte(key, '['); i > 0 && strings.HasSuffix(key, "]") {
+			name, op, explicit = key[:i], key[i+1:len(key)-1], true
+		}
+		field, ok := spec.Fields[name]
+		if !ok || !field.Filter {
+			if explicit {
+				return nil, fmt.Errorf("can't filter by %q", name)
+			}
+			continue
+		}
+		filter, err := parseListFilter(field, name, op, raw)
+		if err != nil {
+			return nil, err
+		}
+		q.filters = append(q.filters, filter)
+	}
+
+	if c.Context().QueryArgs().Has("cursor") {
+		q.cursor = true
+		if raw := c.Query("cursor"); raw != "" {
+			after, err := decodeListCursor(raw, q.sorts)
+			if err != nil {
+				return nil, err
+			}
+			q.after = after
+		}
+	}
+	return q, nil
+}
+
+func parseListFilter(field listField, name string, op string, raw string) (listFilter, error) {
+	filter := listFilter{field: field, op: op}
+	switch op {
+	case "eq", "ne":
+	case "gt", "gte", "lt", "lte":
+		if field.Kind != fieldNumber && field.Kind != fieldTime {
+			return filter, fmt.Errorf("%s[%s] is only supported for numbers and dates", name, op)
+		}
+	case "like":
+		if field.Kind != fieldString {
+			return filter, fmt.Errorf("%s[like] is only supported for text fields", name)
+		}
+		filter.value = "%" + raw + "%"
+		return filter, nil
+	case "in":
+		var values []interface{}
+		for _, part := range strings.Split(raw, ",") {
+			value, err := parseListValue(field, name, strings.TrimSpace(part))
+			if err != nil {
+				return filter, err
+			}
+			values = append(values, value)
+		}
+		filter.value = values
+		return filter, nil
+	default:
+		return filter, fmt.Errorf("unknown filter operator %q", op)
+	}
+	value, err := parseListValue(field, name, raw)
+	if err != nil {
+		return filter, err
+	}
+	// A date-only upper bound includes the whole day
+	if t, ok := value.(time.Time); ok && op == "lte" && len(raw) == len(time.DateOnly) {
+		filter.op = "lt"
+		value = t.AddDate(0, 0, 1)
+	}
+	filter.value = value
+	return filter, nil
+}
+
+func parseListValue(field listField, name string, raw string) (interface{}, error) {
+	switch field.Kind {
+	case fieldNumber:
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a number", name)
+		}
+		return n, nil
+	case fieldTime:
+		if t, err := time.ParseInLocation(time.DateOnly, raw, time.Local); err == nil {
+			return t, nil
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a date (YYYY-MM-DD) or an RFC 3339 time", name)
+		}
+		return t, nil
+	case fieldBool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be true or false", name)
+		}
+		return b, nil
+	}
+	return raw, nil
+}
+
+var listOperators = map[string]string{"eq": "=", "ne": "<>", "gt": ">", "gte": ">=", "lt": "<", "lte": "<=", "like": "LIKE", "in": "IN"}
+
+// rankable reports whether rows can be ordered by search relevance ahead of the sort fields: only when the request
+// didn't choose a sort order, and not in cursor mode, where the order must be fully given by the sort fields
+func (q *listQuery) rankable() bool {
+	return !q.sorted && !q.cursor
+}
+
+// where applies the filters only, for counting and exporting
+func (q *listQuery) where(db *gorm.DB) *gorm.DB {
+	for _, f := range q.filters {
+		db = db.Where(fmt.Sprintf("%s %s ?", f.field.Column, listOperators[f.op]), f.value)
+	}
+	return db
+}
+
+// order applies the sort fields
+func (q *listQuery) order(db *gorm.DB) *gorm.DB {
+	for _, s := range q.sorts {
+		if s.desc {
+			db = db.Order(s.field.Column + " DESC")
+		} else {
+			db = db.Order(s.field.Column)
+		}
+	}
+	return db
+}
+
+// find loads one page of db, filtered and sorted, into dest (a pointer to a slice of models).
+// scopes, such as preloads, only apply to loading the rows and not to counting them.
+func (q *listQuery) find(db *gorm.DB, dest interface{}, scopes ...func(*gorm.DB) *gorm.DB) (Pagination, error) {
+	p := Pagination{PageSize: q.pageSize}
+	filtered := q.where(db)
+
+	if !q.cursor {
+		var total int64
+		if err := filtered.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+			return p, err
+		}
+		if err := q.order(filtered).Scopes(scopes...).Offset((q.page - 1) * q.pageSize).Limit(q.pageSize).Find(dest).Error; err != nil {
+			return p, err
+		}
+		p.Page = q.page
+		p.Total = &total
+		p.TotalPages = int((total + int64(q.pageSize) - 1) / int64(q.pageSize))
+		p.HasMore = q.page < p.TotalPages
+		return p, nil
+	}
+
+	// Keyset pagination: rows after the last one of the previous page in sort order.
+	// One extra row is loaded to know whether there is another page.
+	if q.after != nil {
+		sql, args := q.keyset()
+		filtered = filtered.Where(sql, args...)
+	}
+	if err := q.order(filtered).Scopes(scopes...).Limit(q.pageSize + 1).Find(dest).Error; err != nil {
+		return p, err
+	}
+	rows := reflect.ValueOf(dest).Elem()
+	if rows.Len() > q.pageSize {
+		rows.Set(rows.Slice(0, q.pageSize))
+		p.HasMore = true
+		cursor, err := q.encodeCursor(db, rows.Index(q.pageSize-1))
+		if err != nil {
+			return p, err
+		}
+		p.NextCursor = cursor
+	}
+	return p, nil
+}
+
+// keyset builds (a > ?) OR (a = ? AND b > ?) ... for the sort fields and the cursor values
+func (q *listQuery) keyset() (string, []interface{}) {
+	var clauses []string
+	var args []interface{}
+	for i, s := range q.sorts {
+		var parts []string
+		for j := 0; j < i; j++ {
+			parts = append(parts, q.sorts[j].field.Column+" = ?")
+			args = append(args, q.after[j])
+		}
+		op := ">"
+		if s.desc {
+			op = "<"
+		}
+		parts = append(parts, s.field.Column+" "+op+" ?")
+		args = append(args, q.after[i])
+		clauses = append(clauses, "("+strings.Join(parts, " AND ")+")")
+	}
+	return "(" + strings.Join(clauses, " OR ") + ")", args
+}
+
+func (q *listQuery) encodeCursor(db *gorm.DB, row reflect.Value) (string, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(row.Addr().Interface()); err != nil {
+		return "", err
+	}
+	values := make([]interface{}, len(q.sorts))
+	for i, s := range q.sorts {
+		field := stmt.Schema.LookUpField(s.field.Column)
+		if field == nil {
+			return "", fmt.Errorf("can't use %s for cursor pagination", s.field.Column)
+		}
+		value, _ := field.ValueOf(db.Statement.Context, row)
+		if t, ok := value.(time.Time); ok {
+			value = t.Format(time.RFC3339Nano)
+		}
+		values[i] = value
+	}
+	raw, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func decodeListCursor(cursor string, sorts []listSort) ([]interface{}, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	decoder := json.NewDecoder(strings.NewReader(string(raw)))
+	decoder.UseNumber()
+	var values []interface{}
+	if err := decoder.Decode(&values); err != nil || len(values) != len(sorts) {
+		return nil, fmt.Errorf("invalid cursor, it doesn't match the sort order")
+	}
+	for i, s := range sorts {
+		if raw, ok := values[i].(string); ok && s.field.Kind == fieldTime {
+			t, err := time.Parse(time.RFC3339Nano, raw)
+			if err != nil {
+				return nil, fmt.Errorf("invalid cursor")
+			}
+			values[i] = t
+		}
+	}
+	return values, nil
+}
+
+// respondList writes the list envelope
+func respondList(c *fiber.Ctx, data interface{}, p Pagination) error {
+	return c.JSON(ListResponse{Data: data, Pagination: p})
+}
+
+// respondListError writes the response for an invalid list parameter: 400, or the status of a *fiber.Error
+func respondListError(c *fiber.Ctx, err error) error {
+	status := fiber.StatusBadRequest
+	var fe *fiber.Error
+	if errors.As(err, &fe) {
+		status = fe.Code
+	}
+	return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+}
@@ -0,0 +1,210 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"qp1/models"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestParseListFilter(t *testing.T) {
+	str := listField{Column: "name", Kind: fieldString}
+	num := listField{Column: "unit_cost", Kind: fieldNumber}
+	date := listField{Column: "created_at", Kind: fieldTime}
+	flag := listField{Column: "active", Kind: fieldBool}
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name      string
+		field     listField
+		op        string
+		raw       string
+		wantOp    string
+		wantValue interface{}
+		wantErr   bool
+	}{
+		{"string equality", str, "eq", "Oak", "eq", "Oak", false},
+		{"string like", str, "like", "oak", "like", "%oak%", false},
+		{"string range", str, "gt", "a", "", nil, true},
+		{"number", num, "gte", "12.5", "gte", 12.5, false},
+		{"not a number", num, "eq", "twelve", "", nil, true},
+		{"number like", num, "like", "1", "", nil, true},
+		{"number list", num, "in", "1, 2,3", "in", []interface{}{1.0, 2.0, 3.0}, false},
+		{"bad value in list", num, "in", "1,x", "", nil, true},
+		{"date", date, "gte", "2024-03-01", "gte", day, false},
+		{"date-only upper bound includes the day", date, "lte", "2024-03-01", "lt", day.AddDate(0, 0, 1), false},
+		{"RFC 3339 upper bound is exact", date, "lte", "2024-03-01T10:00:00Z", "lte", time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC), false},
+		{"not a date", date, "eq", "March", "", nil, true},
+		{"bool", flag, "ne", "true", "ne", true, false},
+		{"not a bool", flag, "eq", "maybe", "", nil, true},
+		{"unknown operator", str, "between", "a", "", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseListFilter(tt.field, "field", tt.op, tt.raw)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseListFilter() = %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.op != tt.wantOp || !reflect.DeepEqual(got.value, tt.wantValue) {
+				t.Errorf("parseListFilter() = %s %v, want %s %v", got.op, got.value, tt.wantOp, tt.wantValue)
+			}
+		})
+	}
+}
+
+func TestDecodeListCursor(t *testing.T) {
+	sorts := []listSort{{field: listField{Column: "created_at", Kind: fieldTime}, desc: true}, {field: listIDField}}
+	encode := func(values ...interface{}) string {
+		raw, err := json.Marshal(values)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(raw)
+	}
+	created := time.Date(2024, 3, 1, 10, 0, 0, 123, time.UTC)
+
+	tests := []struct {
+		name    string
+		cursor  string
+		want    []interface{}
+		wantErr bool
+	}{
+		{"time and id", encode(created.Format(time.RFC3339Nano), 42), []interface{}{created, json.Number("42")}, false},
+		{"not base64", "!!", nil, true},
+		{"not JSON", base64.RawURLEncoding.EncodeToString([]byte("nope")), nil, true},
+		{"fewer values than sort fields", encode(42), nil, true},
+		{"more values than sort fields", encode("2024-03-01T10:00:00Z", 1, 2), nil, true},
+		{"bad time", encode("yesterday", 42), nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeListCursor(tt.cursor, sorts)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("decodeListCursor() = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeListCursor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListQueryKeyset(t *testing.T) {
+	name := listField{Column: "name", Kind: fieldString}
+	cost := listField{Column: "unit_cost", Kind: fieldNumber}
+	tests := []struct {
+		name     string
+		sorts    []listSort
+		after    []interface{}
+		wantSQL  string
+		wantArgs []interface{}
+	}{
+		{
+			"id only",
+			[]listSort{{field: listIDField}},
+			[]interface{}{5},
+			"((id > ?))",
+			[]interface{}{5},
+		},
+		{
+			"descending field and id",
+			[]listSort{{field: cost, desc: true}, {field: listIDField}},
+			[]interface{}{9.5, 5},
+			"((unit_cost < ?) OR (unit_cost = ? AND id > ?))",
+			[]interface{}{9.5, 9.5, 5},
+		},
+		{
+			"three fields",
+			[]listSort{{field: name}, {field: cost, desc: true}, {field: listIDField}},
+			[]interface{}{"Oak", 9.5, 5},
+			"((name > ?) OR (name = ? AND unit_cost < ?) OR (name = ? AND unit_cost = ? AND id > ?))",
+			[]interface{}{"Oak", "Oak", 9.5, "Oak", 9.5, 5},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &listQuery{sorts: tt.sorts, after: tt.after}
+			sql, args := q.keyset()
+			if sql != tt.wantSQL || !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("keyset() = %s %v, want %s %v", sql, args, tt.wantSQL, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestCursorPaginationVisitsEveryRowOnce(t *testing.T) {
+	db := setupTest(t)
+	// Equal costs make the id tiebreaker decide the order within a cost
+	for i, cost := range []float64{5, 3, 5, 1, 3, 5, 2} {
+		db.Create(&models.Material{Name: fmt.Sprintf("Material %d", i), Unit: "pcs", UnitCost: cost})
+	}
+	app := fiber.New()
+	app.Get("/materials", ListMaterials)
+
+	for _, sort := range []string{"-unit_cost", "unit_cost", "-created_at", "name"} {
+		t.Run(sort, func(t *testing.T) {
+			var all ListResponse
+			status, body := sendJSON(t, app, http.MethodGet, "/materials?page_size=100&sort="+sort, "")
+			if status != fiber.StatusOK {
+				t.Fatalf("listing returned %d: %s", status, body)
+			}
+			json.Unmarshal([]byte(body), &all)
+			want := materialIDs(t, all.Data)
+
+			var got []uint
+			cursor := ""
+			for pages := 0; ; pages++ {
+				if pages > len(want) {
+					t.Fatal("cursor pagination doesn't end")
+				}
+				status, body := sendJSON(t, app, http.MethodGet, "/materials?page_size=3&sort="+sort+"&cursor="+cursor, "")
+				if status != fiber.StatusOK {
+					t.Fatalf("listing returned %d: %s", status, body)
+				}
+				var page ListResponse
+				json.Unmarshal([]byte(body), &page)
+				got = append(got, materialIDs(t, page.Data)...)
+				if !page.Pagination.HasMore {
+					break
+				}
+				cursor = page.Pagination.NextCursor
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("cursor pages returned %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+// materialIDs returns the ids of the materials in a decoded list response
+func materialIDs(t *testing.T, data interface{}) []uint {
+	t.Helper()
+	raw, _ := json.Marshal(data)
+	var materials []models.Material
+	if err := json.Unmarshal(raw, &materials); err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]uint, len(materials))
+	for i, m := range materials {
+		ids[i] = m.ID
+	}
+	return ids
+}
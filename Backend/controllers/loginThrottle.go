@@ -0,0 +1,202 @@
+package controllers
+
+import (
+	"fmt"
+	"math"
+	"qp1/database"
+	"qp1/models"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// loginDelayAfter is the number of failures after which each further attempt has to wait
+	loginDelayAfter = 3
+	loginMaxDelay   = time.Minute
+)
+
+func accountThrottleKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipThrottleKey(ip string) string {
+	return "ip:" + ip
+}
+
+// loginRetryAfter returns how long the client has to wait before the next login attempt for email is allowed
+func loginRetryAfter(email string, ip string) (time.Duration, error) {
+	settings, err := getSettings(database.DB)
+	if err != nil {
+		return 0, err
+	}
+	window := time.Duration(settings.LoginLockoutMinutes) * time.Minute
+
+	var throttles []models.LoginThrottle
+	if err := database.DB.Where("throttle_key IN ?", []string{accountThrottleKey(email), ipThrottleKey(ip)}).Find(&throttles).Error; err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	var wait time.Duration
+	for _, t := range throttles {
+		var until time.Time
+		if t.LockedUntil != nil {
+			until = *t.LockedUntil
+		} else if t.Failures >= loginDelayAfter && now.Sub(t.LastFailureAt) < window {
+			until = t.LastFailureAt.Add(loginDelay(t.Failures))
+		}
+		if d := until.Sub(now); d > wait {
+			wait = d
+		}
+	}
+	return wait, nil
+}
+
+// loginDelay doubles the wait for every failure past loginDelayAfter: 1s, 2s, 4s, ... up to loginMaxDelay
+func loginDelay(failures int) time.Duration {
+	delay := time.Duration(math.Pow(2, float64(failures-loginDelayAfter))) * time.Second
+	if delay > loginMaxDelay || delay <= 0 {
+		return loginMaxDelay
+	}
+	return delay
+}
+
+// recordLoginFailure counts a failed login against the account and the client IP and locks them once their limit is reached.
+// user is nil when no account exists for email; the email is still counted so lockouts don't reveal which accounts exist.
+func recordLoginFailure(email string, ip string, user *models.User) error {
+	settings, err := getSettings(database.DB)
+	if err != nil {
+		return err
+	}
+	window := time.Duration(settings.LoginLockoutMinutes) * time.Minute
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		limits := []struct {
+			key   string
+			max   int
+			event string
+		}{
+			{accountThrottleKey(email), settings.LoginMaxFailures, models.AuthEventAccountLocked},
+			{ipThrottleKey(ip), settings.LoginMaxFailuresPerIP, models.AuthEventIPLocked},
+		}
+		for _, limit := range limits {
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.LoginThrottle{ThrottleKey: limit.key, LastFailureAt: now}).Error; err != nil {
+				return err
+			}
+			var throttle models.LoginThrottle
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("throttle_key = ?", limit.key).First(&throttle).Error; err != nil {
+				return err
+			}
+
+			// Failures older than the window, or from before an expired lock, no longer count
+			if now.Sub(throttle.LastFailureAt) > window || (throttle.LockedUntil != nil && now.After(*throttle.LockedUntil)) {
+				throttle.Failures = 0
+				throttle.LockedUntil = nil
+			}
+			throttle.Failures++
+			throttle.LastFailureAt = now
+
+			if limit.max > 0 && throttle.Failures >= limit.max && throttle.LockedUntil == nil {
+				lockedUntil := now.Add(window)
+				throttle.LockedUntil = &lockedUntil
+				audit := models.AuthAuditLog{
+					Event:  limit.event,
+					Email:  email,
+					IP:     ip,
+					Detail: fmt.Sprintf("%d failed logins, locked until %s", throttle.Failures, lockedUntil.Format(time.RFC3339)),
+				}
+				if user != nil {
+					audit.UserID = &user.ID
+				}
+				if err := tx.Create(&audit).Error; err != nil {
+					return err
+				}
+			}
+			if err := tx.Save(&throttle).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// resetLoginFailures clears the failed login counter of an account after a successful login.
+// The IP counter is left alone so one valid account can't be used to keep guessing others.
+func resetLoginFailures(email string) {
+	database.DB.Where("throttle_key = ?", accountThrottleKey(email)).Delete(&models.LoginThrottle{})
+}
+
+// respondLoginThrottled rejects a login attempt made before the client's wait is over
+func respondLoginThrottled(c *fiber.Ctx, wait time.Duration) error {
+	seconds := int(math.Ceil(wait.Seconds()))
+	c.Set(fiber.HeaderRetryAfter, fmt.Sprint(seconds))
+	return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+		"error":       "Too many failed login attempts, please try again later",
+		"retry_after": seconds,
+	})
+}
+
+// UnlockUser 管理员解除账户的登录锁定，包括该账户登录失败时被锁定的 IP
+func UnlockUser(c *fiber.Ctx) error {
+	var user models.User
+	if err := database.DB.First(&user, c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// IP locks are recorded in the audit log with the account whose failed login triggered them
+		var ips []string
+		if err := tx.Model(&models.AuthAuditLog{}).Where("event = ? AND (user_id = ? OR email = ?)", models.AuthEventIPLocked, user.ID, user.Email).
+			Distinct().Pluck("ip", &ips).Error; err != nil {
+			return err
+		}
+		keys := []string{accountThrottleKey(user.Email)}
+		for _, ip := range ips {
+			keys = append(keys, ipThrottleKey(ip))
+		}
+		if err := tx.Where("throttle_key IN ?", keys).Delete(&models.LoginThrottle{}).Error; err != nil {
+			return err
+		}
+		audit := models.AuthAuditLog{Event: models.AuthEventAccountUnlocked, UserID: &user.ID, Email: user.Email, IP: c.IP()}
+		if adminID, ok := c.Locals("user_id").(uint); ok {
+			audit.ActorID = &adminID
+		}
+		return tx.Create(&audit).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to unlock user"})
+	}
+	return c.JSON(fiber.Map{"message": "User unlocked successfully"})
+}
+
+var authAuditLogListSpec = listSpec{
+	Fields: map[string]listField{
+		"id":         {Column: "id", Kind: fieldNumber, Sort: true, Filter: true},
+		"event":      {Column: "event", Kind: fieldString, Sort: true, Filter: true},
+		"user_id":    {Column: "user_id", Kind: fieldNumber, Filter: true},
+		"actor_id":   {Column: "actor_id", Kind: fieldNumber, Filter: true},
+		"email":      {Column: "email", Kind: fieldString, Filter: true},
+		"ip":         {Column: "ip", Kind: fieldString, Filter: true},
+		"created_at": {Column: "created_at", Kind: fieldTime, Sort: true, Filter: true},
+	},
+	DefaultSort:     "-id",
+	DefaultPageSize: 100,
+	MaxPageSize:     1000,
+}
+
+// ListAuthAuditLogs 管理员查询认证审计日志，可按 event、user_id、created_at 等过滤
+func ListAuthAuditLogs(c *fiber.Ctx) error {
+	lq, err := parseListQuery(c, authAuditLogListSpec)
+	if err != nil {
+		return respondListError(c, err)
+	}
+	logs := []models.AuthAuditLog{}
+	p, err := lq.find(database.DB.Model(&models.AuthAuditLog{}), &logs)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch audit logs"})
+	}
+	return respondList(c, logs, p)
+}
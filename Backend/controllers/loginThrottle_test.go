@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"fmt"
+	"qp1/models"
+	"testing"
+	"time"
+)
+
+func TestLoginDelay(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{loginDelayAfter, time.Second},
+		{loginDelayAfter + 1, 2 * time.Second},
+		{loginDelayAfter + 2, 4 * time.Second},
+		{loginDelayAfter + 5, 32 * time.Second},
+		{loginDelayAfter + 6, loginMaxDelay},
+		{loginDelayAfter + 100, loginMaxDelay},
+	}
+	for _, tt := range tests {
+		if got := loginDelay(tt.failures); got != tt.want {
+			t.Errorf("loginDelay(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestLoginThrottleLocksAccountAndIP(t *testing.T) {
+	db := setupTest(t)
+	settings, err := getSettings(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings.LoginMaxFailures, settings.LoginMaxFailuresPerIP = 5, 8
+	db.Save(&settings)
+	const ip = "192.0.2.1"
+
+	steps := []struct {
+		name     string
+		email    string
+		ip       string
+		failures int
+		locked   bool
+	}{
+		{"failures below the delay threshold", "a@example.com", ip, loginDelayAfter - 1, false},
+		{"account limit reached", "a@example.com", ip, 5 - (loginDelayAfter - 1), true},
+		{"other account from another IP", "b@example.com", "192.0.2.2", 1, false},
+		{"IP limit reached across accounts", "c@example.com", ip, 8 - 5, true},
+	}
+	for _, step := range steps {
+		for i := 0; i < step.failures; i++ {
+			if err := recordLoginFailure(step.email, step.ip, nil); err != nil {
+				t.Fatalf("%s: %v", step.name, err)
+			}
+		}
+		wait, err := loginRetryAfter(step.email, step.ip)
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		// A lock lasts the whole lockout window; delays before it are at most loginMaxDelay
+		if locked := wait > loginMaxDelay; locked != step.locked {
+			t.Errorf("%s: wait %v, locked %v, want %v", step.name, wait, locked, step.locked)
+		}
+	}
+
+	var events []models.AuthAuditLog
+	db.Order("id").Find(&events)
+	got := ""
+	for _, e := range events {
+		got += fmt.Sprintf("%s %s;", e.Event, e.Email)
+	}
+	want := models.AuthEventAccountLocked + " a@example.com;" + models.AuthEventIPLocked + " c@example.com;"
+	if got != want {
+		t.Errorf("audit events %q, want %q", got, want)
+	}
+
+	// A successful login clears the account counter but not the IP one
+	resetLoginFailures("a@example.com")
+	if wait, _ := loginRetryAfter("a@example.com", "192.0.2.3"); wait != 0 {
+		t.Errorf("account still throttled for %v after reset", wait)
+	}
+	if wait, _ := loginRetryAfter("a@example.com", ip); wait <= loginMaxDelay {
+		t.Errorf("IP lock was cleared by an account reset, wait %v", wait)
+	}
+}
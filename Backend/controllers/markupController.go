@@ -0,0 +1,217 @@
+package controllers
+
+import (
+	"errors"
+	"math"
+	"qp1/database"
+	"qp1/models"
+	"qp1/utils"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// errUnknownClientTier is returned when a quotation names a client tier without a markup rule
+var errUnknownClientTier = errors.New("unknown client tier")
+
+// markupRules are the markups in effect while pricing one quotation
+type markupRules struct {
+	defaultPercent  float64
+	classifications map[string]float64
+	components      map[uint]float64
+	tierPercent     float64
+	currency        string // material prices in other currencies are ignored
+}
+
+// loadMarkupRules loads the markup settings and rules, with the rule of clientTier if one is given
+func loadMarkupRules(tx *gorm.DB, clientTier string) (markupRules, error) {
+	rules := markupRules{classifications: map[string]float64{}, components: map[uint]float64{}}
+	settings, err := getSettings(tx)
+	if err != nil {
+		return rules, err
+	}
+	rules.defaultPercent = settings.DefaultMarkupPercent
+	rules.currency = settings.Currency
+
+	var all []models.MarkupRule
+	if err := tx.Find(&all).Error; err != nil {
+		return rules, err
+	}
+	tierFound := false
+	for _, rule := range all {
+		switch rule.Scope {
+		case models.MarkupScopeClassification:
+			rules.classifications[rule.Target] = rule.MarkupPercent
+		case models.MarkupScopeComponent:
+			if id, err := strconv.ParseUint(rule.Target, 10, 32); err == nil {
+				rules.components[uint(id)] = rule.MarkupPercent
+			}
+		case models.MarkupScopeClientTier:
+			if rule.Target == clientTier {
+				rules.tierPercent = rule.MarkupPercent
+				tierFound = true
+			}
+		}
+	}
+	if clientTier != "" && !tierFound {
+		return rules, errUnknownClientTier
+	}
+	return rules, nil
+}
+
+// componentMarkup returns the markup of a component in percent: the component's own rule, or else the
+// classification markups of its materials weighted by their cost, with its labor and overhead cost at the
+// default markup. The client tier markup is added on top. Materials.Material.SupplierPrices must be preloaded.
+func (r markupRules) componentMarkup(component models.Component, units unitRegistry) float64 {
+	if percent, ok := r.components[component.ID]; ok {
+		return percent + r.tierPercent
+	}
+	var cost, weighted float64
+	for _, line := range component.Materials {
+		unitCost, _ := utils.ResolveMaterialPrice(line.Material, r.currency)
+		lineCost := unitCost * grossQuantity(line, units)
+		percent, ok := r.classifications[line.Material.Classification]
+		if !ok {
+			percent = r.defaultPercent
+		}
+		cost += lineCost
+		weighted += lineCost * percent
+	}
+	conversion := component.LaborCost + component.OverheadCost
+	cost += conversion
+	weighted += conversion * r.defaultPercent
+	if cost == 0 {
+		return r.defaultPercent + r.tierPercent
+	}
+	return weighted/cost + r.tierPercent
+}
+
+// applyMarkup returns the selling price of cost with a markup in percent, rounded to cents
+func applyMarkup(cost float64, percent float64) float64 {
+	return roundCents(cost * (1 + percent/100))
+}
+
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// MarkupRuleRequest is the body for creating or updating a markup rule
+type MarkupRuleRequest struct {
+	Scope         string   `json:"scope"`
+	Target        string   `json:"target"`
+	MarkupPercent *float64 `json:"markup_percent"`
+	Description   string   `json:"description"`
+}
+
+var markupRuleListSpec = listSpec{
+	Fields: map[string]listField{
+		"id":             {Column: "id", Kind: fieldNumber, Sort: true, Filter: true},
+		"scope":          {Column: "scope", Kind: fieldString, Sort: true, Filter: true},
+		"target":         {Column: "target", Kind: fieldString, Sort: true, Filter: true},
+		"markup_percent": {Column: "markup_percent", Kind: fieldNumber, Sort: true, Filter: true},
+	},
+	DefaultSort: "scope,target",
+	MaxPageSize: 500,
+}
+
+// validateMarkupRule checks a rule request and normalizes its target
+func validateMarkupRule(req *MarkupRuleRequest) string {
+	req.Target = strings.TrimSpace(req.Target)
+	switch req.Scope {
+	case models.MarkupScopeClassification, models.MarkupScopeClientTier:
+	case models.MarkupScopeComponent:
+		id, err := strconv.ParseUint(req.Target, 10, 32)
+		if err != nil {
+			return "Target of a component rule must be a component ID"
+		}
+		var component models.Component
+		if err := database.DB.First(&component, id).Error; err != nil {
+			return "Component not found"
+		}
+		req.Target = strconv.FormatUint(id, 10)
+	default:
+		return "Scope must be classification, component or client_tier"
+	}
+	if req.Target == "" {
+		return "Target is required"
+	}
+	if req.MarkupPercent == nil {
+		return "Markup percent is required"
+	}
+	if *req.MarkupPercent <= -100 {
+		return "Markup percent must be greater than -100"
+	}
+	return ""
+}
+
+// ListMarkupRules 查询加价规则（支持排序、过滤、分页）
+func ListMarkupRules(c *fiber.Ctx) error {
+	lq, err := parseListQuery(c, markupRuleListSpec)
+	if err != nil {
+		return respondListError(c, err)
+	}
+	rules := []models.MarkupRule{}
+	p, err := lq.find(database.DB.Model(&models.MarkupRule{}), &rules)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch markup rules"})
+	}
+	return respondList(c, rules, p)
+}
+
+// CreateMarkupRule 新增加价规则；同一范围和目标只能有一条规则
+func CreateMarkupRule(c *fiber.Ctx) error {
+	var req MarkupRuleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Failed to parse request body"})
+	}
+	if msg := validateMarkupRule(&req); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": msg})
+	}
+	var count int64
+	database.DB.Model(&models.MarkupRule{}).Where("scope = ? AND target = ?", req.Scope, req.Target).Count(&count)
+	if count > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "A rule for this target already exists"})
+	}
+	rule := models.MarkupRule{Scope: req.Scope, Target: req.Target, MarkupPercent: *req.MarkupPercent, Description: req.Description}
+	if err := database.DB.Create(&rule).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create markup rule"})
+	}
+	return c.Status(fiber.StatusCreated).JSON(rule)
+}
+
+// UpdateMarkupRule 修改加价规则的加价率和说明
+func UpdateMarkupRule(c *fiber.Ctx) error {
+	var rule models.MarkupRule
+	if err := database.DB.First(&rule, c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Markup rule not found"})
+	}
+	var req MarkupRuleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Failed to parse request body"})
+	}
+	// Scope and target identify the rule and can't be changed
+	req.Scope, req.Target = rule.Scope, rule.Target
+	if msg := validateMarkupRule(&req); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": msg})
+	}
+	rule.MarkupPercent = *req.MarkupPercent
+	rule.Description = req.Description
+	if err := database.DB.Save(&rule).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update markup rule"})
+	}
+	return c.JSON(rule)
+}
+
+// DeleteMarkupRule 删除加价规则
+func DeleteMarkupRule(c *fiber.Ctx) error {
+	result := database.DB.Delete(&models.MarkupRule{}, c.Params("id"))
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete markup rule"})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Markup rule not found"})
+	}
+	return c.JSON(fiber.Map{"message": "Markup rule deleted successfully"})
+}
@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"qp1/models"
+	"testing"
+)
+
+func TestComponentMarkup(t *testing.T) {
+	units := unitRegistry{
+		"m":  {Code: "m", Category: models.UnitCategoryLength, Factor: 1},
+		"mm": {Code: "mm", Category: models.UnitCategoryLength, Factor: 0.001},
+	}
+	wood := models.Material{Name: "Oak", Unit: "m", UnitCost: 10, Classification: "wood"}
+	hardware := models.Material{Name: "Hinge", Unit: "pcs", UnitCost: 10, Classification: "hardware"}
+	other := models.Material{Name: "Glue", Unit: "pcs", UnitCost: 10}
+	line := func(material models.Material, quantity float64) models.ComponentMaterial {
+		return models.ComponentMaterial{Material: material, Quantity: quantity}
+	}
+	rules := markupRules{
+		defaultPercent:  20,
+		classifications: map[string]float64{"wood": 30, "hardware": 10},
+		components:      map[uint]float64{7: 50},
+		currency:        "USD",
+	}
+	withTier := rules
+	withTier.tierPercent = 5
+	hundredPercent := 100.0
+
+	tests := []struct {
+		name      string
+		rules     markupRules
+		component models.Component
+		want      float64
+	}{
+		{"component rule", rules, models.Component{ID: 7, Materials: []models.ComponentMaterial{line(wood, 1)}}, 50},
+		{"component rule plus tier", withTier, models.Component{ID: 7}, 55},
+		{"no cost uses the default", rules, models.Component{ID: 1}, 20},
+		{"no cost uses the default plus tier", withTier, models.Component{ID: 1}, 25},
+		{"one classification", rules, models.Component{ID: 1, Materials: []models.ComponentMaterial{line(wood, 2)}}, 30},
+		{"unclassified material uses the default", rules, models.Component{ID: 1, Materials: []models.ComponentMaterial{line(other, 1)}}, 20},
+		{
+			"weighted by material cost",
+			rules,
+			models.Component{ID: 1, Materials: []models.ComponentMaterial{line(wood, 3), line(hardware, 1)}},
+			(30*30 + 10*10) / 40.0,
+		},
+		{
+			"labor and overhead at the default",
+			rules,
+			models.Component{ID: 1, Materials: []models.ComponentMaterial{line(wood, 1)}, LaborCost: 5, OverheadCost: 5},
+			(10*30 + 10*20) / 20.0,
+		},
+		{
+			"tier on top of the weighted markup",
+			withTier,
+			models.Component{ID: 1, Materials: []models.ComponentMaterial{line(wood, 3), line(hardware, 1)}},
+			(30*30+10*10)/40.0 + 5,
+		},
+		{
+			"waste increases the material's weight",
+			rules,
+			models.Component{ID: 1, Materials: []models.ComponentMaterial{
+				{Material: wood, Quantity: 1, WastePercent: &hundredPercent}, line(hardware, 1),
+			}},
+			(20*30 + 10*10) / 30.0,
+		},
+		{
+			"line unit is converted to the material's unit",
+			rules,
+			models.Component{ID: 1, Materials: []models.ComponentMaterial{
+				{Material: wood, Quantity: 500, Unit: "mm"}, line(hardware, 0.5),
+			}},
+			(5*30 + 5*10) / 10.0,
+		},
+		{
+			"preferred supplier price in the quotation currency",
+			rules,
+			models.Component{ID: 1, Materials: []models.ComponentMaterial{line(hardware, 1), line(models.Material{
+				Classification: "wood", UnitCost: 10, PricingPolicy: models.PricingPolicyPreferred,
+				SupplierPrices: []models.MaterialSupplierPrice{{Price: 30, Currency: "USD", Preferred: true}},
+			}, 1)}},
+			(10*10 + 30*30) / 40.0,
+		},
+		{
+			"supplier price in another currency is ignored",
+			rules,
+			models.Component{ID: 1, Materials: []models.ComponentMaterial{line(hardware, 1), line(models.Material{
+				Classification: "wood", UnitCost: 10, PricingPolicy: models.PricingPolicyPreferred,
+				SupplierPrices: []models.MaterialSupplierPrice{{Price: 30, Currency: "EUR", Preferred: true}},
+			}, 1)}},
+			(10*10 + 10*30) / 20.0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rules.componentMarkup(tt.component, units); !closeTo(got, tt.want) {
+				t.Errorf("componentMarkup() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyMarkup(t *testing.T) {
+	tests := []struct {
+		cost, percent, want float64
+	}{
+		{100, 25, 125},
+		{100, 0, 100},
+		{10, 33.333, 13.33},
+		{0.1, 50, 0.15},
+		{19.99, 12.5, 22.49},
+	}
+	for _, tt := range tests {
+		if got := applyMarkup(tt.cost, tt.percent); got != tt.want {
+			t.Errorf("applyMarkup(%v, %v) = %v, want %v", tt.cost, tt.percent, got, tt.want)
+		}
+	}
+}
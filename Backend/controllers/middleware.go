@@ -3,72 +3,231 @@ package controllers
 import (
 	"qp1/database"
 	"qp1/models"
+	"qp1/utils"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
 )
 
-// RequireAdmin 是一个Fiber中间件，校验JWT中的role字段为admin
-func RequireAdmin(c *fiber.Ctx) error {
+// authError is an authentication failure with the HTTP status to respond with
+type authError struct {
+	status  int
+	message string
+}
+
+func (e *authError) Error() string {
+	return e.message
+}
+
+// authenticate 校验jwt cookie以及对应的服务端会话，会话ID和用户ID写入上下文 session_id、user_id
+func authenticate(c *fiber.Ctx) (jwt.MapClaims, error) {
 	cookie := c.Cookies("jwt")
 	if cookie == "" {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "未登录"})
+		return nil, &authError{fiber.StatusUnauthorized, "未登录"}
 	}
-	token, err := jwt.ParseWithClaims(cookie, &jwt.MapClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(secretKey), nil
-	})
-	if err != nil || !token.Valid {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "无效token"})
+	claims, err := utils.ParseJWT(cookie)
+	if err != nil {
+		return nil, &authError{fiber.StatusUnauthorized, "无效token"}
 	}
-	claims, ok := token.Claims.(*jwt.MapClaims)
-	if !ok {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "解析claims失败"})
+
+	// The access token is only valid while its session has not been revoked
+	sid, _ := claims["sid"].(string)
+	sessionID, err := strconv.ParseUint(sid, 10, 32)
+	if err != nil {
+		return nil, &authError{fiber.StatusUnauthorized, "无效token"}
 	}
-	role, ok := (*claims)["role"].(string)
-	if !ok || role != "admin" {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "无权限，仅限管理员"})
+	var session models.Session
+	if err := database.DB.First(&session, uint(sessionID)).Error; err != nil || !sessionActive(session) {
+		return nil, &authError{fiber.StatusUnauthorized, "会话已失效"}
 	}
-	return c.Next()
+	if time.Since(session.LastSeenAt) > time.Minute {
+		database.DB.Model(&session).UpdateColumn("last_seen_at", time.Now())
+	}
+	c.Locals("session_id", session.ID)
+	c.Locals("user_id", session.UserID)
+	c.Locals("two_factor_verified", session.TwoFactorVerified)
+	return claims, nil
 }
 
-// RequireUser 是一个Fiber中间件，校验JWT并设置用户信息到上下文
-func RequireUser(c *fiber.Ctx) error {
-	cookie := c.Cookies("jwt")
-	if cookie == "" {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "未登录"})
+// respondAuthError writes the response for an error returned by authenticate
+func respondAuthError(c *fiber.Ctx, err error) error {
+	if authErr, ok := err.(*authError); ok {
+		return c.Status(authErr.status).JSON(fiber.Map{"error": authErr.message})
 	}
-	token, err := jwt.ParseWithClaims(cookie, &jwt.MapClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(secretKey), nil
-	})
-	if err != nil || !token.Valid {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "无效token"})
+	return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+}
+
+// authenticateUser 校验登录状态（Bearer API token或jwt cookie）并从数据库读取用户，写入上下文 user
+func authenticateUser(c *fiber.Ctx) (models.User, error) {
+	if header := c.Get(fiber.HeaderAuthorization); header != "" {
+		raw, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok {
+			return models.User{}, &authError{fiber.StatusUnauthorized, "无效token"}
+		}
+		user, err := authenticateAPIToken(c, strings.TrimSpace(raw))
+		if err != nil {
+			return user, err
+		}
+		c.Locals("user", user)
+		return user, nil
 	}
-	claims, ok := token.Claims.(*jwt.MapClaims)
-	if !ok {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "解析claims失败"})
+
+	var user models.User
+	claims, err := authenticate(c)
+	if err != nil {
+		return user, err
 	}
-	id, ok := (*claims)["sub"].(string)
+	id, ok := claims["sub"].(string)
 	if !ok {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "无效用户ID"})
+		return user, &authError{fiber.StatusUnauthorized, "无效用户ID"}
 	}
-	// Remove the unused role variable declaration
-	// role, _ := (*claims)["role"].(string)
-	// Remove unused role variable
 
 	// Convert string ID to uint and fetch user from database
 	userID, err := strconv.ParseUint(id, 10, 32)
 	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "无效用户ID格式"})
+		return user, &authError{fiber.StatusUnauthorized, "无效用户ID格式"}
 	}
 
 	// Fetch user from database
-	var user models.User
 	if err := database.DB.First(&user, uint(userID)).Error; err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "用户不存在"})
+		return user, &authError{fiber.StatusUnauthorized, "用户不存在"}
 	}
 
 	// Store the actual User model
 	c.Locals("user", user)
+	return user, nil
+}
+
+// checkAdminTwoFactor 开启"管理员必须两步验证"后，未通过两步验证的管理员会话不能访问受保护接口。
+// It returns false, together with the result of writing the error response, when the request is refused.
+func checkAdminTwoFactor(c *fiber.Ctx, user models.User) (bool, error) {
+	if user.Role != models.RoleAdmin {
+		return true, nil
+	}
+	if verified, _ := c.Locals("two_factor_verified").(bool); verified {
+		return true, nil
+	}
+	settings, err := getSettings(database.DB)
+	if err != nil {
+		return false, c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to load settings"})
+	}
+	if settings.RequireAdmin2FA {
+		return false, c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error":               "管理员必须开启两步验证",
+			"two_factor_required": true,
+		})
+	}
+	return true, nil
+}
+
+// RequireAdmin 是一个Fiber中间件，校验用户角色为admin
+func RequireAdmin(c *fiber.Ctx) error {
+	user, err := authenticateUser(c)
+	if err != nil {
+		return respondAuthError(c, err)
+	}
+	if user.Role != models.RoleAdmin {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "无权限，仅限管理员"})
+	}
+	if !tokenAllows(c, models.ScopeAdmin) {
+		return respondMissingScope(c, models.ScopeAdmin)
+	}
+	if ok, err := checkAdminTwoFactor(c, user); !ok {
+		return err
+	}
 	return c.Next()
 }
+
+// RequireUser 是一个Fiber中间件，校验JWT并设置用户信息到上下文
+func RequireUser(c *fiber.Ctx) error {
+	if _, err := authenticateUser(c); err != nil {
+		return respondAuthError(c, err)
+	}
+	if !tokenAllows(c, models.ScopeUser) {
+		return respondMissingScope(c, models.ScopeUser)
+	}
+	return c.Next()
+}
+
+// RequireInteractiveSession 拒绝API token请求，用于修改账户、密码、两步验证、会话和token的接口。
+// Must run after RequireUser. A leaked token must not be able to take over the account it belongs to.
+func RequireInteractiveSession(c *fiber.Ctx) error {
+	if _, ok := c.Locals("api_token").(models.APIToken); ok {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "This request requires signing in, API tokens can't be used"})
+	}
+	return c.Next()
+}
+
+// RequirePermission 返回一个Fiber中间件，校验用户角色拥有全部指定权限
+func RequirePermission(permissions ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, err := authenticateUser(c)
+		if err != nil {
+			return respondAuthError(c, err)
+		}
+		granted, err := rolePermissions(user.Role)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to check permissions"})
+		}
+		for _, permission := range permissions {
+			if !granted[permission] {
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+					"error":              "无权限",
+					"missing_permission": permission,
+				})
+			}
+			if !tokenAllows(c, permission) {
+				return respondMissingScope(c, permission)
+			}
+		}
+		if ok, err := checkAdminTwoFactor(c, user); !ok {
+			return err
+		}
+		return c.Next()
+	}
+}
+
+// rolePermissions returns the set of permission names granted to a role
+func rolePermissions(role string) (map[string]bool, error) {
+	var names []string
+	err := database.DB.Table("permissions").
+		Joins("JOIN role_permissions ON role_permissions.permission_id = permissions.id").
+		Joins("JOIN roles ON roles.id = role_permissions.role_id").
+		Where("roles.name = ?", role).
+		Pluck("permissions.name", &names).Error
+	if err != nil {
+		return nil, err
+	}
+	granted := make(map[string]bool, len(names))
+	for _, name := range names {
+		granted[name] = true
+	}
+	return granted, nil
+}
+
+// hasPermission reports whether the user set by RequireUser or RequirePermission has a permission
+func hasPermission(c *fiber.Ctx, permission string) bool {
+	user, ok := c.Locals("user").(models.User)
+	if !ok {
+		return false
+	}
+	if !tokenAllows(c, permission) {
+		return false
+	}
+	granted, err := rolePermissions(user.Role)
+	return err == nil && granted[permission]
+}
+
+// isAdmin reports whether the user set by RequireUser or RequirePermission has the admin role
+func isAdmin(c *fiber.Ctx) bool {
+	user, ok := c.Locals("user").(models.User)
+	return ok && user.Role == models.RoleAdmin && tokenAllows(c, models.ScopeAdmin)
+}
+
+// canManageUser reports whether the current user may change target; only admins may change admin accounts
+func canManageUser(c *fiber.Ctx, target models.User) bool {
+	return target.Role != models.RoleAdmin || isAdmin(c)
+}
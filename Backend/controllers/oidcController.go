@@ -0,0 +1,237 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"qp1/database"
+	"qp1/models"
+	"qp1/utils"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+const (
+	oidcStateCookieName = "oidc_state"
+	oidcStatePurpose    = "oidc"
+	oidcStateTTL        = 10 * time.Minute
+)
+
+var (
+	oidcMu       sync.Mutex
+	oidcProvider *utils.OIDCProvider
+)
+
+// errOIDCAccountConflict is returned when the IdP email belongs to a local account that can't be linked safely
+var errOIDCAccountConflict = errors.New("an account with this email already exists")
+
+// getOIDCProvider returns the configured provider, running discovery on first use.
+// Failed discovery is retried on the next login so a provider outage at startup isn't permanent.
+func getOIDCProvider() (*utils.OIDCProvider, error) {
+	oidcMu.Lock()
+	defer oidcMu.Unlock()
+	if oidcProvider != nil {
+		return oidcProvider, nil
+	}
+	cfg, err := utils.OIDCConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	provider, err := utils.DiscoverOIDC(cfg)
+	if err != nil {
+		return nil, err
+	}
+	oidcProvider = provider
+	return oidcProvider, nil
+}
+
+// oidcRedirectError sends the browser back to the frontend login page with an error code
+func oidcRedirectError(c *fiber.Ctx, code string) error {
+	return c.Redirect(utils.AppURL()+"/login?sso_error="+url.QueryEscape(code), fiber.StatusFound)
+}
+
+// OIDCLogin 跳转到身份提供方登录（授权码 + PKCE）
+func OIDCLogin(c *fiber.Ctx) error {
+	provider, err := getOIDCProvider()
+	if err != nil {
+		fmt.Println("OIDC unavailable:", err)
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Single sign-on is not available"})
+	}
+	state, err1 := utils.GenerateToken(32)
+	nonce, err2 := utils.GenerateToken(32)
+	verifier, err3 := utils.GenerateToken(32)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to generate token"})
+	}
+
+	// state, nonce and the PKCE verifier travel in a signed cookie bound to this browser
+	expires := time.Now().Add(oidcStateTTL)
+	cookie, err := utils.SignJWT(jwt.MapClaims{
+		"purpose":  oidcStatePurpose,
+		"state":    state,
+		"nonce":    nonce,
+		"verifier": verifier,
+		"exp":      expires.Unix(),
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to generate token"})
+	}
+	c.Cookie(&fiber.Cookie{
+		Name:     oidcStateCookieName,
+		Value:    cookie,
+		Path:     "/api/auth/oidc",
+		Expires:  expires,
+		HTTPOnly: true,
+		SameSite: "Lax", // the callback is a top-level redirect from the provider
+	})
+	return c.Redirect(provider.AuthCodeURL(state, nonce, verifier), fiber.StatusFound)
+}
+
+// OIDCCallback 身份提供方回调：校验state和ID token，按需创建用户并同步角色，然后开始会话
+func OIDCCallback(c *fiber.Ctx) error {
+	provider, err := getOIDCProvider()
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Single sign-on is not available"})
+	}
+	stateCookie := c.Cookies(oidcStateCookieName)
+	c.Cookie(&fiber.Cookie{
+		Name:     oidcStateCookieName,
+		Value:    "",
+		Path:     "/api/auth/oidc",
+		Expires:  time.Now().Add(-time.Hour),
+		HTTPOnly: true,
+		SameSite: "Lax",
+	})
+
+	if errCode := c.Query("error"); errCode != "" {
+		fmt.Println("OIDC provider returned error:", errCode, c.Query("error_description"))
+		return oidcRedirectError(c, "provider_error")
+	}
+	state, err := utils.ParseJWT(stateCookie)
+	if err != nil || state["purpose"] != oidcStatePurpose {
+		return oidcRedirectError(c, "expired")
+	}
+	expected, _ := state["state"].(string)
+	if expected == "" || c.Query("state") != expected {
+		return oidcRedirectError(c, "invalid_state")
+	}
+	nonce, _ := state["nonce"].(string)
+	verifier, _ := state["verifier"].(string)
+
+	rawIDToken, err := provider.Exchange(c.Query("code"), verifier)
+	if err != nil {
+		fmt.Println("OIDC code exchange failed:", err)
+		return oidcRedirectError(c, "exchange_failed")
+	}
+	claims, err := provider.VerifyIDToken(rawIDToken, nonce)
+	if err != nil {
+		fmt.Println("OIDC ID token rejected:", err)
+		return oidcRedirectError(c, "invalid_token")
+	}
+
+	user, err := provisionOIDCUser(provider.Config, claims)
+	if err != nil {
+		fmt.Println("OIDC sign-in refused:", err)
+		if errors.Is(err, errOIDCAccountConflict) {
+			return oidcRedirectError(c, "account_exists")
+		}
+		return oidcRedirectError(c, "provisioning_failed")
+	}
+
+	// Accounts with local two-factor authentication still have to enter their code
+	if user.TOTPEnabled {
+		challenge, err := signTwoFactorChallenge(user)
+		if err != nil {
+			return oidcRedirectError(c, "server_error")
+		}
+		return c.Redirect(utils.AppURL()+"/login#two_factor_challenge="+url.QueryEscape(challenge), fiber.StatusFound)
+	}
+
+	// Logins where the provider reports multi-factor authentication count as 2FA-verified
+	if err := startSession(c, user, utils.ClaimHas(claims, "amr", "mfa")); err != nil {
+		return oidcRedirectError(c, "server_error")
+	}
+	return c.Redirect(utils.AppURL()+"/", fiber.StatusFound)
+}
+
+// provisionOIDCUser finds the user for an ID token, creating the account on first sign-in (just-in-time provisioning).
+// With OIDC_ROLE_MAPPING set, the role follows the IdP groups on every sign-in.
+func provisionOIDCUser(cfg utils.OIDCConfig, claims jwt.MapClaims) (models.User, error) {
+	var user models.User
+	issuer, _ := claims["iss"].(string)
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return user, errors.New("ID token has no subject")
+	}
+	email, _ := claims["email"].(string)
+	email = strings.ToLower(strings.TrimSpace(email))
+	emailVerified, _ := claims["email_verified"].(bool)
+	name, _ := claims["name"].(string)
+	role, _ := cfg.MapRole(utils.ClaimStrings(claims, cfg.GroupsClaim))
+	if !roleExists(role) {
+		return user, fmt.Errorf("mapped role %q does not exist", role)
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("oidc_issuer = ? AND oidc_subject = ?", issuer, subject).First(&user).Error
+		if err == nil {
+			if len(cfg.RoleMappings) > 0 {
+				user.Role = role
+			}
+			if name != "" {
+				user.Name = name
+			}
+			return tx.Model(&user).Select("role", "name").Updates(&user).Error
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if email == "" {
+			return errors.New("ID token has no email claim")
+		}
+		// Link an existing local account only when the provider vouches for the email address
+		err = tx.Where("email = ?", email).First(&user).Error
+		if err == nil {
+			if !emailVerified || user.OIDCSubject != nil {
+				return errOIDCAccountConflict
+			}
+			user.OIDCIssuer, user.OIDCSubject = &issuer, &subject
+			if len(cfg.RoleMappings) > 0 {
+				user.Role = role
+			}
+			if err := tx.Model(&user).Select("oidc_issuer", "oidc_subject", "role").Updates(&user).Error; err != nil {
+				return err
+			}
+			// The provider verified the address, which also completes pending sign-ups and invitations
+			return markEmailVerified(tx, &user)
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if name == "" {
+			name = email
+		}
+		// SSO accounts have no password; they can set one through the password reset flow
+		user = models.User{
+			Name:        name,
+			Email:       email,
+			Role:        role,
+			Status:      models.UserStatusActive,
+			OIDCIssuer:  &issuer,
+			OIDCSubject: &subject,
+		}
+		if emailVerified {
+			now := time.Now()
+			user.EmailVerifiedAt = &now
+		}
+		return tx.Create(&user).Error
+	})
+	return user, err
+}
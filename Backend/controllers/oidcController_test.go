@@ -0,0 +1,334 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"qp1/database"
+	"qp1/models"
+	"qp1/utils"
+	"qp1/utils/oidctest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const oidcTestClientID = "qp1-test"
+
+// newOIDCTest starts a mock provider, points the OIDC settings at it and returns an app with the SSO routes
+func newOIDCTest(t *testing.T) (*fiber.App, *oidctest.Provider) {
+	t.Helper()
+	setupTest(t)
+	mock, err := oidctest.NewProvider(oidcTestClientID)
+	if err != nil {
+		t.Fatalf("starting mock provider: %v", err)
+	}
+	t.Cleanup(mock.Close)
+	t.Setenv("OIDC_ISSUER", mock.Issuer())
+	t.Setenv("OIDC_CLIENT_ID", oidcTestClientID)
+	t.Setenv("OIDC_CLIENT_SECRET", "")
+	t.Setenv("OIDC_ROLE_MAPPING", "")
+
+	// Discovery is cached, so every test starts over against its own provider
+	oidcMu.Lock()
+	oidcProvider = nil
+	oidcMu.Unlock()
+	t.Cleanup(func() {
+		oidcMu.Lock()
+		oidcProvider = nil
+		oidcMu.Unlock()
+	})
+
+	app := fiber.New()
+	app.Get("/api/auth/oidc/login", OIDCLogin)
+	app.Get("/api/auth/oidc/callback", OIDCCallback)
+	return app, mock
+}
+
+// oidcFlow is one sign-in in progress: the state cookie set by the login endpoint and the callback query
+// the provider sent the browser back with
+type oidcFlow struct {
+	stateCookie string
+	callback    url.Values
+	authURL     *url.URL
+}
+
+// startOIDCFlow calls the login endpoint and lets the mock provider approve the sign-in
+func startOIDCFlow(t *testing.T, app *fiber.App) oidcFlow {
+	t.Helper()
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/auth/oidc/login", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusFound {
+		t.Fatalf("login returned %d, want a redirect to the provider", resp.StatusCode)
+	}
+	var flow oidcFlow
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == oidcStateCookieName {
+			flow.stateCookie = cookie.Value
+		}
+	}
+	if flow.stateCookie == "" {
+		t.Fatal("login set no state cookie")
+	}
+	flow.authURL, err = url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	authResp, err := client.Get(flow.authURL.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	authResp.Body.Close()
+	back, err := url.Parse(authResp.Header.Get("Location"))
+	if err != nil || back.Query().Get("code") == "" {
+		t.Fatalf("provider did not redirect back with a code: %d %s", authResp.StatusCode, authResp.Header.Get("Location"))
+	}
+	flow.callback = back.Query()
+	return flow
+}
+
+// finish calls the callback and returns where the browser is sent and the cookies set
+func (f oidcFlow) finish(t *testing.T, app *fiber.App) (string, []*http.Cookie) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/api/auth/oidc/callback?"+f.callback.Encode(), nil)
+	if f.stateCookie != "" {
+		req.AddCookie(&http.Cookie{Name: oidcStateCookieName, Value: f.stateCookie})
+	}
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusFound {
+		t.Fatalf("callback returned %d, want a redirect", resp.StatusCode)
+	}
+	return resp.Header.Get("Location"), resp.Cookies()
+}
+
+func hasCookie(cookies []*http.Cookie, name string) bool {
+	for _, cookie := range cookies {
+		if cookie.Name == name && cookie.Value != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func TestOIDCCallbackCreatesUser(t *testing.T) {
+	app, mock := newOIDCTest(t)
+	mock.SetClaims(jwt.MapClaims{"sub": "alice-1", "email": "Alice@Example.com", "email_verified": true, "name": "Alice"})
+
+	flow := startOIDCFlow(t, app)
+	if got := flow.authURL.Query().Get("code_challenge_method"); got != "S256" {
+		t.Errorf("code_challenge_method = %q, want S256", got)
+	}
+	location, cookies := flow.finish(t, app)
+	if location != utils.AppURL()+"/" {
+		t.Fatalf("callback redirected to %q, want the app", location)
+	}
+	if !hasCookie(cookies, "jwt") || !hasCookie(cookies, refreshCookieName) {
+		t.Error("callback started no session")
+	}
+
+	// The verifier sent to the token endpoint must match the challenge sent with the authorization request
+	verifier := mock.LastTokenRequest().Get("code_verifier")
+	if verifier == "" || utils.PKCEChallenge(verifier) != flow.authURL.Query().Get("code_challenge") {
+		t.Errorf("code_verifier %q does not match the code challenge", verifier)
+	}
+
+	var user models.User
+	if err := database.DB.Where("email = ?", "alice@example.com").First(&user).Error; err != nil {
+		t.Fatalf("user was not provisioned: %v", err)
+	}
+	if user.OIDCSubject == nil || *user.OIDCSubject != "alice-1" || user.OIDCIssuer == nil || *user.OIDCIssuer != mock.Issuer() {
+		t.Errorf("user is not linked to the provider identity: issuer %v subject %v", user.OIDCIssuer, user.OIDCSubject)
+	}
+	if user.Name != "Alice" || user.Role != "user" || user.Status != models.UserStatusActive || user.EmailVerifiedAt == nil {
+		t.Errorf("provisioned user = %+v", user)
+	}
+}
+
+func TestOIDCCallbackRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		tamper func(t *testing.T, flow *oidcFlow)
+		want   string
+	}{
+		{
+			name:   "state mismatch",
+			tamper: func(t *testing.T, flow *oidcFlow) { flow.callback.Set("state", "forged") },
+			want:   "invalid_state",
+		},
+		{
+			name:   "missing state cookie",
+			tamper: func(t *testing.T, flow *oidcFlow) { flow.stateCookie = "" },
+			want:   "expired",
+		},
+		{
+			name:   "provider error",
+			tamper: func(t *testing.T, flow *oidcFlow) { flow.callback.Set("error", "access_denied") },
+			want:   "provider_error",
+		},
+		{
+			name: "wrong PKCE verifier",
+			tamper: func(t *testing.T, flow *oidcFlow) {
+				// A correctly signed state cookie from another sign-in carries another verifier
+				claims, err := utils.ParseJWT(flow.stateCookie)
+				if err != nil {
+					t.Fatal(err)
+				}
+				claims["verifier"] = "another-verifier"
+				if flow.stateCookie, err = utils.SignJWT(claims); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: "exchange_failed",
+		},
+		{
+			name:   "nonce mismatch",
+			claims: jwt.MapClaims{"nonce": "replayed-nonce"},
+			want:   "invalid_token",
+		},
+		{
+			name:   "wrong issuer",
+			claims: jwt.MapClaims{"iss": "https://idp.example.com"},
+			want:   "invalid_token",
+		},
+		{
+			name:   "wrong audience",
+			claims: jwt.MapClaims{"aud": "another-client"},
+			want:   "invalid_token",
+		},
+		{
+			name:   "no email",
+			claims: jwt.MapClaims{"email": ""},
+			want:   "provisioning_failed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, mock := newOIDCTest(t)
+			claims := jwt.MapClaims{"sub": "bob-1", "email": "bob@example.com", "email_verified": true}
+			for name, value := range tt.claims {
+				claims[name] = value
+			}
+			mock.SetClaims(claims)
+
+			flow := startOIDCFlow(t, app)
+			if tt.tamper != nil {
+				tt.tamper(t, &flow)
+			}
+			location, cookies := flow.finish(t, app)
+			if want := utils.AppURL() + "/login?sso_error=" + tt.want; location != want {
+				t.Errorf("callback redirected to %q, want %q", location, want)
+			}
+			if hasCookie(cookies, "jwt") {
+				t.Error("a rejected sign-in started a session")
+			}
+			var count int64
+			database.DB.Model(&models.User{}).Count(&count)
+			if count != 0 {
+				t.Errorf("a rejected sign-in created %d users", count)
+			}
+		})
+	}
+}
+
+func TestOIDCCallbackRejectsReusedCode(t *testing.T) {
+	app, mock := newOIDCTest(t)
+	mock.SetClaims(jwt.MapClaims{"sub": "carol-1", "email": "carol@example.com", "email_verified": true})
+	flow := startOIDCFlow(t, app)
+	if location, _ := flow.finish(t, app); location != utils.AppURL()+"/" {
+		t.Fatalf("first callback redirected to %q", location)
+	}
+	if location, _ := flow.finish(t, app); location != utils.AppURL()+"/login?sso_error=exchange_failed" {
+		t.Errorf("replayed callback redirected to %q, want exchange_failed", location)
+	}
+}
+
+func TestOIDCCallbackLinksExistingEmail(t *testing.T) {
+	tests := []struct {
+		name          string
+		emailVerified bool
+		want          string
+		linked        bool
+	}{
+		{"verified email is linked", true, "/", true},
+		{"unverified email is refused", false, "/login?sso_error=account_exists", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, mock := newOIDCTest(t)
+			local := models.User{Name: "Dave", Email: "dave@example.com", Role: "sales", Status: models.UserStatusPending}
+			if err := database.DB.Create(&local).Error; err != nil {
+				t.Fatal(err)
+			}
+			mock.SetClaims(jwt.MapClaims{"sub": "dave-1", "email": "dave@example.com", "email_verified": tt.emailVerified})
+
+			location, _ := startOIDCFlow(t, app).finish(t, app)
+			if location != utils.AppURL()+tt.want {
+				t.Fatalf("callback redirected to %q, want %q", location, utils.AppURL()+tt.want)
+			}
+			var user models.User
+			if err := database.DB.First(&user, local.ID).Error; err != nil {
+				t.Fatal(err)
+			}
+			if linked := user.OIDCSubject != nil && *user.OIDCSubject == "dave-1"; linked != tt.linked {
+				t.Errorf("linked = %v, want %v", linked, tt.linked)
+			}
+			if tt.linked && (user.Status != models.UserStatusActive || user.Role != "sales") {
+				t.Errorf("linked user has status %q and role %q, want active and the local role", user.Status, user.Role)
+			}
+			var count int64
+			database.DB.Model(&models.User{}).Count(&count)
+			if count != 1 {
+				t.Errorf("%d users exist, want the one local account", count)
+			}
+		})
+	}
+}
+
+func TestOIDCCallbackAsksForTwoFactorCode(t *testing.T) {
+	app, mock := newOIDCTest(t)
+	local := models.User{Name: "Erin", Email: "erin@example.com", Role: "user", Status: models.UserStatusActive, TOTPEnabled: true}
+	if err := database.DB.Create(&local).Error; err != nil {
+		t.Fatal(err)
+	}
+	mock.SetClaims(jwt.MapClaims{"sub": "erin-1", "email": "erin@example.com", "email_verified": true})
+
+	location, cookies := startOIDCFlow(t, app).finish(t, app)
+	prefix := utils.AppURL() + "/login#two_factor_challenge="
+	if !strings.HasPrefix(location, prefix) {
+		t.Fatalf("callback redirected to %q, want the two-factor step", location)
+	}
+	if hasCookie(cookies, "jwt") {
+		t.Error("session started before the second factor")
+	}
+	challenge, _ := url.QueryUnescape(strings.TrimPrefix(location, prefix))
+	claims, err := utils.ParseJWT(challenge)
+	if err != nil || claims["purpose"] != twoFactorPurpose {
+		t.Errorf("challenge is not a two-factor login challenge: %v %v", claims, err)
+	}
+}
+
+func TestDiscoverOIDCChecksIssuer(t *testing.T) {
+	mock, err := oidctest.NewProvider(oidcTestClientID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mock.Close()
+
+	if _, err := utils.DiscoverOIDC(utils.OIDCConfig{Issuer: mock.Issuer(), ClientID: oidcTestClientID}); err != nil {
+		t.Errorf("discovery failed: %v", err)
+	}
+	// A provider answering for another issuer (e.g. behind a misconfigured proxy) must be refused
+	if _, err := utils.DiscoverOIDC(utils.OIDCConfig{Issuer: mock.Issuer() + "/tenant", ClientID: oidcTestClientID}); err == nil {
+		t.Error("discovery accepted a document for another issuer")
+	}
+}
@@ -0,0 +1,182 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"qp1/database"
+	"qp1/models"
+	"qp1/utils"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const (
+	emailVerificationTTL = 24 * time.Hour
+	invitationTTL        = 7 * 24 * time.Hour
+)
+
+// verificationRequested is returned whether or not a pending account exists for the email
+const verificationRequested = "If a pending account exists for this email, a verification link has been sent"
+
+func sendVerificationEmail(user models.User, token string) {
+	cfg, err := utils.EmailConfigFromEnv()
+	if err != nil {
+		fmt.Println("Verification email not sent:", err)
+		return
+	}
+	link := utils.AppURL() + "/verify-email?token=" + url.QueryEscape(token)
+	body := fmt.Sprintf("Hello %s,\r\n\r\n"+
+		"Please confirm your email address to activate your account:\r\n\r\n%s\r\n\r\n"+
+		"The link expires in %d hours. If you didn't sign up, you can ignore this email.\r\n",
+		user.Name, link, int(emailVerificationTTL.Hours()))
+	if err := utils.SendEmail(cfg, user.Email, "Confirm your email address", body); err != nil {
+		fmt.Println("Failed to send verification email:", err)
+	}
+}
+
+func sendInvitationEmail(user models.User, invitedBy string, token string) {
+	cfg, err := utils.EmailConfigFromEnv()
+	if err != nil {
+		fmt.Println("Invitation email not sent:", err)
+		return
+	}
+	link := utils.AppURL() + "/accept-invitation?token=" + url.QueryEscape(token)
+	body := fmt.Sprintf("Hello %s,\r\n\r\n"+
+		"%s has invited you to the quotation system. Open the link below to choose your password and activate your account:\r\n\r\n%s\r\n\r\n"+
+		"The invitation expires in %d days.\r\n",
+		user.Name, invitedBy, link, int(invitationTTL.Hours()/24))
+	if err := utils.SendEmail(cfg, user.Email, "You have been invited", body); err != nil {
+		fmt.Println("Failed to send invitation email:", err)
+	}
+}
+
+// inviteUser issues an invitation token for user and emails it in the background
+func inviteUser(c *fiber.Ctx, user models.User) error {
+	token, err := issueUserToken(database.DB, user.ID, models.TokenPurposeInvitation, invitationTTL, c.IP())
+	if err != nil {
+		return err
+	}
+	invitedBy := "An administrator"
+	if admin, ok := c.Locals("user").(models.User); ok && admin.Name != "" {
+		invitedBy = admin.Name
+	}
+	go sendInvitationEmail(user, invitedBy, token)
+	return nil
+}
+
+// VerifyEmail 使用邮件中的链接验证邮箱并激活自助注册的账号
+func VerifyEmail(c *fiber.Ctx) error {
+	var data map[string]string
+	if err := c.BodyParser(&data); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Failed to parse request body"})
+	}
+	userToken, user, err := findUserToken(database.DB, data["token"], models.TokenPurposeEmailVerification)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid or expired token"})
+	}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := consumeUserToken(tx, userToken); err != nil {
+			return err
+		}
+		return markEmailVerified(tx, &user)
+	})
+	if errors.Is(err, errTokenUsed) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid or expired token"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to verify email"})
+	}
+	return c.JSON(fiber.Map{"message": "Email verified, you can now log in"})
+}
+
+// ResendVerificationEmail 重新发送邮箱验证邮件
+func ResendVerificationEmail(c *fiber.Ctx) error {
+	var data map[string]string
+	if err := c.BodyParser(&data); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Failed to parse request body"})
+	}
+	email := strings.TrimSpace(data["email"])
+	if email == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Email is required"})
+	}
+
+	var user models.User
+	if err := database.DB.Where("email = ? AND status = ?", email, models.UserStatusPending).First(&user).Error; err != nil {
+		return c.JSON(fiber.Map{"message": verificationRequested})
+	}
+	// Same per-account throttle as password reset emails
+	var recent int64
+	database.DB.Model(&models.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND created_at > ?", user.ID, models.TokenPurposeEmailVerification, time.Now().Add(-passwordResetInterval)).
+		Count(&recent)
+	if recent > 0 {
+		return c.JSON(fiber.Map{"message": verificationRequested})
+	}
+
+	token, err := issueUserToken(database.DB, user.ID, models.TokenPurposeEmailVerification, emailVerificationTTL, c.IP())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create verification"})
+	}
+	go sendVerificationEmail(user, token)
+	return c.JSON(fiber.Map{"message": verificationRequested})
+}
+
+// AcceptInvitation 受邀用户设置自己的密码并激活账号
+func AcceptInvitation(c *fiber.Ctx) error {
+	var data map[string]string
+	if err := c.BodyParser(&data); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Failed to parse request body"})
+	}
+	if data["password"] == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Password is required"})
+	}
+	userToken, user, err := findUserToken(database.DB, data["token"], models.TokenPurposeInvitation)
+	if err != nil || user.Status != models.UserStatusInvited {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid or expired invitation"})
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := consumeUserToken(tx, userToken); err != nil {
+			return err
+		}
+		if name := strings.TrimSpace(data["name"]); name != "" {
+			user.Name = name
+			if err := tx.Model(&user).Update("name", name).Error; err != nil {
+				return err
+			}
+		}
+		if err := setUserPassword(tx, &user, data["password"]); err != nil {
+			return err
+		}
+		return markEmailVerified(tx, &user)
+	})
+	if errors.Is(err, errTokenUsed) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid or expired invitation"})
+	}
+	if err != nil {
+		return respondPasswordError(c, err)
+	}
+	return c.JSON(fiber.Map{"message": "Invitation accepted, you can now log in"})
+}
+
+// ResendInvitation 管理员重新发送邀请邮件（旧链接失效）
+func ResendInvitation(c *fiber.Ctx) error {
+	var user models.User
+	if err := database.DB.First(&user, c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	}
+	if !canManageUser(c, user) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Only admins can manage admin accounts"})
+	}
+	if user.Status != models.UserStatusInvited {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "User has already accepted the invitation"})
+	}
+	if err := inviteUser(c, user); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create invitation"})
+	}
+	return c.JSON(fiber.Map{"message": "Invitation sent"})
+}
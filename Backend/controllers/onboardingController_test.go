@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"net/http"
+	"qp1/models"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestPendingAccountMustVerifyEmail(t *testing.T) {
+	db, _ := setupPasswordTest(t, "Password-0")
+	settings, _ := getSettings(db)
+	settings.RequireEmailVerification = true
+	db.Save(&settings)
+	app := fiber.New()
+	app.Post("/register", Register)
+	app.Post("/login", Login)
+	app.Post("/verify-email", VerifyEmail)
+	const registration = `{"name": "Alex", "email": "alex@example.com", "password": "Password-1"}`
+	const login = `{"email": "alex@example.com", "password": "Password-1"}`
+
+	// Without email the account could never be verified, so nobody can sign up
+	t.Setenv("SMTP_HOST", "")
+	if status, body := sendJSON(t, app, http.MethodPost, "/register", registration); status != fiber.StatusServiceUnavailable {
+		t.Fatalf("registering without email returned %d: %s", status, body)
+	}
+
+	// Nothing listens on port 1, so sending the email fails without delay
+	t.Setenv("SMTP_HOST", "127.0.0.1")
+	t.Setenv("SMTP_PORT", "1")
+	t.Setenv("SMTP_FROM", "noreply@example.com")
+	status, body := sendJSON(t, app, http.MethodPost, "/register", registration)
+	if status != fiber.StatusOK || !strings.Contains(body, `"email_verification_required":true`) {
+		t.Fatalf("registering returned %d: %s", status, body)
+	}
+	var user models.User
+	db.Where("email = ?", "alex@example.com").First(&user)
+	if user.Status != models.UserStatusPending || user.EmailVerifiedAt != nil {
+		t.Fatalf("a new account is %q, want pending", user.Status)
+	}
+
+	status, body = sendJSON(t, app, http.MethodPost, "/login", login)
+	if status != fiber.StatusForbidden || !strings.Contains(body, `"email_verification_required":true`) {
+		t.Errorf("signing in to a pending account returned %d: %s", status, body)
+	}
+	// The password is checked first, so the response doesn't tell whether the account exists
+	if status, _ := sendJSON(t, app, http.MethodPost, "/login", `{"email": "alex@example.com", "password": "wrong"}`); status != fiber.StatusUnauthorized {
+		t.Errorf("a wrong password for a pending account returned %d", status)
+	}
+
+	// The emailed link only holds the token, so issue one the test knows
+	token, err := issueUserToken(db, user.ID, models.TokenPurposeEmailVerification, emailVerificationTTL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status, body := sendJSON(t, app, http.MethodPost, "/verify-email", `{"token": "`+token+`"}`); status != fiber.StatusOK {
+		t.Fatalf("verifying the email returned %d: %s", status, body)
+	}
+	if status, _ := sendJSON(t, app, http.MethodPost, "/verify-email", `{"token": "`+token+`"}`); status != fiber.StatusBadRequest {
+		t.Errorf("verifying with a used token returned %d", status)
+	}
+	db.First(&user, user.ID)
+	if user.Status != models.UserStatusActive || user.EmailVerifiedAt == nil {
+		t.Errorf("a verified account is %q, verified at %v", user.Status, user.EmailVerifiedAt)
+	}
+	if status, body := sendJSON(t, app, http.MethodPost, "/login", login); status != fiber.StatusOK {
+		t.Errorf("signing in after verifying returned %d: %s", status, body)
+	}
+}
@@ -0,0 +1,123 @@
+package controllers
+
+import (
+	"fmt"
+	"qp1/database"
+	"qp1/models"
+	"qp1/utils"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// passwordPolicyError lists the password policy rules a new password breaks
+type passwordPolicyError struct {
+	violations []string
+}
+
+func (e *passwordPolicyError) Error() string {
+	return strings.Join(e.violations, "; ")
+}
+
+// passwordPolicy builds the password policy from the settings
+func passwordPolicy(settings models.Settings) utils.PasswordPolicy {
+	return utils.PasswordPolicy{
+		MinLength:     settings.PasswordMinLength,
+		RequireUpper:  settings.PasswordRequireUpper,
+		RequireLower:  settings.PasswordRequireLower,
+		RequireDigit:  settings.PasswordRequireDigit,
+		RequireSymbol: settings.PasswordRequireSymbol,
+		CheckCommon:   settings.PasswordCheckCommon,
+	}
+}
+
+// hashNewPassword checks password against the policy and returns its bcrypt hash.
+// Pass a nil user for accounts that don't exist yet; otherwise the user's recent passwords are rejected too.
+func hashNewPassword(tx *gorm.DB, user *models.User, password string) ([]byte, error) {
+	settings, err := getSettings(tx)
+	if err != nil {
+		return nil, err
+	}
+	if violations := utils.ValidatePassword(password, passwordPolicy(settings)); len(violations) > 0 {
+		return nil, &passwordPolicyError{violations}
+	}
+
+	if user != nil && settings.PasswordHistoryCount > 0 {
+		previous := [][]byte{user.Password}
+		if settings.PasswordHistoryCount > 1 {
+			var history []models.PasswordHistory
+			if err := tx.Where("user_id = ?", user.ID).Order("id DESC").Limit(settings.PasswordHistoryCount - 1).Find(&history).Error; err != nil {
+				return nil, err
+			}
+			for _, h := range history {
+				previous = append(previous, h.Password)
+			}
+		}
+		for _, hash := range previous {
+			if len(hash) > 0 && bcrypt.CompareHashAndPassword(hash, []byte(password)) == nil {
+				return nil, &passwordPolicyError{[]string{fmt.Sprintf("Password must not be one of your last %d passwords", settings.PasswordHistoryCount)}}
+			}
+		}
+	}
+
+	return bcrypt.GenerateFromPassword([]byte(password), settings.BcryptCost)
+}
+
+// setUserPassword changes the password of an existing user, enforcing the policy and recording the old hash in the history
+func setUserPassword(tx *gorm.DB, user *models.User, password string) error {
+	hashedPassword, err := hashNewPassword(tx, user, password)
+	if err != nil {
+		return err
+	}
+	settings, err := getSettings(tx)
+	if err != nil {
+		return err
+	}
+	// The current password counts towards the history, so keep one hash less than the policy checks
+	if keep := settings.PasswordHistoryCount - 1; keep > 0 && len(user.Password) > 0 {
+		if err := tx.Create(&models.PasswordHistory{UserID: user.ID, Password: user.Password}).Error; err != nil {
+			return err
+		}
+		var ids []uint
+		if err := tx.Model(&models.PasswordHistory{}).Where("user_id = ?", user.ID).Order("id DESC").Limit(keep).Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ? AND id NOT IN ?", user.ID, ids).Delete(&models.PasswordHistory{}).Error; err != nil {
+			return err
+		}
+	}
+	user.Password = hashedPassword
+	return tx.Model(&models.User{}).Where("id = ?", user.ID).Update("password", hashedPassword).Error
+}
+
+// respondPasswordError writes the response for an error returned by hashNewPassword or setUserPassword
+func respondPasswordError(c *fiber.Ctx, err error) error {
+	if policyErr, ok := err.(*passwordPolicyError); ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":      "Password does not meet the password policy",
+			"violations": policyErr.violations,
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update password"})
+}
+
+// upgradePasswordHash rehashes a password that was just verified when its bcrypt cost is below the configured cost
+func upgradePasswordHash(user *models.User, password string) {
+	cost, err := bcrypt.Cost(user.Password)
+	if err != nil {
+		return
+	}
+	settings, err := getSettings(database.DB)
+	if err != nil || cost >= settings.BcryptCost {
+		return
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), settings.BcryptCost)
+	if err != nil {
+		return
+	}
+	if err := database.DB.Model(&models.User{}).Where("id = ?", user.ID).Update("password", hashedPassword).Error; err == nil {
+		user.Password = hashedPassword
+	}
+}
@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"qp1/database"
+	"qp1/models"
+	"qp1/utils"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const (
+	passwordResetTTL = 30 * time.Minute
+	// passwordResetInterval is the minimum time between two reset emails to the same account
+	passwordResetInterval = 2 * time.Minute
+)
+
+// passwordResetRequested is returned whether or not the account exists, so the endpoint can't be used to probe emails
+const passwordResetRequested = "If an account exists for this email, a password reset link has been sent"
+
+// RequestPasswordReset emails a single-use password reset link to the account owner
+func RequestPasswordReset(c *fiber.Ctx) error {
+	var data map[string]string
+	if err := c.BodyParser(&data); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Failed to parse request body"})
+	}
+	email := strings.TrimSpace(data["email"])
+	if email == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Email is required"})
+	}
+
+	var user models.User
+	if err := database.DB.Where("email = ?", email).First(&user).Error; err != nil {
+		return c.JSON(fiber.Map{"message": passwordResetRequested})
+	}
+
+	// Per-account throttle on top of the per-IP rate limit on the route
+	var recent int64
+	database.DB.Model(&models.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND created_at > ?", user.ID, models.TokenPurposePasswordReset, time.Now().Add(-passwordResetInterval)).
+		Count(&recent)
+	if recent > 0 {
+		return c.JSON(fiber.Map{"message": passwordResetRequested})
+	}
+
+	token, err := issueUserToken(database.DB, user.ID, models.TokenPurposePasswordReset, passwordResetTTL, c.IP())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create password reset"})
+	}
+
+	// Send in the background so the response time doesn't depend on whether the account exists
+	go sendPasswordResetEmail(user, token)
+
+	return c.JSON(fiber.Map{"message": passwordResetRequested})
+}
+
+func sendPasswordResetEmail(user models.User, token string) {
+	cfg, err := utils.EmailConfigFromEnv()
+	if err != nil {
+		fmt.Println("Password reset email not sent:", err)
+		return
+	}
+	link := utils.AppURL() + "/reset-password?token=" + url.QueryEscape(token)
+	body := fmt.Sprintf("Hello %s,\r\n\r\n"+
+		"We received a request to reset your password. Open the link below to choose a new one:\r\n\r\n%s\r\n\r\n"+
+		"The link expires in %d minutes and can only be used once. If you didn't request this, you can ignore this email.\r\n",
+		user.Name, link, int(passwordResetTTL.Minutes()))
+	if err := utils.SendEmail(cfg, user.Email, "Reset your password", body); err != nil {
+		fmt.Println("Failed to send password reset email:", err)
+	}
+}
+
+// ConfirmPasswordReset sets a new password using a token from RequestPasswordReset and signs the user out everywhere.
+// The link was delivered by email, so it also verifies the address of pending and invited accounts.
+func ConfirmPasswordReset(c *fiber.Ctx) error {
+	var data map[string]string
+	if err := c.BodyParser(&data); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Failed to parse request body"})
+	}
+	token := data["token"]
+	newPassword := data["new_password"]
+	if token == "" || newPassword == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Token and new password are required"})
+	}
+
+	userToken, user, err := findUserToken(database.DB, token, models.TokenPurposePasswordReset)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid or expired token"})
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := consumeUserToken(tx, userToken); err != nil {
+			return err
+		}
+		if err := setUserPassword(tx, &user, newPassword); err != nil {
+			return err
+		}
+		if user.Status != models.UserStatusActive {
+			if err := markEmailVerified(tx, &user); err != nil {
+				return err
+			}
+		}
+		if err := revokeUserSessions(tx, user.ID, 0); err != nil {
+			return err
+		}
+		return revokeUserAPITokens(tx, user.ID)
+	})
+	if errors.Is(err, errTokenUsed) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid or expired token"})
+	}
+	if err != nil {
+		return respondPasswordError(c, err)
+	}
+	return c.JSON(fiber.Map{"message": "Password reset successfully"})
+}
@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"net/http"
+	"qp1/models"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// setupPasswordTest lowers the bcrypt cost so hashing doesn't slow the tests down, and creates a user
+func setupPasswordTest(t *testing.T, password string) (*gorm.DB, models.User) {
+	t.Helper()
+	db := setupTest(t)
+	settings, err := getSettings(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings.BcryptCost = bcrypt.MinCost
+	db.Save(&settings)
+	hash, _ := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	user := models.User{Name: "Sam", Email: "sam@example.com", Password: hash, Role: "user", Status: models.UserStatusActive}
+	db.Create(&user)
+	return db, user
+}
+
+func TestPasswordResetTokens(t *testing.T) {
+	db, user := setupPasswordTest(t, "Old-password-1")
+	app := fiber.New()
+	app.Post("/request", RequestPasswordReset)
+	app.Post("/confirm", ConfirmPasswordReset)
+	session := newTestSession(t, db, "signed-in", time.Now().Add(time.Hour), false)
+	db.Model(&session).Update("user_id", user.ID)
+
+	confirm := func(token string, password string) int {
+		t.Helper()
+		status, _ := sendJSON(t, app, http.MethodPost, "/confirm", `{"token": "`+token+`", "new_password": "`+password+`"}`)
+		return status
+	}
+
+	expired, err := issueUserToken(db, user.ID, models.TokenPurposePasswordReset, -time.Minute, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := confirm(expired, "New-password-1"); status != fiber.StatusBadRequest {
+		t.Errorf("an expired token returned %d", status)
+	}
+
+	// Issuing a token invalidates the ones before it
+	replaced, _ := issueUserToken(db, user.ID, models.TokenPurposePasswordReset, passwordResetTTL, "")
+	token, _ := issueUserToken(db, user.ID, models.TokenPurposePasswordReset, passwordResetTTL, "")
+	if status := confirm(replaced, "New-password-1"); status != fiber.StatusBadRequest {
+		t.Errorf("a replaced token returned %d", status)
+	}
+	if status := confirm(token, "New-password-1"); status != fiber.StatusOK {
+		t.Fatalf("resetting the password returned %d", status)
+	}
+	db.First(&user, user.ID)
+	if bcrypt.CompareHashAndPassword(user.Password, []byte("New-password-1")) != nil {
+		t.Error("the password was not changed")
+	}
+	if !sessionRevoked(db, session.ID) {
+		t.Error("the reset did not sign the user out")
+	}
+
+	// A token can only be used once
+	if status := confirm(token, "Other-password-2"); status != fiber.StatusBadRequest {
+		t.Errorf("reusing a token returned %d", status)
+	}
+	if status := confirm("not-a-token", "Other-password-2"); status != fiber.StatusBadRequest {
+		t.Errorf("an unknown token returned %d", status)
+	}
+	db.First(&user, user.ID)
+	if bcrypt.CompareHashAndPassword(user.Password, []byte("New-password-1")) != nil {
+		t.Error("a rejected token changed the password")
+	}
+}
+
+func TestRequestPasswordResetIsThrottled(t *testing.T) {
+	db, user := setupPasswordTest(t, "Old-password-1")
+	t.Setenv("SMTP_HOST", "")
+	app := fiber.New()
+	app.Post("/request", RequestPasswordReset)
+
+	for _, email := range []string{"sam@example.com", "sam@example.com", "nobody@example.com"} {
+		status, body := sendJSON(t, app, http.MethodPost, "/request", `{"email": "`+email+`"}`)
+		if status != fiber.StatusOK {
+			t.Errorf("requesting a reset for %s returned %d: %s", email, status, body)
+		}
+	}
+	var tokens []models.UserToken
+	db.Where("purpose = ?", models.TokenPurposePasswordReset).Find(&tokens)
+	if len(tokens) != 1 || tokens[0].UserID != user.ID {
+		t.Errorf("got %d reset tokens, want one for the existing account", len(tokens))
+	}
+}
@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"net/http"
+	"qp1/models"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestChangePasswordEnforcesPolicyAndHistory(t *testing.T) {
+	db, user := setupPasswordTest(t, "Password-0")
+	settings, _ := getSettings(db)
+	settings.PasswordHistoryCount = 3
+	settings.PasswordRequireDigit = true
+	db.Save(&settings)
+
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		// Like RequireUser, the user is loaded fresh for every request
+		var current models.User
+		db.First(&current, user.ID)
+		c.Locals("user", current)
+		return c.Next()
+	})
+	app.Post("/change-password", ChangePassword)
+
+	current := "Password-0"
+	steps := []struct {
+		name     string
+		password string
+		status   int
+		error    string
+	}{
+		{"policy violation", "no-digits-here", fiber.StatusBadRequest, "Password must contain a digit"},
+		{"common password", "password1", fiber.StatusBadRequest, "Password is too common"},
+		{"current password", "Password-0", fiber.StatusBadRequest, "last 3 passwords"},
+		{"first change", "Password-1", fiber.StatusOK, ""},
+		{"second change", "Password-2", fiber.StatusOK, ""},
+		{"one of the last three", "Password-0", fiber.StatusBadRequest, "last 3 passwords"},
+		{"third change", "Password-3", fiber.StatusOK, ""},
+		{"dropped out of the history", "Password-0", fiber.StatusOK, ""},
+		{"still in the history", "Password-2", fiber.StatusBadRequest, "last 3 passwords"},
+	}
+	for _, step := range steps {
+		status, body := sendJSON(t, app, http.MethodPost, "/change-password",
+			`{"old_password": "`+current+`", "new_password": "`+step.password+`"}`)
+		if status != step.status || !strings.Contains(body, step.error) {
+			t.Fatalf("%s: returned %d: %s", step.name, status, body)
+		}
+		if status == fiber.StatusOK {
+			current = step.password
+		}
+	}
+
+	var history int64
+	db.Model(&models.PasswordHistory{}).Where("user_id = ?", user.ID).Count(&history)
+	if history != 2 {
+		t.Errorf("%d passwords kept in the history, want 2 besides the current one", history)
+	}
+	if status, _ := sendJSON(t, app, http.MethodPost, "/change-password", `{"old_password": "wrong", "new_password": "Password-9"}`); status != fiber.StatusUnauthorized {
+		t.Errorf("a wrong old password returned %d", status)
+	}
+}
+
+func TestUpgradePasswordHash(t *testing.T) {
+	db, user := setupPasswordTest(t, "Password-0")
+	settings, _ := getSettings(db)
+	settings.BcryptCost = bcrypt.MinCost + 1
+	db.Save(&settings)
+
+	upgradePasswordHash(&user, "Password-0")
+	db.First(&user, user.ID)
+	if cost, _ := bcrypt.Cost(user.Password); cost != bcrypt.MinCost+1 {
+		t.Errorf("hash cost %d after login, want %d", cost, bcrypt.MinCost+1)
+	}
+	if bcrypt.CompareHashAndPassword(user.Password, []byte("Password-0")) != nil {
+		t.Error("the upgraded hash doesn't match the password")
+	}
+}
@@ -7,46 +7,36 @@ import (
 	"github.com/gofiber/fiber/v2"
 )
 
-// GetProductList 普通用户获取所有物料列表，支持分类筛选、分页、关键字搜索
+// GetProductList 普通用户获取物料列表，支持排序、过滤（classification、supplier_id 等）、分页、关键字搜索
 func GetProductList(c *fiber.Ctx) error {
-	var materials []models.Material
-	query := database.DB
+	lq, err := parseListQuery(c, materialListSpec)
+	if err != nil {
+		return respondListError(c, err)
+	}
+	query := database.DB.Model(&models.Material{})
 
-	// 分类筛选
-	classification := c.Query("classification")
-	if classification != "" {
-		query = query.Where("classification = ?", classification)
+	// SKU前缀搜索
+	sku := c.Query("sku")
+	if sku != "" {
+		query = query.Where("sku LIKE ?", sku+"%")
 	}
 
-	// 关键字搜索（name/description模糊匹配）
+	// 关键字搜索（name/description/sku全文检索，未指定排序时按相关度排序）
 	keyword := c.Query("keyword")
 	if keyword != "" {
-		like := "%" + keyword + "%"
-		query = query.Where("name LIKE ? OR description LIKE ?", like, like)
-	}
-
-	// 分页
-	page := c.QueryInt("page", 1)
-	pageSize := c.QueryInt("page_size", 10)
-	if page < 1 {
-		page = 1
-	}
-	if pageSize < 1 || pageSize > 100 {
-		pageSize = 10
+		search := newTextSearch(database.DB, keyword, "name", "description", "sku")
+		query = search.where(query)
+		if lq.rankable() {
+			query = search.orderByRelevance(query)
+		}
 	}
-	offset := (page - 1) * pageSize
 
-	var total int64
-	query.Model(&models.Material{}).Count(&total)
-	if err := query.Offset(offset).Limit(pageSize).Find(&materials).Error; err != nil {
+	materials := []models.Material{}
+	p, err := lq.find(query, &materials)
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch product list"})
 	}
-	return c.JSON(fiber.Map{
-		"total":     total,
-		"page":      page,
-		"page_size": pageSize,
-		"data":      materials,
-	})
+	return respondList(c, materials, p)
 }
 
 // GetProductDetail 获取单个物料详情
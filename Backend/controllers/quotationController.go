@@ -7,6 +7,7 @@ import (
 
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/shopspring/decimal"
@@ -18,16 +19,31 @@ type CreateQuotationRequest struct {
 	Title       string                       `json:"title" validate:"required,min=1,max=255"`
 	Description string                       `json:"description" validate:"max=1000"`
 	ClientName  string                       `json:"client_name,omitempty"`
+	ClientTier  string                       `json:"client_tier,omitempty"`
 	Items       []CreateQuotationItemRequest `json:"items" validate:"required,min=1"`
+	// Sections in print order; sections named by items but not listed here follow in order of appearance
+	Sections []string `json:"sections,omitempty"`
 }
 
+// CreateQuotationItemRequest is a component item, or an ad-hoc line when ComponentID is 0. Ad-hoc lines
+// need a description and unit price instead of a component and dimensions.
 type CreateQuotationItemRequest struct {
-	ComponentID uint    `json:"component_id" validate:"required"`
-	Length      float64 `json:"length" validate:"required,min=0.1"`
-	Width       float64 `json:"width" validate:"required,min=0.1"`
-	Height      float64 `json:"height" validate:"required,min=0.1"`
-	Quantity    int     `json:"quantity" validate:"required,min=1"`
-	Notes       string  `json:"notes" validate:"max=255"`
+	ComponentID uint    `json:"component_id"`
+	Length      float64 `json:"length" validate:"min=0.1"`
+	Width       float64 `json:"width" validate:"min=0.1"`
+	Height      float64 `json:"height" validate:"min=0.1"`
+	// DimensionUnit is the length unit of the dimensions, metres when empty
+	DimensionUnit string `json:"dimension_unit" validate:"max=20"`
+	Quantity      int    `json:"quantity" validate:"required,min=1"`
+	Notes         string `json:"notes" validate:"max=255"`
+	Section       string `json:"section" validate:"max=100"` // name of the item's section, empty for none
+	TaxExempt     bool   `json:"tax_exempt"`
+
+	// Ad-hoc lines only
+	Description string   `json:"description" validate:"max=255"`
+	Unit        string   `json:"unit" validate:"max=50"`
+	UnitPrice   *float64 `json:"unit_price"`
+	UnitCost    *float64 `json:"unit_cost"` // defaults to the unit price
 }
 
 // Validation response structure
@@ -62,7 +78,11 @@ func CreateQuotation(c *fiber.Ctx) error {
 			Errors:  validationErrors,
 		})
 	}
+	return createQuotation(c, req)
+}
 
+// createQuotation creates a quotation for the current user from a validated request and costs its items
+func createQuotation(c *fiber.Ctx, req CreateQuotationRequest) error {
 	// Extract user ID from context
 	userData := c.Locals("user").(models.User)
 	userID := uint(userData.ID)
@@ -92,6 +112,7 @@ func CreateQuotation(c *fiber.Ctx) error {
 		Title:       req.Title,
 		Description: req.Description,
 		ClientName:  utils.SanitizeClientName(req.ClientName),
+		ClientTier:  req.ClientTier,
 		Status:      "draft",
 		TotalCost:   decimal.NewFromFloat(0),
 		QuotationNo: quotationNo,
@@ -108,8 +129,7 @@ func CreateQuotation(c *fiber.Ctx) error {
 	}
 
 	// Process items with enhanced error handling
-	totalCost, err := processQuotationItems(tx, quotation.ID, req.Items)
-	if err != nil {
+	if err := processQuotationItems(tx, &quotation, req.Sections, req.Items); err != nil {
 		tx.Rollback()
 		return c.Status(fiber.StatusBadRequest).JSON(APIResponse{
 			Success: false,
@@ -117,8 +137,7 @@ func CreateQuotation(c *fiber.Ctx) error {
 		})
 	}
 
-	// Update total cost
-	quotation.TotalCost = decimal.NewFromFloat(totalCost)
+	// Update totals
 	if err := tx.Save(&quotation).Error; err != nil {
 		tx.Rollback()
 		return c.Status(fiber.StatusInternalServerError).JSON(APIResponse{
@@ -127,6 +146,15 @@ func CreateQuotation(c *fiber.Ctx) error {
 		})
 	}
 
+	// Keep the full-text search column in step with the items
+	if err := database.RefreshQuotationSearchText(tx, quotation.ID); err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(APIResponse{
+			Success: false,
+			Message: "Failed to update search index",
+		})
+	}
+
 	// Commit transaction
 	if err := tx.Commit().Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(APIResponse{
@@ -138,14 +166,14 @@ func CreateQuotation(c *fiber.Ctx) error {
 	// Fetch complete quotation
 	var completeQuotation models.Quotation
 	database.DB.Preload("User").
-		Preload("Items.Component").
+		Scopes(preloadQuotationItems).
 		Preload("Materials.Material").
 		First(&completeQuotation, quotation.ID)
 
 	return c.Status(fiber.StatusCreated).JSON(APIResponse{
 		Success: true,
 		Message: "Quotation created successfully",
-		Data:    completeQuotation,
+		Data:    presentQuotation(c, completeQuotation),
 	})
 }
 
@@ -159,21 +187,12 @@ func SaveQuotationDraft(c *fiber.Ctx) error {
 		})
 	}
 
-	// Enhanced validation for special characters
-	if req.Title == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(APIResponse{
-			Success: false,
-			Message: "Title is required",
-			Errors:  []ValidationError{{Field: "title", Message: "Title cannot be empty"}},
-		})
-	}
-
-	// Validate text fields for potential JSON issues
-	if len(req.Title) > 255 {
+	// Drafts may be saved before any item is added, but the items they have are checked like a quotation's
+	if validationErrors := validateDraftRequest(req); len(validationErrors) > 0 {
 		return c.Status(fiber.StatusBadRequest).JSON(APIResponse{
 			Success: false,
-			Message: "Title too long",
-			Errors:  []ValidationError{{Field: "title", Message: "Title must be less than 255 characters"}},
+			Message: "Validation failed",
+			Errors:  validationErrors,
 		})
 	}
 
@@ -193,6 +212,15 @@ func SaveQuotationDraft(c *fiber.Ctx) error {
 
 // Helper functions
 func validateCreateQuotationRequest(req CreateQuotationRequest) []ValidationError {
+	errors := validateDraftRequest(req)
+	if len(req.Items) == 0 {
+		errors = append(errors, ValidationError{Field: "items", Message: "At least one item is required"})
+	}
+	return errors
+}
+
+// validateDraftRequest checks a quotation request except that it has items, which a draft may not have yet
+func validateDraftRequest(req CreateQuotationRequest) []ValidationError {
 	var errors []ValidationError
 
 	if req.Title == "" {
@@ -204,23 +232,39 @@ func validateCreateQuotationRequest(req CreateQuotationRequest) []ValidationErro
 	if len(req.Description) > 1000 {
 		errors = append(errors, ValidationError{Field: "description", Message: "Description must be less than 1000 characters"})
 	}
-	if len(req.Items) == 0 {
-		errors = append(errors, ValidationError{Field: "items", Message: "At least one item is required"})
-	}
-
 	// Validate client name
 	if len(req.ClientName) > 100 {
 		errors = append(errors, ValidationError{Field: "client_name", Message: "Client name must be less than 100 characters"})
 	}
+	if len(req.ClientTier) > 100 {
+		errors = append(errors, ValidationError{Field: "client_tier", Message: "Client tier must be less than 100 characters"})
+	}
+
+	// Validate sections
+	for i, name := range req.Sections {
+		if len(strings.TrimSpace(name)) > 100 {
+			errors = append(errors, ValidationError{Field: fmt.Sprintf("sections[%d]", i), Message: "Section name must be less than 100 characters"})
+		}
+	}
 
 	// Validate items
 	for i, item := range req.Items {
-		if item.ComponentID == 0 {
+		if len(item.Notes) > 255 {
+			errors = append(errors, ValidationError{
+				Field:   fmt.Sprintf("items[%d].notes", i),
+				Message: "Notes must be less than 255 characters",
+			})
+		}
+		if len(strings.TrimSpace(item.Section)) > 100 {
 			errors = append(errors, ValidationError{
-				Field:   fmt.Sprintf("items[%d].component_id", i),
-				Message: "Component ID is required",
+				Field:   fmt.Sprintf("items[%d].section", i),
+				Message: "Section name must be less than 100 characters",
 			})
 		}
+		if item.ComponentID == 0 {
+			errors = append(errors, validateAdHocItem(i, item)...)
+			continue
+		}
 		if item.Length <= 0 {
 			errors = append(errors, ValidationError{
 				Field:   fmt.Sprintf("items[%d].length", i),
@@ -250,71 +294,263 @@ func validateCreateQuotationRequest(req CreateQuotationRequest) []ValidationErro
 	return errors
 }
 
-func processQuotationItems(tx *gorm.DB, quotationID uint, items []CreateQuotationItemRequest) (float64, error) {
-	totalCost := 0.0
-	materialMap := make(map[uint]float64)
+// validateAdHocItem validates an item without a component
+func validateAdHocItem(i int, item CreateQuotationItemRequest) []ValidationError {
+	var errors []ValidationError
+	if strings.TrimSpace(item.Description) == "" {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("items[%d].description", i),
+			Message: "Description is required for items without a component",
+		})
+	}
+	if len(item.Description) > 255 {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("items[%d].description", i),
+			Message: "Description must be less than 255 characters",
+		})
+	}
+	if len(item.Unit) > 50 {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("items[%d].unit", i),
+			Message: "Unit must be less than 50 characters",
+		})
+	}
+	if item.UnitPrice == nil {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("items[%d].unit_price", i),
+			Message: "Unit price is required for items without a component",
+		})
+	} else if *item.UnitPrice < 0 {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("items[%d].unit_price", i),
+			Message: "Unit price must not be negative",
+		})
+	}
+	if item.UnitCost != nil && *item.UnitCost < 0 {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("items[%d].unit_cost", i),
+			Message: "Unit cost must not be negative",
+		})
+	}
+	if item.Quantity <= 0 {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("items[%d].quantity", i),
+			Message: "Quantity must be greater than 0",
+		})
+	}
+	return errors
+}
 
-	for _, itemReq := range items {
-		// Verify component exists
-		var component models.Component
-		if err := tx.Preload("Materials.Material").First(&component, itemReq.ComponentID).Error; err != nil {
-			return 0, fmt.Errorf("component with ID %d not found", itemReq.ComponentID)
-		}
+// processQuotationItems creates the items and resolved materials of a quotation and sets its cost, sell and tax
+// totals. Component items are sold at cost plus the markup of their component, see markupRules; ad-hoc items at
+// their given unit price. Items are kept in request order and grouped into sections, see createQuotationSections.
+func processQuotationItems(tx *gorm.DB, quotation *models.Quotation, sections []string, items []CreateQuotationItemRequest) error {
+	markups, err := loadMarkupRules(tx, quotation.ClientTier)
+	if err != nil {
+		return err
+	}
+	sectionsByName, err := createQuotationSections(tx, quotation.ID, sections, items)
+	if err != nil {
+		return err
+	}
+	settings, err := getSettings(tx)
+	if err != nil {
+		return err
+	}
+	units, err := loadUnits(tx)
+	if err != nil {
+		return err
+	}
 
-		// Calculate costs
-		volumeMultiplier := itemReq.Length * itemReq.Width * itemReq.Height
-		itemUnitCost := component.TotalCost * volumeMultiplier
-		itemTotalCost := itemUnitCost * float64(itemReq.Quantity)
-
-		// Create quotation item
-		quotationItem := models.QuotationItem{
-			QuotationID: quotationID,
-			ComponentID: itemReq.ComponentID,
-			Length:      itemReq.Length,
-			Width:       itemReq.Width,
-			Height:      itemReq.Height,
-			Quantity:    itemReq.Quantity,
-			UnitCost:    itemUnitCost,
-			TotalCost:   itemTotalCost,
+	totalCost := 0.0
+	sellTotal := 0.0
+	taxable := 0.0
+	netQuantities := make(map[uint]float64)
+	grossQuantities := make(map[uint]float64)
+
+	for position, itemReq := range items {
+		var quotationItem models.QuotationItem
+		if itemReq.ComponentID == 0 {
+			quotationItem = adHocQuotationItem(itemReq)
+		} else {
+			// Verify component exists
+			var component models.Component
+			if err := tx.Preload("Materials.Material.SupplierPrices").First(&component, itemReq.ComponentID).Error; err != nil {
+				return fmt.Errorf("component with ID %d not found", itemReq.ComponentID)
+			}
+
+			// Component costs are per metre, so dimensions in other length units are converted first
+			dimensionUnit := strings.TrimSpace(itemReq.DimensionUnit)
+			if dimensionUnit == "" {
+				dimensionUnit = defaultDimensionUnit
+			}
+			volumeMultiplier := 1.0
+			for _, dimension := range []float64{itemReq.Length, itemReq.Width, itemReq.Height} {
+				metres, err := units.toMetres(dimension, dimensionUnit)
+				if err != nil {
+					return err
+				}
+				volumeMultiplier *= metres
+			}
+
+			// Calculate costs
+			itemUnitCost := component.TotalCost * volumeMultiplier
+			itemTotalCost := itemUnitCost * float64(itemReq.Quantity)
+			multiplier := volumeMultiplier * float64(itemReq.Quantity)
+
+			// Selling price from the markup rules
+			markup := markups.componentMarkup(component, units)
+			sellUnitPrice := applyMarkup(itemUnitCost, markup)
+
+			quotationItem = models.QuotationItem{
+				ComponentID:   &component.ID,
+				Length:        itemReq.Length,
+				Width:         itemReq.Width,
+				Height:        itemReq.Height,
+				DimensionUnit: dimensionUnit,
+				Quantity:      itemReq.Quantity,
+				UnitCost:      itemUnitCost,
+				TotalCost:     itemTotalCost,
+				MaterialCost:  component.MaterialCost * multiplier,
+				LaborCost:     component.LaborCost * multiplier,
+				OverheadCost:  component.OverheadCost * multiplier,
+				MarkupPercent: markup,
+				SellUnitPrice: sellUnitPrice,
+				SellTotal:     roundCents(sellUnitPrice * float64(itemReq.Quantity)),
+			}
+
+			// Accumulate materials
+			for _, compMaterial := range component.Materials {
+				netQuantities[compMaterial.MaterialID] += units.materialQuantity(compMaterial) * multiplier
+				grossQuantities[compMaterial.MaterialID] += grossQuantity(compMaterial, units) * multiplier
+			}
+		}
+		quotationItem.QuotationID = quotation.ID
+		quotationItem.Position = position
+		quotationItem.TaxExempt = itemReq.TaxExempt
+		quotationItem.Notes = strings.TrimSpace(itemReq.Notes)
+		section := sectionsByName[strings.TrimSpace(itemReq.Section)]
+		if section != nil {
+			quotationItem.SectionID = &section.ID
 		}
 
 		if err := tx.Create(&quotationItem).Error; err != nil {
-			return 0, fmt.Errorf("failed to create quotation item: %v", err)
+			return fmt.Errorf("failed to create quotation item: %v", err)
 		}
 
-		// Accumulate materials
-		for _, compMaterial := range component.Materials {
-			materialID := compMaterial.MaterialID
-			materialQuantity := compMaterial.Quantity * volumeMultiplier * float64(itemReq.Quantity)
-			materialMap[materialID] += materialQuantity
+		totalCost += quotationItem.TotalCost
+		sellTotal += quotationItem.SellTotal
+		if !quotationItem.TaxExempt {
+			taxable += quotationItem.SellTotal
+		}
+		if section != nil {
+			section.Subtotal += quotationItem.SellTotal
 		}
+	}
 
-		totalCost += itemTotalCost
+	for _, section := range sectionsByName {
+		section.Subtotal = roundCents(section.Subtotal)
+		if err := tx.Model(section).UpdateColumn("subtotal", section.Subtotal).Error; err != nil {
+			return fmt.Errorf("failed to update quotation section: %v", err)
+		}
 	}
 
-	// Create quotation materials
-	for materialID, totalQuantity := range materialMap {
+	// Create quotation materials. Items are costed for the material they use including waste; the material left
+	// over from buying whole purchase increments is the surplus cost of its quotation material, added to the
+	// quotation's cost but not to any item.
+	for materialID, gross := range grossQuantities {
 		var material models.Material
-		if err := tx.First(&material, materialID).Error; err != nil {
-			return 0, fmt.Errorf("material with ID %d not found", materialID)
+		if err := tx.Preload("SupplierPrices").First(&material, materialID).Error; err != nil {
+			return fmt.Errorf("material with ID %d not found", materialID)
 		}
 
+		// Cost with the supplier price chosen by the material's pricing policy
+		unitCost, supplierPrice := utils.ResolveMaterialPrice(material, settings.Currency)
+		quantity := utils.RoundUpToIncrement(gross, material.PurchaseIncrement)
 		quotationMaterial := models.QuotationMaterial{
-			QuotationID:  quotationID,
-			MaterialID:   materialID,
-			MaterialName: material.Name,
-			Unit:         material.Unit,
-			UnitCost:     material.UnitCost,
-			Quantity:     totalQuantity,
-			TotalCost:    material.UnitCost * totalQuantity,
+			QuotationID:   quotation.ID,
+			MaterialID:    materialID,
+			MaterialName:  material.Name,
+			Unit:          material.Unit,
+			UnitCost:      unitCost,
+			NetQuantity:   netQuantities[materialID],
+			GrossQuantity: gross,
+			Quantity:      quantity,
+			TotalCost:     unitCost * quantity,
+			SurplusCost:   unitCost * (quantity - gross),
+		}
+		if supplierPrice != nil {
+			quotationMaterial.SupplierID = &supplierPrice.SupplierID
 		}
 
 		if err := tx.Create(&quotationMaterial).Error; err != nil {
-			return 0, fmt.Errorf("failed to create quotation material: %v", err)
+			return fmt.Errorf("failed to create quotation material: %v", err)
 		}
+		totalCost += quotationMaterial.SurplusCost
+	}
+
+	quotation.TotalCost = decimal.NewFromFloat(totalCost)
+	quotation.SellTotal = decimal.NewFromFloat(sellTotal)
+	quotation.TaxRate = settings.TaxRate
+	quotation.TaxTotal = decimal.NewFromFloat(roundCents(taxable * settings.TaxRate / 100))
+	quotation.GrandTotal = quotation.SellTotal.Add(quotation.TaxTotal)
+	return nil
+}
+
+// createQuotationSections creates the sections of a quotation: the listed sections in order, then the sections
+// named only by items in order of first use. Items without a section stay outside of all sections.
+func createQuotationSections(tx *gorm.DB, quotationID uint, sections []string, items []CreateQuotationItemRequest) (map[string]*models.QuotationSection, error) {
+	names := make([]string, 0, len(sections))
+	for _, name := range sections {
+		names = append(names, strings.TrimSpace(name))
+	}
+	for _, item := range items {
+		names = append(names, strings.TrimSpace(item.Section))
 	}
 
-	return totalCost, nil
+	byName := make(map[string]*models.QuotationSection)
+	for _, name := range names {
+		if name == "" || byName[name] != nil {
+			continue
+		}
+		section := &models.QuotationSection{QuotationID: quotationID, Name: name, Position: len(byName)}
+		if err := tx.Create(section).Error; err != nil {
+			return nil, fmt.Errorf("failed to create quotation section: %v", err)
+		}
+		byName[name] = section
+	}
+	return byName, nil
+}
+
+// adHocQuotationItem prices an item without a component at its given unit price. Without a unit cost the
+// price is taken as cost, so the line adds no margin.
+func adHocQuotationItem(itemReq CreateQuotationItemRequest) models.QuotationItem {
+	quantity := itemReq.Quantity
+	price := 0.0
+	if itemReq.UnitPrice != nil {
+		price = roundCents(*itemReq.UnitPrice)
+	}
+	cost := price
+	if itemReq.UnitCost != nil {
+		cost = *itemReq.UnitCost
+	}
+	markup := 0.0
+	if cost > 0 {
+		markup = (price - cost) / cost * 100
+	}
+	return models.QuotationItem{
+		Description:   strings.TrimSpace(itemReq.Description),
+		Unit:          strings.TrimSpace(itemReq.Unit),
+		Length:        1,
+		Width:         1,
+		Height:        1,
+		Quantity:      quantity,
+		UnitCost:      cost,
+		TotalCost:     cost * float64(quantity),
+		MarkupPercent: markup,
+		SellUnitPrice: price,
+		SellTotal:     roundCents(price * float64(quantity)),
+	}
 }
 
 func createNewDraft(c *fiber.Ctx, req CreateQuotationRequest, userID uint) error {
@@ -343,6 +579,7 @@ func createNewDraft(c *fiber.Ctx, req CreateQuotationRequest, userID uint) error
 		Title:       req.Title,
 		Description: req.Description,
 		ClientName:  utils.SanitizeClientName(req.ClientName),
+		ClientTier:  req.ClientTier,
 		Status:      "draft",
 		TotalCost:   decimal.NewFromFloat(0),
 		QuotationNo: quotationNo,
@@ -358,15 +595,13 @@ func createNewDraft(c *fiber.Ctx, req CreateQuotationRequest, userID uint) error
 
 	// Process items if any
 	if len(req.Items) > 0 {
-		totalCost, err := processQuotationItems(tx, quotation.ID, req.Items)
-		if err != nil {
+		if err := processQuotationItems(tx, &quotation, req.Sections, req.Items); err != nil {
 			tx.Rollback()
 			return c.Status(fiber.StatusBadRequest).JSON(APIResponse{
 				Success: false,
 				Message: err.Error(),
 			})
 		}
-		quotation.TotalCost = decimal.NewFromFloat(totalCost)
 		if err := tx.Save(&quotation).Error; err != nil {
 			tx.Rollback()
 			return c.Status(fiber.StatusInternalServerError).JSON(APIResponse{
@@ -376,6 +611,15 @@ func createNewDraft(c *fiber.Ctx, req CreateQuotationRequest, userID uint) error
 		}
 	}
 
+	// Keep the full-text search column in step with the items
+	if err := database.RefreshQuotationSearchText(tx, quotation.ID); err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(APIResponse{
+			Success: false,
+			Message: "Failed to update search index",
+		})
+	}
+
 	if err := tx.Commit().Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(APIResponse{
 			Success: false,
@@ -386,7 +630,7 @@ func createNewDraft(c *fiber.Ctx, req CreateQuotationRequest, userID uint) error
 	return c.Status(fiber.StatusCreated).JSON(APIResponse{
 		Success: true,
 		Message: "Draft saved successfully",
-		Data:    quotation,
+		Data:    presentQuotation(c, quotation),
 	})
 }
 
@@ -431,24 +675,27 @@ func updateExistingDraft(c *fiber.Ctx, quotationID string, req CreateQuotationRe
 			Message: "Failed to clear existing materials",
 		})
 	}
+	if err := tx.Where("quotation_id = ?", quotation.ID).Delete(&models.QuotationSection{}).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(APIResponse{
+			Success: false,
+			Message: "Failed to clear existing sections",
+		})
+	}
 
 	// Update quotation details
 	quotation.Title = req.Title
 	quotation.Description = req.Description
 	quotation.ClientName = utils.SanitizeClientName(req.ClientName)
-	quotation.TotalCost = decimal.NewFromFloat(0)
+	quotation.ClientTier = req.ClientTier
 
-	// Process new items
-	if len(req.Items) > 0 {
-		totalCost, err := processQuotationItems(tx, quotation.ID, req.Items)
-		if err != nil {
-			tx.Rollback()
-			return c.Status(fiber.StatusBadRequest).JSON(APIResponse{
-				Success: false,
-				Message: err.Error(),
-			})
-		}
-		quotation.TotalCost = decimal.NewFromFloat(totalCost)
+	// Process new items; an empty draft has zero totals
+	if err := processQuotationItems(tx, &quotation, req.Sections, req.Items); err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusBadRequest).JSON(APIResponse{
+			Success: false,
+			Message: err.Error(),
+		})
 	}
 
 	if err := tx.Save(&quotation).Error; err != nil {
@@ -459,6 +706,15 @@ func updateExistingDraft(c *fiber.Ctx, quotationID string, req CreateQuotationRe
 		})
 	}
 
+	// Keep the full-text search column in step with the items
+	if err := database.RefreshQuotationSearchText(tx, quotation.ID); err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(APIResponse{
+			Success: false,
+			Message: "Failed to update search index",
+		})
+	}
+
 	if err := tx.Commit().Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(APIResponse{
 			Success: false,
@@ -469,7 +725,7 @@ func updateExistingDraft(c *fiber.Ctx, quotationID string, req CreateQuotationRe
 	return c.JSON(APIResponse{
 		Success: true,
 		Message: "Draft updated successfully",
-		Data:    quotation,
+		Data:    presentQuotation(c, quotation),
 	})
 }
 
@@ -560,16 +816,22 @@ func UpdateQuotation(c *fiber.Ctx) error {
 			Message: "Failed to clear existing materials",
 		})
 	}
+	if err := tx.Where("quotation_id = ?", quotation.ID).Delete(&models.QuotationSection{}).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(APIResponse{
+			Success: false,
+			Message: "Failed to clear existing sections",
+		})
+	}
 
 	// Update quotation basic information
 	quotation.Title = req.Title
 	quotation.Description = req.Description
 	quotation.ClientName = utils.SanitizeClientName(req.ClientName)
-	quotation.TotalCost = decimal.NewFromFloat(0) // Will be recalculated
+	quotation.ClientTier = req.ClientTier
 
-	// Process new items and calculate costs
-	totalCost, err := processQuotationItems(tx, quotation.ID, req.Items)
-	if err != nil {
+	// Process new items and calculate costs and selling prices
+	if err := processQuotationItems(tx, &quotation, req.Sections, req.Items); err != nil {
 		tx.Rollback()
 		return c.Status(fiber.StatusBadRequest).JSON(APIResponse{
 			Success: false,
@@ -578,7 +840,6 @@ func UpdateQuotation(c *fiber.Ctx) error {
 	}
 
 	// Update quotation with calculated totals
-	quotation.TotalCost = decimal.NewFromFloat(totalCost)
 	if err := tx.Save(&quotation).Error; err != nil {
 		tx.Rollback()
 		return c.Status(fiber.StatusInternalServerError).JSON(APIResponse{
@@ -587,6 +848,15 @@ func UpdateQuotation(c *fiber.Ctx) error {
 		})
 	}
 
+	// Keep the full-text search column in step with the items
+	if err := database.RefreshQuotationSearchText(tx, quotation.ID); err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(APIResponse{
+			Success: false,
+			Message: "Failed to update search index",
+		})
+	}
+
 	// Commit transaction
 	if err := tx.Commit().Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(APIResponse{
@@ -599,7 +869,7 @@ func UpdateQuotation(c *fiber.Ctx) error {
 	var updatedQuotation models.Quotation
 	if err := database.DB.Where("id = ?", quotation.ID).
 		Preload("User").
-		Preload("Items.Component").
+		Scopes(preloadQuotationItems).
 		Preload("Materials.Material").
 		First(&updatedQuotation).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(APIResponse{
@@ -612,7 +882,7 @@ func UpdateQuotation(c *fiber.Ctx) error {
 		Success: true,
 		Message: "Quotation updated successfully",
 		Data: fiber.Map{
-			"quotation": updatedQuotation,
+			"quotation": presentQuotation(c, updatedQuotation),
 		},
 	})
 }
@@ -626,7 +896,7 @@ func GetQuotation(c *fiber.Ctx) error {
 	var quotation models.Quotation
 	if err := database.DB.Where("id = ? AND user_id = ?", id, userID).
 		Preload("User").
-		Preload("Items.Component").
+		Scopes(preloadQuotationItems).
 		Preload("Materials.Material").
 		First(&quotation).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(APIResponse{
@@ -638,62 +908,60 @@ func GetQuotation(c *fiber.Ctx) error {
 	return c.JSON(APIResponse{
 		Success: true,
 		Message: "Quotation retrieved successfully",
-		Data:    quotation,
+		Data:    presentQuotation(c, quotation),
 	})
 }
 
-// ListQuotations retrieves all quotations for the current user with pagination
-func ListQuotations(c *fiber.Ctx) error {
-	user := c.Locals("user").(models.User)
-	userID := user.ID
-
-	// Parse pagination parameters
-	page, _ := strconv.Atoi(c.Query("page", "1"))
-	limit, _ := strconv.Atoi(c.Query("limit", "10"))
-	offset := (page - 1) * limit
-
-	// Parse status filter
-	status := c.Query("status")
-
-	var quotations []models.Quotation
-	var total int64
+// quotationListSpec is shared by the quotation list and search endpoints. user_id only matters for the admin lists,
+// the user's own list is already restricted to them.
+var quotationListSpec = listSpec{
+	Fields: map[string]listField{
+		"id":           {Column: "id", Kind: fieldNumber, Sort: true, Filter: true},
+		"quotation_no": {Column: "quotation_no", Kind: fieldString, Sort: true, Filter: true},
+		"title":        {Column: "title", Kind: fieldString, Sort: true, Filter: true},
+		"client_name":  {Column: "client_name", Kind: fieldString, Sort: true, Filter: true},
+		"status":       {Column: "status", Kind: fieldString, Sort: true, Filter: true},
+		"total_cost":   {Column: "total_cost", Kind: fieldNumber, Sort: true, Filter: true},
+		"sell_total":   {Column: "sell_total", Kind: fieldNumber, Sort: true, Filter: true},
+		"grand_total":  {Column: "grand_total", Kind: fieldNumber, Sort: true, Filter: true},
+		"client_tier":  {Column: "client_tier", Kind: fieldString, Sort: true, Filter: true},
+		"user_id":      {Column: "user_id", Kind: fieldNumber, Sort: true, Filter: true},
+		"created_at":   {Column: "created_at", Kind: fieldTime, Sort: true, Filter: true},
+		"updated_at":   {Column: "updated_at", Kind: fieldTime, Sort: true, Filter: true},
+	},
+	DefaultSort: "-created_at",
+}
 
-	// Build query
-	query := database.DB.Where("user_id = ?", userID)
-	if status != "" {
-		query = query.Where("status = ?", status)
+// quotationListSpecFor returns the quotation list spec for the current user; costs can only be sorted and
+// filtered on with quotations:view_cost
+func quotationListSpecFor(c *fiber.Ctx) listSpec {
+	if canViewCost(c) {
+		return quotationListSpec
+	}
+	spec := quotationListSpec
+	spec.Fields = make(map[string]listField, len(quotationListSpec.Fields))
+	for name, field := range quotationListSpec.Fields {
+		if name != "total_cost" {
+			spec.Fields[name] = field
+		}
 	}
+	return spec
+}
 
-	// Get total count
-	if err := query.Model(&models.Quotation{}).Count(&total).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(APIResponse{
-			Success: false,
-			Message: "Failed to count quotations",
-		})
-	}
+// ListQuotations retrieves summaries of the current user's quotations with sorting, filtering and pagination.
+// Relations are only loaded when requested with ?include=items,materials,user.
+func ListQuotations(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
 
-	// Get quotations with relationships
-	if err := query.Preload("User").Preload("Items.Component").Preload("Materials.Material").
-		Order("created_at DESC").Offset(offset).Limit(limit).Find(&quotations).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(APIResponse{
-			Success: false,
-			Message: "Failed to retrieve quotations",
-		})
+	lq, err := parseListQuery(c, quotationListSpecFor(c))
+	if err != nil {
+		return respondListError(c, err)
 	}
-
-	totalPages := int((total + int64(limit) - 1) / int64(limit))
-
-	return c.JSON(APIResponse{
-		Success: true,
-		Message: "Quotations retrieved successfully",
-		Data: fiber.Map{
-			"quotations":  quotations,
-			"total":       total,
-			"page":        page,
-			"limit":       limit,
-			"total_pages": totalPages,
-		},
-	})
+	includes, err := quotationIncludes(c)
+	if err != nil {
+		return respondListError(c, err)
+	}
+	return listQuotationSummaries(c, lq, database.DB.Model(&models.Quotation{}).Where("user_id = ?", user.ID), includes)
 }
 
 // DeleteQuotation soft deletes a quotation
@@ -758,6 +1026,14 @@ func DeleteQuotation(c *fiber.Ctx) error {
 		})
 	}
 
+	if err := tx.Where("quotation_id = ?", id).Delete(&models.QuotationSection{}).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(APIResponse{
+			Success: false,
+			Message: "Failed to delete quotation sections",
+		})
+	}
+
 	// Delete quotation
 	if err := tx.Delete(&quotation).Error; err != nil {
 		tx.Rollback()
@@ -775,9 +1051,10 @@ func DeleteQuotation(c *fiber.Ctx) error {
 	})
 }
 
-// UpdateQuotationStatus updates the status of a quotation
+// UpdateQuotationStatus updates the status of a quotation. Issuing a draft is the approval step: it needs
+// quotations:approve, and approvers who also have quotations:view_all can issue other users' drafts.
 func UpdateQuotationStatus(c *fiber.Ctx) error {
-	userID := c.Locals("user_id").(uint)
+	userID := c.Locals("user").(models.User).ID
 	quotationID := c.Params("id")
 
 	// Parse request
@@ -801,9 +1078,16 @@ func UpdateQuotationStatus(c *fiber.Ctx) error {
 		})
 	}
 
-	// Check if quotation exists and belongs to user
+	// Check if quotation exists and belongs to user, or is a draft the user may approve
+	canApprove := hasPermission(c, models.PermQuotationsApprove)
+	query := database.DB.Where("id = ?", id)
+	if req.Status == "issued" && canApprove && hasPermission(c, models.PermQuotationsViewAll) {
+		query = query.Where("user_id = ? OR status = ?", userID, "draft")
+	} else {
+		query = query.Where("user_id = ?", userID)
+	}
 	var quotation models.Quotation
-	if err := database.DB.Where("id = ? AND user_id = ?", id, userID).First(&quotation).Error; err != nil {
+	if err := query.First(&quotation).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return c.Status(fiber.StatusNotFound).JSON(APIResponse{
 				Success: false,
@@ -847,6 +1131,14 @@ func UpdateQuotationStatus(c *fiber.Ctx) error {
 		})
 	}
 
+	// Issuing a draft is the approval step
+	if req.Status == "issued" && !canApprove {
+		return c.Status(fiber.StatusForbidden).JSON(APIResponse{
+			Success: false,
+			Message: "You don't have permission to approve quotations",
+		})
+	}
+
 	// Update status
 	if err := database.DB.Model(&quotation).Update("status", req.Status).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(APIResponse{
@@ -883,7 +1175,7 @@ func DuplicateQuotation(c *fiber.Ctx) error {
 	// Get original quotation with all relationships
 	var originalQuotation models.Quotation
 	if err := database.DB.Where("id = ? AND user_id = ?", id, userID).
-		Preload("Items.Component").Preload("Materials.Material").
+		Scopes(preloadQuotationItems).Preload("Materials.Material").
 		First(&originalQuotation).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return c.Status(fiber.StatusNotFound).JSON(APIResponse{
@@ -922,6 +1214,7 @@ func DuplicateQuotation(c *fiber.Ctx) error {
 		Title:       originalQuotation.Title + " (Copy)",
 		Description: originalQuotation.Description,
 		ClientName:  originalQuotation.ClientName,
+		ClientTier:  originalQuotation.ClientTier,
 		TotalCost:   decimal.NewFromFloat(0), // Will be calculated
 		Status:      "draft",
 		QuotationNo: newQuotationNo,
@@ -935,17 +1228,53 @@ func DuplicateQuotation(c *fiber.Ctx) error {
 		})
 	}
 
+	// Duplicate sections
+	sectionIDs := make(map[uint]uint, len(originalQuotation.Sections))
+	for _, section := range originalQuotation.Sections {
+		newSection := models.QuotationSection{
+			QuotationID: newQuotation.ID,
+			Name:        section.Name,
+			Position:    section.Position,
+			Subtotal:    section.Subtotal,
+		}
+		if err := tx.Create(&newSection).Error; err != nil {
+			tx.Rollback()
+			return c.Status(fiber.StatusInternalServerError).JSON(APIResponse{
+				Success: false,
+				Message: "Failed to create quotation sections",
+			})
+		}
+		sectionIDs[section.ID] = newSection.ID
+	}
+
 	// Duplicate items
 	for _, item := range originalQuotation.Items {
 		newItem := models.QuotationItem{
-			QuotationID: newQuotation.ID,
-			ComponentID: item.ComponentID,
-			Length:      item.Length,
-			Width:       item.Width,
-			Height:      item.Height,
-			Quantity:    item.Quantity,
-			UnitCost:    item.UnitCost,
-			TotalCost:   item.TotalCost,
+			QuotationID:   newQuotation.ID,
+			Position:      item.Position,
+			ComponentID:   item.ComponentID,
+			Description:   item.Description,
+			Unit:          item.Unit,
+			TaxExempt:     item.TaxExempt,
+			Notes:         item.Notes,
+			Length:        item.Length,
+			Width:         item.Width,
+			Height:        item.Height,
+			DimensionUnit: item.DimensionUnit,
+			Quantity:      item.Quantity,
+			UnitCost:      item.UnitCost,
+			TotalCost:     item.TotalCost,
+			MaterialCost:  item.MaterialCost,
+			LaborCost:     item.LaborCost,
+			OverheadCost:  item.OverheadCost,
+			// Keep the prices of the original; they are recalculated when the draft is saved
+			MarkupPercent: item.MarkupPercent,
+			SellUnitPrice: item.SellUnitPrice,
+			SellTotal:     item.SellTotal,
+		}
+		if item.SectionID != nil {
+			sectionID := sectionIDs[*item.SectionID]
+			newItem.SectionID = &sectionID
 		}
 		if err := tx.Create(&newItem).Error; err != nil {
 			tx.Rollback()
@@ -968,13 +1297,17 @@ func DuplicateQuotation(c *fiber.Ctx) error {
 
 	for _, material := range materials {
 		newMaterial := models.QuotationMaterial{
-			QuotationID:  newQuotation.ID,
-			MaterialID:   material.MaterialID,
-			MaterialName: material.MaterialName,
-			Unit:         material.Unit,
-			UnitCost:     material.UnitCost,
-			Quantity:     material.Quantity,
-			TotalCost:    material.TotalCost,
+			QuotationID:   newQuotation.ID,
+			MaterialID:    material.MaterialID,
+			MaterialName:  material.MaterialName,
+			SupplierID:    material.SupplierID,
+			Unit:          material.Unit,
+			UnitCost:      material.UnitCost,
+			NetQuantity:   material.NetQuantity,
+			GrossQuantity: material.GrossQuantity,
+			Quantity:      material.Quantity,
+			TotalCost:     material.TotalCost,
+			SurplusCost:   material.SurplusCost,
 		}
 		if err := tx.Create(&newMaterial).Error; err != nil {
 			tx.Rollback()
@@ -985,8 +1318,14 @@ func DuplicateQuotation(c *fiber.Ctx) error {
 		}
 	}
 
-	// Update total cost
-	if err := tx.Model(&newQuotation).Update("total_cost", originalQuotation.TotalCost).Error; err != nil {
+	// Update totals
+	if err := tx.Model(&newQuotation).Updates(map[string]interface{}{
+		"total_cost":  originalQuotation.TotalCost,
+		"sell_total":  originalQuotation.SellTotal,
+		"tax_rate":    originalQuotation.TaxRate,
+		"tax_total":   originalQuotation.TaxTotal,
+		"grand_total": originalQuotation.GrandTotal,
+	}).Error; err != nil {
 		tx.Rollback()
 		return c.Status(fiber.StatusInternalServerError).JSON(APIResponse{
 			Success: false,
@@ -994,12 +1333,21 @@ func DuplicateQuotation(c *fiber.Ctx) error {
 		})
 	}
 
+	// Keep the full-text search column in step with the items
+	if err := database.RefreshQuotationSearchText(tx, newQuotation.ID); err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(APIResponse{
+			Success: false,
+			Message: "Failed to update search index",
+		})
+	}
+
 	tx.Commit()
 
 	// Get complete quotation with relationships
 	var completeQuotation models.Quotation
 	if err := database.DB.Where("id = ?", newQuotation.ID).
-		Preload("User").Preload("Items.Component").Preload("Materials.Material").
+		Preload("User").Scopes(preloadQuotationItems).Preload("Materials.Material").
 		First(&completeQuotation).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(APIResponse{
 			Success: false,
@@ -1010,7 +1358,7 @@ func DuplicateQuotation(c *fiber.Ctx) error {
 	return c.JSON(APIResponse{
 		Success: true,
 		Message: "Quotation duplicated successfully",
-		Data:    completeQuotation,
+		Data:    presentQuotation(c, completeQuotation),
 	})
 }
 
@@ -1031,7 +1379,7 @@ func GenerateQuotationPDF(c *fiber.Ctx) error {
 	// Get quotation with all relationships
 	var quotation models.Quotation
 	if err := database.DB.Where("id = ? AND user_id = ?", id, userID).
-		Preload("User").Preload("Items.Component").Preload("Materials.Material").
+		Preload("User").Scopes(preloadQuotationItems).Preload("Materials.Material").
 		First(&quotation).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return c.Status(fiber.StatusNotFound).JSON(APIResponse{
@@ -1062,127 +1410,70 @@ func GenerateQuotationPDF(c *fiber.Ctx) error {
 	return c.Send(pdfBytes)
 }
 
-// ListAllQuotations retrieves all quotations for admin with pagination
+// ListAllQuotations retrieves summaries of all quotations for admin with sorting, filtering and pagination
 func ListAllQuotations(c *fiber.Ctx) error {
-	// Parse pagination parameters
-	page, _ := strconv.Atoi(c.Query("page", "1"))
-	limit, _ := strconv.Atoi(c.Query("limit", "10"))
-	offset := (page - 1) * limit
-
-	// Parse filters
-	status := c.Query("status")
-	userID := c.Query("user_id")
-
-	var quotations []models.Quotation
-	var total int64
-
-	// Build query
-	query := database.DB.Model(&models.Quotation{})
-	if status != "" {
-		query = query.Where("status = ?", status)
+	lq, err := parseListQuery(c, quotationListSpecFor(c))
+	if err != nil {
+		return respondListError(c, err)
 	}
-	if userID != "" {
-		query = query.Where("user_id = ?", userID)
+	includes, err := quotationIncludes(c)
+	if err != nil {
+		return respondListError(c, err)
 	}
+	query := database.DB.Model(&models.Quotation{})
 
-	// Get total count
-	if err := query.Count(&total).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(APIResponse{
+	// Stream the full filtered set as a file when an export format is requested
+	format, err := exportFormat(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(APIResponse{
 			Success: false,
-			Message: "Failed to count quotations",
+			Message: err.Error(),
 		})
 	}
-
-	// Get quotations with relationships
-	if err := query.Preload("User").Preload("Items.Component").Preload("Materials.Material").
-		Order("created_at DESC").Offset(offset).Limit(limit).Find(&quotations).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(APIResponse{
-			Success: false,
-			Message: "Failed to retrieve quotations",
-		})
+	if format != "" {
+		return exportQuotations(c, format, "quotations", lq.where(query))
 	}
-
-	totalPages := int((total + int64(limit) - 1) / int64(limit))
-
-	return c.JSON(APIResponse{
-		Success: true,
-		Message: "Quotations retrieved successfully",
-		Data: fiber.Map{
-			"quotations":  quotations,
-			"total":       total,
-			"page":        page,
-			"limit":       limit,
-			"total_pages": totalPages,
-		},
-	})
+	return listQuotationSummaries(c, lq, query, includes)
 }
 
-// SearchQuotations searches quotations by title, description, or quotation number
+// SearchQuotations searches quotations by number, title, client, description and component and material names,
+// best match first unless a sort order is given
 func SearchQuotations(c *fiber.Ctx) error {
 	// Parse search parameters
 	query := c.Query("q")
-	if query == "" {
+	search := newTextSearch(database.DB, query, "search_text")
+	if len(search.terms) == 0 {
 		return c.Status(fiber.StatusBadRequest).JSON(APIResponse{
 			Success: false,
 			Message: "Search query is required",
 		})
 	}
-
-	page, _ := strconv.Atoi(c.Query("page", "1"))
-	limit, _ := strconv.Atoi(c.Query("limit", "10"))
-	offset := (page - 1) * limit
-
-	// Parse filters
-	status := c.Query("status")
-	userID := c.Query("user_id")
-
-	var quotations []models.Quotation
-	var total int64
-
-	// Build search query
-	searchQuery := database.DB.Where(
-		"title ILIKE ? OR description ILIKE ? OR quotation_no ILIKE ?",
-		"%"+query+"%", "%"+query+"%", "%"+query+"%",
-	)
-
-	if status != "" {
-		searchQuery = searchQuery.Where("status = ?", status)
+	lq, err := parseListQuery(c, quotationListSpecFor(c))
+	if err != nil {
+		return respondListError(c, err)
 	}
-	if userID != "" {
-		searchQuery = searchQuery.Where("user_id = ?", userID)
+	includes, err := quotationIncludes(c)
+	if err != nil {
+		return respondListError(c, err)
 	}
+	searchQuery := search.where(database.DB.Model(&models.Quotation{}))
 
-	// Get total count
-	if err := searchQuery.Model(&models.Quotation{}).Count(&total).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(APIResponse{
+	// Stream all matching quotations as a file when an export format is requested
+	format, err := exportFormat(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(APIResponse{
 			Success: false,
-			Message: "Failed to count search results",
+			Message: err.Error(),
 		})
 	}
-
-	// Get quotations with relationships
-	if err := searchQuery.Preload("User").Preload("Items.Component").Preload("Materials.Material").
-		Order("created_at DESC").Offset(offset).Limit(limit).Find(&quotations).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(APIResponse{
-			Success: false,
-			Message: "Failed to search quotations",
-		})
+	if format != "" {
+		return exportQuotations(c, format, "quotation_search", lq.where(searchQuery))
 	}
 
-	totalPages := int((total + int64(limit) - 1) / int64(limit))
-
-	return c.JSON(APIResponse{
-		Success: true,
-		Message: "Search completed successfully",
-		Data: fiber.Map{
-			"quotations":  quotations,
-			"total":       total,
-			"page":        page,
-			"limit":       limit,
-			"total_pages": totalPages,
-			"query":       query,
-		},
-	})
+	if lq.rankable() {
+		searchQuery = search.orderByRelevance(searchQuery)
+	}
+	return listQuotationSummaries(c, lq, searchQuery, includes)
 }
 
 // GenerateSalesReport generates sales report for admin
@@ -1201,8 +1492,44 @@ func GenerateSalesReport(c *fiber.Ctx) error {
 		query = query.Where("created_at <= ?", endDate)
 	}
 
+	format, err := exportFormat(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(APIResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+	}
+	showCost := canViewCost(c)
+	if format != "" {
+		// One row per accepted quotation followed by a totals row
+		return streamExport(c, format, "sales_report", quotationExportHeader(showCost), func(write func(row ...interface{}) error) error {
+			var batch []models.Quotation
+			sellTotal, costTotal := decimal.Zero, decimal.Zero
+			count := 0
+			err := query.Model(&models.Quotation{}).FindInBatches(&batch, exportBatchSize, func(tx *gorm.DB, _ int) error {
+				for _, q := range batch {
+					sellTotal = sellTotal.Add(q.SellTotal)
+					costTotal = costTotal.Add(q.TotalCost)
+					count++
+					if err := write(quotationExportRow(q, showCost)...); err != nil {
+						return err
+					}
+				}
+				return nil
+			}).Error
+			if err != nil {
+				return err
+			}
+			totals := []interface{}{"TOTAL", fmt.Sprintf("%d quotations", count), "", "", "", sellTotal, nil}
+			if showCost {
+				totals = append(totals, costTotal, sellTotal.Sub(costTotal))
+			}
+			return write(totals...)
+		})
+	}
+
 	// Get accepted quotations with relationships
-	if err := query.Preload("User").Preload("Items.Component").Preload("Materials.Material").
+	if err := query.Preload("User").Scopes(preloadQuotationItems).Preload("Materials.Material").
 		Find(&quotations).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(APIResponse{
 			Success: false,
@@ -1211,7 +1538,7 @@ func GenerateSalesReport(c *fiber.Ctx) error {
 	}
 
 	// Generate report using utils function
-	report := utils.GenerateSalesReport(quotations)
+	report := utils.GenerateSalesReport(quotations, showCost)
 
 	return c.JSON(APIResponse{
 		Success: true,
@@ -1243,6 +1570,28 @@ func GenerateMaterialUsageReport(c *fiber.Ctx) error {
 			Where("qm.quotation_id IN (?)", subQuery).Distinct()
 	}
 
+	format, err := exportFormat(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(APIResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+	}
+	if format != "" {
+		header := []string{"Material ID", "Material Name", "Total Used"}
+		return streamExport(c, format, "material_usage_report", header, func(write func(row ...interface{}) error) error {
+			var batch []models.Material
+			return query.FindInBatches(&batch, exportBatchSize, func(tx *gorm.DB, _ int) error {
+				for _, usage := range utils.GenerateMaterialUsageReport(batch) {
+					if err := write(usage.MaterialID, usage.MaterialName, usage.TotalUsed); err != nil {
+						return err
+					}
+				}
+				return nil
+			}).Error
+		})
+	}
+
 	// Get materials
 	if err := query.Find(&materials).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(APIResponse{
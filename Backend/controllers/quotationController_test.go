@@ -0,0 +1,209 @@
+package controllers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"qp1/models"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// newQuotationTestApp returns an app with the quotation routes signed in as user
+func newQuotationTestApp(user models.User) *fiber.App {
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("user", user)
+		return c.Next()
+	})
+	app.Post("/api/quotations", CreateQuotation)
+	app.Post("/api/quotations/draft", SaveQuotationDraft)
+	app.Put("/api/quotations/:id/draft", SaveQuotationDraft)
+	return app
+}
+
+func sendJSON(t *testing.T, app *fiber.App, method string, path string, body string) (int, string) {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(data)
+}
+
+func TestSaveQuotationDraftValidatesItems(t *testing.T) {
+	db := setupTest(t)
+	user := models.User{Name: "Sam", Email: "sam@example.com", Role: "estimator", Status: models.UserStatusActive}
+	db.Create(&user)
+	app := newQuotationTestApp(user)
+
+	// A draft can be saved before it has items
+	status, body := sendJSON(t, app, http.MethodPost, "/api/quotations/draft", `{"title": "Kitchen"}`)
+	if status != fiber.StatusCreated {
+		t.Fatalf("saving an empty draft returned %d: %s", status, body)
+	}
+	var draft models.Quotation
+	db.Last(&draft)
+	existing := "/api/quotations/" + strconv.FormatUint(uint64(draft.ID), 10) + "/draft"
+
+	invalid := []struct {
+		name  string
+		item  string
+		field string
+	}{
+		{"line without component or description", `{"component_id": 0, "unit_price": 10, "quantity": 1}`, "items[0].description"},
+		{"line without a price", `{"description": "Delivery", "quantity": 1}`, "items[0].unit_price"},
+		{"negative price", `{"description": "Delivery", "unit_price": -10, "quantity": 1}`, "items[0].unit_price"},
+		{"negative cost", `{"description": "Delivery", "unit_price": 10, "unit_cost": -1, "quantity": 1}`, "items[0].unit_cost"},
+		{"zero quantity", `{"description": "Delivery", "unit_price": 10, "quantity": 0}`, "items[0].quantity"},
+		{"negative quantity", `{"description": "Delivery", "unit_price": 10, "quantity": -2}`, "items[0].quantity"},
+	}
+	for _, tt := range invalid {
+		for _, path := range []string{"/api/quotations/draft", existing} {
+			method := http.MethodPost
+			if path == existing {
+				method = http.MethodPut
+			}
+			status, body := sendJSON(t, app, method, path, `{"title": "Kitchen", "items": [`+tt.item+`]}`)
+			if status != fiber.StatusBadRequest || !strings.Contains(body, `"field":"`+tt.field+`"`) {
+				t.Errorf("%s %s: got %d %s, want 400 on %s", tt.name, path, status, body, tt.field)
+			}
+		}
+	}
+
+	var items int64
+	db.Model(&models.QuotationItem{}).Count(&items)
+	if items != 0 {
+		t.Errorf("invalid drafts saved %d items", items)
+	}
+
+	status, body = sendJSON(t, app, http.MethodPut, existing, `{"title": "Kitchen", "items": [{"description": "Delivery", "unit_price": 10, "quantity": 3}]}`)
+	if status != fiber.StatusOK {
+		t.Fatalf("saving a valid draft returned %d: %s", status, body)
+	}
+	var item models.QuotationItem
+	db.Where("quotation_id = ?", draft.ID).First(&item)
+	if item.Quantity != 3 || item.SellTotal != 30 {
+		t.Errorf("draft item = %d at %v, want 3 at 30", item.Quantity, item.SellTotal)
+	}
+}
+
+func TestIssuingADraftNeedsApproval(t *testing.T) {
+	db := setupTest(t)
+	owner := models.User{Name: "Pat", Email: "pat@example.com", Role: "user", Status: models.UserStatusActive}
+	approver := models.User{Name: "Sam", Email: "sam@example.com", Role: "sales", Status: models.UserStatusActive}
+	estimator := models.User{Name: "Eve", Email: "eve@example.com", Role: "estimator", Status: models.UserStatusActive}
+	db.Create(&owner)
+	db.Create(&approver)
+	db.Create(&estimator)
+	newQuotation := func(user models.User, status string) string {
+		quotation := models.Quotation{UserID: user.ID, Title: "Kitchen", Status: status, QuotationNo: "Q-" + strconv.Itoa(int(user.ID)) + status}
+		db.Create(&quotation)
+		return "/api/quotations/" + strconv.FormatUint(uint64(quotation.ID), 10) + "/status"
+	}
+	ownDraft := newQuotation(owner, "draft")
+	estimatorDraft := newQuotation(estimator, "draft")
+	issued := newQuotation(owner, "issued")
+
+	steps := []struct {
+		name string
+		user models.User
+		path string
+		body string
+		want int
+	}{
+		{"owner without approve issues own draft", owner, ownDraft, `{"status": "issued"}`, fiber.StatusForbidden},
+		{"user without approve issues another's draft", estimator, ownDraft, `{"status": "issued"}`, fiber.StatusNotFound},
+		{"estimator issues own draft", estimator, estimatorDraft, `{"status": "issued"}`, fiber.StatusForbidden},
+		{"approver issues another user's draft", approver, ownDraft, `{"status": "issued"}`, fiber.StatusOK},
+		{"approver can't decide another user's issued quotation", approver, issued, `{"status": "accepted"}`, fiber.StatusNotFound},
+		{"owner marks an issued quotation accepted", owner, issued, `{"status": "accepted"}`, fiber.StatusOK},
+	}
+	for _, step := range steps {
+		app := fiber.New()
+		app.Use(func(c *fiber.Ctx) error {
+			c.Locals("user", step.user)
+			return c.Next()
+		})
+		app.Put("/api/quotations/:id/status", UpdateQuotationStatus)
+		if status, body := sendJSON(t, app, http.MethodPut, step.path, step.body); status != step.want {
+			t.Errorf("%s: returned %d, want %d: %s", step.name, status, step.want, body)
+		}
+	}
+
+	var draft models.Quotation
+	db.Where("user_id = ? AND quotation_no LIKE ?", owner.ID, "%draft").First(&draft)
+	if draft.Status != "issued" {
+		t.Errorf("draft status %q after approval, want issued", draft.Status)
+	}
+}
+
+func TestQuotationSectionsKeepOrderAndSubtotals(t *testing.T) {
+	db := setupTest(t)
+	user := models.User{Name: "Sam", Email: "sam@example.com", Role: "estimator", Status: models.UserStatusActive}
+	db.Create(&user)
+	app := newQuotationTestApp(user)
+
+	status, body := sendJSON(t, app, http.MethodPost, "/api/quotations", `{
+		"title": "House",
+		"sections": ["Kitchen", " Empty ", "Kitchen"],
+		"items": [
+			{"description": "Tiles", "unit_price": 12.5, "quantity": 4, "section": "Bathroom", "notes": "  white  "},
+			{"description": "Worktop", "unit_price": 300, "quantity": 1, "section": " Kitchen "},
+			{"description": "Delivery", "unit_price": 40, "quantity": 1},
+			{"description": "Sink", "unit_price": 150, "quantity": 2, "section": "Kitchen"},
+			{"description": "Mirror", "unit_price": 80, "quantity": 1, "section": "Bathroom"}
+		]
+	}`)
+	if status != fiber.StatusCreated {
+		t.Fatalf("creating the quotation returned %d: %s", status, body)
+	}
+	var quotation models.Quotation
+	if err := db.Scopes(preloadQuotationItems).Last(&quotation).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// Listed sections come first, then the ones only named by items in order of first use
+	want := []struct {
+		name     string
+		subtotal float64
+	}{{"Kitchen", 600}, {"Empty", 0}, {"Bathroom", 130}}
+	if len(quotation.Sections) != len(want) {
+		t.Fatalf("got %d sections, want %d", len(quotation.Sections), len(want))
+	}
+	sectionNames := map[uint]string{}
+	for i, section := range quotation.Sections {
+		if section.Name != want[i].name || section.Position != i || !closeTo(section.Subtotal, want[i].subtotal) {
+			t.Errorf("section %d is %q at %d with subtotal %v, want %q with %v",
+				i, section.Name, section.Position, section.Subtotal, want[i].name, want[i].subtotal)
+		}
+		sectionNames[section.ID] = section.Name
+	}
+
+	// Items keep the request order and point at their section
+	wantItems := []struct{ description, section string }{
+		{"Tiles", "Bathroom"}, {"Worktop", "Kitchen"}, {"Delivery", ""}, {"Sink", "Kitchen"}, {"Mirror", "Bathroom"},
+	}
+	for i, item := range quotation.Items {
+		section := ""
+		if item.SectionID != nil {
+			section = sectionNames[*item.SectionID]
+		}
+		if item.Description != wantItems[i].description || item.Position != i || section != wantItems[i].section {
+			t.Errorf("item %d is %q at %d in %q, want %q in %q",
+				i, item.Description, item.Position, section, wantItems[i].description, wantItems[i].section)
+		}
+	}
+	if quotation.Items[0].Notes != "white" {
+		t.Errorf("notes %q, want them trimmed", quotation.Items[0].Notes)
+	}
+	if got, _ := quotation.SellTotal.Float64(); !closeTo(got, 770) {
+		t.Errorf("sell total %v, want the section subtotals plus the unsectioned item, 770", got)
+	}
+}
@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"qp1/models"
+	"strconv"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// priceTestQuotation creates a component of material with a line of quantity per metre, re-costs it and prices
+// a quotation of items of it
+func priceTestQuotation(t *testing.T, db *gorm.DB, material models.Material, line models.ComponentMaterial, items []CreateQuotationItemRequest) (models.Quotation, models.QuotationMaterial) {
+	t.Helper()
+	db.Create(&material)
+	component := models.Component{Name: "Shelf"}
+	db.Create(&component)
+	line.ComponentID, line.MaterialID = component.ID, material.ID
+	db.Create(&line)
+	if err := recalculateComponents(db, []uint{component.ID}); err != nil {
+		t.Fatal(err)
+	}
+	for i := range items {
+		items[i].ComponentID = component.ID
+	}
+
+	quotation := models.Quotation{UserID: 1, Title: "Shelves", QuotationNo: "Q-1"}
+	db.Create(&quotation)
+	if err := processQuotationItems(db, &quotation, nil, items); err != nil {
+		t.Fatal(err)
+	}
+	var quotationMaterial models.QuotationMaterial
+	if err := db.Where("quotation_id = ? AND material_id = ?", quotation.ID, material.ID).First(&quotationMaterial).Error; err != nil {
+		t.Fatal(err)
+	}
+	return quotation, quotationMaterial
+}
+
+func TestQuotationMaterialsIncludeWasteAndPurchaseIncrements(t *testing.T) {
+	override := 25.0
+	tests := []struct {
+		name          string
+		wastePercent  float64
+		lineWaste     *float64
+		increment     float64
+		wantGross     float64
+		wantQuantity  float64
+		wantItemCost  float64
+		wantTotalCost float64
+	}{
+		{name: "neither", wantGross: 4, wantQuantity: 4, wantItemCost: 20, wantTotalCost: 20},
+		{name: "waste", wastePercent: 10, wantGross: 4.4, wantQuantity: 4.4, wantItemCost: 22, wantTotalCost: 22},
+		{name: "line waste overrides the material's", wastePercent: 10, lineWaste: &override, wantGross: 5, wantQuantity: 5, wantItemCost: 25, wantTotalCost: 25},
+		{name: "purchase increment", increment: 3, wantGross: 4, wantQuantity: 6, wantItemCost: 20, wantTotalCost: 30},
+		{name: "exact multiple of the increment", increment: 2, wantGross: 4, wantQuantity: 4, wantItemCost: 20, wantTotalCost: 20},
+		{name: "waste rounded up to the increment", wastePercent: 10, increment: 3, wantGross: 4.4, wantQuantity: 6, wantItemCost: 22, wantTotalCost: 30},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := setupTest(t)
+			material := models.Material{Name: "Pine board", Unit: "m", UnitCost: 5, WastePercent: tt.wastePercent, PurchaseIncrement: tt.increment}
+			// Two shelves of 1 m with 2 m of board each
+			quotation, quotationMaterial := priceTestQuotation(t, db, material,
+				models.ComponentMaterial{Quantity: 2, WastePercent: tt.lineWaste},
+				[]CreateQuotationItemRequest{{Length: 1, Width: 1, Height: 1, Quantity: 2}})
+
+			if !closeTo(quotationMaterial.NetQuantity, 4) || !closeTo(quotationMaterial.GrossQuantity, tt.wantGross) || !closeTo(quotationMaterial.Quantity, tt.wantQuantity) {
+				t.Errorf("quantities net %v, gross %v, bought %v, want 4, %v, %v",
+					quotationMaterial.NetQuantity, quotationMaterial.GrossQuantity, quotationMaterial.Quantity, tt.wantGross, tt.wantQuantity)
+			}
+			if !closeTo(quotationMaterial.TotalCost, tt.wantQuantity*5) {
+				t.Errorf("material total cost %v, want %v", quotationMaterial.TotalCost, tt.wantQuantity*5)
+			}
+			var item models.QuotationItem
+			db.Where("quotation_id = ?", quotation.ID).First(&item)
+			if !closeTo(item.TotalCost, tt.wantItemCost) {
+				t.Errorf("item total cost %v, want %v", item.TotalCost, tt.wantItemCost)
+			}
+			if got, _ := quotation.TotalCost.Float64(); !closeTo(got, tt.wantTotalCost) {
+				t.Errorf("quotation total cost %v, want %v", got, tt.wantTotalCost)
+			}
+
+			// The surplus is on the quotation material, so items and materials add up to the quotation's cost
+			if !closeTo(item.TotalCost+quotationMaterial.SurplusCost, tt.wantTotalCost) {
+				t.Errorf("item cost %v plus surplus cost %v, want %v", item.TotalCost, quotationMaterial.SurplusCost, tt.wantTotalCost)
+			}
+			app := fiber.New()
+			app.Use(func(c *fiber.Ctx) error {
+				c.Locals("user", models.User{ID: 1, Role: "estimator"})
+				return c.Next()
+			})
+			app.Get("/api/quotations/:id", GetQuotation)
+			status, body := sendJSON(t, app, http.MethodGet, "/api/quotations/"+strconv.FormatUint(uint64(quotation.ID), 10), "")
+			var resp struct {
+				Data QuotationResponse `json:"data"`
+			}
+			if err := json.Unmarshal([]byte(body), &resp); err != nil || status != fiber.StatusOK || resp.Data.CostBreakdown == nil {
+				t.Fatalf("quotation returned %d: %s", status, body)
+			}
+			breakdown := resp.Data.CostBreakdown
+			if !closeTo(breakdown.RoundingCost, tt.wantTotalCost-tt.wantItemCost) {
+				t.Errorf("rounding cost %v, want %v", breakdown.RoundingCost, tt.wantTotalCost-tt.wantItemCost)
+			}
+			if sum := breakdown.MaterialCost + breakdown.LaborCost + breakdown.OverheadCost + breakdown.AdHocCost + breakdown.RoundingCost; !closeTo(sum, tt.wantTotalCost) {
+				t.Errorf("cost breakdown adds up to %v, want %v", sum, tt.wantTotalCost)
+			}
+		})
+	}
+}
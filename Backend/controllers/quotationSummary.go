@@ -0,0 +1,258 @@
+package controllers
+
+import (
+	"fmt"
+	"qp1/database"
+	"qp1/models"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// Relations a quotation list can include with ?include=
+const (
+	QuotationIncludeItems     = "items"
+	QuotationIncludeMaterials = "materials"
+	QuotationIncludeUser      = "user"
+)
+
+// quotationSummaryColumns are the columns loaded for list views; the search text is left out
+var quotationSummaryColumns = []string{
+	"id", "user_id", "created_by", "client_name", "client_tier", "title", "description", "status", "total_cost", "sell_total",
+	"tax_total", "grand_total", "quotation_no", "created_at", "updated_at",
+}
+
+// QuotationSummary is the projection of a quotation returned by list endpoints. The full quotation with all
+// its relations is only returned by GET /api/quotations/:id. Costs and margins need quotations:view_cost.
+type QuotationSummary struct {
+	ID          uint            `json:"id"`
+	QuotationNo string          `json:"quotation_no"`
+	Title       string          `json:"title"`
+	ClientName  string          `json:"client_name"`
+	Description string          `json:"description,omitempty"`
+	ClientTier  string          `json:"client_tier,omitempty"`
+	Status      string          `json:"status"`
+	SellTotal   decimal.Decimal `json:"sell_total"`
+	TaxTotal    decimal.Decimal `json:"tax_total"`
+	GrandTotal  decimal.Decimal `json:"grand_total"`
+	UserID      uint            `json:"user_id"`
+	CreatedBy   string          `json:"created_by"`
+	ItemCount   int             `json:"item_count"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+
+	TotalCost     *decimal.Decimal `json:"total_cost,omitempty"`
+	GrossMargin   *decimal.Decimal `json:"gross_margin,omitempty"`
+	MarginPercent *float64         `json:"margin_percent,omitempty"`
+
+	User      *QuotationUserSummary      `json:"user,omitempty"`
+	Items     []QuotationItemSummary     `json:"items,omitempty"`
+	Materials []QuotationMaterialSummary `json:"materials,omitempty"`
+}
+
+// QuotationUserSummary is the owner of a quotation, included with ?include=user
+type QuotationUserSummary struct {
+	ID    uint   `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// QuotationItemSummary is a component or ad-hoc line, included with ?include=items
+type QuotationItemSummary struct {
+	ID            uint    `json:"id"`
+	ComponentID   *uint   `json:"component_id"`
+	ComponentName string  `json:"component_name,omitempty"`
+	Description   string  `json:"description,omitempty"`
+	Unit          string  `json:"unit,omitempty"`
+	SectionID     *uint   `json:"section_id"`
+	Notes         string  `json:"notes,omitempty"`
+	Length        float64 `json:"length"`
+	Width         float64 `json:"width"`
+	Height        float64 `json:"height"`
+	DimensionUnit string  `json:"dimension_unit"`
+	Quantity      int     `json:"quantity"`
+	SellUnitPrice float64 `json:"sell_unit_price"`
+	SellTotal     float64 `json:"sell_total"`
+
+	UnitCost      *float64 `json:"unit_cost,omitempty"`
+	TotalCost     *float64 `json:"total_cost,omitempty"`
+	MarkupPercent *float64 `json:"markup_percent,omitempty"`
+}
+
+// QuotationMaterialSummary is a resolved material line, included with ?include=materials
+type QuotationMaterialSummary struct {
+	MaterialID   uint    `json:"material_id"`
+	MaterialName string  `json:"material_name"`
+	Unit         string  `json:"unit"`
+	Quantity     float64 `json:"quantity"`
+	UnitCost     float64 `json:"unit_cost"`
+	TotalCost    float64 `json:"total_cost"`
+}
+
+// quotationIncludes parses ?include=items,materials,user. Material lines carry internal material costs, so they
+// need quotations:view_cost.
+func quotationIncludes(c *fiber.Ctx) (map[string]bool, error) {
+	includes := map[string]bool{}
+	for _, name := range strings.Split(c.Query("include"), ",") {
+		name = strings.TrimSpace(name)
+		switch name {
+		case "":
+		case QuotationIncludeItems, QuotationIncludeUser:
+			includes[name] = true
+		case QuotationIncludeMaterials:
+			if !canViewCost(c) {
+				return nil, fiber.NewError(fiber.StatusForbidden, "Including materials requires the "+models.PermQuotationsViewCost+" permission")
+			}
+			includes[name] = true
+		default:
+			return nil, fmt.Errorf("unknown include %q", name)
+		}
+	}
+	return includes, nil
+}
+
+// selectQuotationSummary is a list scope that loads only the summary columns and the included relations
+func selectQuotationSummary(includes map[string]bool) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		db = db.Select(quotationSummaryColumns)
+		if includes[QuotationIncludeUser] {
+			db = db.Preload("User", func(db *gorm.DB) *gorm.DB {
+				return db.Select("id", "name", "email")
+			})
+		}
+		if includes[QuotationIncludeItems] {
+			db = db.Preload("Items", func(db *gorm.DB) *gorm.DB {
+				return db.Order("position, id")
+			}).Preload("Items.Component", func(db *gorm.DB) *gorm.DB {
+				return db.Unscoped().Select("id", "name")
+			})
+		}
+		if includes[QuotationIncludeMaterials] {
+			db = db.Preload("Materials", func(db *gorm.DB) *gorm.DB {
+				return db.Order("material_name")
+			})
+		}
+		return db
+	}
+}
+
+// summarizeQuotations converts quotations loaded with selectQuotationSummary into summaries
+func summarizeQuotations(quotations []models.Quotation, includes map[string]bool, showCost bool) ([]QuotationSummary, error) {
+	summaries := make([]QuotationSummary, len(quotations))
+	if len(quotations) == 0 {
+		return summaries, nil
+	}
+
+	ids := make([]uint, len(quotations))
+	for i, q := range quotations {
+		ids[i] = q.ID
+	}
+	var counts []struct {
+		QuotationID uint
+		Items       int
+	}
+	if err := database.DB.Model(&models.QuotationItem{}).Select("quotation_id, COUNT(*) AS items").
+		Where("quotation_id IN ?", ids).Group("quotation_id").Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	itemCounts := make(map[uint]int, len(counts))
+	for _, count := range counts {
+		itemCounts[count.QuotationID] = count.Items
+	}
+
+	for i, q := range quotations {
+		s := QuotationSummary{
+			ID:          q.ID,
+			QuotationNo: q.QuotationNo,
+			Title:       q.Title,
+			ClientName:  q.ClientName,
+			Description: q.Description,
+			ClientTier:  q.ClientTier,
+			Status:      q.Status,
+			SellTotal:   q.SellTotal,
+			TaxTotal:    q.TaxTotal,
+			GrandTotal:  q.GrandTotal,
+			UserID:      q.UserID,
+			CreatedBy:   q.CreatedBy,
+			ItemCount:   itemCounts[q.ID],
+			CreatedAt:   q.CreatedAt,
+			UpdatedAt:   q.UpdatedAt,
+		}
+		if showCost {
+			margin, percent := grossMargin(q.SellTotal, q.TotalCost)
+			s.TotalCost = &q.TotalCost
+			s.GrossMargin = &margin
+			s.MarginPercent = &percent
+		}
+		if includes[QuotationIncludeUser] {
+			s.User = &QuotationUserSummary{ID: q.User.ID, Name: q.User.Name, Email: q.User.Email}
+		}
+		if includes[QuotationIncludeItems] {
+			s.Items = make([]QuotationItemSummary, len(q.Items))
+			for j := range q.Items {
+				item := q.Items[j]
+				s.Items[j] = QuotationItemSummary{
+					ID:            item.ID,
+					ComponentID:   item.ComponentID,
+					Description:   item.Description,
+					Unit:          item.Unit,
+					SectionID:     item.SectionID,
+					Notes:         item.Notes,
+					Length:        item.Length,
+					Width:         item.Width,
+					Height:        item.Height,
+					DimensionUnit: item.DimensionUnit,
+					Quantity:      item.Quantity,
+					SellUnitPrice: item.SellUnitPrice,
+					SellTotal:     item.SellTotal,
+				}
+				if item.Component != nil {
+					s.Items[j].ComponentName = item.Component.Name
+				}
+				if showCost {
+					s.Items[j].UnitCost = &item.UnitCost
+					s.Items[j].TotalCost = &item.TotalCost
+					s.Items[j].MarkupPercent = &item.MarkupPercent
+				}
+			}
+		}
+		if includes[QuotationIncludeMaterials] {
+			s.Materials = make([]QuotationMaterialSummary, len(q.Materials))
+			for j, m := range q.Materials {
+				s.Materials[j] = QuotationMaterialSummary{
+					MaterialID:   m.MaterialID,
+					MaterialName: m.MaterialName,
+					Unit:         m.Unit,
+					Quantity:     m.Quantity,
+					UnitCost:     m.UnitCost,
+					TotalCost:    m.TotalCost,
+				}
+			}
+		}
+		summaries[i] = s
+	}
+	return summaries, nil
+}
+
+// listQuotationSummaries loads one page of query as summaries and writes the list response
+func listQuotationSummaries(c *fiber.Ctx, lq *listQuery, query *gorm.DB, includes map[string]bool) error {
+	quotations := []models.Quotation{}
+	p, err := lq.find(query, &quotations, selectQuotationSummary(includes))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(APIResponse{
+			Success: false,
+			Message: "Failed to retrieve quotations",
+		})
+	}
+	summaries, err := summarizeQuotations(quotations, includes, canViewCost(c))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(APIResponse{
+			Success: false,
+			Message: "Failed to retrieve quotations",
+		})
+	}
+	return respondList(c, summaries, p)
+}
@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"qp1/models"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shopspring/decimal"
+)
+
+func TestQuotationCostsNeedViewCost(t *testing.T) {
+	db := setupTest(t)
+	user := models.User{Name: "Sam", Email: "sam@example.com", Role: "sales", Status: models.UserStatusActive}
+	db.Create(&user)
+	quotation := models.Quotation{
+		UserID: user.ID, Title: "Kitchen", QuotationNo: "Q-1",
+		TotalCost: decimal.NewFromInt(700), SellTotal: decimal.NewFromInt(1000), GrandTotal: decimal.NewFromInt(1000),
+	}
+	db.Create(&quotation)
+	db.Create(&models.QuotationItem{QuotationID: quotation.ID, Description: "Installation", Quantity: 1,
+		UnitCost: 700, TotalCost: 700, MarkupPercent: 42.86, SellUnitPrice: 1000, SellTotal: 1000})
+	db.Create(&models.QuotationMaterial{QuotationID: quotation.ID, MaterialID: 1, MaterialName: "Oak board", Unit: "m", UnitCost: 10, TotalCost: 100})
+
+	// costFields are the response keys that reveal costs; "total_cost" also matches the item and material lines
+	costFields := []string{`"total_cost"`, `"unit_cost"`, `"markup_percent"`, `"gross_margin"`, `"cost_breakdown"`, `"materials"`}
+	id := strconv.FormatUint(uint64(quotation.ID), 10)
+	tests := []struct {
+		role     string
+		path     string
+		status   int
+		showCost bool
+	}{
+		{"sales", "/api/quotations?include=items", fiber.StatusOK, false},
+		{"sales", "/api/quotations/" + id, fiber.StatusOK, false},
+		{"sales", "/api/quotations?include=materials", fiber.StatusForbidden, false},
+		{"sales", "/api/quotations?sort=total_cost", fiber.StatusBadRequest, false},
+		{"estimator", "/api/quotations?include=items,materials&sort=total_cost", fiber.StatusOK, true},
+		{"estimator", "/api/quotations/" + id, fiber.StatusOK, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.role+" "+tt.path, func(t *testing.T) {
+			app := fiber.New()
+			app.Use(func(c *fiber.Ctx) error {
+				u := user
+				u.Role = tt.role
+				c.Locals("user", u)
+				return c.Next()
+			})
+			app.Get("/api/quotations", ListQuotations)
+			app.Get("/api/quotations/:id", GetQuotation)
+
+			resp, err := app.Test(httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d: %s", resp.StatusCode, tt.status, body)
+			}
+			if tt.status != fiber.StatusOK {
+				return
+			}
+			if !strings.Contains(string(body), `"sell_total"`) {
+				t.Errorf("response has no selling prices: %s", body)
+			}
+			for _, field := range costFields {
+				if field == `"gross_margin"` || field == `"cost_breakdown"` {
+					// Only returned for a single quotation
+					if !strings.Contains(tt.path, "/"+id) {
+						continue
+					}
+				}
+				if strings.Contains(string(body), field) != tt.showCost {
+					t.Errorf("%s in response = %v, want %v: %s", field, !tt.showCost, tt.showCost, body)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,427 @@
+package controllers
+
+import (
+	"fmt"
+	"math"
+	"qp1/database"
+	"qp1/models"
+	"regexp"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// templateParamName is the format of template parameter names, e.g. wall_length
+var templateParamName = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+// QuotationTemplateRequest is the body for creating or updating a quotation template. Parameters and items
+// replace the existing ones on update.
+type QuotationTemplateRequest struct {
+	Name        string                              `json:"name"`
+	Description string                              `json:"description"`
+	Title       string                              `json:"title"`
+	Shared      bool                                `json:"shared"`
+	Parameters  []models.QuotationTemplateParameter `json:"parameters"`
+	Items       []models.QuotationTemplateItem      `json:"items"`
+}
+
+// QuotationFromTemplateRequest is the body for creating a quotation from a template. Title defaults to the
+// template's title, and parameters override the template's default values.
+type QuotationFromTemplateRequest struct {
+	Title       string             `json:"title"`
+	Description string             `json:"description"`
+	ClientName  string             `json:"client_name"`
+	ClientTier  string             `json:"client_tier"`
+	Parameters  map[string]float64 `json:"parameters"`
+}
+
+var quotationTemplateListSpec = listSpec{
+	Fields: map[string]listField{
+		"id":         {Column: "id", Kind: fieldNumber, Sort: true, Filter: true},
+		"name":       {Column: "name", Kind: fieldString, Sort: true, Filter: true},
+		"shared":     {Column: "shared", Kind: fieldBool, Sort: true, Filter: true},
+		"created_at": {Column: "created_at", Kind: fieldTime, Sort: true, Filter: true},
+		"updated_at": {Column: "updated_at", Kind: fieldTime, Sort: true, Filter: true},
+	},
+	DefaultSort: "name",
+}
+
+// visibleTemplates restricts db to shared templates and the user's own
+func visibleTemplates(c *fiber.Ctx, db *gorm.DB) *gorm.DB {
+	user := c.Locals("user").(models.User)
+	return db.Where("shared = ? OR user_id = ?", true, user.ID)
+}
+
+// canEditTemplate reports whether the current user may change a template: shared templates need
+// templates:manage, personal templates can only be changed by their owner
+func canEditTemplate(c *fiber.Ctx, template models.QuotationTemplate) bool {
+	if template.Shared {
+		return hasPermission(c, models.PermTemplatesManage)
+	}
+	user := c.Locals("user").(models.User)
+	return template.UserID != nil && *template.UserID == user.ID
+}
+
+func preloadTemplateLines(db *gorm.DB) *gorm.DB {
+	return db.Preload("Parameters", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	}).Preload("Items", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position")
+	}).Preload("Items.Component", func(db *gorm.DB) *gorm.DB {
+		return db.Unscoped().Select("id", "name", "description")
+	})
+}
+
+// findVisibleTemplate loads a template the current user can see, with its parameters and items
+func findVisibleTemplate(c *fiber.Ctx) (models.QuotationTemplate, error) {
+	var template models.QuotationTemplate
+	err := preloadTemplateLines(visibleTemplates(c, database.DB)).First(&template, c.Params("id")).Error
+	return template, err
+}
+
+// validateTemplateRequest checks the template and that every parameter an item uses is defined
+func validateTemplateRequest(req *QuotationTemplateRequest) string {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return "Name is required"
+	}
+	if len(req.Name) > 255 || len(req.Title) > 255 {
+		return "Name and title must be less than 255 characters"
+	}
+	if len(req.Items) == 0 {
+		return "At least one item is required"
+	}
+	units, err := loadUnits(database.DB)
+	if err != nil {
+		return "Failed to load units"
+	}
+
+	params := map[string]bool{}
+	for _, p := range req.Parameters {
+		if !templateParamName.MatchString(p.Name) {
+			return fmt.Sprintf("Invalid parameter name %q, use lowercase letters, digits and underscores", p.Name)
+		}
+		if params[p.Name] {
+			return fmt.Sprintf("Duplicate parameter %q", p.Name)
+		}
+		if p.DefaultValue <= 0 {
+			return fmt.Sprintf("Parameter %q must have a default value greater than 0", p.Name)
+		}
+		params[p.Name] = true
+	}
+
+	componentIDs := map[uint]bool{}
+	for i := range req.Items {
+		item := &req.Items[i]
+		if len(strings.TrimSpace(item.Section)) > 100 {
+			return fmt.Sprintf("items[%d].section must be less than 100 characters", i)
+		}
+		if len(item.Notes) > 255 {
+			return fmt.Sprintf("items[%d].notes must be less than 255 characters", i)
+		}
+		fields := []struct {
+			name  string
+			value float64
+			param string
+		}{
+			{"quantity", float64(item.Quantity), item.QuantityParam},
+			{"length", item.Length, item.LengthParam},
+			{"width", item.Width, item.WidthParam},
+			{"height", item.Height, item.HeightParam},
+		}
+		if item.ComponentID == nil || *item.ComponentID == 0 {
+			// Ad-hoc lines are priced per unit, so they have a quantity but no dimensions
+			item.ComponentID = nil
+			item.Length, item.Width, item.Height = 1, 1, 1
+			item.LengthParam, item.WidthParam, item.HeightParam, item.DimensionUnit = "", "", "", ""
+			line := CreateQuotationItemRequest{Description: item.Description, Unit: item.Unit, UnitPrice: item.UnitPrice, UnitCost: item.UnitCost, Quantity: 1}
+			if errs := validateAdHocItem(i, line); len(errs) > 0 {
+				return errs[0].Field + ": " + errs[0].Message
+			}
+			fields = fields[:1]
+		} else {
+			componentIDs[*item.ComponentID] = true
+			item.Description, item.Unit, item.UnitPrice, item.UnitCost = "", "", nil, nil
+			if _, err := units.toMetres(1, item.DimensionUnit); err != nil {
+				return fmt.Sprintf("items[%d].dimension_unit: %v", i, err)
+			}
+		}
+		for _, f := range fields {
+			if f.param != "" {
+				if !params[f.param] {
+					return fmt.Sprintf("items[%d].%s uses undefined parameter %q", i, f.name, f.param)
+				}
+			} else if f.value <= 0 {
+				return fmt.Sprintf("items[%d].%s must be greater than 0", i, f.name)
+			}
+		}
+	}
+
+	ids := make([]uint, 0, len(componentIDs))
+	for id := range componentIDs {
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return ""
+	}
+	var found int64
+	database.DB.Model(&models.Component{}).Where("id IN ?", ids).Count(&found)
+	if int(found) != len(ids) {
+		return "Some components were not found"
+	}
+	return ""
+}
+
+// saveTemplateLines replaces the parameters and items of a template
+func saveTemplateLines(tx *gorm.DB, templateID uint, req QuotationTemplateRequest) error {
+	if err := tx.Where("template_id = ?", templateID).Delete(&models.QuotationTemplateParameter{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("template_id = ?", templateID).Delete(&models.QuotationTemplateItem{}).Error; err != nil {
+		return err
+	}
+	for _, p := range req.Parameters {
+		p.ID = 0
+		p.TemplateID = templateID
+		if err := tx.Create(&p).Error; err != nil {
+			return err
+		}
+	}
+	for i, item := range req.Items {
+		item.ID = 0
+		item.TemplateID = templateID
+		item.Position = i
+		item.Component = nil
+		if err := tx.Create(&item).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hideTemplateCosts removes the unit cost of ad-hoc lines for users without quotations:view_cost
+func hideTemplateCosts(c *fiber.Ctx, templates []models.QuotationTemplate) {
+	if canViewCost(c) {
+		return
+	}
+	for i := range templates {
+		for j := range templates[i].Items {
+			templates[i].Items[j].UnitCost = nil
+		}
+	}
+}
+
+// ListQuotationTemplates 查询共享模板和自己的个人模板（支持排序、过滤、分页）
+func ListQuotationTemplates(c *fiber.Ctx) error {
+	lq, err := parseListQuery(c, quotationTemplateListSpec)
+	if err != nil {
+		return respondListError(c, err)
+	}
+	templates := []models.QuotationTemplate{}
+	p, err := lq.find(visibleTemplates(c, database.DB.Model(&models.QuotationTemplate{})), &templates, preloadTemplateLines)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch templates"})
+	}
+	hideTemplateCosts(c, templates)
+	return respondList(c, templates, p)
+}
+
+// GetQuotationTemplate 获取单个模板及其参数和组件
+func GetQuotationTemplate(c *fiber.Ctx) error {
+	template, err := findVisibleTemplate(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Template not found"})
+	}
+	hideTemplateCosts(c, []models.QuotationTemplate{template})
+	return c.JSON(template)
+}
+
+// CreateQuotationTemplate 新建模板；共享模板需要 templates:manage 权限
+func CreateQuotationTemplate(c *fiber.Ctx) error {
+	var req QuotationTemplateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Failed to parse request body"})
+	}
+	if req.Shared && !hasPermission(c, models.PermTemplatesManage) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Shared templates require the " + models.PermTemplatesManage + " permission"})
+	}
+	if msg := validateTemplateRequest(&req); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": msg})
+	}
+
+	template := models.QuotationTemplate{Name: req.Name, Description: req.Description, Title: req.Title, Shared: req.Shared}
+	if !req.Shared {
+		user := c.Locals("user").(models.User)
+		template.UserID = &user.ID
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&template).Error; err != nil {
+			return err
+		}
+		return saveTemplateLines(tx, template.ID, req)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create template"})
+	}
+	preloadTemplateLines(database.DB).First(&template, template.ID)
+	return c.Status(fiber.StatusCreated).JSON(template)
+}
+
+// UpdateQuotationTemplate 修改模板，参数和组件整体替换
+func UpdateQuotationTemplate(c *fiber.Ctx) error {
+	template, err := findVisibleTemplate(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Template not found"})
+	}
+	if !canEditTemplate(c, template) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You can't change this template"})
+	}
+	var req QuotationTemplateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Failed to parse request body"})
+	}
+	// Sharing a personal template, or taking a shared one private, both need templates:manage
+	if req.Shared != template.Shared && !hasPermission(c, models.PermTemplatesManage) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Shared templates require the " + models.PermTemplatesManage + " permission"})
+	}
+	if msg := validateTemplateRequest(&req); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": msg})
+	}
+
+	template.Name = req.Name
+	template.Description = req.Description
+	template.Title = req.Title
+	if req.Shared != template.Shared {
+		template.Shared = req.Shared
+		template.UserID = nil
+		if !req.Shared {
+			user := c.Locals("user").(models.User)
+			template.UserID = &user.ID
+		}
+	}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&template).Select("name", "description", "title", "shared", "user_id").Updates(&template).Error; err != nil {
+			return err
+		}
+		return saveTemplateLines(tx, template.ID, req)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update template"})
+	}
+	var updated models.QuotationTemplate
+	preloadTemplateLines(database.DB).First(&updated, template.ID)
+	return c.JSON(updated)
+}
+
+// DeleteQuotationTemplate 删除模板（软删除，已创建的报价单不受影响）
+func DeleteQuotationTemplate(c *fiber.Ctx) error {
+	template, err := findVisibleTemplate(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Template not found"})
+	}
+	if !canEditTemplate(c, template) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You can't delete this template"})
+	}
+	if err := database.DB.Delete(&template).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete template"})
+	}
+	return c.JSON(fiber.Map{"message": "Template deleted successfully"})
+}
+
+// templateItems resolves the template's items with parameter values: the defaults, overridden by values
+func templateItems(template models.QuotationTemplate, values map[string]float64) ([]CreateQuotationItemRequest, []ValidationError) {
+	var errors []ValidationError
+	resolved := map[string]float64{}
+	for _, p := range template.Parameters {
+		resolved[p.Name] = p.DefaultValue
+	}
+	for name, value := range values {
+		if _, ok := resolved[name]; !ok {
+			errors = append(errors, ValidationError{Field: "parameters." + name, Message: "Unknown parameter"})
+			continue
+		}
+		if value <= 0 {
+			errors = append(errors, ValidationError{Field: "parameters." + name, Message: "Value must be greater than 0"})
+			continue
+		}
+		resolved[name] = value
+	}
+
+	value := func(fixed float64, param string) float64 {
+		if param != "" {
+			return resolved[param]
+		}
+		return fixed
+	}
+	items := make([]CreateQuotationItemRequest, len(template.Items))
+	for i, item := range template.Items {
+		quantity := value(float64(item.Quantity), item.QuantityParam)
+		if quantity != math.Trunc(quantity) {
+			errors = append(errors, ValidationError{Field: "parameters." + item.QuantityParam, Message: "Quantity must be a whole number"})
+		}
+		items[i] = CreateQuotationItemRequest{
+			Length:        value(item.Length, item.LengthParam),
+			Width:         value(item.Width, item.WidthParam),
+			Height:        value(item.Height, item.HeightParam),
+			DimensionUnit: item.DimensionUnit,
+			Quantity:      int(quantity),
+			Notes:         item.Notes,
+			Section:       item.Section,
+			TaxExempt:     item.TaxExempt,
+			Description:   item.Description,
+			Unit:          item.Unit,
+			UnitPrice:     item.UnitPrice,
+			UnitCost:      item.UnitCost,
+		}
+		if item.ComponentID != nil {
+			items[i].ComponentID = *item.ComponentID
+		}
+	}
+	return items, errors
+}
+
+// CreateQuotationFromTemplate creates a quotation from a template's items, costed like any other quotation
+func CreateQuotationFromTemplate(c *fiber.Ctx) error {
+	template, err := findVisibleTemplate(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(APIResponse{
+			Success: false,
+			Message: "Template not found",
+		})
+	}
+	var body QuotationFromTemplateRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&body); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(APIResponse{
+				Success: false,
+				Message: "Invalid request format",
+				Errors:  []ValidationError{{Field: "body", Message: "Failed to parse request body"}},
+			})
+		}
+	}
+
+	items, validationErrors := templateItems(template, body.Parameters)
+	req := CreateQuotationRequest{
+		Title:       body.Title,
+		Description: body.Description,
+		ClientName:  body.ClientName,
+		ClientTier:  body.ClientTier,
+		Items:       items,
+	}
+	if req.Title == "" {
+		req.Title = template.Title
+	}
+	if req.Title == "" {
+		req.Title = template.Name
+	}
+	validationErrors = append(validationErrors, validateCreateQuotationRequest(req)...)
+	if len(validationErrors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(APIResponse{
+			Success: false,
+			Message: "Validation failed",
+			Errors:  validationErrors,
+		})
+	}
+	return createQuotation(c, req)
+}
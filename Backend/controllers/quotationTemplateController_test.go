@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"qp1/models"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestQuotationFromTemplateWithAdHocLinesAndSections(t *testing.T) {
+	db := setupTest(t)
+	user := models.User{Name: "Sam", Email: "sam@example.com", Role: "estimator", Status: models.UserStatusActive}
+	db.Create(&user)
+	component := models.Component{Name: "Worktop", TotalCost: 100}
+	db.Create(&component)
+
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("user", user)
+		return c.Next()
+	})
+	app.Post("/api/quotation-templates", CreateQuotationTemplate)
+	app.Post("/api/quotations/from-template/:id", CreateQuotationFromTemplate)
+	post := func(path string, body string) (int, []byte) {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, data
+	}
+
+	invalid := []struct {
+		name string
+		item string
+	}{
+		{"ad-hoc line without a price", `{"description": "Delivery", "quantity": 1}`},
+		{"ad-hoc line with a negative price", `{"description": "Delivery", "unit_price": -5, "quantity": 1}`},
+		{"ad-hoc line without a description", `{"unit_price": 50, "quantity": 1}`},
+		{"unknown dimension unit", `{"component_id": ` + strconv.Itoa(int(component.ID)) + `, "length": 1, "width": 1, "height": 1, "dimension_unit": "parsec", "quantity": 1}`},
+		{"area dimension unit", `{"component_id": ` + strconv.Itoa(int(component.ID)) + `, "length": 1, "width": 1, "height": 1, "dimension_unit": "m2", "quantity": 1}`},
+	}
+	for _, tt := range invalid {
+		if status, body := post("/api/quotation-templates", `{"name": "Bad", "items": [`+tt.item+`]}`); status != fiber.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400: %s", tt.name, status, body)
+		}
+	}
+
+	status, body := post("/api/quotation-templates", `{
+		"name": "Kitchen",
+		"parameters": [{"name": "worktop_length", "default_value": 2000}],
+		"items": [
+			{"component_id": `+strconv.Itoa(int(component.ID))+`, "section": "Worktops", "length_param": "worktop_length",
+			 "width": 600, "height": 40, "dimension_unit": "mm", "quantity": 1},
+			{"description": "Installation", "unit": "job", "unit_price": 250, "unit_cost": 150, "quantity": 1,
+			 "section": "Services", "tax_exempt": true}
+		]
+	}`)
+	if status != fiber.StatusCreated {
+		t.Fatalf("creating the template returned %d: %s", status, body)
+	}
+	var template models.QuotationTemplate
+	if err := json.Unmarshal(body, &template); err != nil {
+		t.Fatal(err)
+	}
+
+	status, body = post("/api/quotations/from-template/"+strconv.Itoa(int(template.ID)), `{"parameters": {"worktop_length": 3000}}`)
+	if status != fiber.StatusCreated {
+		t.Fatalf("creating the quotation returned %d: %s", status, body)
+	}
+	var quotation models.Quotation
+	if err := db.Preload("Items").Preload("Sections").Last(&quotation).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(quotation.Items) != 2 || len(quotation.Sections) != 2 {
+		t.Fatalf("quotation has %d items in %d sections, want 2 in 2", len(quotation.Items), len(quotation.Sections))
+	}
+	sections := map[uint]string{}
+	for _, section := range quotation.Sections {
+		sections[section.ID] = section.Name
+	}
+	worktop, installation := quotation.Items[0], quotation.Items[1]
+	if worktop.Position > installation.Position {
+		worktop, installation = installation, worktop
+	}
+	// 3 m x 0.6 m x 0.04 m of a component costing 100 per cubic metre
+	if worktop.DimensionUnit != "mm" || worktop.Length != 3000 || !closeTo(worktop.TotalCost, 7.2) {
+		t.Errorf("worktop = %s %v x %v x %v costing %v, want 3000 x 600 x 40 mm costing 7.2", worktop.DimensionUnit, worktop.Length, worktop.Width, worktop.Height, worktop.TotalCost)
+	}
+	if worktop.SectionID == nil || sections[*worktop.SectionID] != "Worktops" {
+		t.Errorf("worktop is not in the Worktops section")
+	}
+	if installation.ComponentID != nil || installation.Description != "Installation" || installation.SellTotal != 250 ||
+		installation.UnitCost != 150 || !installation.TaxExempt {
+		t.Errorf("installation line = %+v", installation)
+	}
+	if installation.SectionID == nil || sections[*installation.SectionID] != "Services" {
+		t.Errorf("installation is not in the Services section")
+	}
+}
+
+func closeTo(a, b float64) bool {
+	return a-b < 0.005 && b-a < 0.005
+}
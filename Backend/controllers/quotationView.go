@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"qp1/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// QuotationResponse is a quotation as returned by the API. Without quotations:view_cost only selling prices are
+// shown: costs, markups, margins and the resolved materials are left out.
+type QuotationResponse struct {
+	models.Quotation
+	TotalCost     *decimal.Decimal           `json:"total_cost,omitempty"`
+	CostBreakdown *QuotationCostBreakdown    `json:"cost_breakdown,omitempty"`
+	GrossMargin   *decimal.Decimal           `json:"gross_margin,omitempty"`
+	MarginPercent *float64                   `json:"margin_percent,omitempty"`
+	Items         []QuotationItemResponse    `json:"items,omitempty"`
+	Materials     []models.QuotationMaterial `json:"materials,omitempty"`
+}
+
+// QuotationCostBreakdown splits the cost of a quotation into the material, labor and overhead cost of its
+// component items, the cost of its ad-hoc items and the material bought beyond what the items use
+type QuotationCostBreakdown struct {
+	MaterialCost float64 `json:"material_cost"`
+	LaborCost    float64 `json:"labor_cost"`
+	OverheadCost float64 `json:"overhead_cost"`
+	AdHocCost    float64 `json:"ad_hoc_cost"`   // cost of the items without a component
+	RoundingCost float64 `json:"rounding_cost"` // surplus cost of the quotation materials
+}
+
+// QuotationItemResponse is a quotation item as returned by the API, see QuotationResponse
+type QuotationItemResponse struct {
+	models.QuotationItem
+	UnitCost      *float64                   `json:"unit_cost,omitempty"`
+	TotalCost     *float64                   `json:"total_cost,omitempty"`
+	MaterialCost  *float64                   `json:"material_cost,omitempty"`
+	LaborCost     *float64                   `json:"labor_cost,omitempty"`
+	OverheadCost  *float64                   `json:"overhead_cost,omitempty"`
+	MarkupPercent *float64                   `json:"markup_percent,omitempty"`
+	Component     *QuotationComponentSummary `json:"component,omitempty"`
+	Quotation     *struct{}                  `json:"quotation,omitempty"` // hides the empty back reference
+}
+
+// QuotationComponentSummary is the component of a quotation item; its cost is not shown
+type QuotationComponentSummary struct {
+	ID          uint   `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// preloadQuotationItems loads the sections and items of quotations in their order, with the components of the
+// items even if deleted since
+func preloadQuotationItems(db *gorm.DB) *gorm.DB {
+	return db.Preload("Sections", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position, id")
+	}).Preload("Items", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position, id")
+	}).Preload("Items.Component", func(db *gorm.DB) *gorm.DB {
+		return db.Unscoped()
+	})
+}
+
+// canViewCost reports whether the current user may see costs and margins
+func canViewCost(c *fiber.Ctx) bool {
+	return hasPermission(c, models.PermQuotationsViewCost)
+}
+
+// grossMargin returns the margin of a selling price over its cost and the margin in percent of the selling price
+func grossMargin(sell decimal.Decimal, cost decimal.Decimal) (decimal.Decimal, float64) {
+	margin := sell.Sub(cost)
+	if sell.IsZero() {
+		return margin, 0
+	}
+	return margin, margin.Div(sell).Mul(decimal.NewFromInt(100)).Round(2).InexactFloat64()
+}
+
+// presentQuotation prepares a quotation for the current user
+func presentQuotation(c *fiber.Ctx, q models.Quotation) QuotationResponse {
+	showCost := canViewCost(c)
+	resp := QuotationResponse{Quotation: q, Items: make([]QuotationItemResponse, len(q.Items))}
+	if showCost {
+		margin, percent := grossMargin(q.SellTotal, q.TotalCost)
+		resp.TotalCost = &q.TotalCost
+		resp.GrossMargin = &margin
+		resp.MarginPercent = &percent
+		resp.Materials = q.Materials
+		resp.CostBreakdown = &QuotationCostBreakdown{}
+		for _, material := range q.Materials {
+			resp.CostBreakdown.RoundingCost += material.SurplusCost
+		}
+	}
+	for i := range q.Items {
+		item := q.Items[i]
+		view := QuotationItemResponse{QuotationItem: item}
+		if item.Component != nil {
+			view.Component = &QuotationComponentSummary{ID: item.Component.ID, Name: item.Component.Name, Description: item.Component.Description}
+		}
+		if showCost {
+			view.UnitCost = &item.UnitCost
+			view.TotalCost = &item.TotalCost
+			view.MarkupPercent = &item.MarkupPercent
+			view.MaterialCost = &item.MaterialCost
+			view.LaborCost = &item.LaborCost
+			view.OverheadCost = &item.OverheadCost
+			resp.CostBreakdown.MaterialCost += item.MaterialCost
+			resp.CostBreakdown.LaborCost += item.LaborCost
+			resp.CostBreakdown.OverheadCost += item.OverheadCost
+			if item.ComponentID == nil {
+				resp.CostBreakdown.AdHocCost += item.TotalCost
+			}
+		}
+		resp.Items[i] = view
+	}
+	if b := resp.CostBreakdown; b != nil {
+		b.MaterialCost, b.LaborCost, b.OverheadCost = roundCents(b.MaterialCost), roundCents(b.LaborCost), roundCents(b.OverheadCost)
+		b.AdHocCost, b.RoundingCost = roundCents(b.AdHocCost), roundCents(b.RoundingCost)
+	}
+	return resp
+}
@@ -0,0 +1,251 @@
+package controllers
+
+import (
+	"fmt"
+	"qp1/database"
+	"qp1/models"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// ftMinTokenLength is InnoDB's default innodb_ft_min_token_size; shorter words aren't in FULLTEXT indexes
+const ftMinTokenLength = 3
+
+// Result types of the global search
+const (
+	SearchTypeQuotation = "quotation"
+	SearchTypeClient    = "client"
+	SearchTypeComponent = "component"
+	SearchTypeMaterial  = "material"
+)
+
+// SearchResult is one hit of the global search
+type SearchResult struct {
+	Type     string  `json:"type"`
+	ID       uint    `json:"id,omitempty"` // not set for clients, which are identified by name
+	Title    string  `json:"title"`
+	Subtitle string  `json:"subtitle,omitempty"`
+	Score    float64 `json:"score"`
+}
+
+// textSearch matches every word of a query against a set of columns. On MySQL it uses the FULLTEXT index over
+// the columns with prefix matching and relevance ranking; on other databases, or for words too short for the
+// index, it falls back to LIKE.
+type textSearch struct {
+	columns  []string
+	terms    []string
+	fullText bool
+}
+
+// searchTerms splits a query into words. Only letters and digits are kept, which also strips the boolean mode operators.
+func searchTerms(q string) []string {
+	return strings.FieldsFunc(q, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func newTextSearch(db *gorm.DB, q string, columns ...string) textSearch {
+	s := textSearch{columns: columns, terms: searchTerms(q), fullText: db.Dialector.Name() == "mysql"}
+	for _, term := range s.terms {
+		if utf8.RuneCountInString(term) < ftMinTokenLength {
+			s.fullText = false
+		}
+	}
+	return s
+}
+
+func (s textSearch) booleanQuery() string {
+	parts := make([]string, len(s.terms))
+	for i, term := range s.terms {
+		parts[i] = "+" + term + "*"
+	}
+	return strings.Join(parts, " ")
+}
+
+func (s textSearch) matchSQL() string {
+	return "MATCH(" + strings.Join(s.columns, ", ") + ") AGAINST (? IN BOOLEAN MODE)"
+}
+
+// where restricts db to rows matching every word
+func (s textSearch) where(db *gorm.DB) *gorm.DB {
+	if s.fullText {
+		return db.Where(s.matchSQL(), s.booleanQuery())
+	}
+	for _, term := range s.terms {
+		like := "%" + term + "%"
+		conditions := make([]string, len(s.columns))
+		args := make([]interface{}, len(s.columns))
+		for i, column := range s.columns {
+			conditions[i] = column + " LIKE ?"
+			args[i] = like
+		}
+		db = db.Where("("+strings.Join(conditions, " OR ")+")", args...)
+	}
+	return db
+}
+
+// score returns an SQL expression for the relevance of a row, with its arguments
+func (s textSearch) score() (string, []interface{}) {
+	if s.fullText {
+		return s.matchSQL(), []interface{}{s.booleanQuery()}
+	}
+	return "1", nil
+}
+
+// orderByRelevance sorts db by relevance, best match first
+func (s textSearch) orderByRelevance(db *gorm.DB) *gorm.DB {
+	if !s.fullText {
+		return db
+	}
+	// GORM can't bind parameters in ORDER BY. The terms are letters and digits only, so the query is safe to inline.
+	return db.Order(strings.Replace(s.matchSQL(), "?", "'"+s.booleanQuery()+"'", 1) + " DESC")
+}
+
+// GlobalSearch 全局搜索报价单、客户、组件和物料，按相关度排序
+//
+//	GET /api/search?q=oak+cabinet&types=quotation,client&limit=10
+//
+// Quotations also match on the names of their components and materials. Users only see their own quotations and
+// clients unless they have quotations:view_all, and components require components:read.
+func GlobalSearch(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+	q := strings.TrimSpace(c.Query("q"))
+	limit := c.QueryInt("limit", 10)
+	if limit < 1 || limit > 50 {
+		limit = 10
+	}
+	types := map[string]bool{}
+	if raw := c.Query("types"); raw != "" {
+		for _, t := range strings.Split(raw, ",") {
+			types[strings.TrimSpace(t)] = true
+		}
+	} else {
+		for _, t := range []string{SearchTypeQuotation, SearchTypeClient, SearchTypeComponent, SearchTypeMaterial} {
+			types[t] = true
+		}
+	}
+
+	if len(searchTerms(q)) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Search query is required"})
+	}
+	results := []SearchResult{}
+
+	// Quotations outside the user's own are only visible with quotations:view_all
+	visibleQuotations := func() *gorm.DB {
+		db := database.DB.Model(&models.Quotation{})
+		if !hasPermission(c, models.PermQuotationsViewAll) {
+			db = db.Where("user_id = ?", user.ID)
+		}
+		return db
+	}
+
+	if types[SearchTypeQuotation] {
+		s := newTextSearch(database.DB, q, "search_text")
+		score, args := s.score()
+		var rows []struct {
+			ID          uint
+			QuotationNo string
+			Title       string
+			ClientName  string
+			Relevance   float64
+		}
+		if err := s.where(visibleQuotations()).
+			Select("id, quotation_no, title, client_name, "+score+" AS relevance", args...).
+			Order("relevance DESC, id DESC").Limit(limit).Scan(&rows).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to search quotations"})
+		}
+		for _, r := range rows {
+			subtitle := r.QuotationNo
+			if r.ClientName != "" {
+				subtitle += " · " + r.ClientName
+			}
+			results = append(results, SearchResult{Type: SearchTypeQuotation, ID: r.ID, Title: r.Title, Subtitle: subtitle, Score: r.Relevance})
+		}
+	}
+
+	if types[SearchTypeClient] {
+		s := newTextSearch(database.DB, q, "client_name")
+		score, args := s.score()
+		var rows []struct {
+			ClientName string
+			Relevance  float64
+			Quotations int
+		}
+		if err := s.where(visibleQuotations()).Where("client_name <> ''").
+			Select("client_name, MAX("+score+") AS relevance, COUNT(*) AS quotations", args...).
+			Group("client_name").Order("relevance DESC, client_name").Limit(limit).Scan(&rows).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to search clients"})
+		}
+		for _, r := range rows {
+			results = append(results, SearchResult{Type: SearchTypeClient, Title: r.ClientName, Subtitle: pluralize(r.Quotations, "quotation"), Score: r.Relevance})
+		}
+	}
+
+	if types[SearchTypeComponent] && hasPermission(c, models.PermComponentsRead) {
+		s := newTextSearch(database.DB, q, "name", "description")
+		score, args := s.score()
+		var rows []struct {
+			ID          uint
+			Name        string
+			Description string
+			Relevance   float64
+		}
+		if err := s.where(database.DB.Model(&models.Component{})).
+			Select("id, name, description, "+score+" AS relevance", args...).
+			Order("relevance DESC, name").Limit(limit).Scan(&rows).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to search components"})
+		}
+		for _, r := range rows {
+			results = append(results, SearchResult{Type: SearchTypeComponent, ID: r.ID, Title: r.Name, Subtitle: truncate(r.Description, 120), Score: r.Relevance})
+		}
+	}
+
+	// Materials are visible to every user through /api/products
+	if types[SearchTypeMaterial] {
+		s := newTextSearch(database.DB, q, "name", "description", "sku")
+		score, args := s.score()
+		var rows []struct {
+			ID        uint
+			Name      string
+			SKU       *string `gorm:"column:sku"`
+			Relevance float64
+		}
+		if err := s.where(database.DB.Model(&models.Material{})).
+			Select("id, name, sku, "+score+" AS relevance", args...).
+			Order("relevance DESC, name").Limit(limit).Scan(&rows).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to search materials"})
+		}
+		for _, r := range rows {
+			result := SearchResult{Type: SearchTypeMaterial, ID: r.ID, Title: r.Name, Score: r.Relevance}
+			if r.SKU != nil {
+				result.Subtitle = *r.SKU
+			}
+			results = append(results, result)
+		}
+	}
+
+	// Best matches first across all types; the sort is stable so each type keeps its own order on ties
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	return c.JSON(fiber.Map{"query": q, "results": results})
+}
+
+func pluralize(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n]) + "…"
+}
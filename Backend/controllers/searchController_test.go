@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"qp1/database"
+	"qp1/models"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestRenamingRefreshesQuotationSearch(t *testing.T) {
+	db := setupTest(t)
+	component := models.Component{Name: "Oak cabinet"}
+	material := models.Material{Name: "Oak board", Unit: "m"}
+	db.Create(&component)
+	db.Create(&material)
+	quotation := models.Quotation{UserID: 1, Title: "Kitchen", QuotationNo: "Q-1"}
+	db.Create(&quotation)
+	db.Create(&models.QuotationItem{QuotationID: quotation.ID, ComponentID: &component.ID})
+	db.Create(&models.QuotationMaterial{QuotationID: quotation.ID, MaterialID: material.ID, MaterialName: material.Name, Unit: "m"})
+	if err := database.RefreshQuotationSearchText(db, quotation.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	app := fiber.New()
+	app.Put("/components/:id", UpdateComponent)
+	app.Put("/materials/:id", UpdateMaterial)
+	renames := []struct {
+		path string
+		name string
+	}{
+		{"/components/" + strconv.FormatUint(uint64(component.ID), 10), "Walnut cabinet"},
+		{"/materials/" + strconv.FormatUint(uint64(material.ID), 10), "Walnut board"},
+	}
+	for _, rename := range renames {
+		req := httptest.NewRequest(http.MethodPut, rename.path, strings.NewReader(`{"name": "`+rename.name+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("renaming %s returned %d", rename.path, resp.StatusCode)
+		}
+	}
+
+	var saved models.Quotation
+	db.First(&saved, quotation.ID)
+	for _, want := range []string{"Walnut cabinet", "Walnut board", "Oak board"} {
+		if !strings.Contains(saved.SearchText, want) {
+			t.Errorf("search text %q does not contain %q", saved.SearchText, want)
+		}
+	}
+	if strings.Contains(saved.SearchText, "Oak cabinet") {
+		t.Errorf("search text %q still has the old component name", saved.SearchText)
+	}
+}
@@ -0,0 +1,247 @@
+package controllers
+
+import (
+	"qp1/database"
+	"qp1/models"
+	"qp1/utils"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+const (
+	// accessTokenTTL is short because access tokens are checked against the session on every request anyway
+	accessTokenTTL = 15 * time.Minute
+	// refreshTokenTTL is extended every time the refresh token is rotated
+	refreshTokenTTL   = 7 * 24 * time.Hour
+	refreshCookieName = "refresh_token"
+)
+
+// sessionActive reports whether a session has been neither revoked nor expired
+func sessionActive(session models.Session) bool {
+	return session.RevokedAt == nil && time.Now().Before(session.ExpiresAt)
+}
+
+// startSession creates a server-side session for user and sets the access and refresh token cookies.
+// twoFactor records whether the user signed in with a second factor.
+func startSession(c *fiber.Ctx, user models.User, twoFactor bool) error {
+	refreshToken, err := utils.GenerateToken(32)
+	if err != nil {
+		return err
+	}
+	device := c.Get(fiber.HeaderUserAgent)
+	if len(device) > 255 {
+		device = device[:255]
+	}
+	now := time.Now()
+	session := models.Session{
+		UserID:            user.ID,
+		RefreshTokenHash:  utils.HashToken(refreshToken),
+		Device:            device,
+		IP:                c.IP(),
+		LastSeenAt:        now,
+		ExpiresAt:         now.Add(refreshTokenTTL),
+		TwoFactorVerified: twoFactor,
+	}
+	if err := database.DB.Create(&session).Error; err != nil {
+		return err
+	}
+	return setSessionCookies(c, user, session, refreshToken)
+}
+
+// setSessionCookies issues a new access token for the session and stores both tokens in HTTP-only cookies
+func setSessionCookies(c *fiber.Ctx, user models.User, session models.Session, refreshToken string) error {
+	now := time.Now()
+	token, err := utils.SignJWT(jwt.MapClaims{
+		"sub":  strconv.Itoa(int(user.ID)),
+		"role": user.Role, // 登录时写入数据库中的role
+		"sid":  strconv.Itoa(int(session.ID)),
+		"exp":  now.Add(accessTokenTTL).Unix(),
+	})
+	if err != nil {
+		return err
+	}
+	c.Cookie(&fiber.Cookie{
+		Name:     "jwt",
+		Value:    token,
+		Expires:  now.Add(accessTokenTTL),
+		HTTPOnly: true,
+		Secure:   false, // Change this to true when served over HTTPS
+	})
+	c.Cookie(&fiber.Cookie{
+		Name:     refreshCookieName,
+		Value:    refreshToken,
+		Path:     "/api",
+		Expires:  session.ExpiresAt,
+		HTTPOnly: true,
+		Secure:   false,
+		SameSite: fiber.CookieSameSiteStrictMode,
+	})
+	return nil
+}
+
+// clearSessionCookies expires the access and refresh token cookies
+func clearSessionCookies(c *fiber.Ctx) {
+	expired := time.Now().Add(-time.Hour) // Expired 1 hour ago
+	c.Cookie(&fiber.Cookie{Name: "jwt", Value: "", Expires: expired, HTTPOnly: true, Secure: true})
+	c.Cookie(&fiber.Cookie{Name: refreshCookieName, Value: "", Path: "/api", Expires: expired, HTTPOnly: true, Secure: true})
+}
+
+// revokeUserSessions revokes all active sessions of a user except exceptID (0 revokes all)
+func revokeUserSessions(tx *gorm.DB, userID uint, exceptID uint) error {
+	query := tx.Model(&models.Session{}).Where("user_id = ? AND revoked_at IS NULL", userID)
+	if exceptID != 0 {
+		query = query.Where("id <> ?", exceptID)
+	}
+	return query.Update("revoked_at", time.Now()).Error
+}
+
+// RefreshSession exchanges a refresh token for a new access token and a rotated refresh token.
+// The refresh token is read from its cookie, or from {"refresh_token": "..."} for non-browser clients.
+func RefreshSession(c *fiber.Ctx) error {
+	refreshToken := c.Cookies(refreshCookieName)
+	if refreshToken == "" {
+		var body struct {
+			RefreshToken string `json:"refresh_token"`
+		}
+		_ = c.BodyParser(&body)
+		refreshToken = body.RefreshToken
+	}
+	if refreshToken == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Refresh token is required"})
+	}
+
+	hash := utils.HashToken(refreshToken)
+	var session models.Session
+	if err := database.DB.Where("refresh_token_hash = ?", hash).First(&session).Error; err != nil {
+		// A rotated token being presented again means it was copied; kill the session it belonged to
+		if err := database.DB.Where("previous_token_hash = ?", hash).First(&session).Error; err == nil {
+			database.DB.Model(&session).Update("revoked_at", time.Now())
+		}
+		clearSessionCookies(c)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid refresh token"})
+	}
+	if !sessionActive(session) {
+		clearSessionCookies(c)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Session expired"})
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, session.UserID).Error; err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "User not found"})
+	}
+
+	newToken, err := utils.GenerateToken(32)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to generate token"})
+	}
+	now := time.Now()
+	session.PreviousTokenHash = hash
+	session.RefreshTokenHash = utils.HashToken(newToken)
+	session.LastSeenAt = now
+	session.ExpiresAt = now.Add(refreshTokenTTL)
+	session.IP = c.IP()
+	// Rotate only if the token is still the current one, so of two concurrent refreshes with it only one wins
+	result := database.DB.Model(&models.Session{}).
+		Where("id = ? AND refresh_token_hash = ? AND revoked_at IS NULL", session.ID, hash).
+		Updates(map[string]interface{}{
+			"previous_token_hash": session.PreviousTokenHash,
+			"refresh_token_hash":  session.RefreshTokenHash,
+			"last_seen_at":        session.LastSeenAt,
+			"expires_at":          session.ExpiresAt,
+			"ip":                  session.IP,
+		})
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to refresh session"})
+	}
+	if result.RowsAffected == 0 {
+		// Someone else rotated or revoked it first; the token has been used twice, so treat it as reuse
+		database.DB.Model(&models.Session{}).Where("id = ?", session.ID).Update("revoked_at", now)
+		clearSessionCookies(c)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid refresh token"})
+	}
+	if err := setSessionCookies(c, user, session, newToken); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to generate token"})
+	}
+	return c.JSON(fiber.Map{"message": "Session refreshed"})
+}
+
+// ListSessions returns the current user's active sessions
+func ListSessions(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+	currentID, _ := c.Locals("session_id").(uint)
+
+	var sessions []models.Session
+	if err := database.DB.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", user.ID, time.Now()).
+		Order("last_seen_at DESC").Find(&sessions).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch sessions"})
+	}
+	result := make([]fiber.Map, 0, len(sessions))
+	for _, s := range sessions {
+		result = append(result, fiber.Map{
+			"id":           s.ID,
+			"device":       s.Device,
+			"ip":           s.IP,
+			"last_seen_at": s.LastSeenAt,
+			"created_at":   s.CreatedAt,
+			"expires_at":   s.ExpiresAt,
+			"current":      s.ID == currentID,
+		})
+	}
+	return c.JSON(result)
+}
+
+// RevokeSession revokes one of the current user's sessions
+func RevokeSession(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+	result := database.DB.Model(&models.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", c.Params("id"), user.ID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to revoke session"})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Session not found"})
+	}
+	if currentID, _ := c.Locals("session_id").(uint); c.Params("id") == strconv.Itoa(int(currentID)) {
+		clearSessionCookies(c)
+	}
+	return c.JSON(fiber.Map{"message": "Session revoked"})
+}
+
+// RevokeAllSessions revokes all of the current user's sessions; ?keep_current=true keeps this one signed in
+func RevokeAllSessions(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+	var exceptID uint
+	if c.QueryBool("keep_current", false) {
+		exceptID, _ = c.Locals("session_id").(uint)
+	}
+	if err := revokeUserSessions(database.DB, user.ID, exceptID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to revoke sessions"})
+	}
+	if exceptID == 0 {
+		clearSessionCookies(c)
+	}
+	return c.JSON(fiber.Map{"message": "Sessions revoked"})
+}
+
+// AdminRevokeUserSessions 管理员强制注销某个用户的所有会话并吊销其API token
+func AdminRevokeUserSessions(c *fiber.Ctx) error {
+	var user models.User
+	if err := database.DB.First(&user, c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := revokeUserSessions(tx, user.ID, 0); err != nil {
+			return err
+		}
+		return revokeUserAPITokens(tx, user.ID)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to revoke sessions"})
+	}
+	return c.JSON(fiber.Map{"message": "User sessions revoked"})
+}
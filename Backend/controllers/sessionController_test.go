@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"qp1/models"
+	"qp1/utils"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// refresh posts a refresh token and returns the status and the rotated refresh token, if any
+func refresh(t *testing.T, app *fiber.App, token string) (int, string) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/refresh", strings.NewReader(`{"refresh_token": "`+token+`"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == refreshCookieName {
+			return resp.StatusCode, cookie.Value
+		}
+	}
+	return resp.StatusCode, ""
+}
+
+// newTestSession stores a session for a new user and returns it with its refresh token
+func newTestSession(t *testing.T, db *gorm.DB, token string, expiresAt time.Time, revoked bool) models.Session {
+	t.Helper()
+	user := models.User{Name: token, Email: token + "@example.com", Role: "user", Status: models.UserStatusActive}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	session := models.Session{UserID: user.ID, RefreshTokenHash: utils.HashToken(token), LastSeenAt: time.Now(), ExpiresAt: expiresAt}
+	if revoked {
+		now := time.Now()
+		session.RevokedAt = &now
+	}
+	if err := db.Create(&session).Error; err != nil {
+		t.Fatal(err)
+	}
+	return session
+}
+
+func sessionRevoked(db *gorm.DB, id uint) bool {
+	var session models.Session
+	db.First(&session, id)
+	return session.RevokedAt != nil
+}
+
+func TestRefreshSessionRotatesToken(t *testing.T) {
+	db := setupTest(t)
+	app := fiber.New()
+	app.Post("/api/refresh", RefreshSession)
+	session := newTestSession(t, db, "first", time.Now().Add(time.Hour), false)
+
+	status, second := refresh(t, app, "first")
+	if status != fiber.StatusOK || second == "" || second == "first" {
+		t.Fatalf("refresh returned %d with token %q", status, second)
+	}
+	status, third := refresh(t, app, second)
+	if status != fiber.StatusOK || third == "" || third == second {
+		t.Fatalf("refreshing the rotated token returned %d with token %q", status, third)
+	}
+	if sessionRevoked(db, session.ID) {
+		t.Fatal("rotation revoked the session")
+	}
+
+	// Presenting a token that has already been rotated away means it was copied
+	if status, _ := refresh(t, app, second); status != fiber.StatusUnauthorized {
+		t.Errorf("reusing a rotated token returned %d", status)
+	}
+	if !sessionRevoked(db, session.ID) {
+		t.Error("reusing a rotated token did not revoke the session")
+	}
+	if status, _ := refresh(t, app, third); status != fiber.StatusUnauthorized {
+		t.Errorf("the latest token of a revoked session returned %d", status)
+	}
+}
+
+func TestRefreshSessionRejectsInactiveSessions(t *testing.T) {
+	db := setupTest(t)
+	app := fiber.New()
+	app.Post("/api/refresh", RefreshSession)
+	newTestSession(t, db, "expired", time.Now().Add(-time.Minute), false)
+	newTestSession(t, db, "revoked", time.Now().Add(time.Hour), true)
+
+	for _, token := range []string{"expired", "revoked", "unknown", ""} {
+		if status, rotated := refresh(t, app, token); status != fiber.StatusUnauthorized || rotated != "" {
+			t.Errorf("refreshing %q returned %d with token %q", token, status, rotated)
+		}
+	}
+}
+
+func TestConcurrentRefreshOnlyRotatesOnce(t *testing.T) {
+	db := setupTest(t)
+	app := fiber.New()
+	app.Post("/api/refresh", RefreshSession)
+	session := newTestSession(t, db, "shared", time.Now().Add(time.Hour), false)
+
+	// Let another request with the same token rotate it between this request's read and its update
+	raced := false
+	err := db.Callback().Update().Before("gorm:update").Register("test:race", func(tx *gorm.DB) {
+		if raced || tx.Statement.Table != "sessions" {
+			return
+		}
+		raced = true
+		tx.Session(&gorm.Session{NewDB: true}).Model(&models.Session{}).Where("id = ?", session.ID).
+			Updates(map[string]interface{}{"previous_token_hash": utils.HashToken("shared"), "refresh_token_hash": utils.HashToken("winner")})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status, rotated := refresh(t, app, "shared"); status != fiber.StatusUnauthorized || rotated != "" {
+		t.Errorf("the losing refresh returned %d with token %q", status, rotated)
+	}
+	if !raced {
+		t.Fatal("the competing rotation never ran")
+	}
+	if !sessionRevoked(db, session.ID) {
+		t.Error("a refresh token used twice did not revoke the session")
+	}
+}
@@ -1,12 +1,31 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
 	"qp1/database"
 	"qp1/models"
+	"qp1/utils"
 
 	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
+// getSettings returns the settings row, creating it with the default values on first use
+func getSettings(tx *gorm.DB) (models.Settings, error) {
+	var settings models.Settings
+	err := tx.First(&settings).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// Create first and read back so the column defaults are filled in
+		if err := tx.Create(&settings).Error; err != nil {
+			return settings, err
+		}
+		err = tx.First(&settings, settings.ID).Error
+	}
+	return settings, err
+}
+
 // UpdateCompanyInfo updates company name, address, and logo
 func UpdateCompanyInfo(c *fiber.Ctx) error {
 	var data struct {
@@ -17,8 +36,10 @@ func UpdateCompanyInfo(c *fiber.Ctx) error {
 	if err := c.BodyParser(&data); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
 	}
-	var settings models.Settings
-	database.DB.First(&settings)
+	settings, err := getSettings(database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to load settings"})
+	}
 	settings.CompanyName = data.Name
 	settings.CompanyAddress = data.Address
 	settings.CompanyLogo = data.Logo
@@ -36,8 +57,13 @@ func UpdateTaxSettings(c *fiber.Ctx) error {
 	if err := c.BodyParser(&data); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
 	}
-	var settings models.Settings
-	database.DB.First(&settings)
+	if data.TaxRate < 0 || data.TaxRate > 100 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Tax rate must be between 0 and 100 percent"})
+	}
+	settings, err := getSettings(database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to load settings"})
+	}
 	settings.TaxRate = data.TaxRate
 	if err := database.DB.Save(&settings).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update tax settings"})
@@ -45,6 +71,28 @@ func UpdateTaxSettings(c *fiber.Ctx) error {
 	return c.JSON(settings)
 }
 
+// UpdateMarkupSettings updates the default markup, used when no markup rule matches
+func UpdateMarkupSettings(c *fiber.Ctx) error {
+	var data struct {
+		DefaultMarkupPercent float64 `json:"default_markup_percent"`
+	}
+	if err := c.BodyParser(&data); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	}
+	if data.DefaultMarkupPercent <= -100 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Markup percent must be greater than -100"})
+	}
+	settings, err := getSettings(database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to load settings"})
+	}
+	settings.DefaultMarkupPercent = data.DefaultMarkupPercent
+	if err := database.DB.Save(&settings).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update markup settings"})
+	}
+	return c.JSON(settings)
+}
+
 // UpdateCurrencySettings updates the currency
 func UpdateCurrencySettings(c *fiber.Ctx) error {
 	var data struct {
@@ -53,8 +101,10 @@ func UpdateCurrencySettings(c *fiber.Ctx) error {
 	if err := c.BodyParser(&data); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
 	}
-	var settings models.Settings
-	database.DB.First(&settings)
+	settings, err := getSettings(database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to load settings"})
+	}
 	settings.Currency = data.Currency
 	if err := database.DB.Save(&settings).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update currency settings"})
@@ -70,8 +120,10 @@ func UpdateQuotationNumberFormat(c *fiber.Ctx) error {
 	if err := c.BodyParser(&data); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
 	}
-	var settings models.Settings
-	database.DB.First(&settings)
+	settings, err := getSettings(database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to load settings"})
+	}
 	settings.QuotationNoFormat = data.QuotationNoFormat
 	if err := database.DB.Save(&settings).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update quotation number format"})
@@ -87,11 +139,161 @@ func UpdateTermsAndConditions(c *fiber.Ctx) error {
 	if err := c.BodyParser(&data); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
 	}
-	var settings models.Settings
-	database.DB.First(&settings)
+	settings, err := getSettings(database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to load settings"})
+	}
 	settings.TermsAndConditions = data.Terms
 	if err := database.DB.Save(&settings).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update terms and conditions"})
 	}
 	return c.JSON(settings)
 }
+
+// UpdatePasswordPolicy updates the password rules and the bcrypt cost used for new hashes
+func UpdatePasswordPolicy(c *fiber.Ctx) error {
+	var data struct {
+		MinLength     *int  `json:"min_length"`
+		RequireUpper  *bool `json:"require_upper"`
+		RequireLower  *bool `json:"require_lower"`
+		RequireDigit  *bool `json:"require_digit"`
+		RequireSymbol *bool `json:"require_symbol"`
+		CheckCommon   *bool `json:"check_common"`
+		HistoryCount  *int  `json:"history_count"`
+		BcryptCost    *int  `json:"bcrypt_cost"`
+	}
+	if err := c.BodyParser(&data); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	}
+	settings, err := getSettings(database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to load settings"})
+	}
+	if data.MinLength != nil {
+		if *data.MinLength < 6 || *data.MinLength > utils.PasswordMaxLength {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Minimum length must be between 6 and %d", utils.PasswordMaxLength)})
+		}
+		settings.PasswordMinLength = *data.MinLength
+	}
+	if data.HistoryCount != nil {
+		if *data.HistoryCount < 0 || *data.HistoryCount > 24 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "History count must be between 0 and 24"})
+		}
+		settings.PasswordHistoryCount = *data.HistoryCount
+	}
+	if data.BcryptCost != nil {
+		// Above 16 every login takes seconds of CPU
+		if *data.BcryptCost < bcrypt.DefaultCost || *data.BcryptCost > 16 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Bcrypt cost must be between %d and 16", bcrypt.DefaultCost)})
+		}
+		settings.BcryptCost = *data.BcryptCost
+	}
+	if data.RequireUpper != nil {
+		settings.PasswordRequireUpper = *data.RequireUpper
+	}
+	if data.RequireLower != nil {
+		settings.PasswordRequireLower = *data.RequireLower
+	}
+	if data.RequireDigit != nil {
+		settings.PasswordRequireDigit = *data.RequireDigit
+	}
+	if data.RequireSymbol != nil {
+		settings.PasswordRequireSymbol = *data.RequireSymbol
+	}
+	if data.CheckCommon != nil {
+		settings.PasswordCheckCommon = *data.CheckCommon
+	}
+	if err := database.DB.Save(&settings).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update password policy"})
+	}
+	return c.JSON(settings)
+}
+
+// UpdateLoginSecuritySettings updates the failed login limits and the lockout duration
+func UpdateLoginSecuritySettings(c *fiber.Ctx) error {
+	var data struct {
+		MaxFailures      *int `json:"max_failures"`
+		MaxFailuresPerIP *int `json:"max_failures_per_ip"`
+		LockoutMinutes   *int `json:"lockout_minutes"`
+	}
+	if err := c.BodyParser(&data); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	}
+	settings, err := getSettings(database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to load settings"})
+	}
+	if data.MaxFailures != nil {
+		if *data.MaxFailures < 1 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Max failures must be at least 1"})
+		}
+		settings.LoginMaxFailures = *data.MaxFailures
+	}
+	if data.MaxFailuresPerIP != nil {
+		if *data.MaxFailuresPerIP < 1 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Max failures per IP must be at least 1"})
+		}
+		settings.LoginMaxFailuresPerIP = *data.MaxFailuresPerIP
+	}
+	if data.LockoutMinutes != nil {
+		if *data.LockoutMinutes < 1 || *data.LockoutMinutes > 24*60 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Lockout must be between 1 and 1440 minutes"})
+		}
+		settings.LoginLockoutMinutes = *data.LockoutMinutes
+	}
+	if err := database.DB.Save(&settings).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update login security settings"})
+	}
+	return c.JSON(settings)
+}
+
+// UpdateRegistrationSettings turns self-registration and email verification on or off
+func UpdateRegistrationSettings(c *fiber.Ctx) error {
+	var data struct {
+		SelfRegistrationEnabled  *bool `json:"self_registration_enabled"`
+		RequireEmailVerification *bool `json:"require_email_verification"`
+	}
+	if err := c.BodyParser(&data); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	}
+	settings, err := getSettings(database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to load settings"})
+	}
+	if data.SelfRegistrationEnabled != nil {
+		settings.SelfRegistrationEnabled = *data.SelfRegistrationEnabled
+	}
+	if data.RequireEmailVerification != nil {
+		if _, err := utils.EmailConfigFromEnv(); err != nil && *data.RequireEmailVerification {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Configure email (SMTP_HOST and SMTP_FROM) before requiring email verification"})
+		}
+		settings.RequireEmailVerification = *data.RequireEmailVerification
+	}
+	if err := database.DB.Save(&settings).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update registration settings"})
+	}
+	return c.JSON(settings)
+}
+
+// UpdateTwoFactorSettings sets whether admins must use two-factor authentication
+func UpdateTwoFactorSettings(c *fiber.Ctx) error {
+	var data struct {
+		RequireAdmin2FA bool `json:"require_admin_2fa"`
+	}
+	if err := c.BodyParser(&data); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	}
+	settings, err := getSettings(database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to load settings"})
+	}
+	// Don't let an admin lock themselves out of the admin API
+	if verified, _ := c.Locals("two_factor_verified").(bool); data.RequireAdmin2FA && !verified {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Enable two-factor authentication on your own account first"})
+	}
+	settings.RequireAdmin2FA = data.RequireAdmin2FA
+	if err := database.DB.Save(&settings).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update two-factor settings"})
+	}
+	return c.JSON(settings)
+}
@@ -0,0 +1,308 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"qp1/database"
+	"qp1/models"
+	"qp1/utils"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	// twoFactorChallengeTTL is how long the user has to enter the code after the password was accepted
+	twoFactorChallengeTTL = 5 * time.Minute
+	twoFactorPurpose      = "2fa"
+	recoveryCodeCount     = 10
+)
+
+// errInvalidSecondFactor is returned when a TOTP or recovery code doesn't match
+var errInvalidSecondFactor = errors.New("invalid second factor")
+
+// TwoFactorRequest carries a TOTP code or, where accepted, a recovery code
+type TwoFactorRequest struct {
+	Challenge    string `json:"challenge"`
+	Password     string `json:"password"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// totpIssuer is the account issuer shown in authenticator apps
+func totpIssuer() string {
+	if settings, err := getSettings(database.DB); err == nil && settings.CompanyName != "" {
+		return settings.CompanyName
+	}
+	return "QP1"
+}
+
+// signTwoFactorChallenge returns a short-lived token proving the password step of a two-step login succeeded.
+// It has no sid claim, so it can't be used as an access token.
+func signTwoFactorChallenge(user models.User) (string, error) {
+	return utils.SignJWT(jwt.MapClaims{
+		"sub":     strconv.Itoa(int(user.ID)),
+		"purpose": twoFactorPurpose,
+		"exp":     time.Now().Add(twoFactorChallengeTTL).Unix(),
+	})
+}
+
+// verifySecondFactor checks a TOTP code or a recovery code for user and consumes it so it can't be used again
+func verifySecondFactor(tx *gorm.DB, user *models.User, code string, recoveryCode string) (bool, error) {
+	if code != "" {
+		step, ok := utils.ValidateTOTP(user.TOTPSecret, code, time.Now())
+		if !ok || step <= user.TOTPLastStep {
+			return false, nil
+		}
+		// Conditional update so the same code can't be accepted twice by concurrent requests
+		result := tx.Model(&models.User{}).Where("id = ? AND totp_last_step < ?", user.ID, step).Update("totp_last_step", step)
+		if result.Error != nil {
+			return false, result.Error
+		}
+		user.TOTPLastStep = step
+		return result.RowsAffected == 1, nil
+	}
+	if recoveryCode != "" {
+		result := tx.Model(&models.RecoveryCode{}).
+			Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, utils.HashToken(utils.NormalizeRecoveryCode(recoveryCode))).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return false, result.Error
+		}
+		return result.RowsAffected == 1, nil
+	}
+	return false, nil
+}
+
+// replaceRecoveryCodes discards the user's recovery codes and returns a new set, which is only shown once
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := utils.GenerateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		if err := tx.Create(&models.RecoveryCode{UserID: userID, CodeHash: utils.HashToken(utils.NormalizeRecoveryCode(code))}).Error; err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// EnrollTwoFactor starts TOTP enrollment and returns the secret and the otpauth:// URI to show as a QR code
+func EnrollTwoFactor(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+	if user.TOTPEnabled {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Two-factor authentication is already enabled"})
+	}
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to generate secret"})
+	}
+	if err := database.DB.Model(&models.User{}).Where("id = ?", user.ID).Update("totp_secret", secret).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to start enrollment"})
+	}
+	return c.JSON(fiber.Map{
+		"secret":      secret,
+		"otpauth_uri": utils.TOTPProvisioningURI(totpIssuer(), user.Email, secret),
+	})
+}
+
+// VerifyTwoFactor completes enrollment with a code from the authenticator app and returns the recovery codes
+func VerifyTwoFactor(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+	var req TwoFactorRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Failed to parse request body"})
+	}
+	if user.TOTPEnabled {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Two-factor authentication is already enabled"})
+	}
+	if user.TOTPSecret == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Start enrollment first"})
+	}
+	step, ok := utils.ValidateTOTP(user.TOTPSecret, req.Code, time.Now())
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid code"})
+	}
+
+	sessionID, _ := c.Locals("session_id").(uint)
+	var codes []string
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+			"totp_enabled":   true,
+			"totp_last_step": step,
+		}).Error; err != nil {
+			return err
+		}
+		var err error
+		if codes, err = replaceRecoveryCodes(tx, user.ID); err != nil {
+			return err
+		}
+		// The user just proved they hold the authenticator, so this session counts as two-factor verified
+		return tx.Model(&models.Session{}).Where("id = ?", sessionID).Update("two_factor_verified", true).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to enable two-factor authentication"})
+	}
+	return c.JSON(fiber.Map{
+		"message":        "Two-factor authentication enabled",
+		"recovery_codes": codes,
+	})
+}
+
+// DisableTwoFactor turns off two-factor authentication; requires the password and a TOTP or recovery code
+func DisableTwoFactor(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+	var req TwoFactorRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Failed to parse request body"})
+	}
+	if !user.TOTPEnabled {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Two-factor authentication is not enabled"})
+	}
+	if user.Role == models.RoleAdmin {
+		if settings, err := getSettings(database.DB); err != nil || settings.RequireAdmin2FA {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Two-factor authentication is required for admins"})
+		}
+	}
+	if err := bcrypt.CompareHashAndPassword(user.Password, []byte(req.Password)); err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Password is incorrect"})
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		ok, err := verifySecondFactor(tx, &user, req.Code, req.RecoveryCode)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errInvalidSecondFactor
+		}
+		return clearTwoFactor(tx, user.ID)
+	})
+	if errors.Is(err, errInvalidSecondFactor) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid code"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to disable two-factor authentication"})
+	}
+	return c.JSON(fiber.Map{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes; requires a current TOTP code
+func RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+	var req TwoFactorRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Failed to parse request body"})
+	}
+	if !user.TOTPEnabled {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Two-factor authentication is not enabled"})
+	}
+
+	var codes []string
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		ok, err := verifySecondFactor(tx, &user, req.Code, "")
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errInvalidSecondFactor
+		}
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if errors.Is(err, errInvalidSecondFactor) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid code"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to generate recovery codes"})
+	}
+	return c.JSON(fiber.Map{"recovery_codes": codes})
+}
+
+// LoginTwoFactor is the second step of Login for users with two-factor authentication enabled
+func LoginTwoFactor(c *fiber.Ctx) error {
+	var req TwoFactorRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Failed to parse request body"})
+	}
+	claims, err := utils.ParseJWT(req.Challenge)
+	if err != nil || claims["purpose"] != twoFactorPurpose {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Login challenge is invalid or expired"})
+	}
+	sub, _ := claims["sub"].(string)
+	var user models.User
+	if err := database.DB.First(&user, sub).Error; err != nil || !user.TOTPEnabled {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Login challenge is invalid or expired"})
+	}
+
+	// Wrong codes count as failed logins, so codes can't be brute-forced either
+	wait, err := loginRetryAfter(user.Email, c.IP())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to check login attempts"})
+	}
+	if wait > 0 {
+		return respondLoginThrottled(c, wait)
+	}
+	ok, err := verifySecondFactor(database.DB, &user, req.Code, req.RecoveryCode)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to verify code"})
+	}
+	if !ok {
+		if err := recordLoginFailure(user.Email, c.IP(), &user); err != nil {
+			fmt.Println("Failed to record login failure:", err)
+		}
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid code"})
+	}
+	resetLoginFailures(user.Email)
+
+	if err := startSession(c, user, true); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to generate token"})
+	}
+	return c.JSON(fiber.Map{"message": "Login successful"})
+}
+
+// AdminResetTwoFactor 管理员清除用户的两步验证（用户丢失验证器且没有恢复码时）
+func AdminResetTwoFactor(c *fiber.Ctx) error {
+	var user models.User
+	if err := database.DB.First(&user, c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	}
+	if !canManageUser(c, user) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Only admins can manage admin accounts"})
+	}
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := clearTwoFactor(tx, user.ID); err != nil {
+			return err
+		}
+		// Sessions and tokens signed in with the old authenticator no longer count as verified
+		if err := revokeUserSessions(tx, user.ID, 0); err != nil {
+			return err
+		}
+		return revokeUserAPITokens(tx, user.ID)
+	}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to reset two-factor authentication"})
+	}
+	return c.JSON(fiber.Map{"message": "Two-factor authentication reset"})
+}
+
+// clearTwoFactor turns off two-factor authentication for a user and deletes their recovery codes
+func clearTwoFactor(tx *gorm.DB, userID uint) error {
+	if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"totp_enabled":   false,
+		"totp_secret":    "",
+		"totp_last_step": 0,
+	}).Error; err != nil {
+		return err
+	}
+	return tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+}
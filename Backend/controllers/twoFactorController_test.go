@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	"qp1/models"
+	"qp1/utils"
+	"testing"
+	"time"
+)
+
+func TestVerifySecondFactorRejectsReuse(t *testing.T) {
+	db := setupTest(t)
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := models.User{Name: "Sam", Email: "sam@example.com", Role: "sales", Status: models.UserStatusActive, TOTPEnabled: true, TOTPSecret: secret}
+	db.Create(&user)
+	codes, err := replaceRecoveryCodes(db, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only steps that stay within the accepted skew if the clock moves on to the next step during the test
+	step := utils.TOTPStep(time.Now())
+	code := func(s int64) string {
+		c, err := utils.TOTPCode(secret, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	// Each attempt reloads the user, like a new login request would
+	attempts := []struct {
+		name         string
+		code         string
+		recoveryCode string
+		want         bool
+	}{
+		{"current step", code(step), "", true},
+		{"same code again", code(step), "", false},
+		{"next step", code(step + 1), "", true},
+		{"earlier step after a later one", code(step), "", false},
+		{"next step again", code(step + 1), "", false},
+		{"wrong code", "000000", "", false},
+		{"recovery code", "", codes[0], true},
+		{"recovery code again", "", codes[0], false},
+		{"another recovery code, typed differently", "", " " + codes[1][:5] + codes[1][6:] + " ", true},
+		{"nothing given", "", "", false},
+	}
+	for _, attempt := range attempts {
+		var current models.User
+		db.First(&current, user.ID)
+		ok, err := verifySecondFactor(db, &current, attempt.code, attempt.recoveryCode)
+		if err != nil {
+			t.Fatalf("%s: %v", attempt.name, err)
+		}
+		if ok != attempt.want {
+			t.Errorf("%s: accepted %v, want %v", attempt.name, ok, attempt.want)
+		}
+	}
+
+	// A stale copy of the user, e.g. from a concurrent request, can't reuse the step either
+	stale := user
+	if ok, _ := verifySecondFactor(db, &stale, code(step+1), ""); ok {
+		t.Error("a stale user record accepted an already used step")
+	}
+}
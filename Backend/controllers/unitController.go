@@ -0,0 +1,273 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"qp1/database"
+	"qp1/models"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// errUnknownUnit and errIncompatibleUnits are returned when a quantity can't be converted between two units
+var (
+	errUnknownUnit       = errors.New("unknown unit")
+	errIncompatibleUnits = errors.New("incompatible units")
+)
+
+// defaultDimensionUnit is the unit of quotation item dimensions when none is given
+const defaultDimensionUnit = "m"
+
+// unitRegistry holds the registered units by lower-case code
+type unitRegistry map[string]models.UnitOfMeasure
+
+// loadUnits loads the unit registry
+func loadUnits(tx *gorm.DB) (unitRegistry, error) {
+	var all []models.UnitOfMeasure
+	if err := tx.Find(&all).Error; err != nil {
+		return nil, err
+	}
+	units := make(unitRegistry, len(all))
+	for _, unit := range all {
+		units[strings.ToLower(unit.Code)] = unit
+	}
+	return units, nil
+}
+
+// lookup returns the registered unit for code
+func (u unitRegistry) lookup(code string) (models.UnitOfMeasure, bool) {
+	unit, ok := u[strings.ToLower(strings.TrimSpace(code))]
+	return unit, ok
+}
+
+// checkMaterialUnit checks that a material's unit is registered, so its component lines can be converted
+func (u unitRegistry) checkMaterialUnit(code string) error {
+	if _, ok := u.lookup(code); !ok {
+		return fmt.Errorf("%w %q", errUnknownUnit, strings.TrimSpace(code))
+	}
+	return nil
+}
+
+// sameUnit reports whether two unit codes name the same unit
+func sameUnit(a, b string) bool {
+	return strings.EqualFold(strings.TrimSpace(a), strings.TrimSpace(b))
+}
+
+// convert converts quantity from one unit to another of the same category. Equal codes need no registration.
+func (u unitRegistry) convert(quantity float64, from, to string) (float64, error) {
+	if sameUnit(from, to) {
+		return quantity, nil
+	}
+	fromUnit, ok := u.lookup(from)
+	if !ok {
+		return 0, fmt.Errorf("%w %q", errUnknownUnit, from)
+	}
+	toUnit, ok := u.lookup(to)
+	if !ok {
+		return 0, fmt.Errorf("%w %q", errUnknownUnit, to)
+	}
+	if fromUnit.Category != toUnit.Category {
+		return 0, fmt.Errorf("%w: %s is a %s unit and %s a %s unit", errIncompatibleUnits, fromUnit.Code, fromUnit.Category, toUnit.Code, toUnit.Category)
+	}
+	return quantity * fromUnit.Factor / toUnit.Factor, nil
+}
+
+// checkLineUnit checks that a component line given in unit can be converted to the unit of its material
+func (u unitRegistry) checkLineUnit(unit string, material models.Material) error {
+	if unit == "" {
+		return nil
+	}
+	_, err := u.convert(1, unit, material.Unit)
+	return err
+}
+
+// materialQuantity returns the quantity of a component material line in the unit of its material. Line units
+// are checked when they are saved, so a line that can't be converted is taken as it is. Material must be loaded.
+func (u unitRegistry) materialQuantity(line models.ComponentMaterial) float64 {
+	if line.Unit == "" {
+		return line.Quantity
+	}
+	quantity, err := u.convert(line.Quantity, line.Unit, line.Material.Unit)
+	if err != nil {
+		return line.Quantity
+	}
+	return quantity
+}
+
+// toMetres converts a quotation dimension to metres, which component material quantities are per
+func (u unitRegistry) toMetres(value float64, unit string) (float64, error) {
+	if unit == "" {
+		return value, nil
+	}
+	if from, ok := u.lookup(unit); !ok || from.Category != models.UnitCategoryLength {
+		return 0, fmt.Errorf("dimension unit %q is not a length unit", unit)
+	}
+	return u.convert(value, unit, defaultDimensionUnit)
+}
+
+// checkMaterialUnitChange checks that the component lines with their own unit can still be converted when a
+// material's unit changes
+func checkMaterialUnitChange(tx *gorm.DB, material models.Material) error {
+	var lineUnits []string
+	if err := tx.Model(&models.ComponentMaterial{}).Where("material_id = ? AND unit <> ''", material.ID).
+		Distinct().Pluck("unit", &lineUnits).Error; err != nil {
+		return err
+	}
+	if len(lineUnits) == 0 {
+		return nil
+	}
+	units, err := loadUnits(tx)
+	if err != nil {
+		return err
+	}
+	for _, unit := range lineUnits {
+		if err := units.checkLineUnit(unit, material); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UnitRequest is the body for creating or updating a unit of measure
+type UnitRequest struct {
+	Code     string   `json:"code"`
+	Name     string   `json:"name"`
+	Category string   `json:"category"`
+	Factor   *float64 `json:"factor"`
+}
+
+// validateUnit checks a unit request and normalizes its code
+func validateUnit(req *UnitRequest) string {
+	req.Code = strings.TrimSpace(req.Code)
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Code == "" {
+		return "Code is required"
+	}
+	if len(req.Code) > 20 {
+		return "Code must be at most 20 characters"
+	}
+	valid := false
+	for _, category := range models.UnitCategories {
+		valid = valid || req.Category == category
+	}
+	if !valid {
+		return "Category must be one of " + strings.Join(models.UnitCategories, ", ")
+	}
+	if req.Factor == nil || *req.Factor <= 0 || math.IsInf(*req.Factor, 0) {
+		return "Factor must be greater than 0"
+	}
+	return ""
+}
+
+// ListUnits 查询计量单位，可按类别过滤 (?category=length)
+func ListUnits(c *fiber.Ctx) error {
+	query := database.DB.Order("category, factor, code")
+	if category := c.Query("category"); category != "" {
+		query = query.Where("category = ?", category)
+	}
+	units := []models.UnitOfMeasure{}
+	if err := query.Find(&units).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch units"})
+	}
+	return c.JSON(units)
+}
+
+// CreateUnit 新增计量单位
+func CreateUnit(c *fiber.Ctx) error {
+	var req UnitRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Failed to parse request body"})
+	}
+	if msg := validateUnit(&req); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": msg})
+	}
+	var count int64
+	database.DB.Model(&models.UnitOfMeasure{}).Where("LOWER(code) = ?", strings.ToLower(req.Code)).Count(&count)
+	if count > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "A unit with this code already exists"})
+	}
+	unit := models.UnitOfMeasure{Code: req.Code, Name: req.Name, Category: req.Category, Factor: *req.Factor}
+	if err := database.DB.Create(&unit).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create unit"})
+	}
+	return c.Status(fiber.StatusCreated).JSON(unit)
+}
+
+// UpdateUnit 修改计量单位的名称和换算系数，并重新计算受影响的组件成本。代码和类别不能修改，
+// 默认尺寸单位 (m) 的换算系数也不能修改。
+func UpdateUnit(c *fiber.Ctx) error {
+	var unit models.UnitOfMeasure
+	if err := database.DB.First(&unit, c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Unit not found"})
+	}
+	var req UnitRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Failed to parse request body"})
+	}
+	// Lines and materials refer to the code, and their conversions depend on the category
+	req.Code, req.Category = unit.Code, unit.Category
+	if msg := validateUnit(&req); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": msg})
+	}
+	// Quotation dimensions are costed in metres, so the base length unit must stay 1
+	if sameUnit(unit.Code, defaultDimensionUnit) && *req.Factor != unit.Factor {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "The factor of the default dimension unit can't be changed"})
+	}
+	unit.Name, unit.Factor = req.Name, *req.Factor
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&unit).Error; err != nil {
+			return err
+		}
+		// Re-cost the components with a line converted from or to this unit
+		var componentIDs []uint
+		if err := tx.Model(&models.ComponentMaterial{}).
+			Joins("JOIN materials ON materials.id = component_materials.material_id").
+			Where("component_materials.unit <> '' AND (LOWER(component_materials.unit) = ? OR LOWER(materials.unit) = ?)", strings.ToLower(unit.Code), strings.ToLower(unit.Code)).
+			Distinct().Pluck("component_materials.component_id", &componentIDs).Error; err != nil {
+			return err
+		}
+		return recalculateComponents(tx, componentIDs)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update unit"})
+	}
+	return c.JSON(unit)
+}
+
+// DeleteUnit 删除计量单位；默认尺寸单位 (m) 以及仍被物料、组件、报价或模板使用的单位不能删除
+func DeleteUnit(c *fiber.Ctx) error {
+	var unit models.UnitOfMeasure
+	if err := database.DB.First(&unit, c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Unit not found"})
+	}
+	if sameUnit(unit.Code, defaultDimensionUnit) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "The default dimension unit can't be deleted"})
+	}
+	code := strings.ToLower(unit.Code)
+	var materials, lines, items, templateItems int64
+	if err := database.DB.Model(&models.Material{}).Where("LOWER(unit) = ?", code).Count(&materials).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete unit"})
+	}
+	if err := database.DB.Model(&models.ComponentMaterial{}).Where("LOWER(unit) = ?", code).Count(&lines).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete unit"})
+	}
+	if err := database.DB.Model(&models.QuotationItem{}).Where("LOWER(dimension_unit) = ?", code).Count(&items).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete unit"})
+	}
+	if err := database.DB.Model(&models.QuotationTemplateItem{}).Where("LOWER(dimension_unit) = ?", code).Count(&templateItems).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete unit"})
+	}
+	if materials > 0 || lines > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Unit is used by materials or components"})
+	}
+	if items > 0 || templateItems > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Unit is used by quotation or template item dimensions"})
+	}
+	if err := database.DB.Delete(&unit).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete unit"})
+	}
+	return c.JSON(fiber.Map{"message": "Unit deleted successfully"})
+}
@@ -0,0 +1,221 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"qp1/models"
+	"strconv"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func newUnitTestApp() *fiber.App {
+	app := fiber.New()
+	app.Put("/units/:id", UpdateUnit)
+	app.Delete("/units/:id", DeleteUnit)
+	app.Post("/materials", CreateMaterial)
+	app.Put("/materials/:id", UpdateMaterial)
+	return app
+}
+
+func TestDefaultDimensionUnitIsProtected(t *testing.T) {
+	db := setupTest(t)
+	app := newUnitTestApp()
+	var metre models.UnitOfMeasure
+	if err := db.Where("code = ?", defaultDimensionUnit).First(&metre).Error; err != nil {
+		t.Fatal(err)
+	}
+	path := "/units/" + strconv.FormatUint(uint64(metre.ID), 10)
+
+	if status, body := sendJSON(t, app, http.MethodPut, path, `{"name": "Meter", "factor": 2}`); status != fiber.StatusBadRequest {
+		t.Errorf("changing the factor of m returned %d: %s", status, body)
+	}
+	if status, body := sendJSON(t, app, http.MethodPut, path, `{"name": "Meter", "factor": 1}`); status != fiber.StatusOK {
+		t.Errorf("renaming m returned %d: %s", status, body)
+	}
+	if status, body := sendJSON(t, app, http.MethodDelete, path, ""); status != fiber.StatusBadRequest {
+		t.Errorf("deleting m returned %d: %s", status, body)
+	}
+	db.First(&metre, metre.ID)
+	if metre.Factor != 1 {
+		t.Errorf("factor of m is %v, want 1", metre.Factor)
+	}
+}
+
+func TestDeleteUnitInUseByQuotationDimensions(t *testing.T) {
+	db := setupTest(t)
+	app := newUnitTestApp()
+	var unit models.UnitOfMeasure
+	if err := db.Where("code = ?", "ft").First(&unit).Error; err != nil {
+		t.Fatal(err)
+	}
+	path := "/units/" + strconv.FormatUint(uint64(unit.ID), 10)
+
+	item := models.QuotationItem{QuotationID: 1, DimensionUnit: "FT"}
+	db.Create(&item)
+	if status, body := sendJSON(t, app, http.MethodDelete, path, ""); status != fiber.StatusBadRequest {
+		t.Fatalf("deleting a unit used by a quotation item returned %d: %s", status, body)
+	}
+
+	db.Delete(&item)
+	if status, body := sendJSON(t, app, http.MethodDelete, path, ""); status != fiber.StatusOK {
+		t.Fatalf("deleting an unused unit returned %d: %s", status, body)
+	}
+}
+
+func TestMaterialUnitMustBeRegistered(t *testing.T) {
+	db := setupTest(t)
+	app := newUnitTestApp()
+
+	if status, body := sendJSON(t, app, http.MethodPost, "/materials", `{"name": "Oak board", "unit": "furlong"}`); status != fiber.StatusBadRequest {
+		t.Errorf("creating a material with an unknown unit returned %d: %s", status, body)
+	}
+	if status, body := sendJSON(t, app, http.MethodPost, "/materials", `{"name": "Oak board", "unit": "M"}`); status != fiber.StatusOK {
+		t.Fatalf("creating a material returned %d: %s", status, body)
+	}
+
+	// A unit from before the registry is kept, but can't be changed to another unknown unit
+	legacy := models.Material{Name: "Glue", Unit: "tube"}
+	db.Create(&legacy)
+	path := "/materials/" + strconv.FormatUint(uint64(legacy.ID), 10)
+	if status, body := sendJSON(t, app, http.MethodPut, path, `{"name": "Wood glue", "unit": "tube"}`); status != fiber.StatusOK {
+		t.Errorf("updating a material with its own legacy unit returned %d: %s", status, body)
+	}
+	if status, body := sendJSON(t, app, http.MethodPut, path, `{"unit": "bottle"}`); status != fiber.StatusBadRequest {
+		t.Errorf("changing a material to an unknown unit returned %d: %s", status, body)
+	}
+}
+
+// defaultUnitRegistry returns the seeded units without a database
+func defaultUnitRegistry() unitRegistry {
+	units := unitRegistry{}
+	for _, unit := range models.DefaultUnits {
+		units[unit.Code] = unit
+	}
+	return units
+}
+
+func TestUnitRegistryConvert(t *testing.T) {
+	units := defaultUnitRegistry()
+	tests := []struct {
+		name     string
+		quantity float64
+		from, to string
+		want     float64
+		wantErr  error
+	}{
+		{"same unit", 3, "m", "m", 3, nil},
+		{"same unregistered unit", 3, "sheet", "SHEET", 3, nil},
+		{"mm to m", 500, "mm", "m", 0.5, nil},
+		{"m to mm", 0.5, "m", "mm", 500, nil},
+		{"codes ignore case and spaces", 1, " FT ", "In", 12, nil},
+		{"area", 1, "m2", "cm2", 10000, nil},
+		{"count", 2, "dozen", "pcs", 24, nil},
+		{"weight", 1, "lb", "kg", 0.45359237, nil},
+		{"unknown from", 1, "furlong", "m", 0, errUnknownUnit},
+		{"unknown to", 1, "m", "furlong", 0, errUnknownUnit},
+		{"other category", 1, "m", "kg", 0, errIncompatibleUnits},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := units.convert(tt.quantity, tt.from, tt.to)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("convert() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := got - tt.want; diff > 1e-9 || diff < -1e-9 {
+				t.Errorf("convert(%v, %q, %q) = %v, want %v", tt.quantity, tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnitRegistryToMetres(t *testing.T) {
+	units := defaultUnitRegistry()
+	tests := []struct {
+		value   float64
+		unit    string
+		want    float64
+		wantErr bool
+	}{
+		{2, "", 2, false},
+		{2, "m", 2, false},
+		{600, "mm", 0.6, false},
+		{10, "ft", 3.048, false},
+		{1, "m2", 0, true},
+		{1, "pcs", 0, true},
+		{1, "furlong", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := units.toMetres(tt.value, tt.unit)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("toMetres(%v, %q) error = %v, want error %v", tt.value, tt.unit, err, tt.wantErr)
+			continue
+		}
+		if diff := got - tt.want; diff > 1e-9 || diff < -1e-9 {
+			t.Errorf("toMetres(%v, %q) = %v, want %v", tt.value, tt.unit, got, tt.want)
+		}
+	}
+}
+
+func TestQuotationConvertsDimensionAndLineUnits(t *testing.T) {
+	tests := []struct {
+		name         string
+		lineQuantity float64
+		lineUnit     string
+		item         CreateQuotationItemRequest
+		wantNet      float64
+		wantItemCost float64
+	}{
+		{"metres", 2, "", CreateQuotationItemRequest{Length: 0.5, Width: 1, Height: 1, Quantity: 2}, 2, 10},
+		{"millimetre dimensions", 2, "", CreateQuotationItemRequest{Length: 500, Width: 1000, Height: 1000, DimensionUnit: "mm", Quantity: 2}, 2, 10},
+		{"foot dimensions", 2, "", CreateQuotationItemRequest{Length: 10, Width: 1, Height: 1, DimensionUnit: "ft", Quantity: 1}, 2 * 3.048 * 0.3048 * 0.3048, 10 * 3.048 * 0.3048 * 0.3048},
+		{"line in centimetres", 200, "cm", CreateQuotationItemRequest{Length: 0.5, Width: 1, Height: 1, Quantity: 2}, 2, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := setupTest(t)
+			material := models.Material{Name: "Pine board", Unit: "m", UnitCost: 5}
+			quotation, quotationMaterial := priceTestQuotation(t, db, material,
+				models.ComponentMaterial{Quantity: tt.lineQuantity, Unit: tt.lineUnit},
+				[]CreateQuotationItemRequest{tt.item})
+
+			if !closeTo(quotationMaterial.NetQuantity, tt.wantNet) || !closeTo(quotationMaterial.Quantity, tt.wantNet) {
+				t.Errorf("material quantity net %v, bought %v, want %v", quotationMaterial.NetQuantity, quotationMaterial.Quantity, tt.wantNet)
+			}
+			if !closeTo(quotationMaterial.TotalCost, tt.wantNet*5) {
+				t.Errorf("material total cost %v, want %v", quotationMaterial.TotalCost, tt.wantNet*5)
+			}
+			var item models.QuotationItem
+			db.Where("quotation_id = ?", quotation.ID).First(&item)
+			if !closeTo(item.TotalCost, tt.wantItemCost) {
+				t.Errorf("item total cost %v, want %v", item.TotalCost, tt.wantItemCost)
+			}
+			if want := tt.item.DimensionUnit; want != "" && item.DimensionUnit != want {
+				t.Errorf("item dimension unit %q, want %q", item.DimensionUnit, want)
+			}
+			if got, _ := quotation.TotalCost.Float64(); !closeTo(got, tt.wantItemCost) {
+				t.Errorf("quotation total cost %v, want %v", got, tt.wantItemCost)
+			}
+		})
+	}
+
+	// A dimension unit that isn't a length is refused
+	db := setupTest(t)
+	component := models.Component{Name: "Panel", TotalCost: 10}
+	db.Create(&component)
+	quotation := models.Quotation{UserID: 1, Title: "Panels", QuotationNo: "Q-1"}
+	db.Create(&quotation)
+	err := processQuotationItems(db, &quotation, nil, []CreateQuotationItemRequest{
+		{ComponentID: component.ID, Length: 1, Width: 1, Height: 1, DimensionUnit: "kg", Quantity: 1},
+	})
+	if err == nil {
+		t.Error("a weight unit was accepted for dimensions")
+	}
+}
@@ -6,12 +6,13 @@ import (
 
 	"github.com/gofiber/fiber/v2"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 // updateUserProfile allows a logged-in user to update their own profile
 func UpdateUserProfile(c *fiber.Ctx) error {
-	user, ok := c.Locals("user").(*models.User)
-	if !ok || user == nil {
+	user, ok := c.Locals("user").(models.User)
+	if !ok {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
 	}
 
@@ -28,7 +29,7 @@ func UpdateUserProfile(c *fiber.Ctx) error {
 	user.Email = data.Email
 	user.Contact = data.Contact
 
-	if err := database.DB.Save(user).Error; err != nil {
+	if err := database.DB.Save(&user).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update profile"})
 	}
 	return c.JSON(user)
@@ -36,8 +37,8 @@ func UpdateUserProfile(c *fiber.Ctx) error {
 
 // updateUserPassword allows a logged-in user to change their password
 func UpdateUserPassword(c *fiber.Ctx) error {
-	user, ok := c.Locals("user").(*models.User)
-	if !ok || user == nil {
+	user, ok := c.Locals("user").(models.User)
+	if !ok {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
 	}
 
@@ -54,15 +55,16 @@ func UpdateUserPassword(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Old password is incorrect"})
 	}
 
-	// Hash new password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(data.NewPassword), bcrypt.DefaultCost)
+	// Update password and sign out the user's other sessions
+	sessionID, _ := c.Locals("session_id").(uint)
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := setUserPassword(tx, &user, data.NewPassword); err != nil {
+			return err
+		}
+		return revokeUserSessions(tx, user.ID, sessionID)
+	})
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to hash new password"})
-	}
-
-	user.Password = hashedPassword // hashedPassword is already []byte
-	if err := database.DB.Save(user).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update password"})
+		return respondPasswordError(c, err)
 	}
 	return c.JSON(fiber.Map{"message": "Password updated successfully"})
 }
@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"errors"
+	"qp1/models"
+	"qp1/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// errTokenUsed is returned when a single-use token was consumed by a concurrent request
+var errTokenUsed = errors.New("token already used")
+
+// errTokenInvalid is returned for unknown, used or expired tokens
+var errTokenInvalid = errors.New("invalid or expired token")
+
+// issueUserToken creates a single-use emailed token for purpose and returns it in plain text.
+// Older unused tokens with the same purpose are invalidated, so only the newest link works.
+func issueUserToken(tx *gorm.DB, userID uint, purpose string, ttl time.Duration, ip string) (string, error) {
+	token, err := utils.GenerateToken(32)
+	if err != nil {
+		return "", err
+	}
+	err = tx.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.UserToken{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+			Update("used_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.UserToken{
+			UserID:    userID,
+			Purpose:   purpose,
+			TokenHash: utils.HashToken(token),
+			ExpiresAt: time.Now().Add(ttl),
+			RequestIP: ip,
+		}).Error
+	})
+	return token, err
+}
+
+// findUserToken looks up an unused, unexpired token for purpose together with its user
+func findUserToken(tx *gorm.DB, token string, purpose string) (models.UserToken, models.User, error) {
+	var userToken models.UserToken
+	var user models.User
+	if token == "" {
+		return userToken, user, errTokenInvalid
+	}
+	if err := tx.Where("token_hash = ? AND purpose = ?", utils.HashToken(token), purpose).
+		First(&userToken).Error; err != nil || userToken.UsedAt != nil || time.Now().After(userToken.ExpiresAt) {
+		return userToken, user, errTokenInvalid
+	}
+	if err := tx.First(&user, userToken.UserID).Error; err != nil {
+		return userToken, user, errTokenInvalid
+	}
+	return userToken, user, nil
+}
+
+// consumeUserToken marks a token as used. The update is conditional so two concurrent requests can't both use it.
+func consumeUserToken(tx *gorm.DB, userToken models.UserToken) error {
+	result := tx.Model(&models.UserToken{}).Where("id = ? AND used_at IS NULL", userToken.ID).Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errTokenUsed
+	}
+	return nil
+}
+
+// markEmailVerified activates an account whose owner proved control of the email address
+func markEmailVerified(tx *gorm.DB, user *models.User) error {
+	now := time.Now()
+	user.Status = models.UserStatusActive
+	if user.EmailVerifiedAt == nil {
+		user.EmailVerifiedAt = &now
+	}
+	return tx.Model(user).Select("status", "email_verified_at").Updates(user).Error
+}
@@ -26,19 +26,67 @@ func ConnectDB() (*gorm.DB, error) {
 
 	DB = db
 
-	err = db.AutoMigrate(
+	if err := Migrate(db); err != nil {
+		return nil, err
+	}
+	fmt.Println("Database migration completed successfully")
+	return db, nil
+}
+
+// Migrate creates or updates the tables, seeds the roles and units and fills in data added by later versions
+func Migrate(db *gorm.DB) error {
+	err := db.AutoMigrate(
 		&models.User{},
+		&models.Session{},
+		&models.UserToken{},
+		&models.PasswordHistory{},
+		&models.LoginThrottle{},
+		&models.AuthAuditLog{},
+		&models.RecoveryCode{},
+		&models.APIToken{},
+		&models.Supplier{},
 		&models.Material{},
+		&models.MaterialSupplierPrice{},
 		&models.Component{},
 		&models.ComponentMaterial{},
+		&models.LaborRate{},
+		&models.OverheadRate{},
+		&models.ComponentLabor{},
+		&models.ComponentOverhead{},
 		&models.Quotation{},
 		&models.QuotationItem{},
+		&models.QuotationSection{},
 		&models.QuotationMaterial{},
+		&models.MarkupRule{},
+		&models.QuotationTemplate{},
+		&models.QuotationTemplateParameter{},
+		&models.QuotationTemplateItem{},
+		&models.UnitOfMeasure{},
+		&models.Settings{},
+		&models.Permission{},
+		&models.Role{},
+		&models.AppliedRoleGrant{},
+		&models.AppliedBackfill{},
 	)
 	if err != nil {
 		fmt.Printf("Migration error: %v\n", err)
-		return nil, err
+		return err
 	}
-	fmt.Println("Database migration completed successfully")
-	return db, nil
+	if err := seedRoles(db); err != nil {
+		fmt.Printf("Seeding roles failed: %v\n", err)
+		return err
+	}
+	if err := seedUnits(db); err != nil {
+		fmt.Printf("Seeding units of measure failed: %v\n", err)
+		return err
+	}
+	if err := backfillQuotationSearch(db); err != nil {
+		fmt.Printf("Building the quotation search index failed: %v\n", err)
+		return err
+	}
+	if err := runBackfills(db); err != nil {
+		fmt.Printf("Filling in data for existing rows failed: %v\n", err)
+		return err
+	}
+	return nil
 }
@@ -0,0 +1,48 @@
+// Package dbtest opens an in-memory SQLite database with the application schema for tests.
+// It is only imported by tests, so the SQLite driver never ends up in the server binary.
+package dbtest
+
+import (
+	"fmt"
+	"qp1/database"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var databases atomic.Int64
+
+// Open creates a fresh migrated database, installs it as database.DB for the duration of the test and
+// returns it. Every call gets its own database, so tests don't see each other's rows.
+func Open(t testing.TB) *gorm.DB {
+	t.Helper()
+	dsn := fmt.Sprintf("file:qp1test%d?mode=memory&cache=shared&_foreign_keys=0", databases.Add(1))
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("opening test database: %v", err)
+	}
+	// SQLite has no FULLTEXT indexes; a plain index keeps the schema otherwise the same
+	db.Callback().Raw().Before("gorm:raw").Register("dbtest:fulltext", func(db *gorm.DB) {
+		if sql := db.Statement.SQL.String(); strings.Contains(sql, "CREATE FULLTEXT INDEX") {
+			db.Statement.SQL.Reset()
+			db.Statement.SQL.WriteString(strings.Replace(sql, "CREATE FULLTEXT INDEX", "CREATE INDEX", 1))
+		}
+	})
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("migrating test database: %v", err)
+	}
+
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() {
+		database.DB = previous
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
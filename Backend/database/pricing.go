@@ -0,0 +1,83 @@
+package database
+
+import (
+	"fmt"
+	"qp1/models"
+
+	"gorm.io/gorm"
+)
+
+// backfills fill in data added by later versions for the rows written before them. Each runs once per
+// database; rows written afterwards already have the data, and e.g. free ad-hoc lines must keep their zero price.
+var backfills = []struct {
+	name string
+	run  func(tx *gorm.DB) error
+}{
+	{"quotation_sell_prices", backfillSellPrices},
+	{"component_cost_split", backfillComponentCosts},
+	{"quotation_material_quantities", backfillMaterialQuantities},
+}
+
+// runBackfills runs the backfills that haven't been applied yet
+func runBackfills(db *gorm.DB) error {
+	for _, backfill := range backfills {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var applied int64
+			if err := tx.Model(&models.AppliedBackfill{}).Where("name = ?", backfill.name).Count(&applied).Error; err != nil {
+				return err
+			}
+			if applied > 0 {
+				return nil
+			}
+			if err := backfill.run(tx); err != nil {
+				return err
+			}
+			return tx.Create(&models.AppliedBackfill{Name: backfill.name}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", backfill.name, err)
+		}
+	}
+	return nil
+}
+
+// backfillSellPrices fills the selling prices of quotations priced before markups existed. They were quoted
+// at cost, so the sell values are set to the cost values. The grand total of quotations without tax is their
+// selling price.
+func backfillSellPrices(db *gorm.DB) error {
+	if err := db.Model(&models.QuotationItem{}).
+		Where("component_id IS NOT NULL AND sell_total = 0 AND total_cost <> 0").
+		Updates(map[string]interface{}{"sell_unit_price": gorm.Expr("unit_cost"), "sell_total": gorm.Expr("total_cost")}).Error; err != nil {
+		return err
+	}
+	if err := db.Model(&models.Quotation{}).Unscoped().
+		Where("sell_total = 0 AND total_cost <> 0").
+		UpdateColumn("sell_total", gorm.Expr("total_cost")).Error; err != nil {
+		return err
+	}
+	// Quotations priced before taxes were added have no tax
+	return db.Model(&models.Quotation{}).Unscoped().
+		Where("grand_total = 0 AND sell_total <> 0").
+		UpdateColumn("grand_total", gorm.Expr("sell_total")).Error
+}
+
+// backfillComponentCosts fills the cost split of components costed before labor and overhead existed. Their
+// total cost was the cost of their materials.
+func backfillComponentCosts(db *gorm.DB) error {
+	if err := db.Model(&models.Component{}).Unscoped().
+		Where("material_cost = 0 AND labor_cost = 0 AND overhead_cost = 0 AND total_cost <> 0").
+		UpdateColumn("material_cost", gorm.Expr("total_cost")).Error; err != nil {
+		return err
+	}
+	return db.Model(&models.QuotationItem{}).
+		Where("component_id IS NOT NULL AND material_cost = 0 AND labor_cost = 0 AND overhead_cost = 0 AND total_cost <> 0").
+		UpdateColumn("material_cost", gorm.Expr("total_cost")).Error
+}
+
+// backfillMaterialQuantities fills the net and gross quantities of quotation materials resolved before waste
+// and purchase increments existed, which bought exactly what was used
+func backfillMaterialQuantities(db *gorm.DB) error {
+	return db.Model(&models.QuotationMaterial{}).
+		Where("gross_quantity = 0 AND quantity <> 0").
+		Updates(map[string]interface{}{"net_quantity": gorm.Expr("quantity"), "gross_quantity": gorm.Expr("quantity")}).Error
+}
@@ -0,0 +1,80 @@
+package database_test
+
+import (
+	"qp1/database"
+	"qp1/database/dbtest"
+	"qp1/models"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestBackfillsLeaveAdHocLinesAlone(t *testing.T) {
+	db := dbtest.Open(t)
+	// A free delivery line that still costs 5, on a quotation written after the backfills were applied
+	quotation := models.Quotation{UserID: 1, Title: "Kitchen", QuotationNo: "Q-1", TotalCost: decimal.NewFromInt(5)}
+	db.Create(&quotation)
+	item := models.QuotationItem{QuotationID: quotation.ID, Description: "Delivery", Unit: "job", Quantity: 1, UnitCost: 5, TotalCost: 5}
+	db.Create(&item)
+
+	for i := 0; i < 2; i++ {
+		if err := database.Migrate(db); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var savedItem models.QuotationItem
+	db.First(&savedItem, item.ID)
+	if savedItem.SellUnitPrice != 0 || savedItem.SellTotal != 0 || savedItem.MaterialCost != 0 {
+		t.Errorf("ad-hoc line changed to sell %v/%v, material cost %v", savedItem.SellUnitPrice, savedItem.SellTotal, savedItem.MaterialCost)
+	}
+	var saved models.Quotation
+	db.First(&saved, quotation.ID)
+	if !saved.SellTotal.IsZero() || !saved.GrandTotal.IsZero() {
+		t.Errorf("quotation totals changed to sell %s, grand %s", saved.SellTotal, saved.GrandTotal)
+	}
+}
+
+func TestBackfillsFillRowsFromBeforeThem(t *testing.T) {
+	db := dbtest.Open(t)
+	// A quotation priced at cost before selling prices and cost splits existed, next to an ad-hoc line
+	component := models.Component{Name: "Cabinet", TotalCost: 40}
+	db.Create(&component)
+	quotation := models.Quotation{UserID: 1, Title: "Kitchen", QuotationNo: "Q-1", TotalCost: decimal.NewFromInt(85)}
+	db.Create(&quotation)
+	item := models.QuotationItem{QuotationID: quotation.ID, ComponentID: &component.ID, Quantity: 2, UnitCost: 40, TotalCost: 80}
+	adHoc := models.QuotationItem{QuotationID: quotation.ID, Description: "Delivery", Quantity: 1, UnitCost: 5, TotalCost: 5}
+	db.Create(&item)
+	db.Create(&adHoc)
+	db.Where("1 = 1").Delete(&models.AppliedBackfill{})
+
+	if err := database.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	db.First(&item, item.ID)
+	if item.SellUnitPrice != 40 || item.SellTotal != 80 || item.MaterialCost != 80 {
+		t.Errorf("component item sell %v/%v, material cost %v, want 40/80, 80", item.SellUnitPrice, item.SellTotal, item.MaterialCost)
+	}
+	db.First(&adHoc, adHoc.ID)
+	if adHoc.SellTotal != 0 || adHoc.MaterialCost != 0 {
+		t.Errorf("ad-hoc line was backfilled: sell %v, material cost %v", adHoc.SellTotal, adHoc.MaterialCost)
+	}
+	db.First(&component, component.ID)
+	if component.MaterialCost != 40 {
+		t.Errorf("component material cost %v, want 40", component.MaterialCost)
+	}
+	var saved models.Quotation
+	db.First(&saved, quotation.ID)
+	if !saved.SellTotal.Equal(decimal.NewFromInt(85)) || !saved.GrandTotal.Equal(decimal.NewFromInt(85)) {
+		t.Errorf("quotation sell %s, grand %s, want 85", saved.SellTotal, saved.GrandTotal)
+	}
+
+	// Rows written after the backfill ran are not touched by later startups
+	db.Model(&item).Updates(map[string]interface{}{"sell_unit_price": 0, "sell_total": 0})
+	if err := database.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	db.First(&item, item.ID)
+	if item.SellTotal != 0 {
+		t.Errorf("backfill ran again, sell total %v", item.SellTotal)
+	}
+}
@@ -0,0 +1,96 @@
+package database
+
+import (
+	"qp1/models"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// RefreshQuotationSearchText rebuilds the full-text search column of a quotation from its own fields, the
+// names of its components and materials and the descriptions of its ad-hoc items. Call it in the same
+// transaction after changing a quotation's items, or after renaming a component or material it uses (see
+// RefreshQuotationSearchTextForComponent and RefreshQuotationSearchTextForMaterial).
+// Materials match on both the name they had when quoted and their current name.
+func RefreshQuotationSearchText(tx *gorm.DB, quotationID uint) error {
+	var quotation models.Quotation
+	if err := tx.Unscoped().First(&quotation, quotationID).Error; err != nil {
+		return err
+	}
+	parts := []string{quotation.QuotationNo, quotation.Title, quotation.ClientName, quotation.CreatedBy, quotation.Description}
+
+	var componentNames []string
+	if err := tx.Table("quotation_items").
+		Joins("JOIN components ON components.id = quotation_items.component_id").
+		Where("quotation_items.quotation_id = ?", quotationID).
+		Distinct().Pluck("components.name", &componentNames).Error; err != nil {
+		return err
+	}
+	var descriptions []string
+	if err := tx.Model(&models.QuotationItem{}).
+		Where("quotation_id = ? AND description <> ''", quotationID).
+		Distinct().Pluck("description", &descriptions).Error; err != nil {
+		return err
+	}
+	var materialNames []string
+	if err := tx.Model(&models.QuotationMaterial{}).
+		Where("quotation_id = ?", quotationID).
+		Distinct().Pluck("material_name", &materialNames).Error; err != nil {
+		return err
+	}
+	var currentMaterialNames []string
+	if err := tx.Table("quotation_materials").
+		Joins("JOIN materials ON materials.id = quotation_materials.material_id").
+		Where("quotation_materials.quotation_id = ? AND materials.name <> quotation_materials.material_name", quotationID).
+		Distinct().Pluck("materials.name", &currentMaterialNames).Error; err != nil {
+		return err
+	}
+	parts = append(parts, componentNames...)
+	parts = append(parts, descriptions...)
+	parts = append(parts, materialNames...)
+	parts = append(parts, currentMaterialNames...)
+
+	return tx.Model(&models.Quotation{}).Unscoped().Where("id = ?", quotationID).
+		UpdateColumn("search_text", strings.Join(parts, "\n")).Error
+}
+
+// RefreshQuotationSearchTextForComponent rebuilds the search column of every quotation with an item for the
+// component. Call it in the same transaction after renaming the component.
+func RefreshQuotationSearchTextForComponent(tx *gorm.DB, componentID uint) error {
+	var ids []uint
+	if err := tx.Model(&models.QuotationItem{}).
+		Where("component_id = ?", componentID).Distinct().Pluck("quotation_id", &ids).Error; err != nil {
+		return err
+	}
+	return refreshQuotationSearchTexts(tx, ids)
+}
+
+// RefreshQuotationSearchTextForMaterial rebuilds the search column of every quotation that uses the
+// material. Call it in the same transaction after renaming the material.
+func RefreshQuotationSearchTextForMaterial(tx *gorm.DB, materialID uint) error {
+	var ids []uint
+	if err := tx.Model(&models.QuotationMaterial{}).
+		Where("material_id = ?", materialID).Distinct().Pluck("quotation_id", &ids).Error; err != nil {
+		return err
+	}
+	return refreshQuotationSearchTexts(tx, ids)
+}
+
+func refreshQuotationSearchTexts(tx *gorm.DB, ids []uint) error {
+	for _, id := range ids {
+		if err := RefreshQuotationSearchText(tx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backfillQuotationSearch fills the search column of quotations created before it existed
+func backfillQuotationSearch(db *gorm.DB) error {
+	var ids []uint
+	if err := db.Model(&models.Quotation{}).Unscoped().
+		Where("search_text IS NULL OR search_text = ''").Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	return refreshQuotationSearchTexts(db, ids)
+}
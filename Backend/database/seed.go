@@ -0,0 +1,110 @@
+package database
+
+import (
+	"errors"
+	"qp1/models"
+
+	"gorm.io/gorm"
+)
+
+// seedRoles makes sure every permission in the catalog exists, creates the default roles on first startup,
+// keeps the admin role granted every permission and applies new DefaultRoleGrants to existing default roles
+func seedRoles(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		permissions := make(map[string]models.Permission)
+		for _, p := range models.PermissionCatalog {
+			permission := p
+			if err := tx.Where(models.Permission{Name: p.Name}).Assign(models.Permission{Description: p.Description}).FirstOrCreate(&permission).Error; err != nil {
+				return err
+			}
+			permissions[p.Name] = permission
+		}
+
+		var all []models.Permission
+		for _, p := range permissions {
+			all = append(all, p)
+		}
+		admin := models.Role{Name: models.RoleAdmin}
+		if err := tx.Where(models.Role{Name: models.RoleAdmin}).Attrs(models.Role{Description: "Full access", System: true}).FirstOrCreate(&admin).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&admin).Association("Permissions").Replace(all); err != nil {
+			return err
+		}
+
+		for name, names := range models.DefaultRoles {
+			var count int64
+			if err := tx.Model(&models.Role{}).Where("name = ?", name).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				continue
+			}
+			role := models.Role{Name: name, System: true}
+			for _, n := range names {
+				role.Permissions = append(role.Permissions, permissions[n])
+			}
+			if err := tx.Create(&role).Error; err != nil {
+				return err
+			}
+		}
+
+		for _, grant := range models.DefaultRoleGrants {
+			var applied int64
+			if err := tx.Model(&models.AppliedRoleGrant{}).Where("name = ?", grant.Name).Count(&applied).Error; err != nil {
+				return err
+			}
+			if applied > 0 {
+				continue
+			}
+			if err := applyDefaultRoleGrant(tx, grant, permissions); err != nil {
+				return err
+			}
+			if err := tx.Create(&models.AppliedRoleGrant{Name: grant.Name}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// applyDefaultRoleGrant adds the grant's permissions to the built-in roles whose defaults include them. Roles
+// created on this startup already have them.
+func applyDefaultRoleGrant(tx *gorm.DB, grant models.DefaultRoleGrant, permissions map[string]models.Permission) error {
+	for name, names := range models.DefaultRoles {
+		var add []models.Permission
+		for _, p := range grant.Permissions {
+			for _, n := range names {
+				if n == p {
+					add = append(add, permissions[p])
+				}
+			}
+		}
+		if len(add) == 0 {
+			continue
+		}
+		var role models.Role
+		err := tx.Where(&models.Role{Name: name, System: true}).First(&role).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&role).Association("Permissions").Append(add); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// seedUnits adds the default units of measure that are missing; units changed by an admin are left alone
+func seedUnits(db *gorm.DB) error {
+	for _, u := range models.DefaultUnits {
+		unit := u
+		if err := db.Where(models.UnitOfMeasure{Code: u.Code}).Attrs(unit).FirstOrCreate(&unit).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package database_test
+
+import (
+	"qp1/database"
+	"qp1/database/dbtest"
+	"qp1/models"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func rolePermissionNames(t *testing.T, db *gorm.DB, name string) map[string]bool {
+	t.Helper()
+	var role models.Role
+	if err := db.Preload("Permissions").Where("name = ?", name).First(&role).Error; err != nil {
+		t.Fatal(err)
+	}
+	names := map[string]bool{}
+	for _, p := range role.Permissions {
+		names[p.Name] = true
+	}
+	return names
+}
+
+func revoke(t *testing.T, db *gorm.DB, roleName string, permission string) {
+	t.Helper()
+	var role models.Role
+	var perm models.Permission
+	db.Where("name = ?", roleName).First(&role)
+	db.Where("name = ?", permission).First(&perm)
+	if err := db.Model(&role).Association("Permissions").Delete(&perm); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSeedGrantsNewDefaultPermissionsOnce(t *testing.T) {
+	db := dbtest.Open(t)
+	if !rolePermissionNames(t, db, "sales")[models.PermTemplatesManage] || !rolePermissionNames(t, db, "estimator")[models.PermQuotationsViewCost] {
+		t.Fatal("new installs don't get the default permissions")
+	}
+
+	// An install whose roles were created before the grant existed
+	revoke(t, db, "sales", models.PermTemplatesManage)
+	revoke(t, db, "estimator", models.PermQuotationsViewCost)
+	db.Where("1 = 1").Delete(&models.AppliedRoleGrant{})
+	if err := database.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	if !rolePermissionNames(t, db, "sales")[models.PermTemplatesManage] {
+		t.Error("existing sales role was not granted templates:manage")
+	}
+	estimator := rolePermissionNames(t, db, "estimator")
+	if !estimator[models.PermQuotationsViewCost] {
+		t.Error("existing estimator role was not granted quotations:view_cost")
+	}
+	if estimator[models.PermTemplatesManage] {
+		t.Error("estimator was granted a permission its defaults don't list")
+	}
+
+	// Once applied, an admin's removal sticks
+	revoke(t, db, "sales", models.PermTemplatesManage)
+	if err := database.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	if rolePermissionNames(t, db, "sales")[models.PermTemplatesManage] {
+		t.Error("templates:manage was granted again after an admin removed it")
+	}
+}
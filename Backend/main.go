@@ -3,6 +3,7 @@ package main
 import (
 	"qp1/database"
 	"qp1/routes"
+	"qp1/utils"
 	"fmt"
 
 	"github.com/gofiber/fiber/v2"
@@ -16,6 +17,11 @@ func main() {
 	}
 	fmt.Println("Connection is successful")
 
+	// Load JWT signing keys from the environment
+	if err := utils.LoadJWTKeys(); err != nil {
+		panic("could not load JWT keys: " + err.Error())
+	}
+
 	app := fiber.New()
 
 	// Adding CORS middleware with specific origin
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// API token scopes besides permission names
+const (
+	ScopeUser  = "user"  // endpoints available to every signed-in user
+	ScopeAdmin = "admin" // admin-only endpoints such as role management
+)
+
+// APIToken is a personal access token for integrations, sent as "Authorization: Bearer qp1_...".
+// Only the SHA-256 of the token is stored.
+type APIToken struct {
+	ID                uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID            uint       `gorm:"not null;index" json:"user_id"`
+	Name              string     `gorm:"type:varchar(100);not null" json:"name"`
+	TokenPrefix       string     `gorm:"type:varchar(16)" json:"token_prefix"` // first characters of the token, to recognise it in lists
+	TokenHash         string     `gorm:"type:char(64);uniqueIndex" json:"-"`
+	Scopes            string     `gorm:"type:text" json:"-"` // comma separated
+	TwoFactorVerified bool       `json:"-"`                  // created from a session that signed in with a second factor
+	ExpiresAt         time.Time  `json:"expires_at"`
+	LastUsedAt        *time.Time `json:"last_used_at"`
+	RevokedAt         *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+}
@@ -0,0 +1,9 @@
+package models
+
+import "time"
+
+// AppliedBackfill records that a one-time data backfill has run, so rows written after it are never touched
+type AppliedBackfill struct {
+	Name      string    `gorm:"type:varchar(100);primaryKey" json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
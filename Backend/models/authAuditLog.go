@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Authentication audit events
+const (
+	AuthEventAccountLocked   = "account_locked"
+	AuthEventIPLocked        = "ip_locked"
+	AuthEventAccountUnlocked = "account_unlocked"
+)
+
+// AuthAuditLog records security relevant authentication events
+type AuthAuditLog struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Event     string    `gorm:"type:varchar(32);not null;index" json:"event"`
+	UserID    *uint     `gorm:"index" json:"user_id,omitempty"`
+	ActorID   *uint     `json:"actor_id,omitempty"` // admin who performed the action, if any
+	Email     string    `gorm:"type:varchar(255)" json:"email"`
+	IP        string    `gorm:"type:varchar(64)" json:"ip"`
+	Detail    string    `json:"detail"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
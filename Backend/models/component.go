@@ -8,16 +8,22 @@ import (
 
 // Component represents a component made up of multiple materials
 type Component struct {
-	ID          uint           `gorm:"primaryKey;autoIncrement" json:"id"`
-	Name        string         `gorm:"unique;not null" json:"name"`
-	Description string         `gorm:"type:text" json:"description"`
-	TotalCost   float64        `gorm:"type:decimal(10,2);not null;default:0" json:"total_cost"`
-	CreatedAt   time.Time      `json:"created_at"`
-	UpdatedAt   time.Time      `json:"updated_at"`
-	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+	ID          uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name        string `gorm:"unique;not null;index:idx_components_search,class:FULLTEXT" json:"name"`
+	Description string `gorm:"type:text;index:idx_components_search,class:FULLTEXT" json:"description"`
+	// TotalCost is the sum of the material, labor and overhead costs, kept up to date when prices change
+	MaterialCost float64        `gorm:"type:decimal(10,2);not null;default:0" json:"material_cost"`
+	LaborCost    float64        `gorm:"type:decimal(10,2);not null;default:0" json:"labor_cost"`
+	OverheadCost float64        `gorm:"type:decimal(10,2);not null;default:0" json:"overhead_cost"`
+	TotalCost    float64        `gorm:"type:decimal(10,2);not null;default:0" json:"total_cost"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
 
 	// Many-to-many relationship with materials
 	Materials []ComponentMaterial `json:"materials"`
+	Labor     []ComponentLabor    `json:"labor"`
+	Overheads []ComponentOverhead `json:"overheads"`
 }
 
 // ComponentMaterial represents the junction table between components and materials
@@ -25,7 +31,11 @@ type ComponentMaterial struct {
 	ID          uint    `gorm:"primaryKey;autoIncrement" json:"id"`
 	ComponentID uint    `gorm:"not null" json:"component_id"`
 	MaterialID  uint    `gorm:"not null" json:"material_id"`
-	Quantity    float64 `gorm:"not null;default:1" json:"quantity"`
+	Quantity    float64 `gorm:"not null;default:1" json:"quantity"` // in Unit
+	// Unit the quantity is given in, converted to the material's unit for costing; empty for the material's unit
+	Unit string `gorm:"type:varchar(20);not null;default:''" json:"unit"`
+	// WastePercent overrides the material's waste for this line; nil uses Material.WastePercent
+	WastePercent *float64 `json:"waste_percent"`
 
 	// Relationships
 	Component Component `gorm:"foreignKey:ComponentID" json:"component"`
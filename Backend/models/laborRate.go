@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// LaborRate is the cost of one unit of work, e.g. an hour of welding or of machine time
+type LaborRate struct {
+	ID          uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name        string    `gorm:"type:varchar(100);uniqueIndex;not null" json:"name"`
+	Unit        string    `gorm:"type:varchar(20);not null;default:'hour'" json:"unit"`
+	Rate        float64   `gorm:"type:decimal(10,2);not null;default:0" json:"rate"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// OverheadRate is an overhead charged in percent of the material and labor cost of a component
+type OverheadRate struct {
+	ID          uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name        string    `gorm:"type:varchar(100);uniqueIndex;not null" json:"name"`
+	Percent     float64   `gorm:"not null;default:0" json:"percent"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// ComponentLabor is a labor line of a component: Quantity units of a labor rate
+type ComponentLabor struct {
+	ID          uint    `gorm:"primaryKey;autoIncrement" json:"id"`
+	ComponentID uint    `gorm:"not null;index" json:"component_id"`
+	LaborRateID uint    `gorm:"not null;index" json:"labor_rate_id"`
+	Quantity    float64 `gorm:"not null;default:0" json:"quantity"`
+
+	LaborRate LaborRate `gorm:"foreignKey:LaborRateID" json:"labor_rate"`
+}
+
+// ComponentOverhead applies an overhead rate to a component
+type ComponentOverhead struct {
+	ID             uint `gorm:"primaryKey;autoIncrement" json:"id"`
+	ComponentID    uint `gorm:"not null;index" json:"component_id"`
+	OverheadRateID uint `gorm:"not null;index" json:"overhead_rate_id"`
+
+	OverheadRate OverheadRate `gorm:"foreignKey:OverheadRateID" json:"overhead_rate"`
+}
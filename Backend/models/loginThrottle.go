@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// LoginThrottle counts recent failed logins for one account ("account:<email>") or one client IP ("ip:<address>")
+type LoginThrottle struct {
+	ID            uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	ThrottleKey   string     `gorm:"type:varchar(191);uniqueIndex;not null" json:"throttle_key"`
+	Failures      int        `json:"failures"`
+	LastFailureAt time.Time  `json:"last_failure_at"`
+	LockedUntil   *time.Time `json:"locked_until,omitempty"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
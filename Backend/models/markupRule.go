@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Markup rule scopes. A component rule wins over the classification rules of its materials, which win over
+// Settings.DefaultMarkupPercent; the client tier rule of a quotation is added on top.
+const (
+	MarkupScopeClassification = "classification" // Target is a Material.Classification
+	MarkupScopeComponent      = "component"      // Target is a component ID
+	MarkupScopeClientTier     = "client_tier"    // Target is a tier name, chosen per quotation; may be negative
+)
+
+// MarkupRule sets the markup, in percent of cost, for a classification, component or client tier
+type MarkupRule struct {
+	ID            uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Scope         string    `gorm:"type:varchar(20);not null;uniqueIndex:idx_markup_rules_target" json:"scope"`
+	Target        string    `gorm:"type:varchar(100);not null;uniqueIndex:idx_markup_rules_target" json:"target"`
+	MarkupPercent float64   `gorm:"not null;default:0" json:"markup_percent"`
+	Description   string    `json:"description"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
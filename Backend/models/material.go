@@ -7,14 +7,27 @@ import (
 )
 
 type Material struct {
-	ID             uint           `gorm:"primaryKey;autoIncrement" json:"id"`
-	Name           string         `gorm:"unique;not null" json:"name"`
-	Description    string         `gorm:"type:text" json:"description"`
-	Unit           string         `json:"unit"`
-	UnitCost       float64        `gorm:"type:decimal(10,2);not null;default:0" json:"unit_cost"`
-	StockQty       float64        `gorm:"not null;default:0" json:"stock_qty"`
-	Classification string         `json:"classification"`
-	CreatedAt      time.Time      `json:"created_at"`
-	UpdatedAt      time.Time      `json:"updated_at"`
-	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
+	ID             uint    `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name           string  `gorm:"unique;not null;index:idx_materials_search,class:FULLTEXT" json:"name"`
+	Description    string  `gorm:"type:text;index:idx_materials_search,class:FULLTEXT" json:"description"`
+	Unit           string  `json:"unit"`
+	UnitCost       float64 `gorm:"type:decimal(10,2);not null;default:0" json:"unit_cost"`
+	StockQty       float64 `gorm:"not null;default:0" json:"stock_qty"`
+	Classification string  `json:"classification"`
+	SKU            *string `gorm:"type:varchar(100);uniqueIndex;index:idx_materials_search,class:FULLTEXT" json:"sku"`
+	SupplierID     *uint   `gorm:"index" json:"supplier_id"`
+	SupplierPartNo string  `gorm:"type:varchar(100)" json:"supplier_part_no"`
+	LeadTimeDays   int     `gorm:"not null;default:0" json:"lead_time_days"`
+	MinOrderQty    float64 `gorm:"not null;default:0" json:"min_order_qty"`
+	PricingPolicy  string  `gorm:"type:varchar(20);not null;default:'preferred'" json:"pricing_policy"`
+	// Cutting waste in percent of the quantity used, and the quantity the material is bought in, e.g. 2.88 for a
+	// sheet of 2.88 m2; 0 when any quantity can be bought
+	WastePercent      float64        `gorm:"not null;default:0" json:"waste_percent"`
+	PurchaseIncrement float64        `gorm:"not null;default:0" json:"purchase_increment"`
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Supplier       *Supplier               `gorm:"foreignKey:SupplierID" json:"supplier,omitempty"`
+	SupplierPrices []MaterialSupplierPrice `gorm:"foreignKey:MaterialID" json:"supplier_prices,omitempty"`
 }
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Pricing policies decide which purchase price is used when costing a material
+const (
+	PricingPolicyPreferred = "preferred" // the preferred supplier price, falling back to the cheapest
+	PricingPolicyCheapest  = "cheapest"  // the lowest supplier price
+	PricingPolicyUnitCost  = "unit_cost" // ignore supplier prices and use Material.UnitCost
+)
+
+// MaterialSupplierPrice is the price a supplier charges for a material
+type MaterialSupplierPrice struct {
+	ID           uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	MaterialID   uint      `gorm:"not null;uniqueIndex:idx_material_supplier" json:"material_id"`
+	SupplierID   uint      `gorm:"not null;uniqueIndex:idx_material_supplier" json:"supplier_id"`
+	Price        float64   `gorm:"type:decimal(10,2);not null;default:0" json:"price"`
+	Currency     string    `gorm:"type:varchar(10)" json:"currency"`
+	LeadTimeDays int       `gorm:"not null;default:0" json:"lead_time_days"`
+	Preferred    bool      `gorm:"not null;default:false" json:"preferred"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+
+	// Relationships
+	Supplier Supplier `gorm:"foreignKey:SupplierID" json:"supplier"`
+}
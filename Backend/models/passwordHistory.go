@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// PasswordHistory keeps the previous password hashes of a user so recent passwords can't be reused
+type PasswordHistory struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    uint      `gorm:"not null;index" json:"user_id"`
+	Password  []byte    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -12,49 +12,89 @@ type Quotation struct {
 	ID          uint            `gorm:"primaryKey" json:"id"`
 	UserID      uint            `gorm:"not null;index" json:"user_id"`
 	CreatedBy   string          `gorm:"type:varchar(255)" json:"created_by"`
-	ClientName  string          `gorm:"type:varchar(255)" json:"client_name,omitempty"`
+	ClientName  string          `gorm:"type:varchar(255);index:idx_quotations_client,class:FULLTEXT" json:"client_name,omitempty"`
 	Title       string          `gorm:"type:varchar(255);not null" json:"title"`
 	Description string          `gorm:"type:text" json:"description,omitempty"`
 	Status      string          `gorm:"type:varchar(50);default:'draft'" json:"status"`
 	TotalCost   decimal.Decimal `gorm:"type:decimal(15,2);default:0" json:"total_cost"`
+	SellTotal   decimal.Decimal `gorm:"type:decimal(15,2);default:0" json:"sell_total"`
+	TaxRate     float64         `gorm:"not null;default:0" json:"tax_rate"` // Settings.TaxRate when the quotation was priced
+	TaxTotal    decimal.Decimal `gorm:"type:decimal(15,2);default:0" json:"tax_total"`
+	GrandTotal  decimal.Decimal `gorm:"type:decimal(15,2);default:0" json:"grand_total"` // SellTotal plus TaxTotal
+	ClientTier  string          `gorm:"type:varchar(100)" json:"client_tier,omitempty"`  // selects the client tier markup rule
 	QuotationNo string          `gorm:"type:varchar(100);uniqueIndex" json:"quotation_no"`
 	CreatedAt   time.Time       `json:"created_at"`
 	UpdatedAt   time.Time       `json:"updated_at"`
 	DeletedAt   gorm.DeletedAt  `gorm:"index" json:"-"`
 
+	// SearchText is the quotation's text plus the names of its components and materials, kept up to date by
+	// database.RefreshQuotationSearchText and indexed for full-text search
+	SearchText string `gorm:"type:text;index:idx_quotations_search,class:FULLTEXT" json:"-"`
+
 	// Remove Client relationship
-	User      User               `gorm:"foreignKey:UserID" json:"user,omitempty"`
-	Items     []QuotationItem    `gorm:"foreignKey:QuotationID" json:"items,omitempty"`
-	Materials []QuotationMaterial `gorm:"foreignKey:QuotationID" json:"materials,omitempty"` 
+	User      User                `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Items     []QuotationItem     `gorm:"foreignKey:QuotationID" json:"items,omitempty"`
+	Sections  []QuotationSection  `gorm:"foreignKey:QuotationID" json:"sections,omitempty"`
+	Materials []QuotationMaterial `gorm:"foreignKey:QuotationID" json:"materials,omitempty"`
 }
 
-// QuotationItem represents a component in a quotation with dimensions and quantities
+// QuotationItem represents a component in a quotation with dimensions and quantities, or an ad-hoc line such as
+// delivery or installation. Ad-hoc lines have no component; they have a description and unit and are priced as given.
 type QuotationItem struct {
 	ID          uint    `gorm:"primaryKey;autoIncrement" json:"id"`
 	QuotationID uint    `gorm:"not null" json:"quotation_id"`
-	ComponentID uint    `gorm:"not null" json:"component_id"`
+	Position    int     `gorm:"not null;default:0" json:"position"` // order of the items in the quotation
+	SectionID   *uint   `gorm:"index" json:"section_id"`            // nil for items outside any section
+	Notes       string  `gorm:"type:varchar(255)" json:"notes,omitempty"`
+	ComponentID *uint   `gorm:"index" json:"component_id"`
+	Description string  `gorm:"type:varchar(255)" json:"description,omitempty"`
+	Unit        string  `gorm:"type:varchar(50)" json:"unit,omitempty"`
+	TaxExempt   bool    `gorm:"not null;default:false" json:"tax_exempt"`
 	Length      float64 `gorm:"not null;default:1" json:"length"`
 	Width       float64 `gorm:"not null;default:1" json:"width"`
 	Height      float64 `gorm:"not null;default:1" json:"height"`
-	Quantity    int     `gorm:"not null;default:1" json:"quantity"`
-	UnitCost    float64 `gorm:"type:decimal(10,2);not null;default:0" json:"unit_cost"`
-	TotalCost   float64 `gorm:"type:decimal(10,2);not null;default:0" json:"total_cost"`
+	// DimensionUnit is the length unit of Length, Width and Height; they are converted to metres for costing
+	DimensionUnit string  `gorm:"type:varchar(20);not null;default:'m'" json:"dimension_unit"`
+	Quantity      int     `gorm:"not null;default:1" json:"quantity"`
+	UnitCost      float64 `gorm:"type:decimal(10,2);not null;default:0" json:"unit_cost"`
+	TotalCost     float64 `gorm:"type:decimal(10,2);not null;default:0" json:"total_cost"`
+	// Split of TotalCost by the component's material, labor and overhead costs
+	MaterialCost float64 `gorm:"type:decimal(10,2);not null;default:0" json:"material_cost"`
+	LaborCost    float64 `gorm:"type:decimal(10,2);not null;default:0" json:"labor_cost"`
+	OverheadCost float64 `gorm:"type:decimal(10,2);not null;default:0" json:"overhead_cost"`
+	// Selling price: the cost with the markup rules applied when the quotation was priced
+	MarkupPercent float64 `gorm:"not null;default:0" json:"markup_percent"`
+	SellUnitPrice float64 `gorm:"type:decimal(10,2);not null;default:0" json:"sell_unit_price"`
+	SellTotal     float64 `gorm:"type:decimal(10,2);not null;default:0" json:"sell_total"`
 
 	// Relationships
-	Quotation Quotation `gorm:"foreignKey:QuotationID" json:"quotation"`
-	Component Component `gorm:"foreignKey:ComponentID" json:"component"`
+	Quotation Quotation  `gorm:"foreignKey:QuotationID" json:"quotation"`
+	Component *Component `gorm:"foreignKey:ComponentID" json:"component,omitempty"`
+}
+
+// QuotationSection groups the items of a quotation under a heading such as "Kitchen"
+type QuotationSection struct {
+	ID          uint    `gorm:"primaryKey;autoIncrement" json:"id"`
+	QuotationID uint    `gorm:"not null;index" json:"quotation_id"`
+	Name        string  `gorm:"type:varchar(100);not null" json:"name"`
+	Position    int     `gorm:"not null;default:0" json:"position"`
+	Subtotal    float64 `gorm:"type:decimal(15,2);not null;default:0" json:"subtotal"` // selling price of its items before tax
 }
 
 // QuotationMaterial represents the resolved materials from components with calculated quantities
 type QuotationMaterial struct {
-	ID           uint    `gorm:"primaryKey;autoIncrement" json:"id"`
-	QuotationID  uint    `gorm:"not null" json:"quotation_id"`
-	MaterialID   uint    `gorm:"not null" json:"material_id"`
-	MaterialName string  `gorm:"not null" json:"material_name"`
-	Unit         string  `gorm:"not null" json:"unit"`
-	UnitCost     float64 `gorm:"type:decimal(10,2);not null;default:0" json:"unit_cost"`
-	Quantity     float64 `gorm:"not null;default:0" json:"quantity"`
-	TotalCost    float64 `gorm:"type:decimal(10,2);not null;default:0" json:"total_cost"`
+	ID            uint    `gorm:"primaryKey;autoIncrement" json:"id"`
+	QuotationID   uint    `gorm:"not null" json:"quotation_id"`
+	MaterialID    uint    `gorm:"not null" json:"material_id"`
+	MaterialName  string  `gorm:"not null" json:"material_name"`
+	SupplierID    *uint   `json:"supplier_id"` // supplier whose price was used, nil for Material.UnitCost
+	Unit          string  `gorm:"not null" json:"unit"`
+	UnitCost      float64 `gorm:"type:decimal(10,2);not null;default:0" json:"unit_cost"`
+	NetQuantity   float64 `gorm:"not null;default:0" json:"net_quantity"`                    // quantity used by the components
+	GrossQuantity float64 `gorm:"not null;default:0" json:"gross_quantity"`                  // NetQuantity plus cutting waste
+	Quantity      float64 `gorm:"not null;default:0" json:"quantity"`                        // GrossQuantity rounded up to purchase increments
+	TotalCost     float64 `gorm:"type:decimal(10,2);not null;default:0" json:"total_cost"`   // cost of Quantity
+	SurplusCost   float64 `gorm:"type:decimal(10,2);not null;default:0" json:"surplus_cost"` // cost of Quantity beyond GrossQuantity, not on any item
 
 	// Relationships
 	Quotation Quotation `gorm:"foreignKey:QuotationID" json:"quotation"`
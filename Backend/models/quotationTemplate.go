@@ -0,0 +1,64 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// QuotationTemplate is a reusable set of quotation items, such as a standard kitchen. Shared templates are
+// managed by users with templates:manage and visible to everyone; personal templates belong to one user.
+type QuotationTemplate struct {
+	ID          uint           `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name        string         `gorm:"type:varchar(255);not null" json:"name"`
+	Description string         `gorm:"type:text" json:"description"`
+	Title       string         `gorm:"type:varchar(255)" json:"title"` // default title of quotations created from the template
+	Shared      bool           `gorm:"not null;default:false;index" json:"shared"`
+	UserID      *uint          `gorm:"index" json:"user_id"` // owner of a personal template, nil for shared templates
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Parameters []QuotationTemplateParameter `gorm:"foreignKey:TemplateID" json:"parameters"`
+	Items      []QuotationTemplateItem      `gorm:"foreignKey:TemplateID" json:"items"`
+}
+
+// QuotationTemplateParameter is a named value that template items can use for a dimension or quantity,
+// e.g. wall_length. The default can be overridden when a quotation is created from the template.
+type QuotationTemplateParameter struct {
+	ID           uint    `gorm:"primaryKey;autoIncrement" json:"id"`
+	TemplateID   uint    `gorm:"not null;index" json:"template_id"`
+	Name         string  `gorm:"type:varchar(64);not null" json:"name"`
+	Label        string  `gorm:"type:varchar(255)" json:"label"`
+	DefaultValue float64 `gorm:"not null;default:0" json:"default_value"`
+}
+
+// QuotationTemplateItem is a line of a template with its default dimensions and quantity: a component, or an
+// ad-hoc line with a description and unit price like QuotationItem. A *Param field names a parameter that
+// replaces the fixed value.
+type QuotationTemplateItem struct {
+	ID            uint    `gorm:"primaryKey;autoIncrement" json:"id"`
+	TemplateID    uint    `gorm:"not null;index" json:"template_id"`
+	ComponentID   *uint   `json:"component_id"` // nil for ad-hoc lines
+	Position      int     `gorm:"not null;default:0" json:"position"`
+	Section       string  `gorm:"type:varchar(100)" json:"section,omitempty"` // section of the quotation item, empty for none
+	Length        float64 `gorm:"not null;default:1" json:"length"`
+	Width         float64 `gorm:"not null;default:1" json:"width"`
+	Height        float64 `gorm:"not null;default:1" json:"height"`
+	DimensionUnit string  `gorm:"type:varchar(20)" json:"dimension_unit,omitempty"` // metres when empty
+	Quantity      int     `gorm:"not null;default:1" json:"quantity"`
+	LengthParam   string  `gorm:"type:varchar(64)" json:"length_param,omitempty"`
+	WidthParam    string  `gorm:"type:varchar(64)" json:"width_param,omitempty"`
+	HeightParam   string  `gorm:"type:varchar(64)" json:"height_param,omitempty"`
+	QuantityParam string  `gorm:"type:varchar(64)" json:"quantity_param,omitempty"`
+	Notes         string  `gorm:"type:varchar(255)" json:"notes,omitempty"`
+	TaxExempt     bool    `gorm:"not null;default:false" json:"tax_exempt"`
+
+	// Ad-hoc lines only
+	Description string   `gorm:"type:varchar(255)" json:"description,omitempty"`
+	Unit        string   `gorm:"type:varchar(50)" json:"unit,omitempty"`
+	UnitPrice   *float64 `gorm:"type:decimal(10,2)" json:"unit_price,omitempty"`
+	UnitCost    *float64 `gorm:"type:decimal(10,2)" json:"unit_cost,omitempty"` // defaults to the unit price
+
+	Component *Component `gorm:"foreignKey:ComponentID" json:"component,omitempty"`
+}
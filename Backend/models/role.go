@@ -0,0 +1,86 @@
+package models
+
+import "time"
+
+// Permissions checked by the API. New permissions are added to the database at startup and granted to the admin role.
+const (
+	PermUsersManage        = "users:manage"
+	PermSettingsManage     = "settings:manage"
+	PermAuditView          = "audit:view"
+	PermMaterialsRead      = "materials:read"
+	PermMaterialsWrite     = "materials:write"
+	PermSuppliersWrite     = "suppliers:write"
+	PermComponentsRead     = "components:read"
+	PermComponentsWrite    = "components:write"
+	PermQuotationsViewAll  = "quotations:view_all"
+	PermQuotationsApprove  = "quotations:approve"
+	PermQuotationsViewCost = "quotations:view_cost"
+	PermTemplatesManage    = "templates:manage"
+	PermReportsView        = "reports:view"
+)
+
+// RoleAdmin is the built-in role that always has every permission
+const RoleAdmin = "admin"
+
+// PermissionCatalog lists every permission with its description
+var PermissionCatalog = []Permission{
+	{Name: PermUsersManage, Description: "Manage users, their roles and sessions"},
+	{Name: PermSettingsManage, Description: "Change company, tax, currency and security settings"},
+	{Name: PermAuditView, Description: "View the authentication audit log"},
+	{Name: PermMaterialsRead, Description: "View materials and suppliers"},
+	{Name: PermMaterialsWrite, Description: "Create, update, import and delete materials and their supplier prices"},
+	{Name: PermSuppliersWrite, Description: "Create, update and delete suppliers"},
+	{Name: PermComponentsRead, Description: "View and export components"},
+	{Name: PermComponentsWrite, Description: "Create, update, import and delete components"},
+	{Name: PermQuotationsViewAll, Description: "View and search the quotations of all users"},
+	{Name: PermQuotationsApprove, Description: "Approve draft quotations so they can be issued to clients"},
+	{Name: PermQuotationsViewCost, Description: "See costs, markups and gross margins of quotations; without it only selling prices are shown"},
+	{Name: PermTemplatesManage, Description: "Create, update and delete shared quotation templates"},
+	{Name: PermReportsView, Description: "View sales and material usage reports"},
+}
+
+// DefaultRoles are created on first startup with these permissions; afterwards they are managed through the API.
+// When a permission is added to a default role later, also add it to DefaultRoleGrants.
+var DefaultRoles = map[string][]string{
+	"sales":              {PermMaterialsRead, PermComponentsRead, PermQuotationsViewAll, PermQuotationsApprove, PermTemplatesManage, PermReportsView},
+	"estimator":          {PermMaterialsRead, PermComponentsRead, PermComponentsWrite, PermQuotationsViewCost},
+	"purchasing_manager": {PermMaterialsRead, PermMaterialsWrite, PermSuppliersWrite, PermComponentsRead, PermReportsView},
+	"viewer":             {PermMaterialsRead, PermComponentsRead, PermQuotationsViewAll, PermReportsView},
+	"user":               {}, // default role for self-registered users; their drafts are issued by an approver
+}
+
+// DefaultRoleGrant is a batch of permissions added to DefaultRoles after the roles were first created
+type DefaultRoleGrant struct {
+	Name        string
+	Permissions []string
+}
+
+// DefaultRoleGrants are granted once, on the first startup that knows them, to the existing default roles that
+// list the permission in DefaultRoles. Afterwards admins can remove them again.
+var DefaultRoleGrants = []DefaultRoleGrant{
+	{Name: "quotation_templates_and_costs", Permissions: []string{PermTemplatesManage, PermQuotationsViewCost}},
+}
+
+// AppliedRoleGrant records that a DefaultRoleGrant has been granted
+type AppliedRoleGrant struct {
+	Name      string    `gorm:"type:varchar(100);primaryKey" json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Permission is a named capability that can be granted to roles
+type Permission struct {
+	ID          uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name        string `gorm:"type:varchar(100);uniqueIndex;not null" json:"name"`
+	Description string `json:"description"`
+}
+
+// Role is a named set of permissions. User.Role holds the role name.
+type Role struct {
+	ID          uint         `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name        string       `gorm:"type:varchar(50);uniqueIndex;not null" json:"name"`
+	Description string       `json:"description"`
+	System      bool         `json:"system"` // built-in roles can't be deleted
+	Permissions []Permission `gorm:"many2many:role_permissions" json:"permissions"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Session is a server-side login session. Access tokens carry the session ID in their sid claim,
+// so revoking the session invalidates them immediately.
+type Session struct {
+	ID                uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID            uint       `gorm:"not null;index" json:"user_id"`
+	RefreshTokenHash  string     `gorm:"type:char(64);uniqueIndex" json:"-"`
+	PreviousTokenHash string     `gorm:"type:char(64);index" json:"-"` // detects reuse of a rotated refresh token
+	Device            string     `gorm:"type:varchar(255)" json:"device"`
+	IP                string     `gorm:"type:varchar(64)" json:"ip"`
+	LastSeenAt        time.Time  `json:"last_seen_at"`
+	ExpiresAt         time.Time  `json:"expires_at"`
+	RevokedAt         *time.Time `json:"revoked_at,omitempty"`
+	TwoFactorVerified bool       `json:"two_factor_verified"` // signed in with a second factor
+	CreatedAt         time.Time  `json:"created_at"`
+}
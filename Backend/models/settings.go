@@ -7,15 +7,41 @@ import (
 )
 
 type Settings struct {
-	ID                 uint           `gorm:"primaryKey" json:"id"`
-	CompanyName        string         `json:"company_name"`
-	CompanyAddress     string         `json:"company_address"`
-	CompanyLogo        string         `json:"company_logo"` // URL or base64
-	TaxRate            float64        `json:"tax_rate"`
-	Currency           string         `json:"currency"`
-	QuotationNoFormat  string         `json:"quotation_no_format"`
-	TermsAndConditions string         `json:"terms_and_conditions"`
-	CreatedAt          time.Time      `json:"created_at"`
-	UpdatedAt          time.Time      `json:"updated_at"`
-	DeletedAt          gorm.DeletedAt `gorm:"index" json:"-"`
+	ID                 uint    `gorm:"primaryKey" json:"id"`
+	CompanyName        string  `json:"company_name"`
+	CompanyAddress     string  `json:"company_address"`
+	CompanyLogo        string  `json:"company_logo"` // URL or base64
+	TaxRate            float64 `json:"tax_rate"`     // in percent, charged on the taxable items of quotations
+	Currency           string  `json:"currency"`
+	QuotationNoFormat  string  `json:"quotation_no_format"`
+	TermsAndConditions string  `json:"terms_and_conditions"`
+
+	// Markup applied to costs when no markup rule matches, in percent (25 sells a cost of 100 for 125)
+	DefaultMarkupPercent float64 `gorm:"not null;default:0" json:"default_markup_percent"`
+
+	// Password policy
+	PasswordMinLength     int  `gorm:"default:8" json:"password_min_length"`
+	PasswordRequireUpper  bool `json:"password_require_upper"`
+	PasswordRequireLower  bool `json:"password_require_lower"`
+	PasswordRequireDigit  bool `json:"password_require_digit"`
+	PasswordRequireSymbol bool `json:"password_require_symbol"`
+	PasswordCheckCommon   bool `gorm:"default:true" json:"password_check_common"`
+	PasswordHistoryCount  int  `gorm:"default:5" json:"password_history_count"` // number of previous passwords that can't be reused
+	BcryptCost            int  `gorm:"default:12" json:"bcrypt_cost"`
+
+	// Login brute-force protection
+	LoginMaxFailures      int `gorm:"default:5" json:"login_max_failures"`         // failed logins per account before it is locked
+	LoginMaxFailuresPerIP int `gorm:"default:20" json:"login_max_failures_per_ip"` // failed logins per client IP before it is locked
+	LoginLockoutMinutes   int `gorm:"default:15" json:"login_lockout_minutes"`
+
+	// Registration
+	SelfRegistrationEnabled  bool `gorm:"default:true" json:"self_registration_enabled"`   // anyone can sign up through /api/register
+	RequireEmailVerification bool `gorm:"default:false" json:"require_email_verification"` // self-registered accounts stay pending until the email is verified, needs SMTP
+
+	// Two-factor authentication
+	RequireAdmin2FA bool `json:"require_admin_2fa"` // admins must sign in with two-factor authentication
+
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 }
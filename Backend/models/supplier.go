@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Supplier is a vendor that materials are purchased from
+type Supplier struct {
+	ID          uint           `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name        string         `gorm:"unique;not null" json:"name"`
+	ContactName string         `json:"contact_name"`
+	Email       string         `json:"email"`
+	Phone       string         `json:"phone"`
+	Address     string         `gorm:"type:text" json:"address"`
+	Notes       string         `gorm:"type:text" json:"notes"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+}
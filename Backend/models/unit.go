@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// Unit categories. Units convert only within a category, through the category's base unit.
+const (
+	UnitCategoryLength = "length" // base unit m
+	UnitCategoryArea   = "area"   // base unit m2
+	UnitCategoryVolume = "volume" // base unit m3
+	UnitCategoryCount  = "count"  // base unit pcs
+	UnitCategoryWeight = "weight" // base unit kg
+)
+
+// UnitCategories lists the valid unit categories
+var UnitCategories = []string{UnitCategoryLength, UnitCategoryArea, UnitCategoryVolume, UnitCategoryCount, UnitCategoryWeight}
+
+// UnitOfMeasure is a registered unit. Factor is the size of the unit in its category's base unit, e.g. 0.001
+// for mm. Codes are matched without regard to case.
+type UnitOfMeasure struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Code      string    `gorm:"type:varchar(20);uniqueIndex;not null" json:"code"`
+	Name      string    `gorm:"type:varchar(100)" json:"name"`
+	Category  string    `gorm:"type:varchar(20);not null;index" json:"category"`
+	Factor    float64   `gorm:"not null;default:1" json:"factor"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// DefaultUnits are added to the registry at startup when missing
+var DefaultUnits = []UnitOfMeasure{
+	{Code: "m", Name: "Metre", Category: UnitCategoryLength, Factor: 1},
+	{Code: "cm", Name: "Centimetre", Category: UnitCategoryLength, Factor: 0.01},
+	{Code: "mm", Name: "Millimetre", Category: UnitCategoryLength, Factor: 0.001},
+	{Code: "in", Name: "Inch", Category: UnitCategoryLength, Factor: 0.0254},
+	{Code: "ft", Name: "Foot", Category: UnitCategoryLength, Factor: 0.3048},
+	{Code: "m2", Name: "Square metre", Category: UnitCategoryArea, Factor: 1},
+	{Code: "cm2", Name: "Square centimetre", Category: UnitCategoryArea, Factor: 0.0001},
+	{Code: "mm2", Name: "Square millimetre", Category: UnitCategoryArea, Factor: 0.000001},
+	{Code: "ft2", Name: "Square foot", Category: UnitCategoryArea, Factor: 0.09290304},
+	{Code: "m3", Name: "Cubic metre", Category: UnitCategoryVolume, Factor: 1},
+	{Code: "l", Name: "Litre", Category: UnitCategoryVolume, Factor: 0.001},
+	{Code: "ml", Name: "Millilitre", Category: UnitCategoryVolume, Factor: 0.000001},
+	{Code: "pcs", Name: "Piece", Category: UnitCategoryCount, Factor: 1},
+	{Code: "dozen", Name: "Dozen", Category: UnitCategoryCount, Factor: 12},
+	{Code: "kg", Name: "Kilogram", Category: UnitCategoryWeight, Factor: 1},
+	{Code: "g", Name: "Gram", Category: UnitCategoryWeight, Factor: 0.001},
+	{Code: "t", Name: "Tonne", Category: UnitCategoryWeight, Factor: 1000},
+	{Code: "lb", Name: "Pound", Category: UnitCategoryWeight, Factor: 0.45359237},
+}
@@ -1,5 +1,14 @@
 package models
 
+import "time"
+
+// Account states
+const (
+	UserStatusActive  = "active"
+	UserStatusPending = "pending" // self-registered, email not verified yet
+	UserStatusInvited = "invited" // invited by an admin, invitation not accepted yet
+)
+
 type User struct {
 	ID       uint   `gorm:"primaryKey;autoIncrement" json:"id"`
 	Name     string `json:"name"`
@@ -7,4 +16,17 @@ type User struct {
 	Password []byte `json:"-"`
 	Role     string `gorm:"default:user" json:"role"`
 	Contact  string `json:"contact"`
+
+	// Only active accounts can sign in
+	Status          string     `gorm:"type:varchar(16);default:active" json:"status"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+
+	// Two-factor authentication. TOTPSecret is set on enrollment and only used once TOTPEnabled is true.
+	TOTPSecret   string `gorm:"type:varchar(64)" json:"-"`
+	TOTPEnabled  bool   `json:"totp_enabled"`
+	TOTPLastStep int64  `json:"-"` // last accepted time step, so a code can't be used twice
+
+	// Single sign-on identity, set when the user first signs in through the OpenID Connect provider
+	OIDCIssuer  *string `gorm:"column:oidc_issuer;type:varchar(255);uniqueIndex:idx_users_oidc" json:"-"`
+	OIDCSubject *string `gorm:"column:oidc_subject;type:varchar(255);uniqueIndex:idx_users_oidc" json:"-"`
 }
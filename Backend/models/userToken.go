@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Purposes of single-use user tokens
+const (
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeEmailVerification = "email_verification"
+	TokenPurposeInvitation        = "invitation"
+)
+
+// UserToken is a single-use, expiring token sent to a user by email. Only the SHA-256 of the token is stored.
+type UserToken struct {
+	ID        uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	Purpose   string     `gorm:"type:varchar(32);not null;index" json:"purpose"`
+	TokenHash string     `gorm:"type:char(64);uniqueIndex" json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	RequestIP string     `gorm:"type:varchar(64)" json:"request_ip"`
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -2,29 +2,62 @@ package routes
 
 import (
 	"qp1/controllers"
+	"qp1/models"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/limiter"
 )
 
 func SetupRoutes(app *fiber.App) {
+	// Per-IP rate limit for the unauthenticated endpoints that send or redeem emailed tokens
+	emailTokenLimiter := limiter.New(limiter.Config{
+		Max:        5,
+		Expiration: 15 * time.Minute,
+		LimitReached: func(c *fiber.Ctx) error {
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": "Too many requests, please try again later"})
+		},
+	})
+
 	// -------------------- Public & Auth Routes --------------------
 	app.Get("/", controllers.Hello)
 	app.Post("/api/register", controllers.Register)
 	app.Post("/api/login", controllers.Login)
 	app.Get("/api/user", controllers.User)
 	app.Post("/api/logout", controllers.Logout)
-	app.Post("/api/reset-password", controllers.ResetPassword)
-	app.Post("/api/change-password", controllers.RequireUser, controllers.ChangePassword)
-
-	// -------------------- User Management (Admin Only) --------------------
-	app.Get("/api/admin/users", controllers.RequireAdmin, controllers.AdminUserList)
-	app.Post("/api/admin/user", controllers.RequireAdmin, controllers.AddUser)
-	app.Put("/api/admin/user/:id", controllers.RequireAdmin, controllers.UpdateUser)
-	app.Delete("/api/admin/user/:id", controllers.RequireAdmin, controllers.DeleteUser)
-	app.Get("/api/admin/user/:id", controllers.RequireAdmin, controllers.GetUserById)
-	app.Put("/api/admin/user/:id/role", controllers.RequireAdmin, controllers.UpdateUserRole)
-	app.Post("/api/admin/reset-password", controllers.RequireAdmin, controllers.AdminResetPassword)
-	app.Post("/api/admin/delete-user", controllers.RequireAdmin, controllers.AdminDeleteUser)
+	app.Post("/api/login/2fa", controllers.LoginTwoFactor)
+	app.Get("/api/auth/oidc/login", controllers.OIDCLogin)
+	app.Get("/api/auth/oidc/callback", controllers.OIDCCallback)
+	app.Post("/api/refresh", controllers.RefreshSession)
+	app.Post("/api/password-reset/request", emailTokenLimiter, controllers.RequestPasswordReset)
+	app.Post("/api/password-reset/confirm", emailTokenLimiter, controllers.ConfirmPasswordReset)
+	app.Post("/api/verify-email", emailTokenLimiter, controllers.VerifyEmail)
+	app.Post("/api/verify-email/resend", emailTokenLimiter, controllers.ResendVerificationEmail)
+	app.Post("/api/invitations/accept", emailTokenLimiter, controllers.AcceptInvitation)
+	app.Post("/api/change-password", controllers.RequireUser, controllers.RequireInteractiveSession, controllers.ChangePassword)
+
+	// -------------------- User Management (users:manage) --------------------
+	app.Get("/api/admin/users", controllers.RequirePermission(models.PermUsersManage), controllers.AdminUserList)
+	app.Post("/api/admin/user", controllers.RequirePermission(models.PermUsersManage), controllers.AddUser)
+	app.Put("/api/admin/user/:id", controllers.RequirePermission(models.PermUsersManage), controllers.UpdateUser)
+	app.Delete("/api/admin/user/:id", controllers.RequirePermission(models.PermUsersManage), controllers.DeleteUser)
+	app.Get("/api/admin/user/:id", controllers.RequirePermission(models.PermUsersManage), controllers.GetUserById)
+	app.Put("/api/admin/user/:id/role", controllers.RequirePermission(models.PermUsersManage), controllers.UpdateUserRole)
+	app.Post("/api/admin/user/:id/invitation", controllers.RequirePermission(models.PermUsersManage), controllers.ResendInvitation)
+	app.Post("/api/admin/reset-password", controllers.RequirePermission(models.PermUsersManage), controllers.AdminResetPassword)
+	app.Post("/api/admin/delete-user", controllers.RequirePermission(models.PermUsersManage), controllers.AdminDeleteUser)
+	app.Delete("/api/admin/user/:id/sessions", controllers.RequirePermission(models.PermUsersManage), controllers.AdminRevokeUserSessions)
+	app.Post("/api/admin/user/:id/unlock", controllers.RequirePermission(models.PermUsersManage), controllers.UnlockUser)
+	app.Delete("/api/admin/user/:id/2fa", controllers.RequirePermission(models.PermUsersManage), controllers.AdminResetTwoFactor)
+	app.Get("/api/admin/auth-audit-logs", controllers.RequirePermission(models.PermAuditView), controllers.ListAuthAuditLogs)
+
+	// -------------------- Role & Permission Management (Admin Only) --------------------
+	app.Get("/api/admin/permissions", controllers.RequireAdmin, controllers.ListPermissions)
+	app.Get("/api/admin/roles", controllers.RequireAdmin, controllers.ListRoles)
+	app.Post("/api/admin/role", controllers.RequireAdmin, controllers.CreateRole)
+	app.Get("/api/admin/role/:id", controllers.RequireAdmin, controllers.GetRoleById)
+	app.Put("/api/admin/role/:id", controllers.RequireAdmin, controllers.UpdateRole)
+	app.Delete("/api/admin/role/:id", controllers.RequireAdmin, controllers.DeleteRole)
 
 	// -------------------- Client Management (Admin Only) --------------------
 	// Remove these client management routes:
@@ -35,21 +68,44 @@ func SetupRoutes(app *fiber.App) {
 	// app.Get("/api/admin/clients", controllers.RequireAdmin, controllers.ListClients)
 	// app.Get("/api/admin/search-clients", controllers.RequireAdmin, controllers.SearchClients)
 
-	// -------------------- Material Management (Admin Only) --------------------
-	app.Post("/api/admin/create-material", controllers.RequireAdmin, controllers.CreateMaterial)
-	app.Get("/api/admin/get-materials", controllers.RequireAdmin, controllers.ListMaterials)
-	app.Put("/api/admin/update-material/:id", controllers.RequireAdmin, controllers.UpdateMaterial)
-	app.Delete("/api/admin/delete-material/:id", controllers.RequireAdmin, controllers.DeleteMaterial)
-	app.Get("/api/admin/get-material/:id", controllers.RequireAdmin, controllers.GetMaterialById)
-	app.Get("/api/admin/search-materials", controllers.RequireAdmin, controllers.SearchMaterials)
-
-	// -------------------- Component Management (Admin Only) --------------------
-	app.Post("/api/admin/component", controllers.RequireAdmin, controllers.CreateComponent)
-	app.Get("/api/admin/components", controllers.RequireAdmin, controllers.ListComponents)
-	app.Get("/api/admin/component/:id", controllers.RequireAdmin, controllers.GetComponent)
-	app.Put("/api/admin/component/:id", controllers.RequireAdmin, controllers.UpdateComponent)
-	app.Delete("/api/admin/component/:id", controllers.RequireAdmin, controllers.DeleteComponent)
-	app.Get("/api/admin/search-components", controllers.RequireAdmin, controllers.SearchComponents)
+	// -------------------- Material Management (materials:read / materials:write) --------------------
+	app.Post("/api/admin/create-material", controllers.RequirePermission(models.PermMaterialsWrite), controllers.CreateMaterial)
+	app.Get("/api/admin/get-materials", controllers.RequirePermission(models.PermMaterialsRead), controllers.ListMaterials)
+	app.Put("/api/admin/update-material/:id", controllers.RequirePermission(models.PermMaterialsWrite), controllers.UpdateMaterial)
+	app.Delete("/api/admin/delete-material/:id", controllers.RequirePermission(models.PermMaterialsWrite), controllers.DeleteMaterial)
+	app.Get("/api/admin/get-material/:id", controllers.RequirePermission(models.PermMaterialsRead), controllers.GetMaterialById)
+	app.Get("/api/admin/search-materials", controllers.RequirePermission(models.PermMaterialsRead), controllers.SearchMaterials)
+	app.Post("/api/admin/import-materials", controllers.RequirePermission(models.PermMaterialsWrite), controllers.ImportMaterials)
+	app.Get("/api/admin/material/:id/supplier-prices", controllers.RequirePermission(models.PermMaterialsRead), controllers.ListMaterialPrices)
+	app.Post("/api/admin/material/:id/supplier-prices", controllers.RequirePermission(models.PermMaterialsWrite), controllers.CreateMaterialPrice)
+	app.Put("/api/admin/material/:id/supplier-prices/:priceId", controllers.RequirePermission(models.PermMaterialsWrite), controllers.UpdateMaterialPrice)
+	app.Delete("/api/admin/material/:id/supplier-prices/:priceId", controllers.RequirePermission(models.PermMaterialsWrite), controllers.DeleteMaterialPrice)
+
+	// -------------------- Supplier Management (materials:read / suppliers:write) --------------------
+	app.Post("/api/admin/create-supplier", controllers.RequirePermission(models.PermSuppliersWrite), controllers.CreateSupplier)
+	app.Get("/api/admin/get-suppliers", controllers.RequirePermission(models.PermMaterialsRead), controllers.ListSuppliers)
+	app.Put("/api/admin/update-supplier/:id", controllers.RequirePermission(models.PermSuppliersWrite), controllers.UpdateSupplier)
+	app.Delete("/api/admin/delete-supplier/:id", controllers.RequirePermission(models.PermSuppliersWrite), controllers.DeleteSupplier)
+	app.Get("/api/admin/get-supplier/:id", controllers.RequirePermission(models.PermMaterialsRead), controllers.GetSupplierById)
+
+	// -------------------- Component Management (components:read / components:write) --------------------
+	app.Post("/api/admin/component", controllers.RequirePermission(models.PermComponentsWrite), controllers.CreateComponent)
+	app.Get("/api/admin/components", controllers.RequirePermission(models.PermComponentsRead), controllers.ListComponents)
+	app.Get("/api/admin/component/:id", controllers.RequirePermission(models.PermComponentsRead), controllers.GetComponent)
+	app.Put("/api/admin/component/:id", controllers.RequirePermission(models.PermComponentsWrite), controllers.UpdateComponent)
+	app.Delete("/api/admin/component/:id", controllers.RequirePermission(models.PermComponentsWrite), controllers.DeleteComponent)
+	app.Get("/api/admin/search-components", controllers.RequirePermission(models.PermComponentsRead), controllers.SearchComponents)
+	app.Get("/api/admin/export-components", controllers.RequirePermission(models.PermComponentsRead), controllers.ExportComponents)
+	app.Post("/api/admin/import-components", controllers.RequirePermission(models.PermComponentsWrite), controllers.ImportComponents)
+	app.Get("/api/admin/component/:id/bom", controllers.RequirePermission(models.PermComponentsRead), controllers.GetComponentBOM)
+	app.Get("/api/admin/labor-rates", controllers.RequirePermission(models.PermComponentsRead), controllers.ListLaborRates)
+	app.Post("/api/admin/labor-rates", controllers.RequirePermission(models.PermComponentsWrite), controllers.CreateLaborRate)
+	app.Put("/api/admin/labor-rates/:id", controllers.RequirePermission(models.PermComponentsWrite), controllers.UpdateLaborRate)
+	app.Delete("/api/admin/labor-rates/:id", controllers.RequirePermission(models.PermComponentsWrite), controllers.DeleteLaborRate)
+	app.Get("/api/admin/overhead-rates", controllers.RequirePermission(models.PermComponentsRead), controllers.ListOverheadRates)
+	app.Post("/api/admin/overhead-rates", controllers.RequirePermission(models.PermComponentsWrite), controllers.CreateOverheadRate)
+	app.Put("/api/admin/overhead-rates/:id", controllers.RequirePermission(models.PermComponentsWrite), controllers.UpdateOverheadRate)
+	app.Delete("/api/admin/overhead-rates/:id", controllers.RequirePermission(models.PermComponentsWrite), controllers.DeleteOverheadRate)
 
 	// -------------------- Quotation Management (User) --------------------
 	app.Post("/api/quotations", controllers.RequireUser, controllers.CreateQuotation)
@@ -62,25 +118,59 @@ func SetupRoutes(app *fiber.App) {
 	app.Put("/api/quotations/:id/status", controllers.RequireUser, controllers.UpdateQuotationStatus)
 	app.Post("/api/quotations/:id/duplicate", controllers.RequireUser, controllers.DuplicateQuotation)
 	app.Get("/api/quotations/:id/pdf", controllers.RequireUser, controllers.GenerateQuotationPDF)
+	app.Post("/api/quotations/from-template/:id", controllers.RequireUser, controllers.CreateQuotationFromTemplate)
+	app.Get("/api/units", controllers.RequireUser, controllers.ListUnits)
+
+	// -------------------- Quotation Templates (User; shared templates need templates:manage) --------------------
+	app.Get("/api/quotation-templates", controllers.RequireUser, controllers.ListQuotationTemplates)
+	app.Get("/api/quotation-templates/:id", controllers.RequireUser, controllers.GetQuotationTemplate)
+	app.Post("/api/quotation-templates", controllers.RequireUser, controllers.CreateQuotationTemplate)
+	app.Put("/api/quotation-templates/:id", controllers.RequireUser, controllers.UpdateQuotationTemplate)
+	app.Delete("/api/quotation-templates/:id", controllers.RequireUser, controllers.DeleteQuotationTemplate)
+
+	// -------------------- Quotation Management (quotations:view_all / reports:view) --------------------
+	app.Get("/api/admin/quotations", controllers.RequirePermission(models.PermQuotationsViewAll), controllers.ListAllQuotations)
+	app.Get("/api/admin/search-quotations", controllers.RequirePermission(models.PermQuotationsViewAll), controllers.SearchQuotations)
+	app.Get("/api/admin/sales-report", controllers.RequirePermission(models.PermReportsView), controllers.GenerateSalesReport)
+	app.Get("/api/admin/material-usage-report", controllers.RequirePermission(models.PermReportsView), controllers.GenerateMaterialUsageReport)
 
-	// -------------------- Quotation Management (Admin) --------------------
-	app.Get("/api/admin/quotations", controllers.RequireAdmin, controllers.ListAllQuotations)
-	app.Get("/api/admin/search-quotations", controllers.RequireAdmin, controllers.SearchQuotations)
-	app.Get("/api/admin/sales-report", controllers.RequireAdmin, controllers.GenerateSalesReport)
-	app.Get("/api/admin/material-usage-report", controllers.RequireAdmin, controllers.GenerateMaterialUsageReport)
+	// -------------------- Global Search (User) --------------------
+	app.Get("/api/search", controllers.RequireUser, controllers.GlobalSearch)
 
 	// -------------------- Product/Material List (User) --------------------
 	app.Get("/api/products", controllers.RequireUser, controllers.GetProductList)
 	app.Get("/api/products/:id", controllers.RequireUser, controllers.GetProductDetail)
 
-	// -------------------- Settings Management (Admin Only) --------------------
-	app.Put("/api/admin/settings/company", controllers.RequireAdmin, controllers.UpdateCompanyInfo)
-	app.Put("/api/admin/settings/tax", controllers.RequireAdmin, controllers.UpdateTaxSettings)
-	app.Put("/api/admin/settings/currency", controllers.RequireAdmin, controllers.UpdateCurrencySettings)
-	app.Put("/api/admin/settings/quotation-no-format", controllers.RequireAdmin, controllers.UpdateQuotationNumberFormat)
-	app.Put("/api/admin/settings/terms", controllers.RequireAdmin, controllers.UpdateTermsAndConditions)
+	// -------------------- Settings Management (settings:manage) --------------------
+	app.Put("/api/admin/settings/company", controllers.RequirePermission(models.PermSettingsManage), controllers.UpdateCompanyInfo)
+	app.Put("/api/admin/settings/tax", controllers.RequirePermission(models.PermSettingsManage), controllers.UpdateTaxSettings)
+	app.Put("/api/admin/settings/currency", controllers.RequirePermission(models.PermSettingsManage), controllers.UpdateCurrencySettings)
+	app.Put("/api/admin/settings/markup", controllers.RequirePermission(models.PermSettingsManage), controllers.UpdateMarkupSettings)
+	app.Get("/api/admin/markup-rules", controllers.RequirePermission(models.PermSettingsManage), controllers.ListMarkupRules)
+	app.Post("/api/admin/markup-rules", controllers.RequirePermission(models.PermSettingsManage), controllers.CreateMarkupRule)
+	app.Put("/api/admin/markup-rules/:id", controllers.RequirePermission(models.PermSettingsManage), controllers.UpdateMarkupRule)
+	app.Delete("/api/admin/markup-rules/:id", controllers.RequirePermission(models.PermSettingsManage), controllers.DeleteMarkupRule)
+	app.Post("/api/admin/units", controllers.RequirePermission(models.PermSettingsManage), controllers.CreateUnit)
+	app.Put("/api/admin/units/:id", controllers.RequirePermission(models.PermSettingsManage), controllers.UpdateUnit)
+	app.Delete("/api/admin/units/:id", controllers.RequirePermission(models.PermSettingsManage), controllers.DeleteUnit)
+	app.Put("/api/admin/settings/quotation-no-format", controllers.RequirePermission(models.PermSettingsManage), controllers.UpdateQuotationNumberFormat)
+	app.Put("/api/admin/settings/terms", controllers.RequirePermission(models.PermSettingsManage), controllers.UpdateTermsAndConditions)
+	app.Put("/api/admin/settings/password-policy", controllers.RequirePermission(models.PermSettingsManage), controllers.UpdatePasswordPolicy)
+	app.Put("/api/admin/settings/login-security", controllers.RequirePermission(models.PermSettingsManage), controllers.UpdateLoginSecuritySettings)
+	app.Put("/api/admin/settings/registration", controllers.RequirePermission(models.PermSettingsManage), controllers.UpdateRegistrationSettings)
+	app.Put("/api/admin/settings/two-factor", controllers.RequirePermission(models.PermSettingsManage), controllers.UpdateTwoFactorSettings)
 
 	// -------------------- User Self-Service (Profile & Password) --------------------
-	app.Put("/api/user/profile", controllers.RequireUser, controllers.UpdateUserProfile)
-	app.Put("/api/user/password", controllers.RequireUser, controllers.UpdateUserPassword)
+	app.Put("/api/user/profile", controllers.RequireUser, controllers.RequireInteractiveSession, controllers.UpdateUserProfile)
+	app.Put("/api/user/password", controllers.RequireUser, controllers.RequireInteractiveSession, controllers.UpdateUserPassword)
+	app.Get("/api/sessions", controllers.RequireUser, controllers.RequireInteractiveSession, controllers.ListSessions)
+	app.Delete("/api/sessions", controllers.RequireUser, controllers.RequireInteractiveSession, controllers.RevokeAllSessions)
+	app.Delete("/api/sessions/:id", controllers.RequireUser, controllers.RequireInteractiveSession, controllers.RevokeSession)
+	app.Post("/api/2fa/enroll", controllers.RequireUser, controllers.RequireInteractiveSession, controllers.EnrollTwoFactor)
+	app.Post("/api/2fa/verify", controllers.RequireUser, controllers.RequireInteractiveSession, controllers.VerifyTwoFactor)
+	app.Post("/api/2fa/disable", controllers.RequireUser, controllers.RequireInteractiveSession, controllers.DisableTwoFactor)
+	app.Post("/api/2fa/recovery-codes", controllers.RequireUser, controllers.RequireInteractiveSession, controllers.RegenerateRecoveryCodes)
+	app.Get("/api/tokens", controllers.RequireUser, controllers.RequireInteractiveSession, controllers.ListAPITokens)
+	app.Post("/api/tokens", controllers.RequireUser, controllers.RequireInteractiveSession, controllers.CreateAPIToken)
+	app.Delete("/api/tokens/:id", controllers.RequireUser, controllers.RequireInteractiveSession, controllers.RevokeAPIToken)
 }
@@ -0,0 +1,18 @@
+package utils
+
+import "math"
+
+// ApplyWaste returns the quantity of material to cut for quantity when wastePercent of it is lost, e.g. 10 for 10%
+func ApplyWaste(quantity float64, wastePercent float64) float64 {
+	return quantity * (1 + wastePercent/100)
+}
+
+// RoundUpToIncrement rounds quantity up to a whole number of purchase increments, e.g. whole sheets or boxes.
+// An increment of 0 leaves the quantity unchanged.
+func RoundUpToIncrement(quantity float64, increment float64) float64 {
+	if increment <= 0 || quantity <= 0 {
+		return quantity
+	}
+	// Tolerate float error so that exact multiples aren't rounded up to the next increment
+	return math.Ceil(quantity/increment-1e-9) * increment
+}
@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"math"
+	"testing"
+)
+
+func TestApplyWaste(t *testing.T) {
+	tests := []struct {
+		quantity, wastePercent, want float64
+	}{
+		{10, 0, 10},
+		{10, 10, 11},
+		{2.5, 20, 3},
+		{0, 15, 0},
+		{4, 100, 8},
+	}
+	for _, tt := range tests {
+		if got := ApplyWaste(tt.quantity, tt.wastePercent); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("ApplyWaste(%v, %v) = %v, want %v", tt.quantity, tt.wastePercent, got, tt.want)
+		}
+	}
+}
+
+func TestRoundUpToIncrement(t *testing.T) {
+	tests := []struct {
+		name                string
+		quantity, increment float64
+		want                float64
+	}{
+		{"no increment", 4.4, 0, 4.4},
+		{"negative increment", 4.4, -1, 4.4},
+		{"zero quantity", 0, 3, 0},
+		{"rounded up", 4.4, 3, 6},
+		{"just over a multiple", 6.01, 3, 9},
+		{"exact multiple", 6, 3, 6},
+		{"exact multiple with float error", 0.1 + 0.2, 0.1, 0.3},
+		{"fractional increment", 5, 2.88, 5.76},
+		{"less than one increment", 0.5, 10, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RoundUpToIncrement(tt.quantity, tt.increment); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("RoundUpToIncrement(%v, %v) = %v, want %v", tt.quantity, tt.increment, got, tt.want)
+			}
+		})
+	}
+}
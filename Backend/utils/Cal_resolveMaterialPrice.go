@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"qp1/models"
+	"strings"
+)
+
+// ResolveMaterialPrice returns the unit price used to cost a material according to its pricing policy,
+// and the supplier price it came from (nil when Material.UnitCost is used).
+// SupplierPrices must be preloaded. Only prices in currency, the quoting currency, are considered; prices
+// without a currency are assumed to be in it, and an empty currency accepts every price.
+func ResolveMaterialPrice(material models.Material, currency string) (float64, *models.MaterialSupplierPrice) {
+	if material.PricingPolicy == models.PricingPolicyUnitCost {
+		return material.UnitCost, nil
+	}
+
+	var cheapest *models.MaterialSupplierPrice
+	for i := range material.SupplierPrices {
+		price := &material.SupplierPrices[i]
+		if currency != "" && price.Currency != "" && !strings.EqualFold(price.Currency, currency) {
+			continue
+		}
+		if material.PricingPolicy != models.PricingPolicyCheapest && price.Preferred {
+			return price.Price, price
+		}
+		if cheapest == nil || price.Price < cheapest.Price {
+			cheapest = price
+		}
+	}
+	if cheapest == nil {
+		return material.UnitCost, nil
+	}
+	return cheapest.Price, cheapest
+}
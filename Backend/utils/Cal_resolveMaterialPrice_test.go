@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"qp1/models"
+	"testing"
+)
+
+func TestResolveMaterialPrice(t *testing.T) {
+	prices := []models.MaterialSupplierPrice{
+		{SupplierID: 1, Price: 12, Preferred: true},
+		{SupplierID: 2, Price: 9},
+		{SupplierID: 3, Price: 7, Currency: "USD"},
+		{SupplierID: 4, Price: 10, Currency: "eur"},
+	}
+	tests := []struct {
+		name         string
+		policy       string
+		prices       []models.MaterialSupplierPrice
+		currency     string
+		want         float64
+		wantSupplier uint // 0 for the material's unit cost
+	}{
+		{"preferred", models.PricingPolicyPreferred, prices, "EUR", 12, 1},
+		{"cheapest", models.PricingPolicyCheapest, prices, "EUR", 9, 2},
+		{"cheapest in another currency is ignored", models.PricingPolicyCheapest, prices[2:], "EUR", 10, 4},
+		{"any currency without a quoting currency", models.PricingPolicyCheapest, prices, "", 7, 3},
+		{"preferred falls back to the cheapest", models.PricingPolicyPreferred, prices[1:], "EUR", 9, 2},
+		{"preferred price in another currency", models.PricingPolicyPreferred, []models.MaterialSupplierPrice{{SupplierID: 1, Price: 5, Currency: "USD", Preferred: true}, prices[1]}, "EUR", 9, 2},
+		{"unit cost policy", models.PricingPolicyUnitCost, prices, "EUR", 20, 0},
+		{"no supplier prices", models.PricingPolicyPreferred, nil, "EUR", 20, 0},
+		{"only prices in other currencies", models.PricingPolicyCheapest, prices[2:3], "EUR", 20, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			material := models.Material{UnitCost: 20, PricingPolicy: tt.policy, SupplierPrices: tt.prices}
+			got, price := ResolveMaterialPrice(material, tt.currency)
+			supplier := uint(0)
+			if price != nil {
+				supplier = price.SupplierID
+			}
+			if got != tt.want || supplier != tt.wantSupplier {
+				t.Errorf("ResolveMaterialPrice() = %v from supplier %d, want %v from supplier %d", got, supplier, tt.want, tt.wantSupplier)
+			}
+		})
+	}
+}
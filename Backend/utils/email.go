@@ -1,8 +1,12 @@
 package utils
 
 import (
+	"errors"
 	"fmt"
 	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
 )
 
 // EmailConfig holds SMTP server config
@@ -33,3 +37,54 @@ func EmailQuotationToClient(cfg EmailConfig, to string, subject string, body str
 	addr := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
 	return smtp.SendMail(addr, auth, cfg.From, []string{to}, message)
 }
+
+// EmailConfigFromEnv reads the SMTP settings from SMTP_HOST, SMTP_PORT, SMTP_USERNAME, SMTP_PASSWORD and SMTP_FROM
+func EmailConfigFromEnv() (EmailConfig, error) {
+	cfg := EmailConfig{
+		Host:     os.Getenv("SMTP_HOST"),
+		Port:     587,
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("SMTP_FROM"),
+	}
+	if cfg.Host == "" || cfg.From == "" {
+		return cfg, errors.New("email is not configured, set SMTP_HOST and SMTP_FROM")
+	}
+	if port := os.Getenv("SMTP_PORT"); port != "" {
+		p, err := strconv.Atoi(port)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid SMTP_PORT %q", port)
+		}
+		cfg.Port = p
+	}
+	return cfg, nil
+}
+
+// SendEmail sends a plain text email
+func SendEmail(cfg EmailConfig, to string, subject string, body string) error {
+	if strings.ContainsAny(to+subject, "\r\n") {
+		return errors.New("invalid email header")
+	}
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+
+	msg := "From: " + cfg.From + "\r\n" +
+		"To: " + to + "\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n\r\n" +
+		body
+
+	addr := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
+	return smtp.SendMail(addr, auth, cfg.From, []string{to}, []byte(msg))
+}
+
+// AppURL returns the public URL of the frontend used in links sent by email (APP_URL, defaults to http://localhost:3000)
+func AppURL() string {
+	if url := strings.TrimRight(os.Getenv("APP_URL"), "/"); url != "" {
+		return url
+	}
+	return "http://localhost:3000"
+}
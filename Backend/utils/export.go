@@ -0,0 +1,166 @@
+package utils
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/xuri/excelize/v2"
+)
+
+// Supported export formats
+const (
+	ExportFormatCSV  = "csv"
+	ExportFormatXLSX = "xlsx"
+)
+
+// TableWriter writes rows of tabular data to an export file
+type TableWriter interface {
+	WriteRow(values []interface{}) error
+	Close() error
+}
+
+// IsExportFormat reports whether format is one of the supported export formats
+func IsExportFormat(format string) bool {
+	return format == ExportFormatCSV || format == ExportFormatXLSX
+}
+
+// ExportContentType returns the MIME type for an export format
+func ExportContentType(format string) string {
+	if format == ExportFormatXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// NewTableWriter creates a writer for the given export format that writes to w
+func NewTableWriter(format string, w io.Writer) (TableWriter, error) {
+	switch format {
+	case ExportFormatCSV:
+		return &csvTableWriter{w: csv.NewWriter(w), dst: w}, nil
+	case ExportFormatXLSX:
+		f := excelize.NewFile()
+		sw, err := f.NewStreamWriter("Sheet1")
+		if err != nil {
+			return nil, err
+		}
+		return &xlsxTableWriter{f: f, sw: sw, out: w}, nil
+	}
+	return nil, fmt.Errorf("unsupported export format: %s", format)
+}
+
+// csvTableWriter flushes every batch of rows so large exports reach the client progressively
+type csvTableWriter struct {
+	w    *csv.Writer
+	dst  io.Writer
+	rows int
+}
+
+func (t *csvTableWriter) WriteRow(values []interface{}) error {
+	record := make([]string, len(values))
+	for i, v := range values {
+		if text, ok := v.(string); ok {
+			record[i] = escapeFormula(text)
+			continue
+		}
+		record[i] = formatExportValue(v)
+	}
+	if err := t.w.Write(record); err != nil {
+		return err
+	}
+	t.rows++
+	if t.rows%500 == 0 {
+		t.w.Flush()
+		if f, ok := t.dst.(interface{ Flush() error }); ok {
+			if err := f.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return t.w.Error()
+}
+
+func (t *csvTableWriter) Close() error {
+	t.w.Flush()
+	return t.w.Error()
+}
+
+// formulaPrefixes are the first characters that make a spreadsheet read a cell as a formula
+const formulaPrefixes = "=+-@\t\r"
+
+// escapeFormula prefixes text that a spreadsheet would read as a formula with a quote, so user
+// entered names like "=HYPERLINK(...)" open as text. Numbers are never passed through here.
+func escapeFormula(text string) string {
+	if text != "" && strings.ContainsRune(formulaPrefixes, rune(text[0])) {
+		return "'" + text
+	}
+	return text
+}
+
+// unescapeFormula undoes escapeFormula, so an exported CSV file can be imported again unchanged
+func unescapeFormula(text string) string {
+	if len(text) > 1 && text[0] == '\'' && strings.ContainsRune(formulaPrefixes, rune(text[1])) {
+		return text[1:]
+	}
+	return text
+}
+
+// xlsxTableWriter uses the excelize stream writer, which spills rows to a temp file instead of keeping them in memory.
+// Strings are written as inline string cells, never formulas, so they need no escaping.
+type xlsxTableWriter struct {
+	f   *excelize.File
+	sw  *excelize.StreamWriter
+	out io.Writer
+	row int
+}
+
+func (t *xlsxTableWriter) WriteRow(values []interface{}) error {
+	t.row++
+	cell, err := excelize.CoordinatesToCellName(1, t.row)
+	if err != nil {
+		return err
+	}
+	converted := make([]interface{}, len(values))
+	for i, v := range values {
+		switch val := v.(type) {
+		case decimal.Decimal:
+			converted[i] = val.InexactFloat64()
+		case time.Time:
+			converted[i] = formatExportValue(val)
+		default:
+			converted[i] = val
+		}
+	}
+	return t.sw.SetRow(cell, converted)
+}
+
+func (t *xlsxTableWriter) Close() error {
+	defer t.f.Close()
+	if err := t.sw.Flush(); err != nil {
+		return err
+	}
+	_, err := t.f.WriteTo(t.out)
+	return err
+}
+
+func formatExportValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case decimal.Decimal:
+		return val.StringFixed(2)
+	case float64:
+		return decimal.NewFromFloat(val).String()
+	case time.Time:
+		if val.IsZero() {
+			return ""
+		}
+		return val.Format("2006-01-02 15:04:05")
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/csv"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/xuri/excelize/v2"
+)
+
+// exportTestRows are written by every round-trip test: a header, then data rows with user text
+// that a spreadsheet would otherwise evaluate, and the value types the exports use
+var exportTestRows = [][]interface{}{
+	{"Name", "Amount", "Created At", "Note"},
+	{"=HYPERLINK(\"http://x\")", decimal.NewFromFloat(12.5), time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC), nil},
+	{"+1", decimal.NewFromInt(-3), time.Time{}, "-2"},
+	{"@SUM(A1)", 4.25, "\tTabbed", "\rReturn"},
+	{"Plain", 7, "", "a=b"},
+}
+
+func writeExport(t *testing.T, format string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw, err := NewTableWriter(format, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range exportTestRows {
+		if err := tw.WriteRow(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestCSVExportRoundTrip(t *testing.T) {
+	records, err := csv.NewReader(bytes.NewReader(writeExport(t, ExportFormatCSV))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"Name", "Amount", "Created At", "Note"},
+		{"'=HYPERLINK(\"http://x\")", "12.50", "2024-03-01 09:30:00", ""},
+		{"'+1", "-3.00", "", "'-2"},
+		{"'@SUM(A1)", "4.25", "'\tTabbed", "'\rReturn"},
+		{"Plain", "7", "", "a=b"},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("CSV records\n%q\nwant\n%q", records, want)
+	}
+
+	// Importing the file gives back the text as it was written
+	rows, err := ReadTable(bytes.NewReader(writeExport(t, ExportFormatCSV)), "export.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range []int{1, 2, 3} {
+		if got, want := rows[i][0], exportTestRows[i][0]; got != want {
+			t.Errorf("imported name %q, want %q", got, want)
+		}
+	}
+	if got := rows[3][2:]; !reflect.DeepEqual(got, []string{"\tTabbed", "\rReturn"}) {
+		t.Errorf("imported row %q", rows[3])
+	}
+}
+
+func TestXLSXExportRoundTrip(t *testing.T) {
+	f, err := excelize.OpenReader(bytes.NewReader(writeExport(t, ExportFormatXLSX)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	rows, err := f.GetRows("Sheet1", excelize.Options{RawCellValue: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"Name", "Amount", "Created At", "Note"},
+		{"=HYPERLINK(\"http://x\")", "12.5", "2024-03-01 09:30:00"},
+		{"+1", "-3", "", "-2"},
+		{"@SUM(A1)", "4.25", "\tTabbed", "\rReturn"},
+		{"Plain", "7", "", "a=b"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("XLSX rows\n%q\nwant\n%q", rows, want)
+	}
+
+	// Text is stored as a string cell, so it is shown as typed instead of being evaluated
+	for _, cell := range []string{"A2", "A3", "A4", "D3"} {
+		formula, err := f.GetCellFormula("Sheet1", cell)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if formula != "" {
+			t.Errorf("%s has formula %q", cell, formula)
+		}
+		cellType, err := f.GetCellType("Sheet1", cell)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cellType != excelize.CellTypeInlineString {
+			t.Errorf("%s has cell type %v, want an inline string", cell, cellType)
+		}
+	}
+	if cellType, _ := f.GetCellType("Sheet1", "B2"); cellType == excelize.CellTypeInlineString {
+		t.Error("B2 amount was written as text")
+	}
+}
@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// ReadTable reads all rows of an uploaded CSV or XLSX file (first sheet), including the header row.
+// The format is chosen from the file extension. CSV cells escaped by our own exports are unescaped.
+func ReadTable(r io.Reader, filename string) ([][]string, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+		rows, err := reader.ReadAll()
+		for _, row := range rows {
+			for i := range row {
+				row[i] = unescapeFormula(row[i])
+			}
+		}
+		return rows, err
+	case ".xlsx":
+		f, err := excelize.OpenReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		sheets := f.GetSheetList()
+		if len(sheets) == 0 {
+			return nil, fmt.Errorf("workbook has no sheets")
+		}
+		return f.GetRows(sheets[0])
+	}
+	return nil, fmt.Errorf("unsupported file type %q, expected .csv or .xlsx", filepath.Ext(filename))
+}
+
+// HeaderIndex maps normalized header names (lower case, spaces as underscores) to their column index
+func HeaderIndex(header []string) map[string]int {
+	index := make(map[string]int, len(header))
+	for i, h := range header {
+		key := strings.TrimPrefix(h, "\ufeff") // Excel adds a BOM to UTF-8 CSV files
+		key = strings.ToLower(strings.TrimSpace(key))
+		key = strings.ReplaceAll(key, " ", "_")
+		if _, exists := index[key]; !exists && key != "" {
+			index[key] = i
+		}
+	}
+	return index
+}
+
+// CellValue returns the trimmed value of a named column in row, and whether the column exists
+func CellValue(row []string, index map[string]int, column string) (string, bool) {
+	i, ok := index[column]
+	if !ok {
+		return "", false
+	}
+	if i >= len(row) {
+		return "", true
+	}
+	return strings.TrimSpace(row[i]), true
+}
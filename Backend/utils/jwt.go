@@ -0,0 +1,169 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JWTKey is a key used to sign and/or verify tokens, identified by the kid header
+type JWTKey struct {
+	ID        string
+	Method    jwt.SigningMethod
+	SignKey   interface{} // nil for verify-only keys kept around during rotation
+	VerifyKey interface{}
+}
+
+type jwtKeySet struct {
+	active *JWTKey
+	keys   map[string]*JWTKey
+}
+
+var jwtKeys *jwtKeySet
+
+// minHS256SecretLength is the shortest shared secret accepted for HS256, from JWT_KEYS or JWT_SECRET
+const minHS256SecretLength = 32
+
+// LoadJWTKeys loads the signing keys from the environment. It must be called once at startup.
+//
+//	JWT_KEYS        comma separated kid:alg:path entries, alg is HS256, RS256 or EdDSA.
+//	                HS256 files contain the shared secret, RS256/EdDSA files a PEM private key
+//	                (sign and verify) or public key (verify only).
+//	JWT_ACTIVE_KID  kid used to sign new tokens, defaults to the first entry in JWT_KEYS.
+//	JWT_SECRET      shorthand for a single HS256 secret when JWT_KEYS is not set.
+//
+// To rotate, add the new key, make it active and keep the old one listed until its tokens have expired.
+func LoadJWTKeys() error {
+	set := &jwtKeySet{keys: make(map[string]*JWTKey)}
+	var order []string
+
+	if spec := strings.TrimSpace(os.Getenv("JWT_KEYS")); spec != "" {
+		for _, entry := range strings.Split(spec, ",") {
+			parts := strings.SplitN(strings.TrimSpace(entry), ":", 3)
+			if len(parts) != 3 {
+				return fmt.Errorf("invalid JWT_KEYS entry %q, expected kid:alg:path", entry)
+			}
+			key, err := loadJWTKey(parts[0], parts[1], parts[2])
+			if err != nil {
+				return err
+			}
+			if _, exists := set.keys[key.ID]; exists {
+				return fmt.Errorf("duplicate JWT key id %q", key.ID)
+			}
+			set.keys[key.ID] = key
+			order = append(order, key.ID)
+		}
+	} else {
+		secret := os.Getenv("JWT_SECRET")
+		if secret == "" {
+			// Development fallback: tokens stop working whenever the server restarts
+			fmt.Println("WARNING: JWT_KEYS and JWT_SECRET are not set, using a random signing secret")
+			buf := make([]byte, 32)
+			if _, err := rand.Read(buf); err != nil {
+				return err
+			}
+			secret = hex.EncodeToString(buf)
+		} else if len(secret) < minHS256SecretLength {
+			return fmt.Errorf("JWT_SECRET must be at least %d bytes", minHS256SecretLength)
+		}
+		set.keys["default"] = &JWTKey{ID: "default", Method: jwt.SigningMethodHS256, SignKey: []byte(secret), VerifyKey: []byte(secret)}
+		order = append(order, "default")
+	}
+
+	activeID := os.Getenv("JWT_ACTIVE_KID")
+	if activeID == "" {
+		activeID = order[0]
+	}
+	set.active = set.keys[activeID]
+	if set.active == nil {
+		return fmt.Errorf("JWT_ACTIVE_KID %q is not listed in JWT_KEYS", activeID)
+	}
+	if set.active.SignKey == nil {
+		return fmt.Errorf("active JWT key %q has no private key", activeID)
+	}
+	jwtKeys = set
+	return nil
+}
+
+func loadJWTKey(id, alg, path string) (*JWTKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading JWT key %q: %w", id, err)
+	}
+	key := &JWTKey{ID: id}
+	switch alg {
+	case "HS256":
+		secret := []byte(strings.TrimSpace(string(data)))
+		if len(secret) < minHS256SecretLength {
+			return nil, fmt.Errorf("JWT key %q: HS256 secrets must be at least %d bytes", id, minHS256SecretLength)
+		}
+		key.Method, key.SignKey, key.VerifyKey = jwt.SigningMethodHS256, secret, secret
+	case "RS256":
+		key.Method = jwt.SigningMethodRS256
+		if private, err := jwt.ParseRSAPrivateKeyFromPEM(data); err == nil {
+			key.SignKey, key.VerifyKey = private, &private.PublicKey
+		} else if public, err := jwt.ParseRSAPublicKeyFromPEM(data); err == nil {
+			key.VerifyKey = public
+		} else {
+			return nil, fmt.Errorf("JWT key %q: not a PEM encoded RSA key", id)
+		}
+	case "EdDSA":
+		key.Method = jwt.SigningMethodEdDSA
+		if private, err := jwt.ParseEdPrivateKeyFromPEM(data); err == nil {
+			edKey := private.(ed25519.PrivateKey)
+			key.SignKey, key.VerifyKey = edKey, edKey.Public()
+		} else if public, err := jwt.ParseEdPublicKeyFromPEM(data); err == nil {
+			key.VerifyKey = public
+		} else {
+			return nil, fmt.Errorf("JWT key %q: not a PEM encoded Ed25519 key", id)
+		}
+	default:
+		return nil, fmt.Errorf("JWT key %q: unsupported algorithm %q", id, alg)
+	}
+	return key, nil
+}
+
+// SignJWT signs claims with the active key and sets the kid header
+func SignJWT(claims jwt.MapClaims) (string, error) {
+	if jwtKeys == nil {
+		return "", errors.New("JWT keys are not loaded")
+	}
+	token := jwt.NewWithClaims(jwtKeys.active.Method, claims)
+	token.Header["kid"] = jwtKeys.active.ID
+	return token.SignedString(jwtKeys.active.SignKey)
+}
+
+// ParseJWT verifies a token against the key named by its kid header and returns its claims.
+// Tokens without a kid are checked against the active key.
+func ParseJWT(tokenString string) (jwt.MapClaims, error) {
+	if jwtKeys == nil {
+		return nil, errors.New("JWT keys are not loaded")
+	}
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		key := jwtKeys.active
+		if kid, ok := token.Header["kid"].(string); ok {
+			if key, ok = jwtKeys.keys[kid]; !ok {
+				return nil, fmt.Errorf("unknown key id %q", kid)
+			}
+		}
+		// Never let the token choose a different algorithm than the key was configured for
+		if token.Method.Alg() != key.Method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+		}
+		return key.VerifyKey, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
@@ -0,0 +1,166 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// writeKeyFile writes data to a file in a temporary directory and returns its path
+func writeKeyFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// edKeyFiles writes an Ed25519 private key and its public key as PEM files
+func edKeyFiles(t *testing.T) (string, string) {
+	t.Helper()
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writeKeyFile(t, "ed.pem", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER})),
+		writeKeyFile(t, "ed.pub.pem", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}))
+}
+
+func setJWTEnv(t *testing.T, keys, activeKID, secret string) {
+	t.Helper()
+	t.Setenv("JWT_KEYS", keys)
+	t.Setenv("JWT_ACTIVE_KID", activeKID)
+	t.Setenv("JWT_SECRET", secret)
+	previous := jwtKeys
+	t.Cleanup(func() { jwtKeys = previous })
+}
+
+func TestLoadJWTKeys(t *testing.T) {
+	longSecret := strings.Repeat("s", minHS256SecretLength)
+	shortSecret := strings.Repeat("s", minHS256SecretLength-1)
+	hsFile := writeKeyFile(t, "hs.key", []byte(longSecret+"\n"))
+	shortFile := writeKeyFile(t, "short.key", []byte(shortSecret))
+	edPrivate, edPublic := edKeyFiles(t)
+
+	tests := []struct {
+		name      string
+		keys      string
+		activeKID string
+		secret    string
+		wantErr   string
+	}{
+		{name: "random secret without configuration"},
+		{name: "JWT_SECRET", secret: longSecret},
+		{name: "short JWT_SECRET", secret: shortSecret, wantErr: "at least 32 bytes"},
+		{name: "HS256 key file", keys: "a:HS256:" + hsFile},
+		{name: "short HS256 key file", keys: "a:HS256:" + shortFile, wantErr: "at least 32 bytes"},
+		{name: "EdDSA private key", keys: "a:EdDSA:" + edPrivate},
+		{name: "verify-only key kept for rotation", keys: "new:HS256:" + hsFile + ",old:EdDSA:" + edPublic},
+		{name: "verify-only active key", keys: "a:EdDSA:" + edPublic, wantErr: "has no private key"},
+		{name: "active kid not listed", keys: "a:HS256:" + hsFile, activeKID: "b", wantErr: "not listed"},
+		{name: "duplicate kid", keys: "a:HS256:" + hsFile + ",a:EdDSA:" + edPrivate, wantErr: "duplicate"},
+		{name: "malformed entry", keys: "a:HS256", wantErr: "expected kid:alg:path"},
+		{name: "unsupported algorithm", keys: "a:HS512:" + hsFile, wantErr: "unsupported algorithm"},
+		{name: "wrong key type", keys: "a:RS256:" + edPrivate, wantErr: "not a PEM encoded RSA key"},
+		{name: "missing file", keys: "a:HS256:" + filepath.Join(t.TempDir(), "missing"), wantErr: "reading JWT key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setJWTEnv(t, tt.keys, tt.activeKID, tt.secret)
+			err := LoadJWTKeys()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("LoadJWTKeys() = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("LoadJWTKeys() = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseJWTAfterRotation(t *testing.T) {
+	hsFile := writeKeyFile(t, "hs.key", []byte(strings.Repeat("s", minHS256SecretLength)))
+	edPrivate, _ := edKeyFiles(t)
+	claims := jwt.MapClaims{"sub": "1", "exp": time.Now().Add(time.Hour).Unix()}
+
+	// Sign with the old key, then make a new key active while keeping the old one listed
+	setJWTEnv(t, "old:EdDSA:"+edPrivate, "", "")
+	if err := LoadJWTKeys(); err != nil {
+		t.Fatal(err)
+	}
+	oldToken, err := SignJWT(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("JWT_KEYS", "new:HS256:"+hsFile+",old:EdDSA:"+edPrivate)
+	if err := LoadJWTKeys(); err != nil {
+		t.Fatal(err)
+	}
+	newToken, err := SignJWT(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A token claiming the old kid but signed with the new HS256 secret must not be accepted
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	forged.Header["kid"] = "old"
+	forgedToken, err := forged.SignedString(jwtKeys.active.SignKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unknown := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	unknown.Header["kid"] = "gone"
+	unknownToken, err := unknown.SignedString(jwtKeys.active.SignKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{"token from the active key", newToken, true},
+		{"token from a rotated-out key", oldToken, true},
+		{"algorithm not matching the kid", forgedToken, false},
+		{"unknown kid", unknownToken, false},
+		{"tampered token", newToken[:len(newToken)-2] + "xx", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := ParseJWT(tt.token)
+			if tt.valid {
+				if err != nil {
+					t.Fatalf("ParseJWT() = %v", err)
+				}
+				if parsed["sub"] != "1" {
+					t.Errorf("sub = %v, want 1", parsed["sub"])
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("ParseJWT() accepted the token")
+			}
+		})
+	}
+}
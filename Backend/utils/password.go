@@ -0,0 +1,87 @@
+package utils
+
+import (
+	_ "embed"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// PasswordMaxLength is the longest password bcrypt can hash without truncating
+const PasswordMaxLength = 72
+
+// PasswordPolicy describes the rules a new password must satisfy
+type PasswordPolicy struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	CheckCommon   bool
+}
+
+//go:embed data/common-passwords.txt
+var commonPasswordList string
+
+var (
+	commonPasswords     map[string]struct{}
+	commonPasswordsOnce sync.Once
+)
+
+// IsCommonPassword reports whether password appears in the bundled list of common and breached passwords
+func IsCommonPassword(password string) bool {
+	commonPasswordsOnce.Do(func() {
+		commonPasswords = make(map[string]struct{})
+		for _, line := range strings.Split(commonPasswordList, "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			commonPasswords[strings.ToLower(line)] = struct{}{}
+		}
+	})
+	_, found := commonPasswords[strings.ToLower(password)]
+	return found
+}
+
+// ValidatePassword returns the rules of policy that password breaks, or nil if it is acceptable
+func ValidatePassword(password string, policy PasswordPolicy) []string {
+	var violations []string
+	if len([]rune(password)) < policy.MinLength {
+		violations = append(violations, fmt.Sprintf("Password must be at least %d characters", policy.MinLength))
+	}
+	if len(password) > PasswordMaxLength {
+		violations = append(violations, fmt.Sprintf("Password must be at most %d bytes", PasswordMaxLength))
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	if policy.RequireUpper && !upper {
+		violations = append(violations, "Password must contain an uppercase letter")
+	}
+	if policy.RequireLower && !lower {
+		violations = append(violations, "Password must contain a lowercase letter")
+	}
+	if policy.RequireDigit && !digit {
+		violations = append(violations, "Password must contain a digit")
+	}
+	if policy.RequireSymbol && !symbol {
+		violations = append(violations, "Password must contain a symbol")
+	}
+	if policy.CheckCommon && IsCommonPassword(password) {
+		violations = append(violations, "Password is too common")
+	}
+	return violations
+}
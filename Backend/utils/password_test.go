@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidatePassword(t *testing.T) {
+	strict := PasswordPolicy{MinLength: 10, RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSymbol: true, CheckCommon: true}
+	tests := []struct {
+		name     string
+		password string
+		policy   PasswordPolicy
+		want     []string
+	}{
+		{"acceptable", "Correct-horse-9", strict, nil},
+		{"too short", "Ab1-", strict, []string{"Password must be at least 10 characters"}},
+		{"length counts characters, not bytes", "Ünïcödé-1x", strict, nil},
+		{"too long for bcrypt", "Aa1-" + strings.Repeat("x", PasswordMaxLength), strict, []string{"Password must be at most 72 bytes"}},
+		{"missing classes", "lowercaseonly", strict, []string{
+			"Password must contain an uppercase letter", "Password must contain a digit", "Password must contain a symbol",
+		}},
+		{"a space is a symbol", "Correct horse 9", strict, nil},
+		{"common password", "password", PasswordPolicy{MinLength: 8, CheckCommon: true}, []string{"Password is too common"}},
+		{"common check ignores case", "QWERTY123", PasswordPolicy{CheckCommon: true}, []string{"Password is too common"}},
+		{"common check disabled", "password", PasswordPolicy{MinLength: 8}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ValidatePassword(tt.password, tt.policy); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ValidatePassword(%q) = %q, want %q", tt.password, got, tt.want)
+			}
+		})
+	}
+}
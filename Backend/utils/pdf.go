@@ -2,6 +2,7 @@ package utils
 
 import (
 	"bytes"
+	"fmt"
 	"qp1/models"
 
 	"github.com/gofiber/fiber/v2"
@@ -22,7 +23,69 @@ func GenerateQuotationPDF(quotation *models.Quotation) ([]byte, error) {
 	pdf.Ln(8)
 	pdf.Cell(40, 10, "Description: "+quotation.Description)
 	pdf.Ln(8)
-	// Add more fields as needed...
+	if quotation.ClientName != "" {
+		pdf.Cell(40, 10, "Client: "+quotation.ClientName)
+		pdf.Ln(8)
+	}
+	pdf.Ln(4)
+
+	// Items at selling prices; the PDF goes to the client, so costs and markups are never printed
+	widths := []float64{70, 45, 15, 30, 30}
+	pdf.SetFont("Arial", "B", 10)
+	for i, h := range []string{"Item", "Dimensions", "Qty", "Unit Price", "Amount"} {
+		align := "L"
+		if i >= 2 {
+			align = "R"
+		}
+		pdf.CellFormat(widths[i], 8, h, "B", 0, align, false, 0, "")
+	}
+	pdf.Ln(-1)
+	label := widths[0] + widths[1] + widths[2] + widths[3]
+
+	// Items outside any section come first, then each section with its subtotal
+	sectioned := make(map[uint][]models.QuotationItem)
+	for _, item := range quotation.Items {
+		if item.SectionID == nil {
+			writePDFItem(pdf, widths, quotation, item)
+		} else {
+			sectioned[*item.SectionID] = append(sectioned[*item.SectionID], item)
+		}
+	}
+	for _, section := range quotation.Sections {
+		pdf.Ln(2)
+		pdf.SetFont("Arial", "B", 10)
+		pdf.CellFormat(label+widths[4], 8, section.Name, "B", 0, "L", false, 0, "")
+		pdf.Ln(-1)
+		for _, item := range sectioned[section.ID] {
+			writePDFItem(pdf, widths, quotation, item)
+		}
+		pdf.SetFont("Arial", "I", 10)
+		pdf.CellFormat(label, 7, "Subtotal "+section.Name, "T", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[4], 7, fmt.Sprintf("%.2f", section.Subtotal), "T", 0, "R", false, 0, "")
+		pdf.Ln(-1)
+	}
+	pdf.Ln(2)
+	pdf.SetFont("Arial", "", 10)
+	pdf.CellFormat(label, 7, "Subtotal", "T", 0, "R", false, 0, "")
+	pdf.CellFormat(widths[4], 7, quotation.SellTotal.StringFixed(2), "T", 0, "R", false, 0, "")
+	pdf.Ln(-1)
+	pdf.CellFormat(label, 7, fmt.Sprintf("Tax (%g%%)", quotation.TaxRate), "", 0, "R", false, 0, "")
+	pdf.CellFormat(widths[4], 7, quotation.TaxTotal.StringFixed(2), "", 0, "R", false, 0, "")
+	pdf.Ln(-1)
+	pdf.SetFont("Arial", "B", 10)
+	pdf.CellFormat(label, 8, "Total", "T", 0, "R", false, 0, "")
+	pdf.CellFormat(widths[4], 8, quotation.GrandTotal.StringFixed(2), "T", 0, "R", false, 0, "")
+	pdf.Ln(-1)
+	if quotation.TaxRate > 0 {
+		for _, item := range quotation.Items {
+			if item.TaxExempt {
+				pdf.SetFont("Arial", "", 8)
+				pdf.Cell(40, 6, "* Not subject to tax")
+				pdf.Ln(-1)
+				break
+			}
+		}
+	}
 
 	var buf bytes.Buffer
 	err := pdf.Output(&buf)
@@ -32,6 +95,30 @@ func GenerateQuotationPDF(quotation *models.Quotation) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// writePDFItem prints one item row at selling prices, with its notes below it
+func writePDFItem(pdf *gofpdf.Fpdf, widths []float64, quotation *models.Quotation, item models.QuotationItem) {
+	// Ad-hoc lines show their description and unit instead of a component and dimensions
+	name, size := item.Description, item.Unit
+	if item.Component != nil {
+		name, size = item.Component.Name, fmt.Sprintf("%g x %g x %g %s", item.Length, item.Width, item.Height, item.DimensionUnit)
+	}
+	if item.TaxExempt && quotation.TaxRate > 0 {
+		name += " *"
+	}
+	pdf.SetFont("Arial", "", 10)
+	pdf.CellFormat(widths[0], 7, name, "", 0, "L", false, 0, "")
+	pdf.CellFormat(widths[1], 7, size, "", 0, "L", false, 0, "")
+	pdf.CellFormat(widths[2], 7, fmt.Sprintf("%d", item.Quantity), "", 0, "R", false, 0, "")
+	pdf.CellFormat(widths[3], 7, fmt.Sprintf("%.2f", item.SellUnitPrice), "", 0, "R", false, 0, "")
+	pdf.CellFormat(widths[4], 7, fmt.Sprintf("%.2f", item.SellTotal), "", 0, "R", false, 0, "")
+	pdf.Ln(-1)
+	if item.Notes != "" {
+		pdf.SetFont("Arial", "I", 8)
+		pdf.SetX(pdf.GetX() + 4)
+		pdf.MultiCell(widths[0]+widths[1]-4, 4, item.Notes, "", "L", false)
+	}
+}
+
 // DownloadQuotationPDF writes the PDF to the HTTP response for download
 func DownloadQuotationPDF(c *fiber.Ctx, quotation *models.Quotation) error {
 	pdfBytes, err := GenerateQuotationPDF(quotation)
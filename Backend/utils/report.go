@@ -2,20 +2,31 @@ package utils
 
 import "qp1/models"
 
+// SalesReport sums the selling prices of quotations; cost and margin are only filled in when includeCost is set.
+// It used to have total_amount, the summed cost; that is now total_cost, next to sell_total.
 type SalesReport struct {
-	TotalQuotations int     `json:"total_quotations"`
-	TotalAmount     float64 `json:"total_amount"`
+	TotalQuotations int      `json:"total_quotations"`
+	SellTotal       float64  `json:"sell_total"`
+	TotalCost       *float64 `json:"total_cost,omitempty"`
+	GrossMargin     *float64 `json:"gross_margin,omitempty"`
 }
 
-func GenerateSalesReport(quotations []models.Quotation) SalesReport {
-	var total float64
+func GenerateSalesReport(quotations []models.Quotation, includeCost bool) SalesReport {
+	var total, cost float64
 	for _, q := range quotations {
-		total += q.TotalCost.InexactFloat64() // Fix: Convert decimal to float64
+		total += q.SellTotal.InexactFloat64() // Fix: Convert decimal to float64
+		cost += q.TotalCost.InexactFloat64()
 	}
-	return SalesReport{
+	report := SalesReport{
 		TotalQuotations: len(quotations),
-		TotalAmount:     total,
+		SellTotal:       total,
 	}
+	if includeCost {
+		margin := total - cost
+		report.TotalCost = &cost
+		report.GrossMargin = &margin
+	}
+	return report
 }
 
 type MaterialUsage struct {
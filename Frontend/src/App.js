@@ -7,12 +7,16 @@ import CssBaseline from '@mui/material/CssBaseline';
 import HomePage from "./pages/HomePage";
 import LoginPage from "./pages/LoginPage";
 import RegisterPage from "./pages/RegisterPage";
+import VerifyEmailPage from "./pages/VerifyEmailPage";
+import AcceptInvitationPage from "./pages/AcceptInvitationPage";
+import ResetPasswordPage from "./pages/ResetPasswordPage";
 import Layout from "./components/Layout";
 import { Box, Typography, CircularProgress } from '@mui/material';
 import QuotationsPage from "./pages/QuotationsPage";
 import CreateQuotationPage from "./pages/CreateQuotationPage";
 import { NotificationProvider } from './contexts/NotificationContext';
 import ErrorBoundary from './components/ErrorBoundary';
+import { API_BASE_URL, apiFetch } from './services/apiClient';
 
 // Create placeholder components for each route
 const Dashboard = () => <Box sx={{ p: 3 }}><Typography variant="h4">Dashboard</Typography></Box>;
@@ -47,7 +51,7 @@ function App() {
     const fetchUserData = async () => {
       try {
         console.log('Checking authentication status...');
-        const response = await fetch(`http://localhost:8000/api/user`, {
+        const response = await apiFetch(`${API_BASE_URL}/user`, {
           method: "GET",
           headers: {
             "Content-Type": "application/json",
@@ -112,6 +116,10 @@ function App() {
               <Route path="/register" element={
                 isAuthenticated ? <Navigate to="/" /> : <RegisterPage />
               } />
+              {/* Pages opened from emailed links, reachable signed in or not */}
+              <Route path="/verify-email" element={<VerifyEmailPage />} />
+              <Route path="/accept-invitation" element={<AcceptInvitationPage />} />
+              <Route path="/reset-password" element={<ResetPasswordPage />} />
               
               {/* Protected routes with Layout */}
               <Route path="/" element={
@@ -14,10 +14,8 @@ export const SidebarSummary = ({
   quotationData,
   autoSaveStatus,
   formatCurrency,
-  calculateSubtotal,
-  calculateMarkup,
-  calculateTax,
-  calculateGrandTotal
+  totals,
+  isDirty
 }) => {
   return (
     <Paper 
@@ -50,43 +48,48 @@ export const SidebarSummary = ({
         
         <Divider />
         
-        <Box>
-          <Typography variant="body2" color="text.secondary">
-            Subtotal
-          </Typography>
-          <Typography variant="h6">
-            {formatCurrency(calculateSubtotal())}
-          </Typography>
-        </Box>
-        
-        <Box>
-          <Typography variant="body2" color="text.secondary">
-            Markup ({quotationData.markupPercentage}%)
-          </Typography>
-          <Typography variant="body1">
-            {formatCurrency(calculateMarkup())}
-          </Typography>
-        </Box>
-        
-        <Box>
-          <Typography variant="body2" color="text.secondary">
-            Tax ({quotationData.taxRate}%)
-          </Typography>
-          <Typography variant="body1">
-            {formatCurrency(calculateTax())}
-          </Typography>
-        </Box>
-        
-        <Divider />
-        
-        <Box>
+        {totals ? (
+          <>
+            <Box>
+              <Typography variant="body2" color="text.secondary">
+                Subtotal
+              </Typography>
+              <Typography variant="h6">
+                {formatCurrency(totals.sellTotal)}
+              </Typography>
+            </Box>
+
+            <Box>
+              <Typography variant="body2" color="text.secondary">
+                Tax ({totals.taxRate}%)
+              </Typography>
+              <Typography variant="body1">
+                {formatCurrency(totals.taxTotal)}
+              </Typography>
+            </Box>
+
+            <Divider />
+
+            <Box>
+              <Typography variant="body2" color="text.secondary">
+                Grand Total
+              </Typography>
+              <Typography variant="h5" fontWeight="bold" color="primary">
+                {formatCurrency(totals.grandTotal)}
+              </Typography>
+            </Box>
+
+            {isDirty && (
+              <Typography variant="caption" color="text.secondary">
+                Totals update when the draft is saved
+              </Typography>
+            )}
+          </>
+        ) : (
           <Typography variant="body2" color="text.secondary">
-            Grand Total
+            Save the draft to price the quotation
           </Typography>
-          <Typography variant="h5" fontWeight="bold" color="primary">
-            {formatCurrency(calculateGrandTotal())}
-          </Typography>
-        </Box>
+        )}
         
         {autoSaveStatus && (
           <Fade in={!!autoSaveStatus}>
@@ -68,6 +68,8 @@ export const defaultQuotationData = {
   
   // Customer Information
   client: null,
+  clientName: '',
+  clientTier: '',
   customerType: 'individual',
   companyName: '',
   contactPerson: '',
@@ -99,13 +101,11 @@ export const defaultQuotationData = {
   issueDate: new Date().toISOString().split('T')[0],
   expirationDate: '',
   currency: 'USD',
-  taxRate: 0,
   salesRepresentative: '',
   jobDescription: '',
   
   // Legacy fields
   items: [],
-  markupPercentage: 20,
   defaultPricingMethod: 'linear-foot',
   measurementUnit: 'ft'
 };
@@ -0,0 +1,123 @@
+import React, { useState } from 'react';
+import Avatar from '@mui/material/Avatar';
+import Button from '@mui/material/Button';
+import TextField from '@mui/material/TextField';
+import Link from '@mui/material/Link';
+import Box from '@mui/material/Box';
+import PersonAddOutlinedIcon from '@mui/icons-material/PersonAddOutlined';
+import Typography from '@mui/material/Typography';
+import Container from '@mui/material/Container';
+import Alert from '@mui/material/Alert';
+import { useSearchParams } from 'react-router-dom';
+import { API_BASE_URL } from '../services/apiClient';
+
+// AcceptInvitationPage is opened from the link in the invitation email. The invited user chooses a password,
+// which activates the account.
+function AcceptInvitationPage() {
+  const [searchParams] = useSearchParams();
+  const token = searchParams.get('token') || '';
+  const [name, setName] = useState('');
+  const [password, setPassword] = useState('');
+  const [confirmPassword, setConfirmPassword] = useState('');
+  const [error, setError] = useState(token ? '' : 'This invitation link is incomplete.');
+  const [success, setSuccess] = useState('');
+
+  const handleSubmit = async (event) => {
+    event.preventDefault();
+    setError('');
+    if (password !== confirmPassword) {
+      setError('Passwords do not match');
+      return;
+    }
+
+    try {
+      const response = await fetch(`${API_BASE_URL}/invitations/accept`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        // The name is optional: an empty one keeps the name the admin entered
+        body: JSON.stringify({ token, name, password }),
+      });
+      const data = await response.json();
+      if (response.ok) {
+        setSuccess(data.message || 'Invitation accepted, you can now log in');
+      } else {
+        setError(data.error || 'Failed to accept the invitation');
+      }
+    } catch (err) {
+      setError('An error occurred. Please try again.');
+      console.error(err);
+    }
+  };
+
+  return (
+    <Container component="main" maxWidth="xs">
+      <Box
+        sx={{
+          marginTop: 8,
+          display: 'flex',
+          flexDirection: 'column',
+          alignItems: 'center',
+        }}
+      >
+        <Avatar sx={{ m: 1, bgcolor: 'secondary.main' }}>
+          <PersonAddOutlinedIcon />
+        </Avatar>
+        <Typography component="h1" variant="h5">
+          Accept invitation
+        </Typography>
+        {error && <Alert severity="error" sx={{ mt: 2, width: '100%' }}>{error}</Alert>}
+        {success ? (
+          <>
+            <Alert severity="success" sx={{ mt: 2, width: '100%' }}>{success}</Alert>
+            <Link href="/login" variant="body2" sx={{ mt: 2 }}>
+              Sign in
+            </Link>
+          </>
+        ) : token && (
+        <Box component="form" onSubmit={handleSubmit} noValidate sx={{ mt: 1, width: '100%' }}>
+          <TextField
+            margin="normal"
+            fullWidth
+            id="name"
+            label="Name (optional)"
+            name="name"
+            autoComplete="name"
+            value={name}
+            onChange={(e) => setName(e.target.value)}
+          />
+          <TextField
+            margin="normal"
+            required
+            fullWidth
+            name="password"
+            label="Password"
+            type="password"
+            id="password"
+            autoComplete="new-password"
+            autoFocus
+            value={password}
+            onChange={(e) => setPassword(e.target.value)}
+          />
+          <TextField
+            margin="normal"
+            required
+            fullWidth
+            name="confirmPassword"
+            label="Confirm Password"
+            type="password"
+            id="confirmPassword"
+            autoComplete="new-password"
+            value={confirmPassword}
+            onChange={(e) => setConfirmPassword(e.target.value)}
+          />
+          <Button type="submit" fullWidth variant="contained" sx={{ mt: 3, mb: 2 }}>
+            Activate Account
+          </Button>
+        </Box>
+        )}
+      </Box>
+    </Container>
+  );
+}
+
+export default AcceptInvitationPage;
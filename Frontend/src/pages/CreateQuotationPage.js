@@ -43,10 +43,6 @@ import {
 } from '../constants/quotationConstants';
 
 import {
-  calculateSubtotal,
-  calculateMarkup,
-  calculateTax,
-  calculateGrandTotal,
   formatCurrency,
   generateQuotationNumber
 } from '../utils/quotationUtils';
@@ -202,7 +198,6 @@ const CreateQuotationPage = () => {
   const [activeTab, setActiveTab] = useState(0);
   const [validationErrors, setValidationErrors] = useState({});
   const [showDeleteConfirm, setShowDeleteConfirm] = useState(null);
-  const [realTimeCalculations, setRealTimeCalculations] = useState({});
   const [currentQuotationId, setCurrentQuotationId] = useState(null);
   const [expandedItems, setExpandedItems] = useState({});
   
@@ -258,7 +253,8 @@ const CreateQuotationPage = () => {
         markAsSaved({
           id: result.data.id,
           quotationNo: result.data.quotation_no,
-          totalCost: result.data.total_cost
+          totalCost: result.data.sell_total,
+          totals: serverTotals(result.data)
         });
       }
     } catch (error) {
@@ -285,6 +281,15 @@ const CreateQuotationPage = () => {
 
 
 
+  // Pricing (client tier markup, tax) is computed by the server; keep the
+  // totals from the last save so the summary matches the saved quotation.
+  const serverTotals = (data) => ({
+    sellTotal: data.sell_total,
+    taxRate: data.tax_rate,
+    taxTotal: data.tax_total,
+    grandTotal: data.grand_total
+  });
+
   const transformQuotationDataForAPI = (data) => {
     return {
       title: data.title || '',
@@ -293,8 +298,8 @@ const CreateQuotationPage = () => {
       project_name: data.project?.name || '',
       due_date: data.project?.deadline ? new Date(data.project.deadline).toISOString() : null,
       notes: data.notes || '',
-      markup: parseFloat(data.markupPercentage) || 0,
-      tax_rate: parseFloat(data.taxRate) || 0,
+      client_name: data.clientName || '',
+      client_tier: data.clientTier || '',
       items: data.items.filter(item => item.componentId && item.quantity > 0).map(item => ({
         component_id: item.componentId,
         length: parseFloat(item.length) || 1,
@@ -364,7 +369,8 @@ const CreateQuotationPage = () => {
         markAsSaved({
           id: result.data.id,
           quotationNo: result.data.quotation_no,
-          totalCost: result.data.total_cost
+          totalCost: result.data.sell_total,
+          totals: serverTotals(result.data)
         });
         
         showSuccess('Draft saved successfully!', {
@@ -754,6 +760,18 @@ const CreateQuotationPage = () => {
                 placeholder="Enter client name or company"
                 helperText="Optional field for client identification"
               />
+              <TextField
+                fullWidth
+                sx={{ mt: 2 }}
+                label="Client Tier (Optional)"
+                value={quotationData.clientTier || ''}
+                onChange={(e) => {
+                  const value = e.target.value.slice(0, 100);
+                  updateQuotationData(prev => ({ ...prev, clientTier: value }));
+                }}
+                placeholder="e.g. retail, trade"
+                helperText="Selects the markup applied to component prices"
+              />
             </CardContent>
           </Card>
           <Grid container spacing={2}>
@@ -833,10 +851,8 @@ const CreateQuotationPage = () => {
             quotationData={quotationData}
             autoSaveStatus={autoSaveStatus}
             formatCurrency={formatCurrency}
-            calculateSubtotal={() => calculateSubtotal(quotationData.items, realTimeCalculations)}
-            calculateMarkup={() => calculateMarkup(calculateSubtotal(quotationData.items, realTimeCalculations), quotationData.markupPercentage)}
-            calculateTax={() => calculateTax(calculateSubtotal(quotationData.items, realTimeCalculations) + calculateMarkup(calculateSubtotal(quotationData.items, realTimeCalculations), quotationData.markupPercentage), quotationData.taxRate)}
-            calculateGrandTotal={() => calculateGrandTotal(quotationData.items, quotationData.markupPercentage, quotationData.taxRate, realTimeCalculations)}
+            totals={quotationData.totals}
+            isDirty={isDirty}
           />
         </Grid>
       </Grid>
@@ -1,4 +1,4 @@
-import React, { useState } from 'react';
+import React, { useEffect, useState } from 'react';
 import Avatar from '@mui/material/Avatar';
 import Button from '@mui/material/Button';
 import TextField from '@mui/material/TextField';
@@ -11,13 +11,55 @@ import Container from '@mui/material/Container';
 import Alert from '@mui/material/Alert';
 import { useNavigate } from 'react-router-dom';
 
+// Messages for the sso_error codes the single sign-on callback redirects back with
+const SSO_ERRORS = {
+  provider_error: 'The identity provider did not complete the sign-in.',
+  expired: 'The sign-in took too long, please try again.',
+  invalid_state: 'The sign-in could not be verified, please try again.',
+  exchange_failed: 'The sign-in could not be completed with the identity provider, please try again.',
+  invalid_token: 'The identity provider sent an invalid sign-in, please contact your administrator.',
+  account_exists: 'An account with this email already exists. Sign in with your password, or ask an administrator to link it.',
+  provisioning_failed: 'Your account could not be signed in through single sign-on, please contact your administrator.',
+  server_error: 'Something went wrong, please try again.',
+};
+
 function LoginPage() {
   const [email, setEmail] = useState('');
   const [password, setPassword] = useState('');
   const [error, setError] = useState('');
   const [success, setSuccess] = useState('');
+  // Set when the password was accepted but the account has two-factor authentication enabled
+  const [challenge, setChallenge] = useState('');
+  const [code, setCode] = useState('');
+  const [useRecoveryCode, setUseRecoveryCode] = useState(false);
   const navigate = useNavigate();
 
+  // Single sign-on sends the browser back here with ?sso_error=<code> on failure, or with
+  // #two_factor_challenge=<challenge> when the account still has to enter its 2FA code
+  useEffect(() => {
+    const ssoError = new URLSearchParams(window.location.search).get('sso_error');
+    const hash = new URLSearchParams(window.location.hash.replace(/^#/, ''));
+    const ssoChallenge = hash.get('two_factor_challenge');
+    if (ssoError) {
+      setError(SSO_ERRORS[ssoError] || 'Single sign-on failed, please try again.');
+    }
+    if (ssoChallenge) {
+      setChallenge(ssoChallenge);
+    }
+    if (ssoError || ssoChallenge) {
+      // Keep the challenge out of the history and stop a reload from repeating the error
+      window.history.replaceState(null, '', window.location.pathname);
+    }
+  }, []);
+
+  const completeLogin = () => {
+    setSuccess('Login successful!');
+    // Force a page reload to trigger the authentication check in App.js
+    setTimeout(() => {
+      window.location.reload();
+    }, 500);
+  };
+
   const handleSubmit = async (event) => {
     event.preventDefault();
     setError('');
@@ -38,15 +80,48 @@ function LoginPage() {
 
       const data = await response.json();
       
-      // Check for both 200 OK and 202 Accepted status codes
-      if (response.status === 200 || response.status === 202) {
-        setSuccess('Login successful!');
-        // Force a page reload to trigger the authentication check in App.js
-        setTimeout(() => {
-          window.location.reload();
-        }, 500);
+      if (response.ok && data.two_factor_required) {
+        // No session yet: the code is sent with the challenge to the second step
+        setChallenge(data.challenge);
+      } else if (response.status === 200 || response.status === 202) {
+        // Check for both 200 OK and 202 Accepted status codes
+        completeLogin();
+      } else {
+        setError(data.message || data.error || 'Login failed');
+      }
+    } catch (err) {
+      setError('An error occurred. Please try again.');
+      console.error(err);
+    }
+  };
+
+  const handleTwoFactorSubmit = async (event) => {
+    event.preventDefault();
+    setError('');
+
+    try {
+      const response = await fetch('http://localhost:8000/api/login/2fa', {
+        method: 'POST',
+        headers: {
+          'Content-Type': 'application/json',
+        },
+        credentials: 'include',
+        body: JSON.stringify(
+          useRecoveryCode ? { challenge, recovery_code: code } : { challenge, code }
+        ),
+      });
+
+      const data = await response.json();
+
+      if (response.ok) {
+        completeLogin();
+      } else if (response.status === 401 && data.error !== 'Invalid code') {
+        // The challenge expired; start over with the password
+        setChallenge('');
+        setCode('');
+        setError(data.error || 'Login challenge expired, please sign in again');
       } else {
-        setError(data.message || 'Login failed');
+        setError(data.error || 'Verification failed');
       }
     } catch (err) {
       setError('An error occurred. Please try again.');
@@ -72,6 +147,47 @@ function LoginPage() {
         </Typography>
         {error && <Alert severity="error" sx={{ mt: 2, width: '100%' }}>{error}</Alert>}
         {success && <Alert severity="success" sx={{ mt: 2, width: '100%' }}>{success}</Alert>}
+        {challenge ? (
+        <Box component="form" onSubmit={handleTwoFactorSubmit} noValidate sx={{ mt: 1, width: '100%' }}>
+          <Typography variant="body2" sx={{ mt: 2 }}>
+            {useRecoveryCode
+              ? 'Enter one of your recovery codes.'
+              : 'Enter the 6-digit code from your authenticator app.'}
+          </Typography>
+          <TextField
+            margin="normal"
+            required
+            fullWidth
+            id="code"
+            label={useRecoveryCode ? 'Recovery Code' : 'Authentication Code'}
+            name="code"
+            autoComplete="one-time-code"
+            autoFocus
+            value={code}
+            onChange={(e) => setCode(e.target.value.trim())}
+          />
+          <Button
+            type="submit"
+            fullWidth
+            variant="contained"
+            sx={{ mt: 3, mb: 2 }}
+          >
+            Verify
+          </Button>
+          <Grid container justifyContent="space-between">
+            <Grid item>
+              <Link component="button" type="button" variant="body2" onClick={() => { setUseRecoveryCode(!useRecoveryCode); setCode(''); }}>
+                {useRecoveryCode ? 'Use an authentication code' : 'Use a recovery code'}
+              </Link>
+            </Grid>
+            <Grid item>
+              <Link component="button" type="button" variant="body2" onClick={() => { setChallenge(''); setCode(''); setError(''); }}>
+                Back to sign in
+              </Link>
+            </Grid>
+          </Grid>
+        </Box>
+        ) : (
         <Box component="form" onSubmit={handleSubmit} noValidate sx={{ mt: 1 }}>
           <TextField
             margin="normal"
@@ -105,7 +221,20 @@ function LoginPage() {
           >
             Sign In
           </Button>
-          <Grid container>
+          <Button
+            fullWidth
+            variant="outlined"
+            href="http://localhost:8000/api/auth/oidc/login"
+            sx={{ mb: 2 }}
+          >
+            Sign in with SSO
+          </Button>
+          <Grid container justifyContent="space-between">
+            <Grid item>
+              <Link href="/reset-password" variant="body2">
+                Forgot password?
+              </Link>
+            </Grid>
             <Grid item>
               <Link href="/register" variant="body2">
                 {"Don't have an account? Sign Up"}
@@ -113,6 +242,7 @@ function LoginPage() {
             </Grid>
           </Grid>
         </Box>
+        )}
       </Box>
     </Container>
   );
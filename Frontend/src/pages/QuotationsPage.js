@@ -114,11 +114,11 @@ const QuotationsPage = () => {
       
       const response = await quotationService.listQuotations();
       
-      if (response.success) {
-        const quotationsData = response.data?.quotations || [];
+      if (Array.isArray(response.data)) {
+        const quotationsData = response.data;
         setQuotations(quotationsData);
-        setTotalCount(response.data?.total || quotationsData.length);
-        setTotalPages(response.data?.total_pages || Math.ceil(quotationsData.length / itemsPerPage));
+        setTotalCount(response.pagination?.total || quotationsData.length);
+        setTotalPages(response.pagination?.total_pages || Math.ceil(quotationsData.length / itemsPerPage));
         setLastFetchTime(new Date());
         
         // Update cache
@@ -190,7 +190,7 @@ const QuotationsPage = () => {
       let aValue = a[sortConfig.key];
       let bValue = b[sortConfig.key];
 
-      if (sortConfig.key === 'total_cost') {
+      if (sortConfig.key === 'sell_total') {
         aValue = parseFloat(aValue || 0);
         bValue = parseFloat(bValue || 0);
       } else if (sortConfig.key === 'created_at') {
@@ -572,7 +572,7 @@ const QuotationsPage = () => {
                 <Box>
                   <Typography variant="h6">
                     {formatCurrency(
-                      filteredQuotations.reduce((sum, q) => sum + q.total_cost, 0)
+                      filteredQuotations.reduce((sum, q) => sum + parseFloat(q.sell_total || 0), 0)
                     )}
                   </Typography>
                   <Typography variant="body2" color="text.secondary">
@@ -722,8 +722,8 @@ const QuotationsPage = () => {
               <SortableTableCell sortKey="title" sx={{ minWidth: 250 }}>
                 Title
               </SortableTableCell>
-              <SortableTableCell sortKey="total_cost" sx={{ minWidth: 120 }}>
-                Total Cost
+              <SortableTableCell sortKey="sell_total" sx={{ minWidth: 120 }}>
+                Total
               </SortableTableCell>
               <SortableTableCell sortKey="status" sx={{ minWidth: 100 }}>
                 Status
@@ -782,7 +782,7 @@ const QuotationsPage = () => {
                   </Box>
                 </TableCell>
                 <TableCell sx={{ fontWeight: 600, color: 'primary.main' }}>
-                  {formatCurrency(quotation.total_cost)}
+                  {formatCurrency(quotation.sell_total)}
                 </TableCell>
                 <TableCell>
                   <Chip
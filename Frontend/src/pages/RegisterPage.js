@@ -34,7 +34,10 @@ const RegisterPage = () => {
       if (response.ok) {
         console.log("Registration successful");
         setResponseMessage(responseData.message);
-        setRedirect(true); // Set redirect state to true upon successful registration
+        // A pending account can't sign in yet, so stay here and show where to find the verification link
+        if (!responseData.email_verification_required) {
+          setRedirect(true); // Set redirect state to true upon successful registration
+        }
       } else {
         console.error("Registration failed");
         setResponseMessage(responseData.error || "Registration failed");
@@ -0,0 +1,138 @@
+import React, { useState } from 'react';
+import Avatar from '@mui/material/Avatar';
+import Button from '@mui/material/Button';
+import TextField from '@mui/material/TextField';
+import Link from '@mui/material/Link';
+import Box from '@mui/material/Box';
+import LockResetOutlinedIcon from '@mui/icons-material/LockResetOutlined';
+import Typography from '@mui/material/Typography';
+import Container from '@mui/material/Container';
+import Alert from '@mui/material/Alert';
+import { useSearchParams } from 'react-router-dom';
+import { API_BASE_URL } from '../services/apiClient';
+
+// ResetPasswordPage asks for the account's email to send a reset link. Opened from that link, with a token,
+// it sets the new password instead.
+function ResetPasswordPage() {
+  const [searchParams] = useSearchParams();
+  const token = searchParams.get('token') || '';
+  const [email, setEmail] = useState('');
+  const [password, setPassword] = useState('');
+  const [confirmPassword, setConfirmPassword] = useState('');
+  const [error, setError] = useState('');
+  const [success, setSuccess] = useState('');
+
+  const post = async (path, body) => {
+    setError('');
+    try {
+      const response = await fetch(`${API_BASE_URL}${path}`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify(body),
+      });
+      const data = await response.json();
+      if (response.ok) {
+        setSuccess(data.message);
+      } else {
+        setError(data.error || 'Password reset failed');
+      }
+    } catch (err) {
+      setError('An error occurred. Please try again.');
+      console.error(err);
+    }
+  };
+
+  const handleRequest = (event) => {
+    event.preventDefault();
+    post('/password-reset/request', { email });
+  };
+
+  const handleConfirm = (event) => {
+    event.preventDefault();
+    if (password !== confirmPassword) {
+      setError('Passwords do not match');
+      return;
+    }
+    post('/password-reset/confirm', { token, new_password: password });
+  };
+
+  return (
+    <Container component="main" maxWidth="xs">
+      <Box
+        sx={{
+          marginTop: 8,
+          display: 'flex',
+          flexDirection: 'column',
+          alignItems: 'center',
+        }}
+      >
+        <Avatar sx={{ m: 1, bgcolor: 'secondary.main' }}>
+          <LockResetOutlinedIcon />
+        </Avatar>
+        <Typography component="h1" variant="h5">
+          Reset password
+        </Typography>
+        {error && <Alert severity="error" sx={{ mt: 2, width: '100%' }}>{error}</Alert>}
+        {success && <Alert severity="success" sx={{ mt: 2, width: '100%' }}>{success}</Alert>}
+        {!success && (token ? (
+        <Box component="form" onSubmit={handleConfirm} noValidate sx={{ mt: 1, width: '100%' }}>
+          <TextField
+            margin="normal"
+            required
+            fullWidth
+            name="password"
+            label="New Password"
+            type="password"
+            id="password"
+            autoComplete="new-password"
+            autoFocus
+            value={password}
+            onChange={(e) => setPassword(e.target.value)}
+          />
+          <TextField
+            margin="normal"
+            required
+            fullWidth
+            name="confirmPassword"
+            label="Confirm New Password"
+            type="password"
+            id="confirmPassword"
+            autoComplete="new-password"
+            value={confirmPassword}
+            onChange={(e) => setConfirmPassword(e.target.value)}
+          />
+          <Button type="submit" fullWidth variant="contained" sx={{ mt: 3, mb: 2 }}>
+            Set Password
+          </Button>
+        </Box>
+        ) : (
+        <Box component="form" onSubmit={handleRequest} noValidate sx={{ mt: 1, width: '100%' }}>
+          <Typography variant="body2" sx={{ mt: 2 }}>
+            Enter your email address and we will send you a link to reset your password.
+          </Typography>
+          <TextField
+            margin="normal"
+            required
+            fullWidth
+            id="email"
+            label="Email Address"
+            name="email"
+            autoComplete="email"
+            autoFocus
+            value={email}
+            onChange={(e) => setEmail(e.target.value)}
+          />
+          <Button type="submit" fullWidth variant="contained" sx={{ mt: 3, mb: 2 }}>
+            Send Reset Link
+          </Button>
+        </Box>
+        ))}
+        <Link href="/login" variant="body2" sx={{ mt: 2 }}>
+          Back to sign in
+        </Link>
+      </Box>
+    </Container>
+  );
+}
+
+export default ResetPasswordPage;
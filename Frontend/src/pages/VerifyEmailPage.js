@@ -0,0 +1,126 @@
+import React, { useEffect, useRef, useState } from 'react';
+import Avatar from '@mui/material/Avatar';
+import Button from '@mui/material/Button';
+import TextField from '@mui/material/TextField';
+import Link from '@mui/material/Link';
+import Box from '@mui/material/Box';
+import MarkEmailReadOutlinedIcon from '@mui/icons-material/MarkEmailReadOutlined';
+import Typography from '@mui/material/Typography';
+import Container from '@mui/material/Container';
+import Alert from '@mui/material/Alert';
+import CircularProgress from '@mui/material/CircularProgress';
+import { useSearchParams } from 'react-router-dom';
+import { API_BASE_URL } from '../services/apiClient';
+
+// VerifyEmailPage is opened from the link in the verification email. It posts the token once on load; if the
+// link is invalid or expired, the user can ask for a new one.
+function VerifyEmailPage() {
+  const [searchParams] = useSearchParams();
+  const token = searchParams.get('token') || '';
+  const [verifying, setVerifying] = useState(Boolean(token));
+  const [error, setError] = useState(token ? '' : 'This verification link is incomplete.');
+  const [success, setSuccess] = useState('');
+  const [email, setEmail] = useState('');
+  const [resent, setResent] = useState('');
+  // Tokens are single-use, so don't post again when React runs the effect twice in development
+  const submitted = useRef(false);
+
+  useEffect(() => {
+    if (!token || submitted.current) {
+      return;
+    }
+    submitted.current = true;
+    const verify = async () => {
+      try {
+        const response = await fetch(`${API_BASE_URL}/verify-email`, {
+          method: 'POST',
+          headers: { 'Content-Type': 'application/json' },
+          body: JSON.stringify({ token }),
+        });
+        const data = await response.json();
+        if (response.ok) {
+          setSuccess(data.message || 'Email verified, you can now log in');
+        } else {
+          setError(data.error || 'Verification failed');
+        }
+      } catch (err) {
+        setError('An error occurred. Please try again.');
+        console.error(err);
+      } finally {
+        setVerifying(false);
+      }
+    };
+    verify();
+  }, [token]);
+
+  const handleResend = async (event) => {
+    event.preventDefault();
+    setResent('');
+    try {
+      const response = await fetch(`${API_BASE_URL}/verify-email/resend`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ email }),
+      });
+      const data = await response.json();
+      if (response.ok) {
+        setError('');
+        setResent(data.message);
+      } else {
+        setError(data.error || 'Failed to send a new link');
+      }
+    } catch (err) {
+      setError('An error occurred. Please try again.');
+      console.error(err);
+    }
+  };
+
+  return (
+    <Container component="main" maxWidth="xs">
+      <Box
+        sx={{
+          marginTop: 8,
+          display: 'flex',
+          flexDirection: 'column',
+          alignItems: 'center',
+        }}
+      >
+        <Avatar sx={{ m: 1, bgcolor: 'secondary.main' }}>
+          <MarkEmailReadOutlinedIcon />
+        </Avatar>
+        <Typography component="h1" variant="h5">
+          Verify your email
+        </Typography>
+        {verifying && <CircularProgress sx={{ mt: 3 }} />}
+        {error && <Alert severity="error" sx={{ mt: 2, width: '100%' }}>{error}</Alert>}
+        {success && <Alert severity="success" sx={{ mt: 2, width: '100%' }}>{success}</Alert>}
+        {resent && <Alert severity="info" sx={{ mt: 2, width: '100%' }}>{resent}</Alert>}
+        {error && !resent && (
+        <Box component="form" onSubmit={handleResend} noValidate sx={{ mt: 1, width: '100%' }}>
+          <TextField
+            margin="normal"
+            required
+            fullWidth
+            id="email"
+            label="Email Address"
+            name="email"
+            autoComplete="email"
+            value={email}
+            onChange={(e) => setEmail(e.target.value)}
+          />
+          <Button type="submit" fullWidth variant="contained" sx={{ mt: 2, mb: 2 }}>
+            Send a new link
+          </Button>
+        </Box>
+        )}
+        {!verifying && (
+          <Link href="/login" variant="body2" sx={{ mt: 2 }}>
+            Back to sign in
+          </Link>
+        )}
+      </Box>
+    </Container>
+  );
+}
+
+export default VerifyEmailPage;
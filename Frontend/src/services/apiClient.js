@@ -0,0 +1,37 @@
+export const API_BASE_URL = process.env.REACT_APP_API_URL || 'http://localhost:8000/api';
+
+// Requests that must not trigger a refresh: they either are the refresh or start a new session
+const NO_REFRESH_PATHS = ['/refresh', '/login', '/register', '/logout'];
+
+let refreshPromise = null;
+
+// refreshSession asks the server for a new access token using the refresh token cookie.
+// Concurrent 401s share one refresh, since the refresh token is rotated on every use.
+const refreshSession = () => {
+  if (!refreshPromise) {
+    refreshPromise = fetch(`${API_BASE_URL}/refresh`, {
+      method: 'POST',
+      credentials: 'include',
+    })
+      .then((response) => response.ok)
+      .catch(() => false)
+      .finally(() => {
+        refreshPromise = null;
+      });
+  }
+  return refreshPromise;
+};
+
+// apiFetch is fetch with the session cookies included. Access tokens are short-lived, so a 401 refreshes the
+// session once and retries the request; if the refresh fails the 401 is returned to the caller.
+export const apiFetch = async (url, options = {}) => {
+  const request = { credentials: 'include', ...options };
+  const response = await fetch(url, request);
+  if (response.status !== 401 || NO_REFRESH_PATHS.some((path) => url.startsWith(`${API_BASE_URL}${path}`))) {
+    return response;
+  }
+  if (!(await refreshSession())) {
+    return response;
+  }
+  return fetch(url, request);
+};
@@ -1,9 +1,9 @@
-const API_BASE_URL = process.env.REACT_APP_API_URL || 'http://localhost:8000/api';
+import { API_BASE_URL, apiFetch } from './apiClient';
 
 class QuotationAPI {
   async createQuotation(quotationData) {
     try {
-      const response = await fetch(`${API_BASE_URL}/quotations`, {
+      const response = await apiFetch(`${API_BASE_URL}/quotations`, {
         method: 'POST',
         headers: {
           'Content-Type': 'application/json',
@@ -31,7 +31,7 @@ class QuotationAPI {
         ? `${API_BASE_URL}/quotations/${quotationId}/draft`
         : `${API_BASE_URL}/quotations/draft`;
       
-      const response = await fetch(url, {
+      const response = await apiFetch(url, {
         method: quotationId ? 'PUT' : 'POST',
         headers: {
           'Content-Type': 'application/json',
@@ -55,7 +55,7 @@ class QuotationAPI {
 
   async updateQuotation(quotationId, quotationData) {
     try {
-      const response = await fetch(`${API_BASE_URL}/quotations/${quotationId}`, {
+      const response = await apiFetch(`${API_BASE_URL}/quotations/${quotationId}`, {
         method: 'PUT',
         headers: {
           'Content-Type': 'application/json',
@@ -79,7 +79,7 @@ class QuotationAPI {
 
   async getQuotation(quotationId) {
     try {
-      const response = await fetch(`${API_BASE_URL}/quotations/${quotationId}`, {
+      const response = await apiFetch(`${API_BASE_URL}/quotations/${quotationId}`, {
         method: 'GET',
         credentials: 'include',
       });
@@ -99,7 +99,7 @@ class QuotationAPI {
 
   async listQuotations() {
     try {
-      const response = await fetch(`${API_BASE_URL}/quotations`, {
+      const response = await apiFetch(`${API_BASE_URL}/quotations`, {
         method: 'GET',
         credentials: 'include',
       });
@@ -125,8 +125,7 @@ class QuotationAPI {
       project_name: quotationData.projectName,
       due_date: quotationData.dueDate ? new Date(quotationData.dueDate).toISOString() : null,
       notes: quotationData.notes,
-      markup: quotationData.markupPercentage || 0,
-      tax_rate: quotationData.taxRate || 0,
+      client_tier: quotationData.clientTier || '',
       items: quotationData.items.map(item => ({
         component_id: item.componentId,
         length: parseFloat(item.length) || 1,
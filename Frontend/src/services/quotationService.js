@@ -1,9 +1,9 @@
-const API_BASE_URL = process.env.REACT_APP_API_URL || 'http://localhost:8000/api';
+import { API_BASE_URL, apiFetch } from './apiClient';
 
 class QuotationService {
   async createQuotation(quotationData) {
     try {
-      const response = await fetch(`${API_BASE_URL}/quotations`, {
+      const response = await apiFetch(`${API_BASE_URL}/quotations`, {
         method: 'POST',
         headers: {
           'Content-Type': 'application/json',
@@ -31,7 +31,7 @@ class QuotationService {
         ? `${API_BASE_URL}/quotations/${quotationId}/draft`
         : `${API_BASE_URL}/quotations/draft`;
       
-      const response = await fetch(url, {
+      const response = await apiFetch(url, {
         method: quotationId ? 'PUT' : 'POST',
         headers: {
           'Content-Type': 'application/json',
@@ -55,7 +55,7 @@ class QuotationService {
 
   async updateQuotation(quotationId, quotationData) {
     try {
-      const response = await fetch(`${API_BASE_URL}/quotations/${quotationId}`, {
+      const response = await apiFetch(`${API_BASE_URL}/quotations/${quotationId}`, {
         method: 'PUT',
         headers: {
           'Content-Type': 'application/json',
@@ -79,7 +79,7 @@ class QuotationService {
 
   async getQuotation(quotationId) {
     try {
-      const response = await fetch(`${API_BASE_URL}/quotations/${quotationId}`, {
+      const response = await apiFetch(`${API_BASE_URL}/quotations/${quotationId}`, {
         method: 'GET',
         credentials: 'include',
       });
@@ -99,7 +99,7 @@ class QuotationService {
 
   async listQuotations() {
     try {
-      const response = await fetch(`${API_BASE_URL}/quotations`, {
+      const response = await apiFetch(`${API_BASE_URL}/quotations`, {
         method: 'GET',
         credentials: 'include',
       });
@@ -119,7 +119,7 @@ class QuotationService {
 
   async deleteQuotation(quotationId) {
     try {
-      const response = await fetch(`${API_BASE_URL}/quotations/${quotationId}`, {
+      const response = await apiFetch(`${API_BASE_URL}/quotations/${quotationId}`, {
         method: 'DELETE',
         credentials: 'include',
       });
@@ -141,7 +141,7 @@ class QuotationService {
 
   async updateQuotationStatus(quotationId, status) {
     try {
-      const response = await fetch(`${API_BASE_URL}/quotations/${quotationId}/status`, {
+      const response = await apiFetch(`${API_BASE_URL}/quotations/${quotationId}/status`, {
         method: 'PUT',
         headers: {
           'Content-Type': 'application/json',
@@ -165,7 +165,7 @@ class QuotationService {
 
   async duplicateQuotation(quotationId) {
     try {
-      const response = await fetch(`${API_BASE_URL}/quotations/${quotationId}/duplicate`, {
+      const response = await apiFetch(`${API_BASE_URL}/quotations/${quotationId}/duplicate`, {
         method: 'POST',
         credentials: 'include',
       });
@@ -185,7 +185,7 @@ class QuotationService {
 
   async generateQuotationPDF(quotationId) {
     try {
-      const response = await fetch(`${API_BASE_URL}/quotations/${quotationId}/pdf`, {
+      const response = await apiFetch(`${API_BASE_URL}/quotations/${quotationId}/pdf`, {
         method: 'GET',
         credentials: 'include',
       });
@@ -226,7 +226,7 @@ class QuotationService {
         ...(search && { search })
       });
 
-      const response = await fetch(`${API_BASE_URL}/admin/quotations?${params}`, {
+      const response = await apiFetch(`${API_BASE_URL}/admin/quotations?${params}`, {
         method: 'GET',
         credentials: 'include',
       });
@@ -251,7 +251,7 @@ class QuotationService {
         ...filters
       });
 
-      const response = await fetch(`${API_BASE_URL}/quotations/search?${params}`, {
+      const response = await apiFetch(`${API_BASE_URL}/quotations/search?${params}`, {
         method: 'GET',
         credentials: 'include',
       });
@@ -279,7 +279,7 @@ class QuotationService {
         format
       });
 
-      const response = await fetch(`${API_BASE_URL}/reports/sales?${params}`, {
+      const response = await apiFetch(`${API_BASE_URL}/reports/sales?${params}`, {
         method: 'GET',
         credentials: 'include',
       });
@@ -317,7 +317,7 @@ class QuotationService {
         format
       });
 
-      const response = await fetch(`${API_BASE_URL}/reports/material-usage?${params}`, {
+      const response = await apiFetch(`${API_BASE_URL}/reports/material-usage?${params}`, {
         method: 'GET',
         credentials: 'include',
       });
@@ -356,8 +356,7 @@ class QuotationService {
       client_id: quotationData.clientId || quotationData.client?.id,
       due_date: quotationData.dueDate,
       notes: quotationData.notes,
-      markup: quotationData.markup || 0,
-      tax_rate: quotationData.taxRate || 0,
+      client_tier: quotationData.clientTier || '',
       items: quotationData.items?.map(item => ({
         component_id: item.componentId || item.component_id,
         length: parseFloat(item.length) || 1,